@@ -0,0 +1,111 @@
+// fake-k2 runs a local stand-in for the Kolide cloud, for end-to-end testing of
+// launcher without network access. See ee/fakek2 for the APIs it serves.
+//
+// Launcher treats localhost:3000 as a local development server, and talks to its
+// control server there without TLS, so point launcher at it with, for example:
+//
+//	launcher --hostname=localhost:3000 --insecure_transport \
+//	  --tuf_url=http://localhost:3000 --mirror_url=http://localhost:3000 \
+//	  --osquery_publisher_url=http://localhost:3000/ingest --osquery_publisher_percent_enabled=100
+//
+// The server can be scripted while it runs by POSTing a script to /fake-k2/script,
+// and everything it has received can be fetched from /fake-k2/state.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kolide/launcher/ee/fakek2"
+	"github.com/peterbourgon/ff/v3"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "fake-k2: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("fake-k2", flag.ExitOnError)
+
+	var (
+		flAddr         = fs.String("addr", "localhost:3000", "Address to listen on")
+		flTLSCert      = fs.String("tls_cert", "", "Path to a TLS certificate; serves plain HTTP if unset")
+		flTLSKey       = fs.String("tls_key", "", "Path to the TLS certificate's private key")
+		flEnrollSecret = fs.String("enroll_secret", "", "Enroll secret to require; any secret is accepted if unset")
+		flIngestToken  = fs.String("ingest_token", "", "Agent-ingester bearer token to require; any token is accepted if unset")
+		flScript       = fs.String("script", "", "Path to a JSON script to apply at startup")
+		flDebug        = fs.Bool("debug", false, "Log every request")
+	)
+
+	if err := ff.Parse(fs, args, ff.WithEnvVarPrefix("FAKE_K2")); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	level := slog.LevelInfo
+	if *flDebug {
+		level = slog.LevelDebug
+	}
+	slogger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	server, err := fakek2.New(
+		fakek2.WithSlogger(slogger),
+		fakek2.WithEnrollSecret(*flEnrollSecret),
+		fakek2.WithIngestToken(*flIngestToken),
+	)
+	if err != nil {
+		return fmt.Errorf("creating server: %w", err)
+	}
+
+	if *flScript != "" {
+		script, err := fakek2.LoadScript(*flScript)
+		if err != nil {
+			return err
+		}
+		if err := server.Apply(script); err != nil {
+			return fmt.Errorf("applying script: %w", err)
+		}
+	}
+
+	httpServer := &http.Server{
+		Addr:              *flAddr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slogger.Log(ctx, slog.LevelInfo, "listening", "addr", *flAddr, "tls", *flTLSCert != "")
+		if *flTLSCert != "" {
+			errCh <- httpServer.ListenAndServeTLS(*flTLSCert, *flTLSKey)
+		} else {
+			errCh <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("serving: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/cgroups"
+)
+
+// cgroupsObserver watches for changes to the cgroup limit flags and applies them at runtime
+// to osqueryd and commands launched by launcher, without requiring a launcher restart
+type cgroupsObserver struct {
+	slogger          *slog.Logger
+	knapsack         types.Knapsack
+	osqueryRestarter osqueryRestarter
+}
+
+type osqueryRestarter interface {
+	RestartWithReason(ctx context.Context, reason string) error
+}
+
+func newCgroupsObserver(slogger *slog.Logger, k types.Knapsack) *cgroupsObserver {
+	return &cgroupsObserver{
+		slogger:  slogger.With("component", "cgroups_observer"),
+		knapsack: k,
+	}
+}
+
+// SetOsqueryRestarter sets the runner to restart when limits are enabled at runtime, so that
+// the running osqueryd moves into its cgroup.
+func (c *cgroupsObserver) SetOsqueryRestarter(restarter osqueryRestarter) {
+	c.osqueryRestarter = restarter
+}
+
+func (c *cgroupsObserver) FlagsChanged(ctx context.Context, flagKeys ...keys.FlagKey) {
+	c.slogger.Log(ctx, slog.LevelInfo,
+		"cgroup limits changed by control server, applying",
+		"enabled", c.knapsack.CgroupLimitsEnabled(),
+	)
+
+	// osqueryd is placed into its cgroup when it starts; if it started while limits were
+	// disabled, it's running alongside launcher, and must be restarted to pick them up.
+	if !cgroupLimiter(ctx, c.slogger, c.knapsack) || c.osqueryRestarter == nil {
+		return
+	}
+	if err := c.osqueryRestarter.RestartWithReason(ctx, "cgroup limits enabled"); err != nil {
+		c.slogger.Log(ctx, slog.LevelWarn,
+			"could not restart osquery to apply cgroup limits",
+			"err", err,
+		)
+	}
+}
+
+// cgroupLimiter enables or disables cgroup limits according to the current flag values.
+// It returns true if limits were newly enabled.
+func cgroupLimiter(ctx context.Context, slogger *slog.Logger, k types.Knapsack) bool {
+	// cgroups are Linux-only; don't log noise about them elsewhere
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	if !k.CgroupLimitsEnabled() {
+		if err := cgroups.Disable(); err != nil {
+			slogger.Log(ctx, slog.LevelWarn,
+				"could not lift cgroup limits",
+				"err", err,
+			)
+		}
+		return false
+	}
+
+	wasEnabled := cgroups.Get(cgroups.Osqueryd) != nil
+
+	limits := cgroups.Limits{
+		CPUWeight:      k.CgroupCPUWeight(),
+		MemoryMaxBytes: int64(k.CgroupMemoryMaxMB()) * 1024 * 1024,
+		IOWeight:       k.CgroupIOWeight(),
+	}
+	if err := cgroups.Enable(limits); err != nil {
+		// Most hosts do not delegate a cgroup hierarchy to launcher; that's expected, and we
+		// carry on without limits.
+		level := slog.LevelWarn
+		if errors.Is(err, cgroups.ErrUnavailable) {
+			level = slog.LevelInfo
+		}
+		slogger.Log(ctx, level,
+			"could not enable cgroup limits, running osqueryd and commands without them",
+			"err", err,
+		)
+		return false
+	}
+
+	slogger.Log(ctx, slog.LevelInfo,
+		"enabled cgroup limits",
+		"cpu_weight", limits.CPUWeight,
+		"memory_max_bytes", limits.MemoryMaxBytes,
+		"io_weight", limits.IOWeight,
+	)
+
+	return !wasEnabled
+}
@@ -48,6 +48,8 @@ func createControlService(ctx context.Context, k types.Knapsack) (*control.Contr
 
 	controlOpts := []control.Option{
 		control.WithStore(k.ControlStore()),
+		control.WithPayloadVerification(),
+		control.WithSubsystemCache(k.ControlSubsystemCacheStore()),
 	}
 	service := control.New(k, client, controlOpts...)
 
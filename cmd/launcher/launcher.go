@@ -35,9 +35,12 @@ import (
 	"github.com/kolide/launcher/ee/control"
 	"github.com/kolide/launcher/ee/control/actionqueue"
 	"github.com/kolide/launcher/ee/control/consumers/acceleratecontrolconsumer"
+	"github.com/kolide/launcher/ee/control/consumers/collectfilesconsumer"
+	"github.com/kolide/launcher/ee/control/consumers/debugsessionconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/flareconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/keyvalueconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/notificationconsumer"
+	"github.com/kolide/launcher/ee/control/consumers/queryconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/remoterestartconsumer"
 	"github.com/kolide/launcher/ee/control/consumers/uninstallconsumer"
 	performancedebug "github.com/kolide/launcher/ee/debug"
@@ -49,6 +52,7 @@ import (
 	"github.com/kolide/launcher/ee/observability/exporter"
 	"github.com/kolide/launcher/ee/osquerypublisher"
 	"github.com/kolide/launcher/ee/powereventwatcher"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
 	"github.com/kolide/launcher/ee/tables/windowsupdatetable"
 	"github.com/kolide/launcher/ee/tuf"
 	"github.com/kolide/launcher/ee/watchdog"
@@ -213,6 +217,11 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 	// Apply GOMAXPROCS limit from control flag
 	gomaxprocsLimiter(ctx, slogger, k.LauncherGoMaxProcs())
 
+	// Set up cgroup limits for osqueryd and commands, before either is started
+	cgroupsObs := newCgroupsObserver(slogger, k)
+	flagController.RegisterChangeObserver(cgroupsObs, keys.CgroupLimitsEnabled, keys.CgroupCPUWeight, keys.CgroupMemoryMaxMB, keys.CgroupIOWeight)
+	cgroupLimiter(ctx, slogger, k)
+
 	// Set up flag-driven dedup configuration on the main slogger
 	// (following the user's preference that early logs and system logs don't need deduplication)
 	multiSlogger.SetFlags(flagController)
@@ -248,6 +257,11 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 		}))
 	}
 
+	// Capture debug logs for debug sessions started by the control server. The capture
+	// discards everything until a session starts.
+	debugLogCapture := debugsessionconsumer.NewLogCapture()
+	k.AddSlogHandler(debugLogCapture.SlogHandler())
+
 	// create a rungroup for all the actors we create to allow for easy start/stop
 	runGroup := rungroup.NewRunGroup()
 
@@ -385,11 +399,20 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 	windowsUpdatesCacher := windowsupdatetable.NewWindowsUpdatesCacher(k, k.WindowsUpdatesCacheStore(), 1*time.Hour, k.Slogger())
 	runGroup.Add("windowsUpdatesCacher", windowsUpdatesCacher.Execute, windowsUpdatesCacher.Interrupt)
 
+	// Restore and periodically persist launcher table stats, so that they survive restarts
+	tableStatsPersister := tablewrapper.NewStatsPersister(k.Slogger(), k.TableStatsStore(), 15*time.Minute)
+	runGroup.Add("tableStatsPersister", tableStatsPersister.Execute, tableStatsPersister.Interrupt)
+
+	// Cache results for the tables the control server has assigned a TTL
+	tablewrapper.ConfigureCache(ctx, k, k.Slogger())
+
 	var client service.KolideService
 	{
 		switch k.Transport() {
 		case "jsonrpc":
 			client = service.NewJSONRPCClient(k, rootPool)
+		case "tls":
+			client = service.NewTLSClient(k, rootPool)
 		case "osquery":
 			client = service.NewNoopClient(logger)
 		default:
@@ -431,6 +454,7 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 	)
 	runGroup.Add("osqueryRunner", osqueryRunner.Run, osqueryRunner.Interrupt)
 	k.SetInstanceQuerier(osqueryRunner)
+	cgroupsObs.SetOsqueryRestarter(osqueryRunner)
 
 	launcherListener, err := listener.NewLauncherListener(k, slogger, listener.RootLauncherListenerSocketPrefix)
 	if err != nil {
@@ -448,6 +472,7 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 	// Create the control service and services that depend on it
 	var runner *desktopRunner.DesktopUsersProcessesRunner
 	var actionsQueue *actionqueue.ActionQueue
+	var remoteRestartConsumer *remoterestartconsumer.RemoteRestartConsumer
 	if k.ControlServerURL() == "" {
 		slogger.Log(ctx, slog.LevelDebug,
 			"control server URL not set, will not create control service",
@@ -460,14 +485,14 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 		runGroup.Add("controlService", controlService.ExecuteWithContext(ctx), controlService.Interrupt)
 
 		// serverDataConsumer handles server data table updates
-		controlService.RegisterConsumer(serverDataSubsystemName, keyvalueconsumer.New(k.ServerProvidedDataStore()))
+		controlService.RegisterConsumer(serverDataSubsystemName, keyvalueconsumer.New(k.ServerProvidedDataStore(), keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), serverDataSubsystemName)))
 		// agentFlagConsumer handles agent flags pushed from the control server
-		controlService.RegisterConsumer(agentFlagsSubsystemName, keyvalueconsumer.New(flagController))
+		controlService.RegisterConsumer(agentFlagsSubsystemName, keyvalueconsumer.New(flagController, keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), agentFlagsSubsystemName)))
 		// katcConfigConsumer handles updates to Kolide's custom ATC tables
-		controlService.RegisterConsumer(katcSubsystemName, keyvalueconsumer.NewConfigConsumer(k.KatcConfigStore()))
+		controlService.RegisterConsumer(katcSubsystemName, keyvalueconsumer.NewConfigConsumer(k.KatcConfigStore(), keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), katcSubsystemName)))
 		controlService.RegisterSubscriber(katcSubsystemName, osqueryRunner)
 		controlService.RegisterSubscriber(katcSubsystemName, startupSettingsWriter)
-		controlService.RegisterConsumer(serverReleaseTrackerDataSubsystemName, keyvalueconsumer.NewConfigConsumer(k.ServerReleaseTrackerDataStore()))
+		controlService.RegisterConsumer(serverReleaseTrackerDataSubsystemName, keyvalueconsumer.NewConfigConsumer(k.ServerReleaseTrackerDataStore(), keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), serverReleaseTrackerDataSubsystemName)))
 
 		runner, err = desktopRunner.New(
 			k,
@@ -497,8 +522,11 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 			actionqueue.WithContext(ctx),
 			actionqueue.WithStore(k.ControlServerActionsStore()),
 			actionqueue.WithOldNotificationsStore(k.SentNotificationsStore()),
+			actionqueue.WithResultReporter(controlService),
 		)
 		runGroup.Add("actionsQueue", actionsQueue.StartCleanup, actionsQueue.StopCleanup)
+		runGroup.Add("actionResultReporter", actionsQueue.StartResultReporting, actionsQueue.StopResultReporting)
+		runGroup.Add("actionScheduler", actionsQueue.StartScheduledActions, actionsQueue.StopScheduledActions)
 		controlService.RegisterConsumer(actionqueue.ActionsSubsystem, actionsQueue)
 
 		// register accelerate control consumer
@@ -507,6 +535,8 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 		actionsQueue.RegisterActor(uninstallconsumer.UninstallSubsystem, uninstallconsumer.New(k))
 		// register flare consumer
 		actionsQueue.RegisterActor(flareconsumer.FlareSubsystem, flareconsumer.New(k))
+		// register file collection consumer
+		actionsQueue.RegisterActor(collectfilesconsumer.CollectFilesActorType, collectfilesconsumer.New(k, actionsQueue))
 		// register force full control data fetch consumer
 		actionsQueue.RegisterActor(control.ForceFullControlDataFetchAction, controlService)
 
@@ -523,12 +553,26 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 		// register notifications consumer
 		actionsQueue.RegisterActor(notificationconsumer.NotificationSubsystem, notificationConsumer)
 
-		remoteRestartConsumer := remoterestartconsumer.New(k)
+		remoteRestartConsumer = remoterestartconsumer.New(k)
 		runGroup.Add("remoteRestart", remoteRestartConsumer.Execute, remoteRestartConsumer.Interrupt)
 		actionsQueue.RegisterActor(remoterestartconsumer.RemoteRestartActorType, remoteRestartConsumer)
+		// allow remote restarts of individual subsystems, rather than all of launcher
+		remoteRestartConsumer.RegisterSubsystemRestarter(remoterestartconsumer.SubsystemOsquery, osqueryRunner)
+		remoteRestartConsumer.RegisterSubsystemRestarter(remoterestartconsumer.SubsystemDesktop, runner)
+		if logShipper != nil {
+			remoteRestartConsumer.RegisterSubsystemRestarter(remoterestartconsumer.SubsystemLogShipper, logShipper)
+		}
+
+		// register ad hoc query consumer
+		actionsQueue.RegisterActor(queryconsumer.QueryActorType, queryconsumer.New(k, osqueryRunner, actionsQueue))
+
+		// register debug session consumer
+		debugSessionConsumer := debugsessionconsumer.New(k, debugLogCapture, osqueryRunner, actionsQueue)
+		actionsQueue.RegisterActor(debugsessionconsumer.DebugSessionActorType, debugSessionConsumer)
+		debugSessionConsumer.ResumeSession()
 
 		// Set up our tracing instrumentation
-		authTokenConsumer := keyvalueconsumer.New(k.TokenStore())
+		authTokenConsumer := keyvalueconsumer.New(k.TokenStore(), keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), authTokensSubsystemName))
 		if err := controlService.RegisterConsumer(authTokensSubsystemName, authTokenConsumer); err != nil {
 			return fmt.Errorf("failed to register auth token consumer: %w", err)
 		}
@@ -559,7 +603,7 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 		}
 
 		// Set up consumer to receive DT4A info from the control server in both current and legacy subsystem
-		dt4aInfoConsumer := keyvalueconsumer.NewConfigConsumer(k.Dt4aInfoStore())
+		dt4aInfoConsumer := keyvalueconsumer.NewConfigConsumer(k.Dt4aInfoStore(), keyvalueconsumer.WithSchemaStore(k.KeyValueSchemaStore(), dt4aInfoSubsystemName))
 		if err := controlService.RegisterConsumer(dt4aInfoSubsystemName, dt4aInfoConsumer); err != nil {
 			return fmt.Errorf("failed to register dt4a info consumer: %w", err)
 		}
@@ -591,6 +635,9 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 
 		ls.SetQuerier(osqueryRunner)
 		runGroup.Add("localserver", ls.Start, ls.Interrupt)
+		if remoteRestartConsumer != nil {
+			remoteRestartConsumer.RegisterSubsystemRestarter(remoterestartconsumer.SubsystemLocalserver, ls)
+		}
 	}
 
 	// If autoupdating is enabled, run the autoupdater
@@ -606,7 +653,9 @@ func runLauncher(ctx context.Context, cancel func(), multiSlogger, systemMultiSl
 			k,
 			metadataClient,
 			mirrorClient,
-			tuf.WithOsqueryRestart(osqueryRunner.Restart),
+			tuf.WithOsqueryRestart(func(ctx context.Context) error {
+				return osqueryRunner.RestartWithReason(ctx, "osqueryd autoupdate")
+			}),
 		)
 		if err != nil {
 			return fmt.Errorf("creating TUF autoupdater updater: %w", err)
@@ -33,6 +33,7 @@ func main() {
 		flFakeData       = fs.Bool("fakedata", false, "Compile with build tags to falsify some data, like serial numbers")
 		flGithubOutput   = fs.Bool("github", os.Getenv("GITHUB_ACTIONS") != "", "Include github action output")
 		flIncludeSymbols = fs.Bool("debugsymbols", false, "Compile with or without debug symbols (stripped by default)")
+		flSigningKeys    = fs.String("control-signing-keys", "", "Path to the control payload signing keyset to pin in launcher")
 	)
 
 	ffOpts := []ff.Option{
@@ -79,6 +80,10 @@ func main() {
 		opts = append(opts, make.WithOutStripped())
 	}
 
+	if *flSigningKeys != "" {
+		opts = append(opts, make.WithControlSigningKeys(*flSigningKeys))
+	}
+
 	// We need to avoid cgo on windows. See
 	// https://github.com/golang/go/issues/22439 which still
 	// appears current, at least with cgo. But, we need to use cgo
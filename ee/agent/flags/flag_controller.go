@@ -111,6 +111,27 @@ func (fc *FlagController) Update(kvPairs map[string]string) ([]string, error) {
 	return changedKeys, err
 }
 
+// Patch sets and deletes the given agent flags, leaving all others unchanged.
+// Observers will be notified of only the changed and deleted flags.
+func (fc *FlagController) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	ctx, span := observability.StartSpan(context.Background())
+	defer span.End()
+
+	if err := fc.agentFlagsStore.Patch(kvPairs, deletedKeys); err != nil {
+		return err
+	}
+
+	changedKeys := append(maps.Keys(kvPairs), deletedKeys...)
+	fc.notifyObservers(ctx, keys.ToFlagKeys(changedKeys)...)
+
+	return nil
+}
+
+// ForEach iterates over the control-server-provided agent flags.
+func (fc *FlagController) ForEach(fn func(k, v []byte) error) error {
+	return fc.agentFlagsStore.ForEach(fn)
+}
+
 func (fc *FlagController) RegisterChangeObserver(observer types.FlagsChangeObserver, flagKeys ...keys.FlagKey) {
 	fc.observersMutex.Lock()
 	defer fc.observersMutex.Unlock()
@@ -384,6 +405,20 @@ func (fc *FlagController) ControlRequestInterval() time.Duration {
 	).get(fc.getControlServerValue(keys.ControlRequestInterval))
 }
 
+func (fc *FlagController) SetControlPushEnabled(enabled bool) error {
+	return fc.setControlServerValue(keys.ControlPushEnabled, boolToBytes(enabled))
+}
+func (fc *FlagController) ControlPushEnabled() bool {
+	return NewBoolFlagValue(WithDefaultBool(false)).get(fc.getControlServerValue(keys.ControlPushEnabled))
+}
+
+func (fc *FlagController) SetEnforceControlPayloadSignatures(enabled bool) error {
+	return fc.setControlServerValue(keys.EnforceControlPayloadSignatures, boolToBytes(enabled))
+}
+func (fc *FlagController) EnforceControlPayloadSignatures() bool {
+	return NewBoolFlagValue(WithDefaultBool(false)).get(fc.getControlServerValue(keys.EnforceControlPayloadSignatures))
+}
+
 func (fc *FlagController) SetAllowOverlyBroadDt4aAcceleration(enabled bool) error {
 	return fc.setControlServerValue(keys.AllowOverlyBroadDt4aAcceleration, boolToBytes(enabled))
 }
@@ -441,8 +476,20 @@ func (fc *FlagController) DebugLogFile() string {
 func (fc *FlagController) SetOsqueryVerbose(verbose bool) error {
 	return fc.setControlServerValue(keys.OsqueryVerbose, boolToBytes(verbose))
 }
+func (fc *FlagController) SetOsqueryVerboseOverride(value bool, duration time.Duration) {
+	ctx, span := observability.StartSpan(context.TODO())
+	defer span.End()
+
+	fc.overrideFlag(ctx, keys.OsqueryVerbose, duration, value)
+}
 func (fc *FlagController) OsqueryVerbose() bool {
-	return NewBoolFlagValue(WithDefaultBool(fc.cmdLineOpts.OsqueryVerbose)).get(fc.getControlServerValue(keys.OsqueryVerbose))
+	fc.overrideMutex.RLock()
+	defer fc.overrideMutex.RUnlock()
+
+	return NewBoolFlagValue(
+		WithBoolOverride(fc.overrides[keys.OsqueryVerbose]),
+		WithDefaultBool(fc.cmdLineOpts.OsqueryVerbose),
+	).get(fc.getControlServerValue(keys.OsqueryVerbose))
 }
 
 func (fc *FlagController) SetDistributedForwardingInterval(interval time.Duration) error {
@@ -506,6 +553,46 @@ func (fc *FlagController) WatchdogUtilizationLimitPercent() int {
 	).get(fc.getControlServerValue(keys.WatchdogUtilizationLimitPercent))
 }
 
+func (fc *FlagController) SetCgroupLimitsEnabled(enabled bool) error {
+	return fc.setControlServerValue(keys.CgroupLimitsEnabled, boolToBytes(enabled))
+}
+func (fc *FlagController) CgroupLimitsEnabled() bool {
+	return NewBoolFlagValue(WithDefaultBool(false)).get(fc.getControlServerValue(keys.CgroupLimitsEnabled))
+}
+
+func (fc *FlagController) SetCgroupCPUWeight(weight int) error {
+	return fc.setControlServerValue(keys.CgroupCPUWeight, intToBytes(weight))
+}
+func (fc *FlagController) CgroupCPUWeight() int {
+	return NewIntFlagValue(fc.slogger, keys.CgroupCPUWeight,
+		WithIntValueDefault(20),
+		WithIntValueMin(1),
+		WithIntValueMax(10000),
+	).get(fc.getControlServerValue(keys.CgroupCPUWeight))
+}
+
+func (fc *FlagController) SetCgroupMemoryMaxMB(limit int) error {
+	return fc.setControlServerValue(keys.CgroupMemoryMaxMB, intToBytes(limit))
+}
+func (fc *FlagController) CgroupMemoryMaxMB() int {
+	return NewIntFlagValue(fc.slogger, keys.CgroupMemoryMaxMB,
+		WithIntValueDefault(0),
+		WithIntValueMin(0),
+		WithIntValueMax(65536),
+	).get(fc.getControlServerValue(keys.CgroupMemoryMaxMB))
+}
+
+func (fc *FlagController) SetCgroupIOWeight(weight int) error {
+	return fc.setControlServerValue(keys.CgroupIOWeight, intToBytes(weight))
+}
+func (fc *FlagController) CgroupIOWeight() int {
+	return NewIntFlagValue(fc.slogger, keys.CgroupIOWeight,
+		WithIntValueDefault(20),
+		WithIntValueMin(1),
+		WithIntValueMax(10000),
+	).get(fc.getControlServerValue(keys.CgroupIOWeight))
+}
+
 func (fc *FlagController) OsqueryFlags() []string {
 	return fc.cmdLineOpts.OsqueryFlags
 }
@@ -808,6 +895,15 @@ func (fc *FlagController) TableGenerateTimeout() time.Duration {
 	).get(fc.getControlServerValue(keys.TableGenerateTimeout))
 }
 
+func (fc *FlagController) SetTableCacheTTLs(ttls string) error {
+	return fc.setControlServerValue(keys.TableCacheTTLs, []byte(ttls))
+}
+func (fc *FlagController) TableCacheTTLs() string {
+	return NewStringFlagValue(
+		WithDefaultString(""),
+	).get(fc.getControlServerValue(keys.TableCacheTTLs))
+}
+
 func (fc *FlagController) SetUseCachedDataForScheduledQueries(enabled bool) error {
 	return fc.setControlServerValue(keys.UseCachedDataForScheduledQueries, boolToBytes(enabled))
 }
@@ -325,6 +325,46 @@ func TestControllerUpdate(t *testing.T) {
 	}
 }
 
+func TestControllerPatch(t *testing.T) {
+	t.Parallel()
+
+	store, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.AgentFlagsStore.String())
+	require.NoError(t, err)
+	fc := NewFlagController(multislogger.NewNopLogger(), store)
+	assert.NotNil(t, fc)
+
+	_, err = fc.Update(map[string]string{
+		keys.ControlRequestInterval.String(): "125000",
+		keys.ControlServerURL.String():       "kolide-app.com",
+		keys.UpdateChannel.String():          "beta",
+	})
+	require.NoError(t, err)
+
+	// Only the patched and deleted keys are reported as changed, not the unchanged UpdateChannel
+	changedKeys := keys.ToFlagKeys([]string{keys.ControlRequestInterval.String(), keys.ControlServerURL.String()})
+	matchKey := mock.MatchedBy(func(k keys.FlagKey) bool {
+		return assert.Contains(t, changedKeys, k)
+	})
+	mockObserver := mocks.NewFlagsChangeObserver(t)
+	mockObserver.On("FlagsChanged", mock.Anything, matchKey, matchKey).Once()
+	fc.RegisterChangeObserver(mockObserver, keys.ControlRequestInterval, keys.ControlServerURL, keys.UpdateChannel)
+
+	require.NoError(t, fc.Patch(
+		map[string]string{keys.ControlRequestInterval.String(): "60000"},
+		[]string{keys.ControlServerURL.String()},
+	))
+
+	got := make(map[string]string)
+	require.NoError(t, fc.ForEach(func(k, v []byte) error {
+		got[string(k)] = string(v)
+		return nil
+	}))
+	require.Equal(t, map[string]string{
+		keys.ControlRequestInterval.String(): "60000",
+		keys.UpdateChannel.String():          "beta",
+	}, got)
+}
+
 func TestControllerOverride(t *testing.T) {
 	t.Parallel()
 
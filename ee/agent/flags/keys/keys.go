@@ -27,6 +27,8 @@ const (
 	ForceControlSubsystems           FlagKey = "force_control_subsystems"
 	ControlServerURL                 FlagKey = "control_server_url"
 	ControlRequestInterval           FlagKey = "control_request_interval"
+	ControlPushEnabled               FlagKey = "control_push_enabled"
+	EnforceControlPayloadSignatures  FlagKey = "enforce_control_payload_signatures"
 	AllowOverlyBroadDt4aAcceleration FlagKey = "allow_overly_broad_dt4a_acceleration"
 	DisableControlTLS                FlagKey = "disable_control_tls"
 	InsecureControlTLS               FlagKey = "insecure_control_tls"
@@ -41,6 +43,10 @@ const (
 	WatchdogDelaySec                 FlagKey = "watchdog_delay_sec"
 	WatchdogMemoryLimitMB            FlagKey = "watchdog_memory_limit_mb"
 	WatchdogUtilizationLimitPercent  FlagKey = "watchdog_utilization_limit_percent"
+	CgroupLimitsEnabled              FlagKey = "cgroup_limits_enabled"
+	CgroupCPUWeight                  FlagKey = "cgroup_cpu_weight"
+	CgroupMemoryMaxMB                FlagKey = "cgroup_memory_max_mb"
+	CgroupIOWeight                   FlagKey = "cgroup_io_weight"
 	Autoupdate                       FlagKey = "autoupdate"
 	TufServerURL                     FlagKey = "tuf_url"
 	MirrorServerURL                  FlagKey = "mirror_url"
@@ -64,6 +70,7 @@ const (
 	SystrayRestartEnabled            FlagKey = "systray_restart_enabled"
 	CurrentRunningOsqueryVersion     FlagKey = "osquery_version"
 	TableGenerateTimeout             FlagKey = "table_generate_timeout"
+	TableCacheTTLs                   FlagKey = "table_cache_ttls"
 	UseCachedDataForScheduledQueries FlagKey = "use_cached_data_for_scheduled_queries"
 	CachedQueryResultsTTL            FlagKey = "cached_query_results_ttl"
 	ResetOnHardwareChangeEnabled     FlagKey = "reset_on_hardware_change_enabled"
@@ -677,3 +677,15 @@ func (k *knapsack) SetDesktopRunner(runner types.DesktopRunner) {
 func (k *knapsack) ServerReleaseTrackerDataStore() types.KVStore {
 	return k.getKVStore(storage.ServerReleaseTrackerDataStore)
 }
+
+func (k *knapsack) TableStatsStore() types.KVStore {
+	return k.getKVStore(storage.TableStatsStore)
+}
+
+func (k *knapsack) ControlSubsystemCacheStore() types.KVStore {
+	return k.getKVStore(storage.ControlSubsystemCacheStore)
+}
+
+func (k *knapsack) KeyValueSchemaStore() types.KVStore {
+	return k.getKVStore(storage.KeyValueSchemaStore)
+}
@@ -164,6 +164,35 @@ func (s *bboltKeyValueStore) ForEach(fn func(k, v []byte) error) error {
 	})
 }
 
+// Patch sets and deletes the given keys in a single transaction. Unlike Update, if any
+// change fails, none of the changes are made.
+func (s *bboltKeyValueStore) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	if s == nil || s.db == nil {
+		return NoDbError{}
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(s.bucketName))
+		if b == nil {
+			return NewNoBucketError(s.bucketName)
+		}
+
+		for key, value := range kvPairs {
+			if err := b.Put([]byte(key), []byte(value)); err != nil {
+				return fmt.Errorf("storing key %s: %w", key, err)
+			}
+		}
+
+		for _, key := range deletedKeys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return fmt.Errorf("deleting key %s: %w", key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
 func (s *bboltKeyValueStore) Update(kvPairs map[string]string) ([]string, error) {
 	if s == nil || s.db == nil {
 		return nil, NoDbError{}
@@ -38,6 +38,9 @@ func MakeStores(ctx context.Context, slogger *slog.Logger, db *bbolt.DB) (map[st
 		storage.EnrollmentStore,
 		storage.EnrollmentDetailsStore,
 		storage.ServerReleaseTrackerDataStore,
+		storage.TableStatsStore,
+		storage.ControlSubsystemCacheStore,
+		storage.KeyValueSchemaStore,
 	}
 
 	for _, storeName := range storeNames {
@@ -285,6 +285,65 @@ func Test_Updates(t *testing.T) {
 	}
 }
 
+func Test_Patch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		initial     map[string]string
+		patch       map[string]string
+		deletedKeys []string
+		expectedErr bool
+		want        map[string]string
+	}{
+		{
+			name:    "set and delete",
+			initial: map[string]string{"one": "one", "two": "two", "three": "three"},
+			patch:   map[string]string{"one": "new_one", "four": "four"},
+			// Deleting a key that doesn't exist is not an error
+			deletedKeys: []string{"two", "five"},
+			want:        map[string]string{"one": "new_one", "three": "three", "four": "four"},
+		},
+		{
+			name:    "empty patch",
+			initial: map[string]string{"one": "one"},
+			want:    map[string]string{"one": "one"},
+		},
+		{
+			name:        "failed patch changes nothing",
+			initial:     map[string]string{"one": "one", "two": "two"},
+			patch:       map[string]string{"one": "new_one", "": "blank"},
+			deletedKeys: []string{"two"},
+			expectedErr: true,
+			want:        map[string]string{"one": "one", "two": "two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, s := range getStores(t) {
+				_, err := s.Update(tt.initial)
+				require.NoError(t, err)
+
+				err = s.Patch(tt.patch, tt.deletedKeys)
+				if tt.expectedErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+
+				got := make(map[string]string)
+				require.NoError(t, s.ForEach(func(k, v []byte) error {
+					got[string(k)] = string(v)
+					return nil
+				}))
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
 func Test_ForEach(t *testing.T) {
 	t.Parallel()
 
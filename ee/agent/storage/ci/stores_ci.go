@@ -35,6 +35,9 @@ func MakeStores(t *testing.T, slogger *slog.Logger, db *bbolt.DB) (map[storage.S
 		storage.EnrollmentStore,
 		storage.EnrollmentDetailsStore,
 		storage.ServerReleaseTrackerDataStore,
+		storage.TableStatsStore,
+		storage.ControlSubsystemCacheStore,
+		storage.KeyValueSchemaStore,
 	}
 
 	if os.Getenv("CI") == "true" {
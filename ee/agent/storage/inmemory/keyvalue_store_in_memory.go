@@ -137,6 +137,45 @@ func (s *inMemoryKeyValueStore) Update(kvPairs map[string]string) ([]string, err
 	return deletedKeys, nil
 }
 
+// Patch adheres to the Patcher interface for incrementally updating data in a key/value store.
+// All changes are made while holding the lock, so readers see either none or all of them.
+func (s *inMemoryKeyValueStore) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	if s == nil {
+		return errors.New("store is nil")
+	}
+
+	for key := range kvPairs {
+		if key == "" {
+			return errors.New("key is blank")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range kvPairs {
+		if _, exists := s.items[key]; !exists {
+			s.order = append(s.order, key)
+		}
+		s.items[key] = []byte(value)
+	}
+
+	for _, key := range deletedKeys {
+		if _, exists := s.items[key]; !exists {
+			continue
+		}
+		delete(s.items, key)
+		for i, k := range s.order {
+			if k == key {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
 func (s *inMemoryKeyValueStore) Count() (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -23,6 +23,9 @@ const (
 	EnrollmentStore               Store = "registrations"                      // The store used for persisting launcher's enrollments ("registration" key for legacy reasons/backwards compatibility)
 	EnrollmentDetailsStore        Store = "enrollment_details"                 // The store used for persisting enrollment details
 	ServerReleaseTrackerDataStore Store = "kolide_server_release_tracker_data" // The store used for release tracking data sent by control server.
+	TableStatsStore               Store = "table_stats"                        // The store used for persisting per-table execution statistics.
+	ControlSubsystemCacheStore    Store = "control_subsystem_cache"            // The store used for caching the last applied payload for each control subsystem.
+	KeyValueSchemaStore           Store = "key_value_schemas"                  // The store used for persisting the last accepted JSON schema for each key-value store updated by control.
 )
 
 func (storeType Store) String() string {
@@ -101,6 +101,16 @@ type Flags interface {
 	SetControlRequestIntervalOverride(value time.Duration, duration time.Duration)
 	ControlRequestInterval() time.Duration
 
+	// ControlPushEnabled enables a long-lived connection to the control server, over which the server notifies
+	// launcher of subsystem updates as they happen. Interval polling continues regardless.
+	SetControlPushEnabled(enabled bool) error
+	ControlPushEnabled() bool
+
+	// EnforceControlPayloadSignatures rejects control server subsystem payloads that are unsigned, or whose
+	// signatures cannot be verified against our trusted signing keys, rather than only logging them.
+	SetEnforceControlPayloadSignatures(enabled bool) error
+	EnforceControlPayloadSignatures() bool
+
 	// AllowOverlyBroadDt4aAcceleration enables acceleration via /v3/dt4a localserver endpoint. It is a test flag
 	// for development use; it should ultimately be replaced by a call to a new /v3 endpoint that only
 	// performs acceleration.
@@ -135,6 +145,7 @@ type Flags interface {
 
 	// OsqueryVerbose puts osquery into verbose mode.
 	SetOsqueryVerbose(verbose bool) error
+	SetOsqueryVerboseOverride(value bool, duration time.Duration)
 	OsqueryVerbose() bool
 
 	// DistributedForwardingInterval indicates the rate at which we forward osquery distributed requests
@@ -159,6 +170,23 @@ type Flags interface {
 	SetWatchdogUtilizationLimitPercent(limit int) error
 	WatchdogUtilizationLimitPercent() int
 
+	// CgroupLimitsEnabled causes osqueryd and commands run by launcher to be placed in cgroups with
+	// the limits below, where the cgroup v2 hierarchy has been delegated to launcher (Linux only)
+	SetCgroupLimitsEnabled(enabled bool) error
+	CgroupLimitsEnabled() bool
+
+	// CgroupCPUWeight sets cpu.weight for the osqueryd and command cgroups
+	SetCgroupCPUWeight(weight int) error
+	CgroupCPUWeight() int
+
+	// CgroupMemoryMaxMB sets memory.max for the osqueryd and command cgroups; 0 means no limit
+	SetCgroupMemoryMaxMB(limit int) error
+	CgroupMemoryMaxMB() int
+
+	// CgroupIOWeight sets io.weight for the osqueryd and command cgroups
+	SetCgroupIOWeight(weight int) error
+	CgroupIOWeight() int
+
 	// OsqueryFlags defines additional flags to pass to osquery (possibly
 	// overriding Launcher defaults)
 	OsqueryFlags() []string
@@ -262,6 +290,11 @@ type Flags interface {
 	SetTableGenerateTimeout(interval time.Duration) error
 	TableGenerateTimeout() time.Duration
 
+	// TableCacheTTLs is a JSON object mapping Kolide extension table names to how long (as a duration
+	// string, e.g. "5m") their results may be cached for. Tables not in the map are not cached.
+	SetTableCacheTTLs(ttls string) error
+	TableCacheTTLs() string
+
 	// UseCachedDataForScheduledQueries controls whether launcher uses cached data for scheduled queries.
 	// Currently, we do this only for the kolide_windows_updates table, since that table can time out when
 	// querying for fresh data.
@@ -56,6 +56,16 @@ type Updater interface {
 	Update(kvPairs map[string]string) ([]string, error)
 }
 
+// Patcher is an interface for incrementally updating data in a key/value store.
+//
+//mockery:generate: true
+//mockery:filename: keyvalue_store.go
+type Patcher interface {
+	// Patch sets the given key-value pairs and deletes the given keys in a single
+	// transaction. Keys that are not mentioned are left unchanged.
+	Patch(kvPairs map[string]string, deletedKeys []string) error
+}
+
 // Counter is an interface for reporting the count of key-value
 // pairs held by the underlying storage methodology
 //
@@ -140,6 +150,7 @@ type GetterSetterDeleterIteratorUpdaterCounterAppender interface {
 	Deleter
 	Iterator
 	Updater
+	Patcher
 	Counter
 	Appender
 }
@@ -349,6 +349,226 @@ func (_c *Flags_CertPins_Call) RunAndReturn(run func() [][]byte) *Flags_CertPins
 	return _c
 }
 
+// CgroupCPUWeight provides a mock function for the type Flags
+func (_mock *Flags) CgroupCPUWeight() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupCPUWeight")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Flags_CgroupCPUWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupCPUWeight'
+type Flags_CgroupCPUWeight_Call struct {
+	*mock.Call
+}
+
+// CgroupCPUWeight is a helper method to define mock.On call
+func (_e *Flags_Expecter) CgroupCPUWeight() *Flags_CgroupCPUWeight_Call {
+	return &Flags_CgroupCPUWeight_Call{Call: _e.mock.On("CgroupCPUWeight")}
+}
+
+func (_c *Flags_CgroupCPUWeight_Call) Run(run func()) *Flags_CgroupCPUWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_CgroupCPUWeight_Call) Return(n int) *Flags_CgroupCPUWeight_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Flags_CgroupCPUWeight_Call) RunAndReturn(run func() int) *Flags_CgroupCPUWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupIOWeight provides a mock function for the type Flags
+func (_mock *Flags) CgroupIOWeight() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupIOWeight")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Flags_CgroupIOWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupIOWeight'
+type Flags_CgroupIOWeight_Call struct {
+	*mock.Call
+}
+
+// CgroupIOWeight is a helper method to define mock.On call
+func (_e *Flags_Expecter) CgroupIOWeight() *Flags_CgroupIOWeight_Call {
+	return &Flags_CgroupIOWeight_Call{Call: _e.mock.On("CgroupIOWeight")}
+}
+
+func (_c *Flags_CgroupIOWeight_Call) Run(run func()) *Flags_CgroupIOWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_CgroupIOWeight_Call) Return(n int) *Flags_CgroupIOWeight_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Flags_CgroupIOWeight_Call) RunAndReturn(run func() int) *Flags_CgroupIOWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupLimitsEnabled provides a mock function for the type Flags
+func (_mock *Flags) CgroupLimitsEnabled() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupLimitsEnabled")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Flags_CgroupLimitsEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupLimitsEnabled'
+type Flags_CgroupLimitsEnabled_Call struct {
+	*mock.Call
+}
+
+// CgroupLimitsEnabled is a helper method to define mock.On call
+func (_e *Flags_Expecter) CgroupLimitsEnabled() *Flags_CgroupLimitsEnabled_Call {
+	return &Flags_CgroupLimitsEnabled_Call{Call: _e.mock.On("CgroupLimitsEnabled")}
+}
+
+func (_c *Flags_CgroupLimitsEnabled_Call) Run(run func()) *Flags_CgroupLimitsEnabled_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_CgroupLimitsEnabled_Call) Return(b bool) *Flags_CgroupLimitsEnabled_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Flags_CgroupLimitsEnabled_Call) RunAndReturn(run func() bool) *Flags_CgroupLimitsEnabled_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupMemoryMaxMB provides a mock function for the type Flags
+func (_mock *Flags) CgroupMemoryMaxMB() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupMemoryMaxMB")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Flags_CgroupMemoryMaxMB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupMemoryMaxMB'
+type Flags_CgroupMemoryMaxMB_Call struct {
+	*mock.Call
+}
+
+// CgroupMemoryMaxMB is a helper method to define mock.On call
+func (_e *Flags_Expecter) CgroupMemoryMaxMB() *Flags_CgroupMemoryMaxMB_Call {
+	return &Flags_CgroupMemoryMaxMB_Call{Call: _e.mock.On("CgroupMemoryMaxMB")}
+}
+
+func (_c *Flags_CgroupMemoryMaxMB_Call) Run(run func()) *Flags_CgroupMemoryMaxMB_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_CgroupMemoryMaxMB_Call) Return(n int) *Flags_CgroupMemoryMaxMB_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Flags_CgroupMemoryMaxMB_Call) RunAndReturn(run func() int) *Flags_CgroupMemoryMaxMB_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ControlPushEnabled provides a mock function for the type Flags
+func (_mock *Flags) ControlPushEnabled() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ControlPushEnabled")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Flags_ControlPushEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ControlPushEnabled'
+type Flags_ControlPushEnabled_Call struct {
+	*mock.Call
+}
+
+// ControlPushEnabled is a helper method to define mock.On call
+func (_e *Flags_Expecter) ControlPushEnabled() *Flags_ControlPushEnabled_Call {
+	return &Flags_ControlPushEnabled_Call{Call: _e.mock.On("ControlPushEnabled")}
+}
+
+func (_c *Flags_ControlPushEnabled_Call) Run(run func()) *Flags_ControlPushEnabled_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_ControlPushEnabled_Call) Return(b bool) *Flags_ControlPushEnabled_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Flags_ControlPushEnabled_Call) RunAndReturn(run func() bool) *Flags_ControlPushEnabled_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ControlRequestInterval provides a mock function for the type Flags
 func (_mock *Flags) ControlRequestInterval() time.Duration {
 	ret := _mock.Called()
@@ -1049,6 +1269,50 @@ func (_c *Flags_EnableInitialRunner_Call) RunAndReturn(run func() bool) *Flags_E
 	return _c
 }
 
+// EnforceControlPayloadSignatures provides a mock function for the type Flags
+func (_mock *Flags) EnforceControlPayloadSignatures() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnforceControlPayloadSignatures")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Flags_EnforceControlPayloadSignatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnforceControlPayloadSignatures'
+type Flags_EnforceControlPayloadSignatures_Call struct {
+	*mock.Call
+}
+
+// EnforceControlPayloadSignatures is a helper method to define mock.On call
+func (_e *Flags_Expecter) EnforceControlPayloadSignatures() *Flags_EnforceControlPayloadSignatures_Call {
+	return &Flags_EnforceControlPayloadSignatures_Call{Call: _e.mock.On("EnforceControlPayloadSignatures")}
+}
+
+func (_c *Flags_EnforceControlPayloadSignatures_Call) Run(run func()) *Flags_EnforceControlPayloadSignatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_EnforceControlPayloadSignatures_Call) Return(b bool) *Flags_EnforceControlPayloadSignatures_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Flags_EnforceControlPayloadSignatures_Call) RunAndReturn(run func() bool) *Flags_EnforceControlPayloadSignatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // EnrollSecret provides a mock function for the type Flags
 func (_mock *Flags) EnrollSecret() string {
 	ret := _mock.Called()
@@ -2728,79 +2992,329 @@ func (_mock *Flags) SetAutoupdateInitialDelayOverride(value time.Duration, durat
 	return
 }
 
-// Flags_SetAutoupdateInitialDelayOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateInitialDelayOverride'
-type Flags_SetAutoupdateInitialDelayOverride_Call struct {
+// Flags_SetAutoupdateInitialDelayOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateInitialDelayOverride'
+type Flags_SetAutoupdateInitialDelayOverride_Call struct {
+	*mock.Call
+}
+
+// SetAutoupdateInitialDelayOverride is a helper method to define mock.On call
+//   - value time.Duration
+//   - duration time.Duration
+func (_e *Flags_Expecter) SetAutoupdateInitialDelayOverride(value interface{}, duration interface{}) *Flags_SetAutoupdateInitialDelayOverride_Call {
+	return &Flags_SetAutoupdateInitialDelayOverride_Call{Call: _e.mock.On("SetAutoupdateInitialDelayOverride", value, duration)}
+}
+
+func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateInitialDelayOverride_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) Return() *Flags_SetAutoupdateInitialDelayOverride_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateInitialDelayOverride_Call {
+	_c.Run(run)
+	return _c
+}
+
+// SetAutoupdateInterval provides a mock function for the type Flags
+func (_mock *Flags) SetAutoupdateInterval(interval time.Duration) error {
+	ret := _mock.Called(interval)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetAutoupdateInterval")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
+		r0 = returnFunc(interval)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetAutoupdateInterval_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateInterval'
+type Flags_SetAutoupdateInterval_Call struct {
+	*mock.Call
+}
+
+// SetAutoupdateInterval is a helper method to define mock.On call
+//   - interval time.Duration
+func (_e *Flags_Expecter) SetAutoupdateInterval(interval interface{}) *Flags_SetAutoupdateInterval_Call {
+	return &Flags_SetAutoupdateInterval_Call{Call: _e.mock.On("SetAutoupdateInterval", interval)}
+}
+
+func (_c *Flags_SetAutoupdateInterval_Call) Run(run func(interval time.Duration)) *Flags_SetAutoupdateInterval_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateInterval_Call) Return(err error) *Flags_SetAutoupdateInterval_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateInterval_Call) RunAndReturn(run func(interval time.Duration) error) *Flags_SetAutoupdateInterval_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetAutoupdateIntervalOverride provides a mock function for the type Flags
+func (_mock *Flags) SetAutoupdateIntervalOverride(value time.Duration, duration time.Duration) {
+	_mock.Called(value, duration)
+	return
+}
+
+// Flags_SetAutoupdateIntervalOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateIntervalOverride'
+type Flags_SetAutoupdateIntervalOverride_Call struct {
+	*mock.Call
+}
+
+// SetAutoupdateIntervalOverride is a helper method to define mock.On call
+//   - value time.Duration
+//   - duration time.Duration
+func (_e *Flags_Expecter) SetAutoupdateIntervalOverride(value interface{}, duration interface{}) *Flags_SetAutoupdateIntervalOverride_Call {
+	return &Flags_SetAutoupdateIntervalOverride_Call{Call: _e.mock.On("SetAutoupdateIntervalOverride", value, duration)}
+}
+
+func (_c *Flags_SetAutoupdateIntervalOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateIntervalOverride_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateIntervalOverride_Call) Return() *Flags_SetAutoupdateIntervalOverride_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Flags_SetAutoupdateIntervalOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateIntervalOverride_Call {
+	_c.Run(run)
+	return _c
+}
+
+// SetCachedQueryResultsTTL provides a mock function for the type Flags
+func (_mock *Flags) SetCachedQueryResultsTTL(ttl time.Duration) error {
+	ret := _mock.Called(ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCachedQueryResultsTTL")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
+		r0 = returnFunc(ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetCachedQueryResultsTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCachedQueryResultsTTL'
+type Flags_SetCachedQueryResultsTTL_Call struct {
+	*mock.Call
+}
+
+// SetCachedQueryResultsTTL is a helper method to define mock.On call
+//   - ttl time.Duration
+func (_e *Flags_Expecter) SetCachedQueryResultsTTL(ttl interface{}) *Flags_SetCachedQueryResultsTTL_Call {
+	return &Flags_SetCachedQueryResultsTTL_Call{Call: _e.mock.On("SetCachedQueryResultsTTL", ttl)}
+}
+
+func (_c *Flags_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration)) *Flags_SetCachedQueryResultsTTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetCachedQueryResultsTTL_Call) Return(err error) *Flags_SetCachedQueryResultsTTL_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Flags_SetCachedQueryResultsTTL_Call) RunAndReturn(run func(ttl time.Duration) error) *Flags_SetCachedQueryResultsTTL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCgroupCPUWeight provides a mock function for the type Flags
+func (_mock *Flags) SetCgroupCPUWeight(weight int) error {
+	ret := _mock.Called(weight)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupCPUWeight")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(weight)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetCgroupCPUWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupCPUWeight'
+type Flags_SetCgroupCPUWeight_Call struct {
+	*mock.Call
+}
+
+// SetCgroupCPUWeight is a helper method to define mock.On call
+//   - weight int
+func (_e *Flags_Expecter) SetCgroupCPUWeight(weight interface{}) *Flags_SetCgroupCPUWeight_Call {
+	return &Flags_SetCgroupCPUWeight_Call{Call: _e.mock.On("SetCgroupCPUWeight", weight)}
+}
+
+func (_c *Flags_SetCgroupCPUWeight_Call) Run(run func(weight int)) *Flags_SetCgroupCPUWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int
+		if args[0] != nil {
+			arg0 = args[0].(int)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetCgroupCPUWeight_Call) Return(err error) *Flags_SetCgroupCPUWeight_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Flags_SetCgroupCPUWeight_Call) RunAndReturn(run func(weight int) error) *Flags_SetCgroupCPUWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCgroupIOWeight provides a mock function for the type Flags
+func (_mock *Flags) SetCgroupIOWeight(weight int) error {
+	ret := _mock.Called(weight)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupIOWeight")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(weight)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetCgroupIOWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupIOWeight'
+type Flags_SetCgroupIOWeight_Call struct {
 	*mock.Call
 }
 
-// SetAutoupdateInitialDelayOverride is a helper method to define mock.On call
-//   - value time.Duration
-//   - duration time.Duration
-func (_e *Flags_Expecter) SetAutoupdateInitialDelayOverride(value interface{}, duration interface{}) *Flags_SetAutoupdateInitialDelayOverride_Call {
-	return &Flags_SetAutoupdateInitialDelayOverride_Call{Call: _e.mock.On("SetAutoupdateInitialDelayOverride", value, duration)}
+// SetCgroupIOWeight is a helper method to define mock.On call
+//   - weight int
+func (_e *Flags_Expecter) SetCgroupIOWeight(weight interface{}) *Flags_SetCgroupIOWeight_Call {
+	return &Flags_SetCgroupIOWeight_Call{Call: _e.mock.On("SetCgroupIOWeight", weight)}
 }
 
-func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateInitialDelayOverride_Call {
+func (_c *Flags_SetCgroupIOWeight_Call) Run(run func(weight int)) *Flags_SetCgroupIOWeight_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 int
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
-		}
-		var arg1 time.Duration
-		if args[1] != nil {
-			arg1 = args[1].(time.Duration)
+			arg0 = args[0].(int)
 		}
 		run(
 			arg0,
-			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) Return() *Flags_SetAutoupdateInitialDelayOverride_Call {
-	_c.Call.Return()
+func (_c *Flags_SetCgroupIOWeight_Call) Return(err error) *Flags_SetCgroupIOWeight_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateInitialDelayOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateInitialDelayOverride_Call {
-	_c.Run(run)
+func (_c *Flags_SetCgroupIOWeight_Call) RunAndReturn(run func(weight int) error) *Flags_SetCgroupIOWeight_Call {
+	_c.Call.Return(run)
 	return _c
 }
 
-// SetAutoupdateInterval provides a mock function for the type Flags
-func (_mock *Flags) SetAutoupdateInterval(interval time.Duration) error {
-	ret := _mock.Called(interval)
+// SetCgroupLimitsEnabled provides a mock function for the type Flags
+func (_mock *Flags) SetCgroupLimitsEnabled(enabled bool) error {
+	ret := _mock.Called(enabled)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SetAutoupdateInterval")
+		panic("no return value specified for SetCgroupLimitsEnabled")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
-		r0 = returnFunc(interval)
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Flags_SetAutoupdateInterval_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateInterval'
-type Flags_SetAutoupdateInterval_Call struct {
+// Flags_SetCgroupLimitsEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupLimitsEnabled'
+type Flags_SetCgroupLimitsEnabled_Call struct {
 	*mock.Call
 }
 
-// SetAutoupdateInterval is a helper method to define mock.On call
-//   - interval time.Duration
-func (_e *Flags_Expecter) SetAutoupdateInterval(interval interface{}) *Flags_SetAutoupdateInterval_Call {
-	return &Flags_SetAutoupdateInterval_Call{Call: _e.mock.On("SetAutoupdateInterval", interval)}
+// SetCgroupLimitsEnabled is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Flags_Expecter) SetCgroupLimitsEnabled(enabled interface{}) *Flags_SetCgroupLimitsEnabled_Call {
+	return &Flags_SetCgroupLimitsEnabled_Call{Call: _e.mock.On("SetCgroupLimitsEnabled", enabled)}
 }
 
-func (_c *Flags_SetAutoupdateInterval_Call) Run(run func(interval time.Duration)) *Flags_SetAutoupdateInterval_Call {
+func (_c *Flags_SetCgroupLimitsEnabled_Call) Run(run func(enabled bool)) *Flags_SetCgroupLimitsEnabled_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 bool
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
+			arg0 = args[0].(bool)
 		}
 		run(
 			arg0,
@@ -2809,95 +3323,100 @@ func (_c *Flags_SetAutoupdateInterval_Call) Run(run func(interval time.Duration)
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateInterval_Call) Return(err error) *Flags_SetAutoupdateInterval_Call {
+func (_c *Flags_SetCgroupLimitsEnabled_Call) Return(err error) *Flags_SetCgroupLimitsEnabled_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateInterval_Call) RunAndReturn(run func(interval time.Duration) error) *Flags_SetAutoupdateInterval_Call {
+func (_c *Flags_SetCgroupLimitsEnabled_Call) RunAndReturn(run func(enabled bool) error) *Flags_SetCgroupLimitsEnabled_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// SetAutoupdateIntervalOverride provides a mock function for the type Flags
-func (_mock *Flags) SetAutoupdateIntervalOverride(value time.Duration, duration time.Duration) {
-	_mock.Called(value, duration)
-	return
+// SetCgroupMemoryMaxMB provides a mock function for the type Flags
+func (_mock *Flags) SetCgroupMemoryMaxMB(limit int) error {
+	ret := _mock.Called(limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupMemoryMaxMB")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
 }
 
-// Flags_SetAutoupdateIntervalOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateIntervalOverride'
-type Flags_SetAutoupdateIntervalOverride_Call struct {
+// Flags_SetCgroupMemoryMaxMB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupMemoryMaxMB'
+type Flags_SetCgroupMemoryMaxMB_Call struct {
 	*mock.Call
 }
 
-// SetAutoupdateIntervalOverride is a helper method to define mock.On call
-//   - value time.Duration
-//   - duration time.Duration
-func (_e *Flags_Expecter) SetAutoupdateIntervalOverride(value interface{}, duration interface{}) *Flags_SetAutoupdateIntervalOverride_Call {
-	return &Flags_SetAutoupdateIntervalOverride_Call{Call: _e.mock.On("SetAutoupdateIntervalOverride", value, duration)}
+// SetCgroupMemoryMaxMB is a helper method to define mock.On call
+//   - limit int
+func (_e *Flags_Expecter) SetCgroupMemoryMaxMB(limit interface{}) *Flags_SetCgroupMemoryMaxMB_Call {
+	return &Flags_SetCgroupMemoryMaxMB_Call{Call: _e.mock.On("SetCgroupMemoryMaxMB", limit)}
 }
 
-func (_c *Flags_SetAutoupdateIntervalOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateIntervalOverride_Call {
+func (_c *Flags_SetCgroupMemoryMaxMB_Call) Run(run func(limit int)) *Flags_SetCgroupMemoryMaxMB_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 int
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
-		}
-		var arg1 time.Duration
-		if args[1] != nil {
-			arg1 = args[1].(time.Duration)
+			arg0 = args[0].(int)
 		}
 		run(
 			arg0,
-			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateIntervalOverride_Call) Return() *Flags_SetAutoupdateIntervalOverride_Call {
-	_c.Call.Return()
+func (_c *Flags_SetCgroupMemoryMaxMB_Call) Return(err error) *Flags_SetCgroupMemoryMaxMB_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Flags_SetAutoupdateIntervalOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Flags_SetAutoupdateIntervalOverride_Call {
-	_c.Run(run)
+func (_c *Flags_SetCgroupMemoryMaxMB_Call) RunAndReturn(run func(limit int) error) *Flags_SetCgroupMemoryMaxMB_Call {
+	_c.Call.Return(run)
 	return _c
 }
 
-// SetCachedQueryResultsTTL provides a mock function for the type Flags
-func (_mock *Flags) SetCachedQueryResultsTTL(ttl time.Duration) error {
-	ret := _mock.Called(ttl)
+// SetControlPushEnabled provides a mock function for the type Flags
+func (_mock *Flags) SetControlPushEnabled(enabled bool) error {
+	ret := _mock.Called(enabled)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SetCachedQueryResultsTTL")
+		panic("no return value specified for SetControlPushEnabled")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
-		r0 = returnFunc(ttl)
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Flags_SetCachedQueryResultsTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCachedQueryResultsTTL'
-type Flags_SetCachedQueryResultsTTL_Call struct {
+// Flags_SetControlPushEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetControlPushEnabled'
+type Flags_SetControlPushEnabled_Call struct {
 	*mock.Call
 }
 
-// SetCachedQueryResultsTTL is a helper method to define mock.On call
-//   - ttl time.Duration
-func (_e *Flags_Expecter) SetCachedQueryResultsTTL(ttl interface{}) *Flags_SetCachedQueryResultsTTL_Call {
-	return &Flags_SetCachedQueryResultsTTL_Call{Call: _e.mock.On("SetCachedQueryResultsTTL", ttl)}
+// SetControlPushEnabled is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Flags_Expecter) SetControlPushEnabled(enabled interface{}) *Flags_SetControlPushEnabled_Call {
+	return &Flags_SetControlPushEnabled_Call{Call: _e.mock.On("SetControlPushEnabled", enabled)}
 }
 
-func (_c *Flags_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration)) *Flags_SetCachedQueryResultsTTL_Call {
+func (_c *Flags_SetControlPushEnabled_Call) Run(run func(enabled bool)) *Flags_SetControlPushEnabled_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 bool
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
+			arg0 = args[0].(bool)
 		}
 		run(
 			arg0,
@@ -2906,12 +3425,12 @@ func (_c *Flags_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration))
 	return _c
 }
 
-func (_c *Flags_SetCachedQueryResultsTTL_Call) Return(err error) *Flags_SetCachedQueryResultsTTL_Call {
+func (_c *Flags_SetControlPushEnabled_Call) Return(err error) *Flags_SetControlPushEnabled_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Flags_SetCachedQueryResultsTTL_Call) RunAndReturn(run func(ttl time.Duration) error) *Flags_SetCachedQueryResultsTTL_Call {
+func (_c *Flags_SetControlPushEnabled_Call) RunAndReturn(run func(enabled bool) error) *Flags_SetControlPushEnabled_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -3671,6 +4190,57 @@ func (_c *Flags_SetDuplicateLogWindow_Call) RunAndReturn(run func(duration time.
 	return _c
 }
 
+// SetEnforceControlPayloadSignatures provides a mock function for the type Flags
+func (_mock *Flags) SetEnforceControlPayloadSignatures(enabled bool) error {
+	ret := _mock.Called(enabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetEnforceControlPayloadSignatures")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetEnforceControlPayloadSignatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetEnforceControlPayloadSignatures'
+type Flags_SetEnforceControlPayloadSignatures_Call struct {
+	*mock.Call
+}
+
+// SetEnforceControlPayloadSignatures is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Flags_Expecter) SetEnforceControlPayloadSignatures(enabled interface{}) *Flags_SetEnforceControlPayloadSignatures_Call {
+	return &Flags_SetEnforceControlPayloadSignatures_Call{Call: _e.mock.On("SetEnforceControlPayloadSignatures", enabled)}
+}
+
+func (_c *Flags_SetEnforceControlPayloadSignatures_Call) Run(run func(enabled bool)) *Flags_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 bool
+		if args[0] != nil {
+			arg0 = args[0].(bool)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetEnforceControlPayloadSignatures_Call) Return(err error) *Flags_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Flags_SetEnforceControlPayloadSignatures_Call) RunAndReturn(run func(enabled bool) error) *Flags_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetExportTraces provides a mock function for the type Flags
 func (_mock *Flags) SetExportTraces(enabled bool) error {
 	ret := _mock.Called(enabled)
@@ -4630,6 +5200,52 @@ func (_c *Flags_SetOsqueryVerbose_Call) RunAndReturn(run func(verbose bool) erro
 	return _c
 }
 
+// SetOsqueryVerboseOverride provides a mock function for the type Flags
+func (_mock *Flags) SetOsqueryVerboseOverride(value bool, duration time.Duration) {
+	_mock.Called(value, duration)
+	return
+}
+
+// Flags_SetOsqueryVerboseOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetOsqueryVerboseOverride'
+type Flags_SetOsqueryVerboseOverride_Call struct {
+	*mock.Call
+}
+
+// SetOsqueryVerboseOverride is a helper method to define mock.On call
+//   - value bool
+//   - duration time.Duration
+func (_e *Flags_Expecter) SetOsqueryVerboseOverride(value interface{}, duration interface{}) *Flags_SetOsqueryVerboseOverride_Call {
+	return &Flags_SetOsqueryVerboseOverride_Call{Call: _e.mock.On("SetOsqueryVerboseOverride", value, duration)}
+}
+
+func (_c *Flags_SetOsqueryVerboseOverride_Call) Run(run func(value bool, duration time.Duration)) *Flags_SetOsqueryVerboseOverride_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 bool
+		if args[0] != nil {
+			arg0 = args[0].(bool)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetOsqueryVerboseOverride_Call) Return() *Flags_SetOsqueryVerboseOverride_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Flags_SetOsqueryVerboseOverride_Call) RunAndReturn(run func(value bool, duration time.Duration)) *Flags_SetOsqueryVerboseOverride_Call {
+	_c.Run(run)
+	return _c
+}
+
 // SetPerformanceMonitoringEnabled provides a mock function for the type Flags
 func (_mock *Flags) SetPerformanceMonitoringEnabled(enabled bool) error {
 	ret := _mock.Called(enabled)
@@ -4885,6 +5501,57 @@ func (_c *Flags_SetSystrayRestartEnabled_Call) RunAndReturn(run func(enabled boo
 	return _c
 }
 
+// SetTableCacheTTLs provides a mock function for the type Flags
+func (_mock *Flags) SetTableCacheTTLs(ttls string) error {
+	ret := _mock.Called(ttls)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTableCacheTTLs")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
+		r0 = returnFunc(ttls)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Flags_SetTableCacheTTLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetTableCacheTTLs'
+type Flags_SetTableCacheTTLs_Call struct {
+	*mock.Call
+}
+
+// SetTableCacheTTLs is a helper method to define mock.On call
+//   - ttls string
+func (_e *Flags_Expecter) SetTableCacheTTLs(ttls interface{}) *Flags_SetTableCacheTTLs_Call {
+	return &Flags_SetTableCacheTTLs_Call{Call: _e.mock.On("SetTableCacheTTLs", ttls)}
+}
+
+func (_c *Flags_SetTableCacheTTLs_Call) Run(run func(ttls string)) *Flags_SetTableCacheTTLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Flags_SetTableCacheTTLs_Call) Return(err error) *Flags_SetTableCacheTTLs_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Flags_SetTableCacheTTLs_Call) RunAndReturn(run func(ttls string) error) *Flags_SetTableCacheTTLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetTableGenerateTimeout provides a mock function for the type Flags
 func (_mock *Flags) SetTableGenerateTimeout(interval time.Duration) error {
 	ret := _mock.Called(interval)
@@ -5587,6 +6254,50 @@ func (_c *Flags_SystrayRestartEnabled_Call) RunAndReturn(run func() bool) *Flags
 	return _c
 }
 
+// TableCacheTTLs provides a mock function for the type Flags
+func (_mock *Flags) TableCacheTTLs() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for TableCacheTTLs")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// Flags_TableCacheTTLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TableCacheTTLs'
+type Flags_TableCacheTTLs_Call struct {
+	*mock.Call
+}
+
+// TableCacheTTLs is a helper method to define mock.On call
+func (_e *Flags_Expecter) TableCacheTTLs() *Flags_TableCacheTTLs_Call {
+	return &Flags_TableCacheTTLs_Call{Call: _e.mock.On("TableCacheTTLs")}
+}
+
+func (_c *Flags_TableCacheTTLs_Call) Run(run func()) *Flags_TableCacheTTLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Flags_TableCacheTTLs_Call) Return(s string) *Flags_TableCacheTTLs_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *Flags_TableCacheTTLs_Call) RunAndReturn(run func() string) *Flags_TableCacheTTLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // TableGenerateTimeout provides a mock function for the type Flags
 func (_mock *Flags) TableGenerateTimeout() time.Duration {
 	ret := _mock.Called()
@@ -482,6 +482,90 @@ func (_c *Updater_Update_Call) RunAndReturn(run func(kvPairs map[string]string)
 	return _c
 }
 
+// NewPatcher creates a new instance of Patcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPatcher(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Patcher {
+	mock := &Patcher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// Patcher is an autogenerated mock type for the Patcher type
+type Patcher struct {
+	mock.Mock
+}
+
+type Patcher_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *Patcher) EXPECT() *Patcher_Expecter {
+	return &Patcher_Expecter{mock: &_m.Mock}
+}
+
+// Patch provides a mock function for the type Patcher
+func (_mock *Patcher) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	ret := _mock.Called(kvPairs, deletedKeys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Patch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(map[string]string, []string) error); ok {
+		r0 = returnFunc(kvPairs, deletedKeys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Patcher_Patch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Patch'
+type Patcher_Patch_Call struct {
+	*mock.Call
+}
+
+// Patch is a helper method to define mock.On call
+//   - kvPairs map[string]string
+//   - deletedKeys []string
+func (_e *Patcher_Expecter) Patch(kvPairs interface{}, deletedKeys interface{}) *Patcher_Patch_Call {
+	return &Patcher_Patch_Call{Call: _e.mock.On("Patch", kvPairs, deletedKeys)}
+}
+
+func (_c *Patcher_Patch_Call) Run(run func(kvPairs map[string]string, deletedKeys []string)) *Patcher_Patch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 map[string]string
+		if args[0] != nil {
+			arg0 = args[0].(map[string]string)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Patcher_Patch_Call) Return(err error) *Patcher_Patch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Patcher_Patch_Call) RunAndReturn(run func(kvPairs map[string]string, deletedKeys []string) error) *Patcher_Patch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewCounter creates a new instance of Counter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewCounter(t interface {
@@ -2117,6 +2201,63 @@ func (_c *GetterSetterDeleterIteratorUpdaterCounterAppender_Get_Call) RunAndRetu
 	return _c
 }
 
+// Patch provides a mock function for the type GetterSetterDeleterIteratorUpdaterCounterAppender
+func (_mock *GetterSetterDeleterIteratorUpdaterCounterAppender) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	ret := _mock.Called(kvPairs, deletedKeys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Patch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(map[string]string, []string) error); ok {
+		r0 = returnFunc(kvPairs, deletedKeys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Patch'
+type GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call struct {
+	*mock.Call
+}
+
+// Patch is a helper method to define mock.On call
+//   - kvPairs map[string]string
+//   - deletedKeys []string
+func (_e *GetterSetterDeleterIteratorUpdaterCounterAppender_Expecter) Patch(kvPairs interface{}, deletedKeys interface{}) *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call {
+	return &GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call{Call: _e.mock.On("Patch", kvPairs, deletedKeys)}
+}
+
+func (_c *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call) Run(run func(kvPairs map[string]string, deletedKeys []string)) *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 map[string]string
+		if args[0] != nil {
+			arg0 = args[0].(map[string]string)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call) Return(err error) *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call) RunAndReturn(run func(kvPairs map[string]string, deletedKeys []string) error) *GetterSetterDeleterIteratorUpdaterCounterAppender_Patch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type GetterSetterDeleterIteratorUpdaterCounterAppender
 func (_mock *GetterSetterDeleterIteratorUpdaterCounterAppender) Set(key []byte, value []byte) error {
 	ret := _mock.Called(key, value)
@@ -2599,6 +2740,63 @@ func (_c *KVStore_Get_Call) RunAndReturn(run func(key []byte) ([]byte, error)) *
 	return _c
 }
 
+// Patch provides a mock function for the type KVStore
+func (_mock *KVStore) Patch(kvPairs map[string]string, deletedKeys []string) error {
+	ret := _mock.Called(kvPairs, deletedKeys)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Patch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(map[string]string, []string) error); ok {
+		r0 = returnFunc(kvPairs, deletedKeys)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// KVStore_Patch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Patch'
+type KVStore_Patch_Call struct {
+	*mock.Call
+}
+
+// Patch is a helper method to define mock.On call
+//   - kvPairs map[string]string
+//   - deletedKeys []string
+func (_e *KVStore_Expecter) Patch(kvPairs interface{}, deletedKeys interface{}) *KVStore_Patch_Call {
+	return &KVStore_Patch_Call{Call: _e.mock.On("Patch", kvPairs, deletedKeys)}
+}
+
+func (_c *KVStore_Patch_Call) Run(run func(kvPairs map[string]string, deletedKeys []string)) *KVStore_Patch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 map[string]string
+		if args[0] != nil {
+			arg0 = args[0].(map[string]string)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *KVStore_Patch_Call) Return(err error) *KVStore_Patch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *KVStore_Patch_Call) RunAndReturn(run func(kvPairs map[string]string, deletedKeys []string) error) *KVStore_Patch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type KVStore
 func (_mock *KVStore) Set(key []byte, value []byte) error {
 	ret := _mock.Called(key, value)
@@ -497,6 +497,182 @@ func (_c *Knapsack_CertPins_Call) RunAndReturn(run func() [][]byte) *Knapsack_Ce
 	return _c
 }
 
+// CgroupCPUWeight provides a mock function for the type Knapsack
+func (_mock *Knapsack) CgroupCPUWeight() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupCPUWeight")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Knapsack_CgroupCPUWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupCPUWeight'
+type Knapsack_CgroupCPUWeight_Call struct {
+	*mock.Call
+}
+
+// CgroupCPUWeight is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) CgroupCPUWeight() *Knapsack_CgroupCPUWeight_Call {
+	return &Knapsack_CgroupCPUWeight_Call{Call: _e.mock.On("CgroupCPUWeight")}
+}
+
+func (_c *Knapsack_CgroupCPUWeight_Call) Run(run func()) *Knapsack_CgroupCPUWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_CgroupCPUWeight_Call) Return(n int) *Knapsack_CgroupCPUWeight_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Knapsack_CgroupCPUWeight_Call) RunAndReturn(run func() int) *Knapsack_CgroupCPUWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupIOWeight provides a mock function for the type Knapsack
+func (_mock *Knapsack) CgroupIOWeight() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupIOWeight")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Knapsack_CgroupIOWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupIOWeight'
+type Knapsack_CgroupIOWeight_Call struct {
+	*mock.Call
+}
+
+// CgroupIOWeight is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) CgroupIOWeight() *Knapsack_CgroupIOWeight_Call {
+	return &Knapsack_CgroupIOWeight_Call{Call: _e.mock.On("CgroupIOWeight")}
+}
+
+func (_c *Knapsack_CgroupIOWeight_Call) Run(run func()) *Knapsack_CgroupIOWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_CgroupIOWeight_Call) Return(n int) *Knapsack_CgroupIOWeight_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Knapsack_CgroupIOWeight_Call) RunAndReturn(run func() int) *Knapsack_CgroupIOWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupLimitsEnabled provides a mock function for the type Knapsack
+func (_mock *Knapsack) CgroupLimitsEnabled() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupLimitsEnabled")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Knapsack_CgroupLimitsEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupLimitsEnabled'
+type Knapsack_CgroupLimitsEnabled_Call struct {
+	*mock.Call
+}
+
+// CgroupLimitsEnabled is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) CgroupLimitsEnabled() *Knapsack_CgroupLimitsEnabled_Call {
+	return &Knapsack_CgroupLimitsEnabled_Call{Call: _e.mock.On("CgroupLimitsEnabled")}
+}
+
+func (_c *Knapsack_CgroupLimitsEnabled_Call) Run(run func()) *Knapsack_CgroupLimitsEnabled_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_CgroupLimitsEnabled_Call) Return(b bool) *Knapsack_CgroupLimitsEnabled_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Knapsack_CgroupLimitsEnabled_Call) RunAndReturn(run func() bool) *Knapsack_CgroupLimitsEnabled_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CgroupMemoryMaxMB provides a mock function for the type Knapsack
+func (_mock *Knapsack) CgroupMemoryMaxMB() int {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CgroupMemoryMaxMB")
+	}
+
+	var r0 int
+	if returnFunc, ok := ret.Get(0).(func() int); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	return r0
+}
+
+// Knapsack_CgroupMemoryMaxMB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CgroupMemoryMaxMB'
+type Knapsack_CgroupMemoryMaxMB_Call struct {
+	*mock.Call
+}
+
+// CgroupMemoryMaxMB is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) CgroupMemoryMaxMB() *Knapsack_CgroupMemoryMaxMB_Call {
+	return &Knapsack_CgroupMemoryMaxMB_Call{Call: _e.mock.On("CgroupMemoryMaxMB")}
+}
+
+func (_c *Knapsack_CgroupMemoryMaxMB_Call) Run(run func()) *Knapsack_CgroupMemoryMaxMB_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_CgroupMemoryMaxMB_Call) Return(n int) *Knapsack_CgroupMemoryMaxMB_Call {
+	_c.Call.Return(n)
+	return _c
+}
+
+func (_c *Knapsack_CgroupMemoryMaxMB_Call) RunAndReturn(run func() int) *Knapsack_CgroupMemoryMaxMB_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ConfigStore provides a mock function for the type Knapsack
 func (_mock *Knapsack) ConfigStore() types.KVStore {
 	ret := _mock.Called()
@@ -543,6 +719,50 @@ func (_c *Knapsack_ConfigStore_Call) RunAndReturn(run func() types.KVStore) *Kna
 	return _c
 }
 
+// ControlPushEnabled provides a mock function for the type Knapsack
+func (_mock *Knapsack) ControlPushEnabled() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ControlPushEnabled")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Knapsack_ControlPushEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ControlPushEnabled'
+type Knapsack_ControlPushEnabled_Call struct {
+	*mock.Call
+}
+
+// ControlPushEnabled is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) ControlPushEnabled() *Knapsack_ControlPushEnabled_Call {
+	return &Knapsack_ControlPushEnabled_Call{Call: _e.mock.On("ControlPushEnabled")}
+}
+
+func (_c *Knapsack_ControlPushEnabled_Call) Run(run func()) *Knapsack_ControlPushEnabled_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_ControlPushEnabled_Call) Return(b bool) *Knapsack_ControlPushEnabled_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Knapsack_ControlPushEnabled_Call) RunAndReturn(run func() bool) *Knapsack_ControlPushEnabled_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ControlRequestInterval provides a mock function for the type Knapsack
 func (_mock *Knapsack) ControlRequestInterval() time.Duration {
 	ret := _mock.Called()
@@ -677,6 +897,52 @@ func (_c *Knapsack_ControlStore_Call) RunAndReturn(run func() types.KVStore) *Kn
 	return _c
 }
 
+// ControlSubsystemCacheStore provides a mock function for the type Knapsack
+func (_mock *Knapsack) ControlSubsystemCacheStore() types.KVStore {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ControlSubsystemCacheStore")
+	}
+
+	var r0 types.KVStore
+	if returnFunc, ok := ret.Get(0).(func() types.KVStore); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.KVStore)
+		}
+	}
+	return r0
+}
+
+// Knapsack_ControlSubsystemCacheStore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ControlSubsystemCacheStore'
+type Knapsack_ControlSubsystemCacheStore_Call struct {
+	*mock.Call
+}
+
+// ControlSubsystemCacheStore is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) ControlSubsystemCacheStore() *Knapsack_ControlSubsystemCacheStore_Call {
+	return &Knapsack_ControlSubsystemCacheStore_Call{Call: _e.mock.On("ControlSubsystemCacheStore")}
+}
+
+func (_c *Knapsack_ControlSubsystemCacheStore_Call) Run(run func()) *Knapsack_ControlSubsystemCacheStore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_ControlSubsystemCacheStore_Call) Return(v types.KVStore) *Knapsack_ControlSubsystemCacheStore_Call {
+	_c.Call.Return(v)
+	return _c
+}
+
+func (_c *Knapsack_ControlSubsystemCacheStore_Call) RunAndReturn(run func() types.KVStore) *Knapsack_ControlSubsystemCacheStore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CurrentEnrollmentStatus provides a mock function for the type Knapsack
 func (_mock *Knapsack) CurrentEnrollmentStatus() (types.EnrollmentStatus, error) {
 	ret := _mock.Called()
@@ -1439,6 +1705,50 @@ func (_c *Knapsack_EnableInitialRunner_Call) RunAndReturn(run func() bool) *Knap
 	return _c
 }
 
+// EnforceControlPayloadSignatures provides a mock function for the type Knapsack
+func (_mock *Knapsack) EnforceControlPayloadSignatures() bool {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnforceControlPayloadSignatures")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func() bool); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Knapsack_EnforceControlPayloadSignatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnforceControlPayloadSignatures'
+type Knapsack_EnforceControlPayloadSignatures_Call struct {
+	*mock.Call
+}
+
+// EnforceControlPayloadSignatures is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) EnforceControlPayloadSignatures() *Knapsack_EnforceControlPayloadSignatures_Call {
+	return &Knapsack_EnforceControlPayloadSignatures_Call{Call: _e.mock.On("EnforceControlPayloadSignatures")}
+}
+
+func (_c *Knapsack_EnforceControlPayloadSignatures_Call) Run(run func()) *Knapsack_EnforceControlPayloadSignatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_EnforceControlPayloadSignatures_Call) Return(b bool) *Knapsack_EnforceControlPayloadSignatures_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *Knapsack_EnforceControlPayloadSignatures_Call) RunAndReturn(run func() bool) *Knapsack_EnforceControlPayloadSignatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // EnrollSecret provides a mock function for the type Knapsack
 func (_mock *Knapsack) EnrollSecret() string {
 	ret := _mock.Called()
@@ -2349,6 +2659,52 @@ func (_c *Knapsack_KatcConfigStore_Call) RunAndReturn(run func() types.KVStore)
 	return _c
 }
 
+// KeyValueSchemaStore provides a mock function for the type Knapsack
+func (_mock *Knapsack) KeyValueSchemaStore() types.KVStore {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for KeyValueSchemaStore")
+	}
+
+	var r0 types.KVStore
+	if returnFunc, ok := ret.Get(0).(func() types.KVStore); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.KVStore)
+		}
+	}
+	return r0
+}
+
+// Knapsack_KeyValueSchemaStore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'KeyValueSchemaStore'
+type Knapsack_KeyValueSchemaStore_Call struct {
+	*mock.Call
+}
+
+// KeyValueSchemaStore is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) KeyValueSchemaStore() *Knapsack_KeyValueSchemaStore_Call {
+	return &Knapsack_KeyValueSchemaStore_Call{Call: _e.mock.On("KeyValueSchemaStore")}
+}
+
+func (_c *Knapsack_KeyValueSchemaStore_Call) Run(run func()) *Knapsack_KeyValueSchemaStore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_KeyValueSchemaStore_Call) Return(v types.KVStore) *Knapsack_KeyValueSchemaStore_Call {
+	_c.Call.Return(v)
+	return _c
+}
+
+func (_c *Knapsack_KeyValueSchemaStore_Call) RunAndReturn(run func() types.KVStore) *Knapsack_KeyValueSchemaStore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // KolideHosted provides a mock function for the type Knapsack
 func (_mock *Knapsack) KolideHosted() bool {
 	ret := _mock.Called()
@@ -4365,17 +4721,267 @@ type Knapsack_SetAutoupdateInterval_Call struct {
 	*mock.Call
 }
 
-// SetAutoupdateInterval is a helper method to define mock.On call
-//   - interval time.Duration
-func (_e *Knapsack_Expecter) SetAutoupdateInterval(interval interface{}) *Knapsack_SetAutoupdateInterval_Call {
-	return &Knapsack_SetAutoupdateInterval_Call{Call: _e.mock.On("SetAutoupdateInterval", interval)}
+// SetAutoupdateInterval is a helper method to define mock.On call
+//   - interval time.Duration
+func (_e *Knapsack_Expecter) SetAutoupdateInterval(interval interface{}) *Knapsack_SetAutoupdateInterval_Call {
+	return &Knapsack_SetAutoupdateInterval_Call{Call: _e.mock.On("SetAutoupdateInterval", interval)}
+}
+
+func (_c *Knapsack_SetAutoupdateInterval_Call) Run(run func(interval time.Duration)) *Knapsack_SetAutoupdateInterval_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetAutoupdateInterval_Call) Return(err error) *Knapsack_SetAutoupdateInterval_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetAutoupdateInterval_Call) RunAndReturn(run func(interval time.Duration) error) *Knapsack_SetAutoupdateInterval_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetAutoupdateIntervalOverride provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetAutoupdateIntervalOverride(value time.Duration, duration time.Duration) {
+	_mock.Called(value, duration)
+	return
+}
+
+// Knapsack_SetAutoupdateIntervalOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateIntervalOverride'
+type Knapsack_SetAutoupdateIntervalOverride_Call struct {
+	*mock.Call
+}
+
+// SetAutoupdateIntervalOverride is a helper method to define mock.On call
+//   - value time.Duration
+//   - duration time.Duration
+func (_e *Knapsack_Expecter) SetAutoupdateIntervalOverride(value interface{}, duration interface{}) *Knapsack_SetAutoupdateIntervalOverride_Call {
+	return &Knapsack_SetAutoupdateIntervalOverride_Call{Call: _e.mock.On("SetAutoupdateIntervalOverride", value, duration)}
+}
+
+func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Knapsack_SetAutoupdateIntervalOverride_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) Return() *Knapsack_SetAutoupdateIntervalOverride_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Knapsack_SetAutoupdateIntervalOverride_Call {
+	_c.Run(run)
+	return _c
+}
+
+// SetCachedQueryResultsTTL provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetCachedQueryResultsTTL(ttl time.Duration) error {
+	ret := _mock.Called(ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCachedQueryResultsTTL")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
+		r0 = returnFunc(ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetCachedQueryResultsTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCachedQueryResultsTTL'
+type Knapsack_SetCachedQueryResultsTTL_Call struct {
+	*mock.Call
+}
+
+// SetCachedQueryResultsTTL is a helper method to define mock.On call
+//   - ttl time.Duration
+func (_e *Knapsack_Expecter) SetCachedQueryResultsTTL(ttl interface{}) *Knapsack_SetCachedQueryResultsTTL_Call {
+	return &Knapsack_SetCachedQueryResultsTTL_Call{Call: _e.mock.On("SetCachedQueryResultsTTL", ttl)}
+}
+
+func (_c *Knapsack_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration)) *Knapsack_SetCachedQueryResultsTTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 time.Duration
+		if args[0] != nil {
+			arg0 = args[0].(time.Duration)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetCachedQueryResultsTTL_Call) Return(err error) *Knapsack_SetCachedQueryResultsTTL_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetCachedQueryResultsTTL_Call) RunAndReturn(run func(ttl time.Duration) error) *Knapsack_SetCachedQueryResultsTTL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCgroupCPUWeight provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetCgroupCPUWeight(weight int) error {
+	ret := _mock.Called(weight)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupCPUWeight")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(weight)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetCgroupCPUWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupCPUWeight'
+type Knapsack_SetCgroupCPUWeight_Call struct {
+	*mock.Call
+}
+
+// SetCgroupCPUWeight is a helper method to define mock.On call
+//   - weight int
+func (_e *Knapsack_Expecter) SetCgroupCPUWeight(weight interface{}) *Knapsack_SetCgroupCPUWeight_Call {
+	return &Knapsack_SetCgroupCPUWeight_Call{Call: _e.mock.On("SetCgroupCPUWeight", weight)}
+}
+
+func (_c *Knapsack_SetCgroupCPUWeight_Call) Run(run func(weight int)) *Knapsack_SetCgroupCPUWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int
+		if args[0] != nil {
+			arg0 = args[0].(int)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetCgroupCPUWeight_Call) Return(err error) *Knapsack_SetCgroupCPUWeight_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetCgroupCPUWeight_Call) RunAndReturn(run func(weight int) error) *Knapsack_SetCgroupCPUWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCgroupIOWeight provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetCgroupIOWeight(weight int) error {
+	ret := _mock.Called(weight)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupIOWeight")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(weight)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetCgroupIOWeight_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupIOWeight'
+type Knapsack_SetCgroupIOWeight_Call struct {
+	*mock.Call
+}
+
+// SetCgroupIOWeight is a helper method to define mock.On call
+//   - weight int
+func (_e *Knapsack_Expecter) SetCgroupIOWeight(weight interface{}) *Knapsack_SetCgroupIOWeight_Call {
+	return &Knapsack_SetCgroupIOWeight_Call{Call: _e.mock.On("SetCgroupIOWeight", weight)}
+}
+
+func (_c *Knapsack_SetCgroupIOWeight_Call) Run(run func(weight int)) *Knapsack_SetCgroupIOWeight_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int
+		if args[0] != nil {
+			arg0 = args[0].(int)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetCgroupIOWeight_Call) Return(err error) *Knapsack_SetCgroupIOWeight_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetCgroupIOWeight_Call) RunAndReturn(run func(weight int) error) *Knapsack_SetCgroupIOWeight_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCgroupLimitsEnabled provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetCgroupLimitsEnabled(enabled bool) error {
+	ret := _mock.Called(enabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupLimitsEnabled")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetCgroupLimitsEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupLimitsEnabled'
+type Knapsack_SetCgroupLimitsEnabled_Call struct {
+	*mock.Call
+}
+
+// SetCgroupLimitsEnabled is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Knapsack_Expecter) SetCgroupLimitsEnabled(enabled interface{}) *Knapsack_SetCgroupLimitsEnabled_Call {
+	return &Knapsack_SetCgroupLimitsEnabled_Call{Call: _e.mock.On("SetCgroupLimitsEnabled", enabled)}
 }
 
-func (_c *Knapsack_SetAutoupdateInterval_Call) Run(run func(interval time.Duration)) *Knapsack_SetAutoupdateInterval_Call {
+func (_c *Knapsack_SetCgroupLimitsEnabled_Call) Run(run func(enabled bool)) *Knapsack_SetCgroupLimitsEnabled_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 bool
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
+			arg0 = args[0].(bool)
 		}
 		run(
 			arg0,
@@ -4384,95 +4990,100 @@ func (_c *Knapsack_SetAutoupdateInterval_Call) Run(run func(interval time.Durati
 	return _c
 }
 
-func (_c *Knapsack_SetAutoupdateInterval_Call) Return(err error) *Knapsack_SetAutoupdateInterval_Call {
+func (_c *Knapsack_SetCgroupLimitsEnabled_Call) Return(err error) *Knapsack_SetCgroupLimitsEnabled_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Knapsack_SetAutoupdateInterval_Call) RunAndReturn(run func(interval time.Duration) error) *Knapsack_SetAutoupdateInterval_Call {
+func (_c *Knapsack_SetCgroupLimitsEnabled_Call) RunAndReturn(run func(enabled bool) error) *Knapsack_SetCgroupLimitsEnabled_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// SetAutoupdateIntervalOverride provides a mock function for the type Knapsack
-func (_mock *Knapsack) SetAutoupdateIntervalOverride(value time.Duration, duration time.Duration) {
-	_mock.Called(value, duration)
-	return
+// SetCgroupMemoryMaxMB provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetCgroupMemoryMaxMB(limit int) error {
+	ret := _mock.Called(limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCgroupMemoryMaxMB")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int) error); ok {
+		r0 = returnFunc(limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
 }
 
-// Knapsack_SetAutoupdateIntervalOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAutoupdateIntervalOverride'
-type Knapsack_SetAutoupdateIntervalOverride_Call struct {
+// Knapsack_SetCgroupMemoryMaxMB_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCgroupMemoryMaxMB'
+type Knapsack_SetCgroupMemoryMaxMB_Call struct {
 	*mock.Call
 }
 
-// SetAutoupdateIntervalOverride is a helper method to define mock.On call
-//   - value time.Duration
-//   - duration time.Duration
-func (_e *Knapsack_Expecter) SetAutoupdateIntervalOverride(value interface{}, duration interface{}) *Knapsack_SetAutoupdateIntervalOverride_Call {
-	return &Knapsack_SetAutoupdateIntervalOverride_Call{Call: _e.mock.On("SetAutoupdateIntervalOverride", value, duration)}
+// SetCgroupMemoryMaxMB is a helper method to define mock.On call
+//   - limit int
+func (_e *Knapsack_Expecter) SetCgroupMemoryMaxMB(limit interface{}) *Knapsack_SetCgroupMemoryMaxMB_Call {
+	return &Knapsack_SetCgroupMemoryMaxMB_Call{Call: _e.mock.On("SetCgroupMemoryMaxMB", limit)}
 }
 
-func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) Run(run func(value time.Duration, duration time.Duration)) *Knapsack_SetAutoupdateIntervalOverride_Call {
+func (_c *Knapsack_SetCgroupMemoryMaxMB_Call) Run(run func(limit int)) *Knapsack_SetCgroupMemoryMaxMB_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 int
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
-		}
-		var arg1 time.Duration
-		if args[1] != nil {
-			arg1 = args[1].(time.Duration)
+			arg0 = args[0].(int)
 		}
 		run(
 			arg0,
-			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) Return() *Knapsack_SetAutoupdateIntervalOverride_Call {
-	_c.Call.Return()
+func (_c *Knapsack_SetCgroupMemoryMaxMB_Call) Return(err error) *Knapsack_SetCgroupMemoryMaxMB_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Knapsack_SetAutoupdateIntervalOverride_Call) RunAndReturn(run func(value time.Duration, duration time.Duration)) *Knapsack_SetAutoupdateIntervalOverride_Call {
-	_c.Run(run)
+func (_c *Knapsack_SetCgroupMemoryMaxMB_Call) RunAndReturn(run func(limit int) error) *Knapsack_SetCgroupMemoryMaxMB_Call {
+	_c.Call.Return(run)
 	return _c
 }
 
-// SetCachedQueryResultsTTL provides a mock function for the type Knapsack
-func (_mock *Knapsack) SetCachedQueryResultsTTL(ttl time.Duration) error {
-	ret := _mock.Called(ttl)
+// SetControlPushEnabled provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetControlPushEnabled(enabled bool) error {
+	ret := _mock.Called(enabled)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SetCachedQueryResultsTTL")
+		panic("no return value specified for SetControlPushEnabled")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(time.Duration) error); ok {
-		r0 = returnFunc(ttl)
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// Knapsack_SetCachedQueryResultsTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCachedQueryResultsTTL'
-type Knapsack_SetCachedQueryResultsTTL_Call struct {
+// Knapsack_SetControlPushEnabled_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetControlPushEnabled'
+type Knapsack_SetControlPushEnabled_Call struct {
 	*mock.Call
 }
 
-// SetCachedQueryResultsTTL is a helper method to define mock.On call
-//   - ttl time.Duration
-func (_e *Knapsack_Expecter) SetCachedQueryResultsTTL(ttl interface{}) *Knapsack_SetCachedQueryResultsTTL_Call {
-	return &Knapsack_SetCachedQueryResultsTTL_Call{Call: _e.mock.On("SetCachedQueryResultsTTL", ttl)}
+// SetControlPushEnabled is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Knapsack_Expecter) SetControlPushEnabled(enabled interface{}) *Knapsack_SetControlPushEnabled_Call {
+	return &Knapsack_SetControlPushEnabled_Call{Call: _e.mock.On("SetControlPushEnabled", enabled)}
 }
 
-func (_c *Knapsack_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration)) *Knapsack_SetCachedQueryResultsTTL_Call {
+func (_c *Knapsack_SetControlPushEnabled_Call) Run(run func(enabled bool)) *Knapsack_SetControlPushEnabled_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 time.Duration
+		var arg0 bool
 		if args[0] != nil {
-			arg0 = args[0].(time.Duration)
+			arg0 = args[0].(bool)
 		}
 		run(
 			arg0,
@@ -4481,12 +5092,12 @@ func (_c *Knapsack_SetCachedQueryResultsTTL_Call) Run(run func(ttl time.Duration
 	return _c
 }
 
-func (_c *Knapsack_SetCachedQueryResultsTTL_Call) Return(err error) *Knapsack_SetCachedQueryResultsTTL_Call {
+func (_c *Knapsack_SetControlPushEnabled_Call) Return(err error) *Knapsack_SetControlPushEnabled_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *Knapsack_SetCachedQueryResultsTTL_Call) RunAndReturn(run func(ttl time.Duration) error) *Knapsack_SetCachedQueryResultsTTL_Call {
+func (_c *Knapsack_SetControlPushEnabled_Call) RunAndReturn(run func(enabled bool) error) *Knapsack_SetControlPushEnabled_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -5286,6 +5897,57 @@ func (_c *Knapsack_SetDuplicateLogWindow_Call) RunAndReturn(run func(duration ti
 	return _c
 }
 
+// SetEnforceControlPayloadSignatures provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetEnforceControlPayloadSignatures(enabled bool) error {
+	ret := _mock.Called(enabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetEnforceControlPayloadSignatures")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(bool) error); ok {
+		r0 = returnFunc(enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetEnforceControlPayloadSignatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetEnforceControlPayloadSignatures'
+type Knapsack_SetEnforceControlPayloadSignatures_Call struct {
+	*mock.Call
+}
+
+// SetEnforceControlPayloadSignatures is a helper method to define mock.On call
+//   - enabled bool
+func (_e *Knapsack_Expecter) SetEnforceControlPayloadSignatures(enabled interface{}) *Knapsack_SetEnforceControlPayloadSignatures_Call {
+	return &Knapsack_SetEnforceControlPayloadSignatures_Call{Call: _e.mock.On("SetEnforceControlPayloadSignatures", enabled)}
+}
+
+func (_c *Knapsack_SetEnforceControlPayloadSignatures_Call) Run(run func(enabled bool)) *Knapsack_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 bool
+		if args[0] != nil {
+			arg0 = args[0].(bool)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetEnforceControlPayloadSignatures_Call) Return(err error) *Knapsack_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetEnforceControlPayloadSignatures_Call) RunAndReturn(run func(enabled bool) error) *Knapsack_SetEnforceControlPayloadSignatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetEnrollmentDetails provides a mock function for the type Knapsack
 func (_mock *Knapsack) SetEnrollmentDetails(details types.EnrollmentDetails) {
 	_mock.Called(details)
@@ -6405,6 +7067,52 @@ func (_c *Knapsack_SetOsqueryVerbose_Call) RunAndReturn(run func(verbose bool) e
 	return _c
 }
 
+// SetOsqueryVerboseOverride provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetOsqueryVerboseOverride(value bool, duration time.Duration) {
+	_mock.Called(value, duration)
+	return
+}
+
+// Knapsack_SetOsqueryVerboseOverride_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetOsqueryVerboseOverride'
+type Knapsack_SetOsqueryVerboseOverride_Call struct {
+	*mock.Call
+}
+
+// SetOsqueryVerboseOverride is a helper method to define mock.On call
+//   - value bool
+//   - duration time.Duration
+func (_e *Knapsack_Expecter) SetOsqueryVerboseOverride(value interface{}, duration interface{}) *Knapsack_SetOsqueryVerboseOverride_Call {
+	return &Knapsack_SetOsqueryVerboseOverride_Call{Call: _e.mock.On("SetOsqueryVerboseOverride", value, duration)}
+}
+
+func (_c *Knapsack_SetOsqueryVerboseOverride_Call) Run(run func(value bool, duration time.Duration)) *Knapsack_SetOsqueryVerboseOverride_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 bool
+		if args[0] != nil {
+			arg0 = args[0].(bool)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetOsqueryVerboseOverride_Call) Return() *Knapsack_SetOsqueryVerboseOverride_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Knapsack_SetOsqueryVerboseOverride_Call) RunAndReturn(run func(value bool, duration time.Duration)) *Knapsack_SetOsqueryVerboseOverride_Call {
+	_c.Run(run)
+	return _c
+}
+
 // SetPerformanceMonitoringEnabled provides a mock function for the type Knapsack
 func (_mock *Knapsack) SetPerformanceMonitoringEnabled(enabled bool) error {
 	ret := _mock.Called(enabled)
@@ -6660,6 +7368,57 @@ func (_c *Knapsack_SetSystrayRestartEnabled_Call) RunAndReturn(run func(enabled
 	return _c
 }
 
+// SetTableCacheTTLs provides a mock function for the type Knapsack
+func (_mock *Knapsack) SetTableCacheTTLs(ttls string) error {
+	ret := _mock.Called(ttls)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTableCacheTTLs")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
+		r0 = returnFunc(ttls)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// Knapsack_SetTableCacheTTLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetTableCacheTTLs'
+type Knapsack_SetTableCacheTTLs_Call struct {
+	*mock.Call
+}
+
+// SetTableCacheTTLs is a helper method to define mock.On call
+//   - ttls string
+func (_e *Knapsack_Expecter) SetTableCacheTTLs(ttls interface{}) *Knapsack_SetTableCacheTTLs_Call {
+	return &Knapsack_SetTableCacheTTLs_Call{Call: _e.mock.On("SetTableCacheTTLs", ttls)}
+}
+
+func (_c *Knapsack_SetTableCacheTTLs_Call) Run(run func(ttls string)) *Knapsack_SetTableCacheTTLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *Knapsack_SetTableCacheTTLs_Call) Return(err error) *Knapsack_SetTableCacheTTLs_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *Knapsack_SetTableCacheTTLs_Call) RunAndReturn(run func(ttls string) error) *Knapsack_SetTableCacheTTLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetTableGenerateTimeout provides a mock function for the type Knapsack
 func (_mock *Knapsack) SetTableGenerateTimeout(interval time.Duration) error {
 	ret := _mock.Called(interval)
@@ -7546,6 +8305,50 @@ func (_c *Knapsack_SystrayRestartEnabled_Call) RunAndReturn(run func() bool) *Kn
 	return _c
 }
 
+// TableCacheTTLs provides a mock function for the type Knapsack
+func (_mock *Knapsack) TableCacheTTLs() string {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for TableCacheTTLs")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func() string); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// Knapsack_TableCacheTTLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TableCacheTTLs'
+type Knapsack_TableCacheTTLs_Call struct {
+	*mock.Call
+}
+
+// TableCacheTTLs is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) TableCacheTTLs() *Knapsack_TableCacheTTLs_Call {
+	return &Knapsack_TableCacheTTLs_Call{Call: _e.mock.On("TableCacheTTLs")}
+}
+
+func (_c *Knapsack_TableCacheTTLs_Call) Run(run func()) *Knapsack_TableCacheTTLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_TableCacheTTLs_Call) Return(s string) *Knapsack_TableCacheTTLs_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *Knapsack_TableCacheTTLs_Call) RunAndReturn(run func() string) *Knapsack_TableCacheTTLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // TableGenerateTimeout provides a mock function for the type Knapsack
 func (_mock *Knapsack) TableGenerateTimeout() time.Duration {
 	ret := _mock.Called()
@@ -7590,6 +8393,52 @@ func (_c *Knapsack_TableGenerateTimeout_Call) RunAndReturn(run func() time.Durat
 	return _c
 }
 
+// TableStatsStore provides a mock function for the type Knapsack
+func (_mock *Knapsack) TableStatsStore() types.KVStore {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for TableStatsStore")
+	}
+
+	var r0 types.KVStore
+	if returnFunc, ok := ret.Get(0).(func() types.KVStore); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(types.KVStore)
+		}
+	}
+	return r0
+}
+
+// Knapsack_TableStatsStore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TableStatsStore'
+type Knapsack_TableStatsStore_Call struct {
+	*mock.Call
+}
+
+// TableStatsStore is a helper method to define mock.On call
+func (_e *Knapsack_Expecter) TableStatsStore() *Knapsack_TableStatsStore_Call {
+	return &Knapsack_TableStatsStore_Call{Call: _e.mock.On("TableStatsStore")}
+}
+
+func (_c *Knapsack_TableStatsStore_Call) Run(run func()) *Knapsack_TableStatsStore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Knapsack_TableStatsStore_Call) Return(v types.KVStore) *Knapsack_TableStatsStore_Call {
+	_c.Call.Return(v)
+	return _c
+}
+
+func (_c *Knapsack_TableStatsStore_Call) RunAndReturn(run func() types.KVStore) *Knapsack_TableStatsStore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // TokenStore provides a mock function for the type Knapsack
 func (_mock *Knapsack) TokenStore() types.KVStore {
 	ret := _mock.Called()
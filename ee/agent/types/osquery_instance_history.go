@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // OsqueryInstanceTracker is the interface that we expect knapsack to implement,
 // allowing setting and retrieving of the underlying osquery history
 type OsqueryInstanceTracker interface {
@@ -15,8 +17,32 @@ type OsqueryHistorian interface {
 	LatestInstanceStats(enrollmentId string) (map[string]string, error)
 	LatestInstanceId(enrollmentId string) (string, error)
 	LatestInstanceUptimeMinutes(enrollmentId string) (int64, error)
+	StartupFailuresSince(enrollmentId string, binaryPath string, since time.Time) (int, error)
 	SetConnected(runId string, querier Querier) error
 	SetExited(runId string, exitError error) error
+	SetBinaryPath(runId string, binaryPath string) error
+	RecordResourceUsage(runId string, rssBytes uint64, cpuPercent float64) error
+	SetExitDetails(runId string, exit OsqueryInstanceExit) error
+}
+
+// Reasons an osquery instance may exit, as recorded in osquery instance history
+const (
+	OsqueryExitReasonLauncherShutdown  = "launcher_shutdown"  // launcher is shutting down
+	OsqueryExitReasonLauncherRestart   = "launcher_restart"   // launcher restarted osquery, e.g. after a flag change
+	OsqueryExitReasonWatchdog          = "watchdog"           // the osquery watchdog killed its worker shortly before the exit
+	OsqueryExitReasonCrash             = "crash"              // osqueryd exited on its own with an error, or was killed by a signal
+	OsqueryExitReasonExited            = "exited"             // osqueryd exited on its own without error
+	OsqueryExitReasonComponentExit     = "component_exit"     // another component of the instance (e.g. an extension server) exited, shutting down the instance
+	OsqueryExitReasonLaunchFailed      = "launch_failed"      // the instance did not finish starting up, so launcher shut it down to retry
+	OsqueryExitReasonHealthcheckFailed = "healthcheck_failed" // osquery stopped responding to health checks, so launcher restarted it
+)
+
+// OsqueryInstanceExit describes how and why an osquery instance exited.
+type OsqueryInstanceExit struct {
+	Reason   string // one of the OsqueryExitReason constants
+	Detail   string // more context for the reason, e.g. what prompted a restart
+	ExitCode int    // osqueryd's exit code; -1 if unknown or if osqueryd was killed by a signal
+	Signal   string // the signal that killed osqueryd, if any
 }
 
 //mockery:generate: true
@@ -22,4 +22,7 @@ type Stores interface {
 	EnrollmentStore() KVStore
 	EnrollmentDetailsStore() KVStore
 	ServerReleaseTrackerDataStore() KVStore
+	TableStatsStore() KVStore
+	ControlSubsystemCacheStore() KVStore
+	KeyValueSchemaStore() KVStore
 }
@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"sync/atomic"
 
+	"github.com/kolide/launcher/ee/cgroups"
 	"github.com/kolide/launcher/ee/observability"
 )
 
@@ -32,6 +33,8 @@ func (t *TracedCmd) Start() error {
 	_, span := observability.StartSpan(t.Ctx, "path", t.Path, "args", fmt.Sprintf("%+v", t.Args))
 	defer span.End()
 
+	t.placeInCgroup()
+
 	return t.Cmd.Start() //nolint:forbidigo // This is our approved usage of t.Cmd.Start()
 }
 
@@ -40,6 +43,8 @@ func (t *TracedCmd) Run() error {
 	_, span := observability.StartSpan(t.Ctx, "path", t.Path, "args", fmt.Sprintf("%+v", t.Args))
 	defer span.End()
 
+	t.placeInCgroup()
+
 	return t.Cmd.Run() //nolint:forbidigo // This is our approved usage of t.Cmd.Run()
 }
 
@@ -48,6 +53,8 @@ func (t *TracedCmd) Output() ([]byte, error) {
 	_, span := observability.StartSpan(t.Ctx, "path", t.Path, "args", fmt.Sprintf("%+v", t.Args))
 	defer span.End()
 
+	t.placeInCgroup()
+
 	return t.Cmd.Output() //nolint:forbidigo // This is our approved usage of t.Cmd.Output()
 }
 
@@ -56,9 +63,16 @@ func (t *TracedCmd) CombinedOutput() ([]byte, error) {
 	_, span := observability.StartSpan(t.Ctx, "path", t.Path, "args", fmt.Sprintf("%+v", t.Args))
 	defer span.End()
 
+	t.placeInCgroup()
+
 	return t.Cmd.CombinedOutput() //nolint:forbidigo // This is our approved usage of t.Cmd.CombinedOutput()
 }
 
+// placeInCgroup causes the command to run in the commands cgroup, when cgroup limits are enabled.
+func (t *TracedCmd) placeInCgroup() {
+	cgroups.Get(cgroups.Commands).Apply(t.Cmd)
+}
+
 func newCmd(ctx context.Context, fullPathToCmd string, arg ...string) *TracedCmd {
 	cmd := exec.CommandContext(ctx, fullPathToCmd, arg...) //nolint:forbidigo // This is our approved usage of exec.CommandContext
 	cmd.Env = append(cmd.Environ(), fmt.Sprintf("GOMAXPROCS=%d", cmdGoMaxProcs))
@@ -98,6 +98,10 @@ func Pkgutil(ctx context.Context, arg ...string) (*TracedCmd, error) {
 	return validatedCommand(ctx, "/usr/sbin/pkgutil", arg...)
 }
 
+func Pmset(ctx context.Context, arg ...string) (*TracedCmd, error) {
+	return validatedCommand(ctx, "/usr/bin/pmset", arg...)
+}
+
 func Powermetrics(ctx context.Context, arg ...string) (*TracedCmd, error) {
 	return validatedCommand(ctx, "/usr/bin/powermetrics", arg...)
 }
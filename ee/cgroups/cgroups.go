@@ -0,0 +1,107 @@
+// Package cgroups places osqueryd and the commands launcher runs into cgroup v2 cgroups
+// with CPU, memory, and IO limits, so that launcher's work stays in the background on
+// developer machines. It is only functional on Linux, and only where the cgroup v2 hierarchy
+// has been delegated to launcher (e.g. via `Delegate=yes` in the systemd unit); everywhere
+// else, processes run unconstrained, as they always have.
+package cgroups
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// Osqueryd is the cgroup for osqueryd processes.
+	Osqueryd = "osqueryd"
+	// Commands is the cgroup for commands run via ee/allowedcmd.
+	Commands = "commands"
+)
+
+// ErrUnavailable is returned when cgroup limits cannot be used on this system.
+var ErrUnavailable = errors.New("cgroup v2 limits unavailable")
+
+// Limits are the resource limits applied to each cgroup.
+type Limits struct {
+	// CPUWeight is the cgroup's cpu.weight, between 1 and 10000; 100 is the kernel default.
+	CPUWeight int
+	// MemoryMaxBytes is the cgroup's memory.max. 0 means no limit.
+	MemoryMaxBytes int64
+	// IOWeight is the cgroup's io.weight, between 1 and 10000; 100 is the kernel default.
+	IOWeight int
+}
+
+// defaultLimits are the kernel's defaults, restored when limits are disabled.
+var defaultLimits = Limits{
+	CPUWeight:      100,
+	MemoryMaxBytes: 0,
+	IOWeight:       100,
+}
+
+var (
+	// groupsLock protects groups and enabled.
+	groupsLock sync.Mutex
+	// groups holds every cgroup created so far, by name. Once created, a group is kept
+	// for the life of the process, so that commands being started concurrently with
+	// Disable never reference a closed cgroup.
+	groups = make(map[string]*Group)
+	// enabled is whether new processes should be placed into groups.
+	enabled bool
+)
+
+// Get returns the cgroup with the given name, or nil if cgroup limits are not enabled.
+func Get(name string) *Group {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+
+	if !enabled {
+		return nil
+	}
+	return groups[name]
+}
+
+// Enable creates (or updates) the Osqueryd and Commands cgroups with the given limits,
+// and causes Get to return them. It returns an error wrapping ErrUnavailable if the
+// cgroup v2 hierarchy is not available to launcher, in which case limits stay disabled.
+func Enable(limits Limits) error {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+
+	for _, name := range []string{Osqueryd, Commands} {
+		group, ok := groups[name]
+		if !ok {
+			var err error
+			group, err = newGroup(name)
+			if err != nil {
+				enabled = false
+				return err
+			}
+			groups[name] = group
+		}
+
+		if err := group.setLimits(limits); err != nil {
+			enabled = false
+			return err
+		}
+	}
+
+	enabled = true
+	return nil
+}
+
+// Disable stops new processes from being placed into cgroups, and lifts the limits on
+// processes already running in them.
+func Disable() error {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+
+	enabled = false
+
+	var errs []error
+	for _, group := range groups {
+		if err := group.setLimits(defaultLimits); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package cgroups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	cgroupMountpoint = "/sys/fs/cgroup"
+
+	// leafName is the cgroup launcher moves itself into. cgroup v2 only allows enabling
+	// controllers for the children of a cgroup that has no processes of its own, so launcher
+	// can't stay in the cgroup it was started in.
+	leafName = "launcher"
+)
+
+// controllers are the controllers we set limits with.
+var controllers = []string{"cpu", "memory", "io"}
+
+// delegatedRoot is the cgroup launcher was started in, under which our cgroups are created.
+// Set the first time a group is created; protected by groupsLock.
+var delegatedRoot string
+
+// Group is a cgroup that processes can be placed into.
+type Group struct {
+	name string
+	path string
+	// dir is held open for the life of the process, for use as the CgroupFD of new processes.
+	dir *os.File
+}
+
+// Apply configures cmd so that the process it starts is created directly in this cgroup.
+// It must be called before the command is started. A nil Group leaves cmd unchanged.
+func (g *Group) Apply(cmd *exec.Cmd) {
+	if g == nil || cmd == nil {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.dir.Fd())
+}
+
+func newGroup(name string) (*Group, error) {
+	if delegatedRoot == "" {
+		root, err := setUpDelegatedRoot()
+		if err != nil {
+			return nil, err
+		}
+		delegatedRoot = root
+	}
+
+	path := filepath.Join(delegatedRoot, name)
+	if err := os.Mkdir(path, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: creating cgroup %s: %w", ErrUnavailable, path, err)
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening cgroup %s: %w", ErrUnavailable, path, err)
+	}
+
+	return &Group{
+		name: name,
+		path: path,
+		dir:  dir,
+	}, nil
+}
+
+// setLimits writes the given limits to the cgroup. Limits for controllers that aren't
+// available in the cgroup are skipped.
+func (g *Group) setLimits(limits Limits) error {
+	memoryMax := "max"
+	if limits.MemoryMaxBytes > 0 {
+		memoryMax = strconv.FormatInt(limits.MemoryMaxBytes, 10)
+	}
+
+	files := map[string]string{
+		"cpu.weight": strconv.Itoa(limits.CPUWeight),
+		"memory.max": memoryMax,
+		// io.weight only exists when the kernel's IO cost model is in use
+		"io.weight": fmt.Sprintf("default %d", limits.IOWeight),
+	}
+
+	for file, value := range files {
+		err := os.WriteFile(filepath.Join(g.path, file), []byte(value), 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("setting %s for cgroup %s: %w", file, g.name, err)
+		}
+	}
+
+	return nil
+}
+
+// setUpDelegatedRoot checks that launcher can manage the cgroup it was started in, moves the
+// processes in it into a leaf cgroup, and enables our controllers for its children.
+func setUpDelegatedRoot() (string, error) {
+	if err := checkKernelSupport(); err != nil {
+		return "", err
+	}
+
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(cgroupMountpoint, &statfs); err != nil {
+		return "", fmt.Errorf("%w: checking %s: %w", ErrUnavailable, cgroupMountpoint, err)
+	}
+	if statfs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return "", fmt.Errorf("%w: %s is not a cgroup v2 hierarchy", ErrUnavailable, cgroupMountpoint)
+	}
+
+	current, err := currentCgroup()
+	if err != nil {
+		return "", err
+	}
+	if current == "/" {
+		return "", fmt.Errorf("%w: launcher is running in the root cgroup", ErrUnavailable)
+	}
+	root := filepath.Join(cgroupMountpoint, current)
+
+	// If we set up this cgroup before -- i.e. launcher's cgroup is already our leaf -- reuse it.
+	if filepath.Base(root) == leafName {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(root), Osqueryd)); err == nil {
+			return filepath.Dir(root), nil
+		}
+	}
+
+	leaf := filepath.Join(root, leafName)
+	if err := os.Mkdir(leaf, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("%w: cgroup %s is not delegated to launcher: %w", ErrUnavailable, root, err)
+	}
+
+	// Move launcher first: if that fails, the hierarchy isn't ours to manage. Other processes
+	// in the cgroup (e.g. a running osqueryd) may exit while we move them, so their errors
+	// are ignored.
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		_ = os.Remove(leaf)
+		return "", fmt.Errorf("%w: moving launcher into cgroup %s: %w", ErrUnavailable, leaf, err)
+	}
+	if pids, err := os.ReadFile(filepath.Join(root, "cgroup.procs")); err == nil {
+		for _, pid := range strings.Fields(string(pids)) {
+			_ = os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0)
+		}
+	}
+
+	available, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("%w: reading available controllers: %w", ErrUnavailable, err)
+	}
+	var enable []string
+	for _, controller := range strings.Fields(string(available)) {
+		for _, wanted := range controllers {
+			if controller == wanted {
+				enable = append(enable, "+"+controller)
+			}
+		}
+	}
+	if len(enable) == 0 {
+		return "", fmt.Errorf("%w: none of %v are available in cgroup %s", ErrUnavailable, controllers, root)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0); err != nil {
+		return "", fmt.Errorf("%w: enabling controllers in cgroup %s: %w", ErrUnavailable, root, err)
+	}
+
+	return root, nil
+}
+
+// currentCgroup returns launcher's cgroup v2 path, relative to the cgroup mountpoint.
+func currentCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("%w: reading /proc/self/cgroup: %w", ErrUnavailable, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The cgroup v2 entry has hierarchy ID 0 and no controllers
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no cgroup v2 entry in /proc/self/cgroup", ErrUnavailable)
+}
+
+// checkKernelSupport verifies that processes can be created directly in a cgroup, which
+// requires clone3 with CLONE_INTO_CGROUP (Linux 5.7). The Go runtime does not fall back
+// when clone3 is unavailable -- e.g. blocked by a container's seccomp profile -- so starting
+// commands would fail outright.
+func checkKernelSupport() error {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return fmt.Errorf("%w: getting kernel version: %w", ErrUnavailable, err)
+	}
+	release := unix.ByteSliceToString(uname.Release[:])
+	var major, minor int
+	if _, err := fmt.Sscanf(release, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("%w: parsing kernel version %s: %w", ErrUnavailable, release, err)
+	}
+	if major < 5 || (major == 5 && minor < 7) {
+		return fmt.Errorf("%w: kernel %s does not support CLONE_INTO_CGROUP", ErrUnavailable, release)
+	}
+
+	// clone3 with no arguments fails with EINVAL when it is available
+	if _, _, errno := unix.Syscall(unix.SYS_CLONE3, 0, 0, 0); errno != unix.EINVAL {
+		return fmt.Errorf("%w: clone3 is unavailable: %w", ErrUnavailable, errno)
+	}
+
+	return nil
+}
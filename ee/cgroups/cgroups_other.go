@@ -0,0 +1,23 @@
+//go:build !linux
+
+package cgroups
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Group is a cgroup that processes can be placed into. cgroups only exist on Linux.
+type Group struct{}
+
+// Apply is a no-op outside of Linux.
+func (g *Group) Apply(cmd *exec.Cmd) {}
+
+func newGroup(name string) (*Group, error) {
+	return nil, fmt.Errorf("%w: cgroups are not supported on %s", ErrUnavailable, runtime.GOOS)
+}
+
+func (g *Group) setLimits(limits Limits) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package cgroups
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet_NotEnabled(t *testing.T) { // nolint:paralleltest
+	require.NoError(t, Disable())
+	require.Nil(t, Get(Osqueryd))
+	require.Nil(t, Get(Commands))
+
+	// A nil group leaves commands untouched
+	var group *Group
+	group.Apply(nil)
+}
+
+func TestEnable(t *testing.T) { // nolint:paralleltest
+	t.Cleanup(func() { require.NoError(t, Disable()) })
+
+	err := Enable(Limits{CPUWeight: 20, MemoryMaxBytes: 512 * 1024 * 1024, IOWeight: 20})
+	if err != nil {
+		// Most test environments do not delegate a cgroup hierarchy to us
+		require.ErrorIs(t, err, ErrUnavailable)
+		require.Nil(t, Get(Osqueryd))
+		require.Nil(t, Get(Commands))
+		return
+	}
+
+	require.NotNil(t, Get(Osqueryd))
+	require.NotNil(t, Get(Commands))
+
+	require.NoError(t, Disable())
+	require.Nil(t, Get(Osqueryd))
+}
@@ -0,0 +1,159 @@
+// Package compression implements negotiated compression of request bodies sent
+// to the control plane. Servers advertise the content codings they can accept via
+// an Accept-Encoding header on their responses (RFC 7694); until a server has done
+// so, requests are sent uncompressed.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Identity = "identity"
+	Gzip     = "gzip"
+	Zstd     = "zstd"
+)
+
+// preference lists supported encodings from most to least preferred.
+var preference = []string{Zstd, Gzip}
+
+// AcceptEncoding is the value servers should advertise to receive compressed requests.
+var AcceptEncoding = strings.Join(preference, ", ")
+
+// Negotiator tracks the best encoding the server has told us it accepts. It is
+// safe for concurrent use.
+type Negotiator struct {
+	mu       sync.RWMutex
+	encoding string
+}
+
+func NewNegotiator() *Negotiator {
+	return &Negotiator{encoding: Identity}
+}
+
+// Encoding returns the encoding to use for the next request.
+func (n *Negotiator) Encoding() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.encoding
+}
+
+// Observe updates the negotiated encoding from a server response's headers. Responses
+// without an Accept-Encoding header leave the current choice unchanged.
+func (n *Negotiator) Observe(header http.Header) {
+	values := header.Values("Accept-Encoding")
+	if len(values) == 0 {
+		return
+	}
+
+	encoding := selectEncoding(values)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.encoding = encoding
+}
+
+// Reset falls back to sending uncompressed requests, for example after the server
+// rejected a compressed one.
+func (n *Negotiator) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.encoding = Identity
+}
+
+// selectEncoding picks our most preferred encoding out of Accept-Encoding header values.
+// Codings with a q-value of 0 are treated as not accepted.
+func selectEncoding(values []string) string {
+	accepted := make(map[string]bool)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok && strings.Trim(q, "0.") == "" {
+				continue
+			}
+			accepted[coding] = true
+		}
+	}
+
+	for _, encoding := range preference {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+
+	return Identity
+}
+
+// Compress encodes data with the given encoding.
+func Compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case Identity, "":
+		return data, nil
+	case Gzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip compressing: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("closing gzip writer: %w", err)
+		}
+	case Zstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd writer: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("zstd compressing: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("closing zstd writer: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewReader returns a reader that decodes r according to encoding. maxBytes bounds
+// the decoded size, to protect servers from decompression bombs.
+func NewReader(encoding string, r io.Reader, maxBytes int64) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case Identity, "":
+		return io.NopCloser(io.LimitReader(r, maxBytes)), nil
+	case Gzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip reader: %w", err)
+		}
+		return limitedReadCloser{io.LimitReader(gr, maxBytes), gr.Close}, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd reader: %w", err)
+		}
+		return limitedReadCloser{io.LimitReader(zr, maxBytes), func() error { zr.Close(); return nil }}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (l limitedReadCloser) Close() error {
+	return l.close()
+}
@@ -0,0 +1,149 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectEncoding(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name     string
+		values   []string
+		expected string
+	}{
+		{name: "zstd preferred", values: []string{"gzip, zstd"}, expected: Zstd},
+		{name: "gzip only", values: []string{"gzip"}, expected: Gzip},
+		{name: "multiple headers", values: []string{"br", "GZIP"}, expected: Gzip},
+		{name: "q zero excluded", values: []string{"zstd;q=0, gzip;q=0.5"}, expected: Gzip},
+		{name: "nothing supported", values: []string{"br, deflate"}, expected: Identity},
+		{name: "identity", values: []string{"identity"}, expected: Identity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, selectEncoding(tt.values))
+		})
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(strings.Repeat(`{"name":"pack:kolide:processes","columns":{"pid":"1"}}`, 100))
+
+	for _, encoding := range []string{Identity, Gzip, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+
+			compressed, err := Compress(encoding, data)
+			require.NoError(t, err)
+			if encoding != Identity {
+				require.Less(t, len(compressed), len(data))
+			}
+
+			r, err := NewReader(encoding, bytes.NewReader(compressed), int64(len(data)))
+			require.NoError(t, err)
+			defer r.Close()
+
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, data, decoded)
+		})
+	}
+
+	_, err := Compress("br", data)
+	require.Error(t, err)
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var gotEncodings []string
+	var gotBodies []string
+	rejectCompressed := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if rejectCompressed && r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		gotEncodings = append(gotEncodings, r.Header.Get("Content-Encoding"))
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			gotBodies = append(gotBodies, string(body))
+		})).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	negotiator := NewNegotiator()
+	client := &http.Client{Transport: NewTransport(nil, negotiator)}
+
+	body := strings.Repeat("a", 4096)
+	post := func() {
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// Before the server has advertised anything, requests are uncompressed
+	post()
+	require.Equal(t, Zstd, negotiator.Encoding())
+
+	// Then compressed with the negotiated encoding
+	post()
+
+	// Small bodies are never compressed
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// If the server stops accepting compression, we retry uncompressed and back off
+	mu.Lock()
+	rejectCompressed = true
+	mu.Unlock()
+	post()
+	require.Equal(t, Zstd, negotiator.Encoding(), "server still advertises zstd on its successful response")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"", Zstd, "", ""}, gotEncodings)
+	require.Equal(t, []string{body, body, "{}", body}, gotBodies)
+}
+
+func TestHandler_UnsupportedEncoding(t *testing.T) {
+	t.Parallel()
+
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "br")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	require.Equal(t, AcceptEncoding, rec.Header().Get("Accept-Encoding"))
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", Gzip)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
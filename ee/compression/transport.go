@@ -0,0 +1,128 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// minCompressBytes is the smallest body worth compressing.
+	minCompressBytes = 1024
+
+	// maxDecodedBytes bounds the decoded size of a request body in Handler.
+	maxDecodedBytes = 128 << 20
+)
+
+type transport struct {
+	next       http.RoundTripper
+	negotiator *Negotiator
+}
+
+// NewTransport wraps next, compressing request bodies with the encoding negotiated
+// with the server. If the server rejects a compressed request with 415 Unsupported
+// Media Type, the request is retried uncompressed and compression is disabled until
+// the server advertises support again.
+func NewTransport(next http.RoundTripper, negotiator *Negotiator) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &transport{
+		next:       next,
+		negotiator: negotiator,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoding := t.negotiator.Encoding()
+	if req.Body == nil || req.Body == http.NoBody || encoding == Identity || req.Header.Get("Content-Encoding") != "" {
+		return t.roundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+
+	if len(body) < minCompressBytes {
+		return t.roundTrip(withBody(req, body, ""))
+	}
+
+	compressed, err := Compress(encoding, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.roundTrip(withBody(req, compressed, encoding))
+	if err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		return resp, err
+	}
+
+	// The server no longer accepts this encoding
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.negotiator.Reset()
+
+	return t.roundTrip(withBody(req, body, ""))
+}
+
+func (t *transport) roundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.negotiator.Observe(resp.Header)
+	return resp, nil
+}
+
+// withBody returns a copy of req with the given body and Content-Encoding.
+func withBody(req *http.Request, body []byte, encoding string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+	if encoding != "" {
+		r.Header.Set("Content-Encoding", encoding)
+	}
+	return r
+}
+
+// Handler is the server side of the negotiation: it decodes compressed request
+// bodies for next, and advertises the encodings it accepts on every response.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Encoding", AcceptEncoding)
+
+		encoding := r.Header.Get("Content-Encoding")
+		if encoding == "" || encoding == Identity {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if encoding != Gzip && encoding != Zstd {
+			http.Error(w, fmt.Sprintf("unsupported content encoding %q", encoding), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := NewReader(encoding, r.Body, maxDecodedBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+
+		r = r.Clone(r.Context())
+		r.Body = body
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		next.ServeHTTP(w, r)
+	})
+}
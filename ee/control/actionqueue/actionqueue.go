@@ -1,13 +1,14 @@
 package actionqueue
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kolide/launcher/ee/agent/storage/inmemory"
@@ -30,10 +31,24 @@ type actor interface {
 }
 
 type action struct {
-	ID          string    `json:"id"`
-	ValidUntil  int64     `json:"valid_until"` // timestamp
-	Type        string    `json:"type"`
-	ProcessedAt time.Time `json:"processed_at,omitempty"`
+	ID              string    `json:"id"`
+	ValidUntil      int64     `json:"valid_until"`                 // timestamp
+	NotBefore       int64     `json:"not_before,omitempty"`        // timestamp; the action will not be performed before this time
+	RequiresIdle    bool      `json:"requires_idle,omitempty"`     // only perform the action while the device is idle
+	RequiresACPower bool      `json:"requires_ac_power,omitempty"` // only perform the action while the device is on AC power
+	MaxAttempts     int       `json:"max_attempts,omitempty"`      // if set, retry a failed action locally up to this many attempts
+	Type            string    `json:"type"`
+	ProcessedAt     time.Time `json:"processed_at,omitempty"`
+	// Result records the outcome of the most recent attempt to perform the action. An action
+	// that failed has a Result but no ProcessedAt, and will be re-attempted if sent again -- or, if
+	// it has remaining MaxAttempts, retried locally once its retry delay has passed. An action that
+	// an asyncActor is performing in the background has a running Result until the actor finishes it.
+	Result *actionResult `json:"result,omitempty"`
+	// RawAction holds the action as received from the control server while it is waiting to be
+	// performed (because it is scheduled, conditional, or pending a retry) or is running in the
+	// background, so that it survives launcher restarts. It is cleared once the action is no
+	// longer pending.
+	RawAction json.RawMessage `json:"raw_action,omitempty"`
 }
 
 func (a action) String() string {
@@ -42,12 +57,25 @@ func (a action) String() string {
 
 type ActionQueue struct {
 	ctx                   context.Context // nolint:containedctx
+	knapsack              types.Knapsack
 	actors                map[string]actor
 	store                 types.KVStore
 	oldNotificationsStore types.KVStore
 	slogger               *slog.Logger
 	actionCleanupInterval time.Duration
 	cancel                context.CancelFunc
+	recordLock            sync.Mutex // guards read-modify-write of action records
+	resultReporter        resultReporter
+	resultReportInterval  time.Duration
+	reportNow             chan struct{}
+	reportInterrupt       chan struct{}
+	reportInterrupted     *atomic.Bool
+	processLock           sync.Mutex // serializes performing actions
+	scheduleInterval      time.Duration
+	scheduleInterrupt     chan struct{}
+	scheduleInterrupted   *atomic.Bool
+	systemIdle            func(context.Context) (bool, error)
+	onACPower             func(context.Context) (bool, error)
 }
 
 type actionqueueOption func(*ActionQueue)
@@ -79,9 +107,19 @@ func WithContext(ctx context.Context) actionqueueOption {
 func New(k types.Knapsack, opts ...actionqueueOption) *ActionQueue {
 	aq := &ActionQueue{
 		ctx:                   context.Background(),
+		knapsack:              k,
 		actors:                make(map[string]actor, 0),
 		actionCleanupInterval: defaultCleanupInterval,
 		slogger:               k.Slogger().With("component", "actionqueue"),
+		resultReportInterval:  defaultResultReportInterval,
+		reportNow:             make(chan struct{}, 1),
+		reportInterrupt:       make(chan struct{}, 1),
+		reportInterrupted:     &atomic.Bool{},
+		scheduleInterval:      defaultScheduleInterval,
+		scheduleInterrupt:     make(chan struct{}, 1),
+		scheduleInterrupted:   &atomic.Bool{},
+		systemIdle:            systemIdle,
+		onACPower:             onACPower,
 	}
 
 	for _, opt := range opts {
@@ -128,18 +166,9 @@ func (aq *ActionQueue) Update(data io.Reader) error {
 			continue
 		}
 
-		if err := actor.Do(bytes.NewReader(rawAction)); err != nil {
-			aq.slogger.Log(context.TODO(), slog.LevelInfo,
-				"failed to do action with action, not marking action complete",
-				"err", err,
-			)
+		if err := aq.processAction(context.TODO(), action, actor, rawAction); err != nil {
 			processError = fmt.Errorf("actor.Do, action type: %s, failed: %w", action.Type, err)
-			continue
 		}
-
-		// only mark processed when actor was successful
-		action.ProcessedAt = time.Now().UTC()
-		aq.storeActionRecord(action)
 	}
 
 	return processError
@@ -179,6 +208,33 @@ func (aq *ActionQueue) StopCleanup(err error) {
 }
 
 func (aq *ActionQueue) storeActionRecord(actionToStore action) {
+	aq.recordLock.Lock()
+	defer aq.recordLock.Unlock()
+
+	aq.setActionRecord(actionToStore)
+}
+
+// actionRecord returns the stored record for the given action ID, if any.
+func (aq *ActionQueue) actionRecord(id string) (action, bool, error) {
+	var a action
+
+	rawAction, err := aq.store.Get([]byte(id))
+	if err != nil {
+		return a, false, fmt.Errorf("reading action from store: %w", err)
+	}
+	if rawAction == nil {
+		return a, false, nil
+	}
+
+	if err := json.Unmarshal(rawAction, &a); err != nil {
+		return a, false, fmt.Errorf("unmarshalling action: %w", err)
+	}
+
+	return a, true, nil
+}
+
+// setActionRecord stores the given action; callers must hold recordLock.
+func (aq *ActionQueue) setActionRecord(actionToStore action) {
 	rawAction, err := json.Marshal(actionToStore)
 	if err != nil {
 		aq.slogger.Log(context.TODO(), slog.LevelError,
@@ -206,9 +262,15 @@ func (aq *ActionQueue) isActionNew(id string) bool {
 		return false
 	}
 
-	// found previous record, action not new
+	// found previous record of a completed action, action not new
 	if completedActionRaw != nil {
-		return false
+		var previous action
+		if err := json.Unmarshal(completedActionRaw, &previous); err != nil || !previous.ProcessedAt.IsZero() {
+			return false
+		}
+
+		// The action is still pending, or its previous attempt failed, so it may be (re-)attempted
+		return true
 	}
 
 	// the first "actions" were actually notifications
@@ -285,7 +347,17 @@ func (aq *ActionQueue) cleanupActions() {
 			return fmt.Errorf("error processing %s: %w", string(k), err)
 		}
 
-		if processedAction.ProcessedAt.Add(actionRetentionPeriod).Before(time.Now().UTC()) {
+		// Pending actions are expired by the scheduler once they are no longer valid
+		if len(processedAction.RawAction) > 0 && processedAction.ProcessedAt.IsZero() {
+			return nil
+		}
+
+		lastUpdated := processedAction.ProcessedAt
+		if lastUpdated.IsZero() && processedAction.Result != nil {
+			lastUpdated = processedAction.Result.FinishedAt
+		}
+
+		if lastUpdated.Add(actionRetentionPeriod).Before(time.Now().UTC()) {
 			keysToDelete = append(keysToDelete, k)
 		}
 
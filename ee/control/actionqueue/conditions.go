@@ -0,0 +1,56 @@
+package actionqueue
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// minIdleDuration is how long the device must have gone without user input to satisfy
+	// an action's `requires_idle` condition.
+	minIdleDuration = 10 * time.Minute
+)
+
+// readyToRun reports whether the given action's schedule and conditions currently permit
+// performing it. If not, it also returns the reason why.
+func (aq *ActionQueue) readyToRun(ctx context.Context, a action) (bool, string) {
+	if a.NotBefore > 0 && time.Now().Unix() < a.NotBefore {
+		return false, "not_before is in the future"
+	}
+
+	if a.RequiresACPower {
+		onAC, err := aq.onACPower(ctx)
+		if err != nil {
+			aq.slogger.Log(ctx, slog.LevelWarn,
+				"could not determine whether device is on AC power",
+				"err", err,
+			)
+			return false, "could not determine power source"
+		}
+		if !onAC {
+			return false, "device is not on AC power"
+		}
+	}
+
+	if a.RequiresIdle {
+		// A device in modern standby is asleep as far as the user is concerned
+		if aq.knapsack.InModernStandby() {
+			return true, ""
+		}
+
+		idle, err := aq.systemIdle(ctx)
+		if err != nil {
+			aq.slogger.Log(ctx, slog.LevelWarn,
+				"could not determine whether device is idle",
+				"err", err,
+			)
+			return false, "could not determine idle state"
+		}
+		if !idle {
+			return false, "device is not idle"
+		}
+	}
+
+	return true, ""
+}
@@ -0,0 +1,58 @@
+//go:build darwin
+
+package actionqueue
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kolide/launcher/ee/allowedcmd"
+)
+
+var hidIdleTimeRegex = regexp.MustCompile(`"HIDIdleTime" = (\d+)`)
+
+// systemIdle reports whether the device has gone without user input for at least minIdleDuration,
+// according to the HID system's idle time.
+func systemIdle(ctx context.Context) (bool, error) {
+	cmd, err := allowedcmd.Ioreg(ctx, "-c", "IOHIDSystem", "-r", "-d", "1", "-k", "HIDIdleTime")
+	if err != nil {
+		return false, fmt.Errorf("creating ioreg command: %w", err)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("running ioreg: %w", err)
+	}
+
+	matches := hidIdleTimeRegex.FindSubmatch(out)
+	if matches == nil {
+		return false, fmt.Errorf("HIDIdleTime not found in ioreg output")
+	}
+
+	idleNanoseconds, err := strconv.ParseInt(string(matches[1]), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parsing HIDIdleTime %s: %w", string(matches[1]), err)
+	}
+
+	return time.Duration(idleNanoseconds) >= minIdleDuration, nil
+}
+
+// onACPower reports whether the device is currently drawing from AC power.
+func onACPower(ctx context.Context) (bool, error) {
+	cmd, err := allowedcmd.Pmset(ctx, "-g", "ps")
+	if err != nil {
+		return false, fmt.Errorf("creating pmset command: %w", err)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("running pmset: %w", err)
+	}
+
+	// The first line looks like `Now drawing from 'AC Power'` or `Now drawing from 'Battery Power'`
+	return strings.Contains(string(out), "'AC Power'"), nil
+}
@@ -0,0 +1,107 @@
+//go:build linux
+
+package actionqueue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kolide/launcher/ee/allowedcmd"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// systemIdle reports whether no local graphical session is in use, according to logind's idle hint.
+// A device with no local graphical sessions is considered idle.
+func systemIdle(ctx context.Context) (bool, error) {
+	listCmd, err := allowedcmd.Loginctl(ctx, "list-sessions", "--no-legend", "--no-pager")
+	if err != nil {
+		return false, fmt.Errorf("creating loginctl command: %w", err)
+	}
+
+	out, err := listCmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("loginctl list-sessions: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		showCmd, err := allowedcmd.Loginctl(ctx,
+			"show-session", fields[0],
+			"--property=Type",
+			"--property=Remote",
+			"--property=Active",
+			"--property=IdleHint",
+		)
+		if err != nil {
+			return false, fmt.Errorf("creating loginctl command: %w", err)
+		}
+
+		sessionOut, err := showCmd.Output()
+		if err != nil {
+			return false, fmt.Errorf("loginctl show-session (for sessionId %s): %w", fields[0], err)
+		}
+
+		session := string(sessionOut)
+		isGraphical := strings.Contains(session, "Type=x11") || strings.Contains(session, "Type=wayland")
+		if !isGraphical || !strings.Contains(session, "Remote=no") || !strings.Contains(session, "Active=yes") {
+			continue
+		}
+
+		if !strings.Contains(session, "IdleHint=yes") {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// onACPower reports whether the device is currently on AC power. Devices without any
+// power supply information (e.g. most servers and VMs) are assumed to be on AC power.
+func onACPower(_ context.Context) (bool, error) {
+	supplies, err := os.ReadDir(powerSupplyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("reading %s: %w", powerSupplyDir, err)
+	}
+
+	foundMains := false
+	discharging := false
+	for _, supply := range supplies {
+		supplyType := readPowerSupplyAttribute(supply.Name(), "type")
+		switch supplyType {
+		case "Mains":
+			foundMains = true
+			if readPowerSupplyAttribute(supply.Name(), "online") == "1" {
+				return true, nil
+			}
+		case "Battery":
+			if readPowerSupplyAttribute(supply.Name(), "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+
+	// If we found a mains supply, it wasn't online; otherwise, fall back to the battery status
+	return !foundMains && !discharging, nil
+}
+
+func readPowerSupplyAttribute(supply, attribute string) string {
+	value, err := os.ReadFile(filepath.Join(powerSupplyDir, supply, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
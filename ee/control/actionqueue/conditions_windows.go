@@ -0,0 +1,54 @@
+//go:build windows
+
+package actionqueue
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// systemPowerStatus mirrors SYSTEM_POWER_STATUS, see
+// https://learn.microsoft.com/en-us/windows/win32/api/winbase/ns-winbase-system_power_status
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+const (
+	acLineStatusOffline = 0
+	acLineStatusOnline  = 1
+)
+
+// systemIdle always reports false on Windows: launcher runs as a service in session 0, so it
+// cannot observe user input directly. Instead, `requires_idle` is satisfied on Windows only
+// while the device is in modern standby.
+func systemIdle(_ context.Context) (bool, error) {
+	return false, nil
+}
+
+// onACPower reports whether the device is currently on AC power.
+func onACPower(_ context.Context) (bool, error) {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getSystemPowerStatusProc := kernel32.NewProc("GetSystemPowerStatus")
+
+	var status systemPowerStatus
+	r1, _, err := getSystemPowerStatusProc.Call(uintptr(unsafe.Pointer(&status)))
+	if r1 == 0 {
+		return false, fmt.Errorf("could not call GetSystemPowerStatus: %w", err)
+	}
+
+	switch status.ACLineStatus {
+	case acLineStatusOnline:
+		return true, nil
+	case acLineStatusOffline:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown AC line status %d", status.ACLineStatus)
+	}
+}
@@ -0,0 +1,318 @@
+package actionqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/kolide/launcher/ee/observability"
+)
+
+const (
+	ActionStatusSucceeded = "succeeded"
+	ActionStatusFailed    = "failed"
+	ActionStatusExpired   = "expired" // the action's valid_until passed while it was pending
+	ActionStatusRunning   = "running" // an asyncActor is still performing the action in the background
+
+	// maxResultPayloadBytes bounds the payload an actor may attach to its result; larger
+	// payloads are dropped, since results are reported in batches.
+	maxResultPayloadBytes = 64 * 1024
+	// maxResultsPerReport bounds the number of results we send to the control server at once.
+	maxResultsPerReport = 50
+	// defaultResultReportInterval is how often we retry reporting results that the control
+	// server has not yet acknowledged.
+	defaultResultReportInterval = 1 * time.Minute
+)
+
+// resultActor is an optional interface for actors that return a small payload describing the
+// action's result (e.g. a query's output), to be reported to the control server.
+type resultActor interface {
+	DoWithResult(data io.Reader) (json.RawMessage, error)
+}
+
+// asyncActor is an optional interface for actors that keep performing an action in the background
+// after returning. DoAsync returns once the action has started, or with an error if it could not
+// be started; the actor reports the action's outcome later with FinishAction. If the actor has not
+// finished the action within AsyncTimeout -- e.g. because launcher restarted while it was running --
+// the attempt is counted as failed, and the action is retried if it allows it.
+type asyncActor interface {
+	DoAsync(data io.Reader) error
+	AsyncTimeout() time.Duration
+}
+
+// resultReporter sends action results to the control server.
+type resultReporter interface {
+	SendActionResults(ctx context.Context, results any) error
+}
+
+// actionResult records the outcome of the most recent attempt to perform an action.
+type actionResult struct {
+	ActionID       string          `json:"action_id"`
+	Type           string          `json:"type"`
+	Status         string          `json:"status"` // ActionStatusSucceeded, ActionStatusFailed, ActionStatusExpired, or ActionStatusRunning
+	Error          string          `json:"error,omitempty"`
+	Attempts       int             `json:"attempts"`
+	StartedAt      time.Time       `json:"started_at"`
+	FinishedAt     time.Time       `json:"finished_at"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	PayloadDropped bool            `json:"payload_dropped,omitempty"` // the payload exceeded maxResultPayloadBytes
+	ReportedAt     time.Time       `json:"reported_at,omitzero"`      // when the control server acknowledged this result
+}
+
+func WithResultReporter(reporter resultReporter) actionqueueOption {
+	return func(aq *ActionQueue) {
+		aq.resultReporter = reporter
+	}
+}
+
+func WithResultReportInterval(interval time.Duration) actionqueueOption {
+	return func(aq *ActionQueue) {
+		aq.resultReportInterval = interval
+	}
+}
+
+// doAction performs the action with the given actor, and returns a record of the result.
+func (aq *ActionQueue) doAction(a action, actorForAction actor, rawAction []byte, previousAttempts int) (*actionResult, error) {
+	result := &actionResult{
+		ActionID:  a.ID,
+		Type:      a.Type,
+		Attempts:  previousAttempts + 1,
+		StartedAt: time.Now().UTC(),
+	}
+
+	var payload json.RawMessage
+	var err error
+	if ra, ok := actorForAction.(resultActor); ok {
+		payload, err = ra.DoWithResult(bytes.NewReader(rawAction))
+	} else {
+		err = actorForAction.Do(bytes.NewReader(rawAction))
+	}
+	result.finish(payload, err)
+
+	return result, err
+}
+
+// startAsyncAction records the action as running, then has the asyncActor start performing it.
+// The record must exist before the actor starts, since the actor may finish the action at any
+// time after that.
+func (aq *ActionQueue) startAsyncAction(a action, actorForAction asyncActor, rawAction []byte, previousAttempts int) error {
+	a.Result = &actionResult{
+		ActionID:  a.ID,
+		Type:      a.Type,
+		Status:    ActionStatusRunning,
+		Attempts:  previousAttempts + 1,
+		StartedAt: time.Now().UTC(),
+	}
+	// Hold on to the action until it finishes, so that we can retry it if it doesn't
+	a.RawAction = rawAction
+	aq.storeActionRecord(a)
+
+	if err := actorForAction.DoAsync(bytes.NewReader(rawAction)); err != nil {
+		// The actor never started, so it won't finish the action -- record the failure for it
+		if finishErr := aq.FinishAction(a.ID, nil, err); finishErr != nil {
+			aq.slogger.Log(context.TODO(), slog.LevelWarn,
+				"could not record failure to start action",
+				"action", a.String(),
+				"err", finishErr,
+			)
+		}
+		return err
+	}
+
+	aq.signalReport()
+	return nil
+}
+
+// FinishAction records the outcome of an action that an asyncActor performed in the background,
+// and reports it to the control server. Because action records are persisted, actors may finish
+// actions that they started before launcher restarted.
+func (aq *ActionQueue) FinishAction(actionID string, payload json.RawMessage, actionErr error) error {
+	aq.recordLock.Lock()
+	defer aq.recordLock.Unlock()
+
+	a, found, err := aq.actionRecord(actionID)
+	if err != nil {
+		return fmt.Errorf("getting action record: %w", err)
+	}
+	if !found || a.Result == nil {
+		return fmt.Errorf("no record of action %s", actionID)
+	}
+	if a.Result.Status != ActionStatusRunning {
+		return fmt.Errorf("action %s is not running, has status %s", actionID, a.Result.Status)
+	}
+
+	a.Result.finish(payload, actionErr)
+	a.Result.ReportedAt = time.Time{}
+	a.completeAttempt(a.RawAction)
+	aq.setActionRecord(a)
+	aq.signalReport()
+
+	return nil
+}
+
+// failTimedOutAction records that the given running action was not finished within its actor's
+// AsyncTimeout, unless it was finished in the meantime.
+func (aq *ActionQueue) failTimedOutAction(ctx context.Context, a action, timeout time.Duration) {
+	err := fmt.Errorf("action did not finish within %s", timeout.String())
+	if finishErr := aq.FinishAction(a.ID, nil, err); finishErr != nil {
+		// Most likely, the actor finished the action while we were checking on it
+		aq.slogger.Log(ctx, slog.LevelDebug,
+			"could not record timed out action",
+			"action", a.String(),
+			"err", finishErr,
+		)
+		return
+	}
+
+	aq.slogger.Log(ctx, slog.LevelInfo,
+		"running action did not finish in time, counted attempt as failed",
+		"action", a.String(),
+		"timeout", timeout.String(),
+	)
+}
+
+// finish records the outcome of an attempt to perform the action.
+func (r *actionResult) finish(payload json.RawMessage, err error) {
+	r.FinishedAt = time.Now().UTC()
+
+	r.Payload = payload
+	if len(r.Payload) > maxResultPayloadBytes {
+		r.Payload = nil
+		r.PayloadDropped = true
+	}
+
+	if err != nil {
+		r.Status = ActionStatusFailed
+		r.Error = err.Error()
+		return
+	}
+
+	r.Status = ActionStatusSucceeded
+}
+
+// StartResultReporting reports action results to the control server as actions are performed,
+// and periodically retries any results that the control server has not yet acknowledged. It
+// runs until StopResultReporting is called.
+func (aq *ActionQueue) StartResultReporting() error {
+	if aq.resultReporter == nil {
+		<-aq.reportInterrupt
+		return nil
+	}
+
+	ticker := time.NewTicker(aq.resultReportInterval)
+	defer ticker.Stop()
+
+	for {
+		aq.reportResults(context.TODO())
+
+		select {
+		case <-aq.reportInterrupt:
+			aq.slogger.Log(context.TODO(), slog.LevelDebug,
+				"action result reporting stopped due to interrupt",
+			)
+			return nil
+		case <-ticker.C:
+		case <-aq.reportNow:
+		}
+	}
+}
+
+func (aq *ActionQueue) StopResultReporting(_ error) {
+	// Only perform shutdown tasks on first call to interrupt -- no need to repeat on potential extra calls.
+	if aq.reportInterrupted.Swap(true) {
+		return
+	}
+
+	aq.reportInterrupt <- struct{}{}
+}
+
+// signalReport asks the reporting loop to report results now, rather than waiting for its next tick.
+func (aq *ActionQueue) signalReport() {
+	select {
+	case aq.reportNow <- struct{}{}:
+	default:
+	}
+}
+
+// reportResults sends a batch of unacknowledged results to the control server, and marks
+// them acknowledged if the server accepts them.
+func (aq *ActionQueue) reportResults(ctx context.Context) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	results, err := aq.unreportedResults()
+	if err != nil {
+		aq.slogger.Log(ctx, slog.LevelWarn,
+			"could not read unreported action results",
+			"err", err,
+		)
+		return
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	if err := aq.resultReporter.SendActionResults(ctx, results); err != nil {
+		observability.SetError(span, err)
+		aq.slogger.Log(ctx, slog.LevelDebug,
+			"could not report action results, will retry",
+			"result_count", len(results),
+			"err", err,
+		)
+		return
+	}
+
+	aq.markResultsReported(results, time.Now().UTC())
+}
+
+// unreportedResults returns up to maxResultsPerReport results that the control server has
+// not yet acknowledged, oldest first.
+func (aq *ActionQueue) unreportedResults() ([]actionResult, error) {
+	aq.recordLock.Lock()
+	defer aq.recordLock.Unlock()
+
+	results := make([]actionResult, 0)
+	if err := aq.store.ForEach(func(k, v []byte) error {
+		var a action
+		if err := json.Unmarshal(v, &a); err != nil {
+			// Skip this record rather than blocking all reports on it
+			return nil
+		}
+
+		if a.Result != nil && a.Result.ReportedAt.IsZero() {
+			results = append(results, *a.Result)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("iterating over actions: %w", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].FinishedAt.Before(results[j].FinishedAt) })
+	if len(results) > maxResultsPerReport {
+		results = results[:maxResultsPerReport]
+	}
+
+	return results, nil
+}
+
+// markResultsReported records that the control server acknowledged the given results. If an
+// action was re-attempted or finished while we were reporting, its newer result remains unreported.
+func (aq *ActionQueue) markResultsReported(results []actionResult, reportedAt time.Time) {
+	aq.recordLock.Lock()
+	defer aq.recordLock.Unlock()
+
+	for _, result := range results {
+		a, found, err := aq.actionRecord(result.ActionID)
+		if err != nil || !found || a.Result == nil || a.Result.Attempts != result.Attempts || a.Result.Status != result.Status {
+			continue
+		}
+
+		a.Result.ReportedAt = reportedAt
+		aq.setActionRecord(a)
+	}
+}
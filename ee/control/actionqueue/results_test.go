@@ -0,0 +1,372 @@
+package actionqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kolide/kit/ulid"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/control/actionqueue/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type testResultReporter struct {
+	lock     sync.Mutex
+	err      error
+	reported [][]actionResult
+}
+
+func (r *testResultReporter) SendActionResults(_ context.Context, results any) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	r.reported = append(r.reported, results.([]actionResult))
+	return nil
+}
+
+func (r *testResultReporter) setErr(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.err = err
+}
+
+func (r *testResultReporter) reportCount() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.reported)
+}
+
+type testResultActor struct {
+	payload json.RawMessage
+}
+
+func (a *testResultActor) Do(data io.Reader) error {
+	_, err := a.DoWithResult(data)
+	return err
+}
+
+func (a *testResultActor) DoWithResult(_ io.Reader) (json.RawMessage, error) {
+	return a.payload, nil
+}
+
+func TestActionQueue_RecordsResults(t *testing.T) {
+	t.Parallel()
+
+	succeededAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: testActorType}
+	failedAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: anotherTestActorType}
+
+	mockActor := mocks.NewActor(t)
+	mockActor.On("Do", mock.Anything).Return(nil).Once()
+	failingActor := mocks.NewActor(t)
+	failingActor.On("Do", mock.Anything).Return(errors.New("test error")).Twice()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	store := setupStorage(t)
+	actionqueue := New(mockKnapsack, WithStore(store))
+	actionqueue.RegisterActor(testActorType, mockActor)
+	actionqueue.RegisterActor(anotherTestActorType, failingActor)
+
+	testActionsRaw := mustJsonMarshal(t, []action{succeededAction, failedAction})
+	require.Error(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+
+	succeeded, found, err := actionqueue.actionRecord(succeededAction.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, succeeded.ProcessedAt.IsZero())
+	require.NotNil(t, succeeded.Result)
+	require.Equal(t, ActionStatusSucceeded, succeeded.Result.Status)
+	require.Equal(t, 1, succeeded.Result.Attempts)
+	require.False(t, succeeded.Result.StartedAt.After(succeeded.Result.FinishedAt))
+
+	failed, found, err := actionqueue.actionRecord(failedAction.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, failed.ProcessedAt.IsZero(), "failed action should not be marked processed")
+	require.NotNil(t, failed.Result)
+	require.Equal(t, ActionStatusFailed, failed.Result.Status)
+	require.Equal(t, "test error", failed.Result.Error)
+	require.Equal(t, 1, failed.Result.Attempts)
+
+	// Re-sending the actions should re-attempt only the failed one
+	require.Error(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+	failed, _, err = actionqueue.actionRecord(failedAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, failed.Result.Attempts)
+}
+
+func TestActionQueue_RecordsResultPayload(t *testing.T) {
+	t.Parallel()
+
+	smallAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: testActorType}
+	largeAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: anotherTestActorType}
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, &testResultActor{payload: json.RawMessage(`{"rows":1}`)})
+	actionqueue.RegisterActor(anotherTestActorType, &testResultActor{payload: mustJsonMarshal(t, string(make([]byte, maxResultPayloadBytes)))})
+
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{smallAction, largeAction}))))
+
+	small, _, err := actionqueue.actionRecord(smallAction.ID)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rows":1}`, string(small.Result.Payload))
+	require.False(t, small.Result.PayloadDropped)
+
+	large, _, err := actionqueue.actionRecord(largeAction.ID)
+	require.NoError(t, err)
+	require.Nil(t, large.Result.Payload)
+	require.True(t, large.Result.PayloadDropped)
+}
+
+type testAsyncActor struct {
+	lock     sync.Mutex
+	err      error
+	started  int
+	finisher *ActionQueue // if set, the actor finishes the action before DoAsync returns
+}
+
+func (a *testAsyncActor) Do(data io.Reader) error {
+	return a.DoAsync(data)
+}
+
+func (a *testAsyncActor) DoAsync(data io.Reader) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+	a.started += 1
+
+	if a.finisher != nil {
+		var started action
+		if err := json.NewDecoder(data).Decode(&started); err != nil {
+			return err
+		}
+		return a.finisher.FinishAction(started.ID, json.RawMessage(`{"fast":true}`), nil)
+	}
+
+	return nil
+}
+
+func (a *testAsyncActor) AsyncTimeout() time.Duration {
+	return 1 * time.Minute
+}
+
+func (a *testAsyncActor) startCount() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.started
+}
+
+func TestActionQueue_FinishAction(t *testing.T) {
+	t.Parallel()
+
+	asyncAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: testActorType}
+	failedToStartAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: anotherTestActorType}
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	store := setupStorage(t)
+	actionqueue := New(mockKnapsack, WithStore(store))
+	actionqueue.RegisterActor(testActorType, &testAsyncActor{})
+	actionqueue.RegisterActor(anotherTestActorType, &testAsyncActor{err: errors.New("could not start")})
+
+	require.Error(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{asyncAction, failedToStartAction}))))
+
+	// The started action is still running, so is not yet processed
+	running, found, err := actionqueue.actionRecord(asyncAction.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, running.ProcessedAt.IsZero())
+	require.Equal(t, ActionStatusRunning, running.Result.Status)
+	require.True(t, running.Result.FinishedAt.IsZero())
+
+	failed, _, err := actionqueue.actionRecord(failedToStartAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusFailed, failed.Result.Status)
+	require.Equal(t, "could not start", failed.Result.Error)
+
+	// Mark the running result reported, as if the control server acknowledged it
+	unreported, err := actionqueue.unreportedResults()
+	require.NoError(t, err)
+	actionqueue.markResultsReported(unreported, time.Now().UTC())
+
+	// Finishing the action records its result, including after a launcher restart, and reports it again
+	restartedActionqueue := New(mockKnapsack, WithStore(store))
+	require.NoError(t, restartedActionqueue.FinishAction(asyncAction.ID, json.RawMessage(`{"uploaded":true}`), nil))
+	finished, _, err := restartedActionqueue.actionRecord(asyncAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusSucceeded, finished.Result.Status)
+	require.JSONEq(t, `{"uploaded":true}`, string(finished.Result.Payload))
+	require.False(t, finished.Result.FinishedAt.IsZero())
+	require.True(t, finished.Result.ReportedAt.IsZero())
+	require.False(t, finished.ProcessedAt.IsZero())
+	require.Empty(t, finished.RawAction)
+
+	// Actions can only be finished once, and only if they're running
+	require.Error(t, restartedActionqueue.FinishAction(asyncAction.ID, nil, errors.New("test error")))
+	require.Error(t, restartedActionqueue.FinishAction(failedToStartAction.ID, nil, nil))
+	require.Error(t, restartedActionqueue.FinishAction(ulid.New(), nil, nil))
+}
+
+func TestActionQueue_FinishAction_BeforeDoAsyncReturns(t *testing.T) {
+	t.Parallel()
+
+	asyncAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: testActorType}
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, &testAsyncActor{finisher: actionqueue})
+
+	// An actor may finish an action as soon as it starts it, before the action queue hears back
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{asyncAction}))))
+
+	finished, _, err := actionqueue.actionRecord(asyncAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusSucceeded, finished.Result.Status)
+	require.JSONEq(t, `{"fast":true}`, string(finished.Result.Payload))
+	require.False(t, finished.ProcessedAt.IsZero())
+	require.Empty(t, finished.RawAction)
+}
+
+func TestActionQueue_AsyncActionTimeout(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), MaxAttempts: 2, Type: testActorType}
+	testActionsRaw := mustJsonMarshal(t, []action{testAction})
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	asyncActor := &testAsyncActor{}
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, asyncActor)
+
+	require.NoError(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+	require.Equal(t, 1, asyncActor.startCount())
+
+	// While the action is running, it isn't started again, whether by the scheduler or a re-send
+	actionqueue.runPendingActions(t.Context())
+	require.NoError(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+	require.Equal(t, 1, asyncActor.startCount())
+
+	// Once the actor has taken too long to finish the action, the attempt counts as failed
+	record, _, err := actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	record.Result.StartedAt = time.Now().Add(-1 * asyncActor.AsyncTimeout()).Add(-1 * time.Second)
+	actionqueue.storeActionRecord(record)
+	actionqueue.runPendingActions(t.Context())
+
+	record, _, err = actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusFailed, record.Result.Status)
+	require.Equal(t, 1, record.Result.Attempts)
+	require.True(t, record.ProcessedAt.IsZero())
+	require.NotEmpty(t, record.RawAction)
+
+	// A late finish is not recorded
+	require.Error(t, actionqueue.FinishAction(testAction.ID, nil, nil))
+
+	// The action is retried after the retry delay, like any other failed action
+	record.Result.FinishedAt = time.Now().Add(-1 * retryDelay(1)).Add(-1 * time.Second)
+	actionqueue.storeActionRecord(record)
+	actionqueue.runPendingActions(t.Context())
+	require.Equal(t, 2, asyncActor.startCount())
+
+	record, _, err = actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusRunning, record.Result.Status)
+	require.Equal(t, 2, record.Result.Attempts)
+}
+
+func TestActionQueue_ReportsResults(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{ID: ulid.New(), ValidUntil: getValidUntil(), Type: testActorType}
+
+	mockActor := mocks.NewActor(t)
+	mockActor.On("Do", mock.Anything).Return(nil).Once()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	// Start with a reporter that fails, so that we can confirm we retry
+	reporter := &testResultReporter{err: errors.New("test error")}
+	actionqueue := New(mockKnapsack, WithResultReporter(reporter), WithResultReportInterval(100*time.Millisecond))
+	actionqueue.RegisterActor(testActorType, mockActor)
+
+	go actionqueue.StartResultReporting()
+	t.Cleanup(func() { actionqueue.StopResultReporting(nil) })
+
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{testAction}))))
+
+	time.Sleep(300 * time.Millisecond)
+	require.Equal(t, 0, reporter.reportCount())
+	unreported, err := actionqueue.unreportedResults()
+	require.NoError(t, err)
+	require.Len(t, unreported, 1)
+
+	// Now let reporting succeed
+	reporter.setErr(nil)
+	require.Eventually(t, func() bool { return reporter.reportCount() > 0 }, 2*time.Second, 50*time.Millisecond)
+
+	reporter.lock.Lock()
+	require.Len(t, reporter.reported[0], 1)
+	require.Equal(t, testAction.ID, reporter.reported[0][0].ActionID)
+	require.Equal(t, ActionStatusSucceeded, reporter.reported[0][0].Status)
+	reporter.lock.Unlock()
+
+	require.Eventually(t, func() bool {
+		unreported, err := actionqueue.unreportedResults()
+		return err == nil && len(unreported) == 0
+	}, 2*time.Second, 50*time.Millisecond)
+
+	// Acknowledged results are not sent again
+	time.Sleep(300 * time.Millisecond)
+	require.Equal(t, 1, reporter.reportCount())
+}
+
+func TestStopResultReporting_Multiple(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+
+	done := make(chan struct{})
+	go func() {
+		require.NoError(t, actionqueue.StartResultReporting())
+		close(done)
+	}()
+
+	for range 3 {
+		actionqueue.StopResultReporting(nil)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("result reporting did not stop after interrupt")
+	}
+}
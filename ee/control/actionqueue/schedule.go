@@ -0,0 +1,278 @@
+package actionqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultScheduleInterval is how often we check whether pending actions are ready to be performed.
+	defaultScheduleInterval = 1 * time.Minute
+
+	// Delay before locally retrying an action that failed; the delay doubles with each attempt,
+	// up to maxRetryDelay.
+	minRetryDelay = 5 * time.Minute
+	maxRetryDelay = 4 * time.Hour
+)
+
+func WithScheduleInterval(interval time.Duration) actionqueueOption {
+	return func(aq *ActionQueue) {
+		aq.scheduleInterval = interval
+	}
+}
+
+// processAction performs the given action if its schedule and conditions permit; otherwise,
+// it stores the action as pending, to be performed later by the scheduler. Returns the error
+// from the actor, if the action was attempted and failed.
+func (aq *ActionQueue) processAction(ctx context.Context, a action, actorForAction actor, rawAction []byte) error {
+	aq.processLock.Lock()
+	defer aq.processLock.Unlock()
+
+	// Pick up the result of any previous attempt, so that we count attempts across launcher restarts
+	a.Result = nil
+	a.RawAction = nil
+	if previous, found, err := aq.actionRecord(a.ID); err == nil && found {
+		if !previous.ProcessedAt.IsZero() {
+			// Processed while this action was waiting on the lock
+			return nil
+		}
+		a.Result = previous.Result
+	}
+
+	if a.Result != nil && a.Result.Status == ActionStatusRunning {
+		// An asyncActor is performing this action in the background -- leave it be, unless it
+		// has taken too long to finish
+		if aa, ok := actorForAction.(asyncActor); ok && time.Since(a.Result.StartedAt) >= aa.AsyncTimeout() {
+			aq.failTimedOutAction(ctx, a, aa.AsyncTimeout())
+		}
+		return nil
+	}
+
+	if waitingToRetry(a) {
+		// Whether the scheduler or a re-send from the control server got us here, a failed
+		// action that is retried locally waits out its retry delay
+		aq.slogger.Log(ctx, slog.LevelDebug,
+			"action failed recently, will retry after delay",
+			"action", a.String(),
+			"attempts", a.Result.Attempts,
+		)
+		a.RawAction = rawAction
+		aq.storeActionRecord(a)
+		return nil
+	}
+
+	if ready, reason := aq.readyToRun(ctx, a); !ready {
+		aq.slogger.Log(ctx, slog.LevelDebug,
+			"action not ready to run, will check again later",
+			"action", a.String(),
+			"reason", reason,
+		)
+		a.RawAction = rawAction
+		aq.storeActionRecord(a)
+		return nil
+	}
+
+	previousAttempts := 0
+	if a.Result != nil {
+		previousAttempts = a.Result.Attempts
+	}
+
+	if aa, ok := actorForAction.(asyncActor); ok {
+		if err := aq.startAsyncAction(a, aa, rawAction, previousAttempts); err != nil {
+			aq.slogger.Log(ctx, slog.LevelInfo,
+				"failed to start action, not marking action complete",
+				"action", a.String(),
+				"attempts", previousAttempts+1,
+				"err", err,
+			)
+			return err
+		}
+		return nil
+	}
+
+	result, err := aq.doAction(a, actorForAction, rawAction, previousAttempts)
+	a.Result = result
+	if err != nil {
+		aq.slogger.Log(ctx, slog.LevelInfo,
+			"failed to do action with action, not marking action complete",
+			"action", a.String(),
+			"attempts", result.Attempts,
+			"err", err,
+		)
+	}
+	a.completeAttempt(rawAction)
+
+	aq.storeActionRecord(a)
+	aq.signalReport()
+
+	return err
+}
+
+// completeAttempt updates the action for the outcome of the attempt recorded in its Result. Only
+// a successful action is marked processed, unless it is retried locally and is out of attempts;
+// a failed action with remaining attempts holds on to rawAction so that the scheduler can retry it.
+func (a *action) completeAttempt(rawAction []byte) {
+	a.RawAction = nil
+
+	if a.Result.Status != ActionStatusFailed {
+		a.ProcessedAt = a.Result.FinishedAt
+		return
+	}
+
+	if a.MaxAttempts > 0 {
+		if a.Result.Attempts < a.MaxAttempts {
+			a.RawAction = rawAction
+		} else {
+			// Out of attempts -- mark processed so that we don't try again
+			a.ProcessedAt = a.Result.FinishedAt
+		}
+	}
+}
+
+// StartScheduledActions periodically performs pending actions once their schedules and
+// conditions permit, and retries failed actions that allow it. Pending actions are persisted,
+// so this picks up actions received before the launcher last restarted. It runs until
+// StopScheduledActions is called.
+func (aq *ActionQueue) StartScheduledActions() error {
+	ticker := time.NewTicker(aq.scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		aq.runPendingActions(aq.ctx)
+
+		select {
+		case <-aq.scheduleInterrupt:
+			aq.slogger.Log(context.TODO(), slog.LevelDebug,
+				"scheduled actions stopped due to interrupt",
+			)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (aq *ActionQueue) StopScheduledActions(_ error) {
+	// Only perform shutdown tasks on first call to interrupt -- no need to repeat on potential extra calls.
+	if aq.scheduleInterrupted.Swap(true) {
+		return
+	}
+
+	aq.scheduleInterrupt <- struct{}{}
+}
+
+// runPendingActions performs all pending actions that are ready to run.
+func (aq *ActionQueue) runPendingActions(ctx context.Context) {
+	pending, err := aq.pendingActions()
+	if err != nil {
+		aq.slogger.Log(ctx, slog.LevelWarn,
+			"could not read pending actions",
+			"err", err,
+		)
+		return
+	}
+
+	for _, a := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A running action already started before it expired, so let it finish
+		running := a.Result != nil && a.Result.Status == ActionStatusRunning
+		if !running && a.ValidUntil <= time.Now().Unix() {
+			aq.expireAction(a)
+			continue
+		}
+
+		if waitingToRetry(a) {
+			continue
+		}
+
+		actorForAction, err := aq.actorForAction(a)
+		if err != nil {
+			aq.slogger.Log(ctx, slog.LevelInfo,
+				"getting actor for pending action",
+				"action", a.String(),
+				"err", err,
+			)
+			continue
+		}
+
+		// Errors are logged and recorded in the action's result
+		_ = aq.processAction(ctx, a, actorForAction, a.RawAction)
+	}
+}
+
+// pendingActions returns all stored actions that are waiting to be performed.
+func (aq *ActionQueue) pendingActions() ([]action, error) {
+	aq.recordLock.Lock()
+	defer aq.recordLock.Unlock()
+
+	pending := make([]action, 0)
+	if err := aq.store.ForEach(func(k, v []byte) error {
+		var a action
+		if err := json.Unmarshal(v, &a); err != nil {
+			// Skip this record rather than blocking all pending actions on it
+			return nil
+		}
+
+		if len(a.RawAction) > 0 && a.ProcessedAt.IsZero() {
+			pending = append(pending, a)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("iterating over actions: %w", err)
+	}
+
+	return pending, nil
+}
+
+// expireAction records that the given pending action expired before it could be performed.
+func (aq *ActionQueue) expireAction(a action) {
+	aq.processLock.Lock()
+	defer aq.processLock.Unlock()
+
+	aq.slogger.Log(context.TODO(), slog.LevelInfo,
+		"pending action expired before it could be performed",
+		"action", a.String(),
+	)
+
+	result := &actionResult{
+		ActionID:   a.ID,
+		Type:       a.Type,
+		Status:     ActionStatusExpired,
+		Error:      "action expired before it could be performed",
+		FinishedAt: time.Now().UTC(),
+	}
+	if a.Result != nil {
+		result.Attempts = a.Result.Attempts
+		result.StartedAt = a.Result.StartedAt
+	}
+
+	a.Result = result
+	a.RawAction = nil
+	a.ProcessedAt = result.FinishedAt
+	aq.storeActionRecord(a)
+	aq.signalReport()
+}
+
+// waitingToRetry reports whether the given action is retried locally, and its last attempt
+// failed too recently to retry it yet.
+func waitingToRetry(a action) bool {
+	if a.MaxAttempts <= 0 || a.Result == nil || a.Result.Status != ActionStatusFailed {
+		return false
+	}
+
+	return time.Now().Before(a.Result.FinishedAt.Add(retryDelay(a.Result.Attempts)))
+}
+
+// retryDelay returns how long to wait after the given number of failed attempts before retrying.
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,207 @@
+package actionqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kolide/kit/ulid"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/control/actionqueue/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionQueue_NotBefore(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{
+		ID:         ulid.New(),
+		ValidUntil: getValidUntil(),
+		NotBefore:  time.Now().Add(1 * time.Second).Unix(),
+		Type:       testActorType,
+	}
+
+	mockActor := mocks.NewActor(t)
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, mockActor)
+
+	// The action isn't performed yet, but is held as pending
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{testAction}))))
+	pending, err := actionqueue.pendingActions()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// Once not_before has passed, the scheduler performs the action
+	time.Sleep(2 * time.Second)
+	mockActor.On("Do", mock.Anything).Return(nil).Once()
+	actionqueue.runPendingActions(t.Context())
+
+	record, found, err := actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, record.ProcessedAt.IsZero())
+	require.Empty(t, record.RawAction)
+}
+
+func TestActionQueue_RequiresACPower_PersistsAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{
+		ID:              ulid.New(),
+		ValidUntil:      getValidUntil(),
+		RequiresACPower: true,
+		Type:            testActorType,
+	}
+
+	mockActor := mocks.NewActor(t)
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	store := setupStorage(t)
+	actionqueue := New(mockKnapsack, WithStore(store))
+	actionqueue.onACPower = func(context.Context) (bool, error) { return false, nil }
+	actionqueue.RegisterActor(testActorType, mockActor)
+
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{testAction}))))
+	actionqueue.runPendingActions(t.Context())
+
+	// Simulate a launcher restart, after which the device is plugged in
+	restartedQueue := New(mockKnapsack, WithStore(store))
+	restartedQueue.onACPower = func(context.Context) (bool, error) { return true, nil }
+	restartedQueue.RegisterActor(testActorType, mockActor)
+
+	mockActor.On("Do", mock.Anything).Return(nil).Once()
+	restartedQueue.runPendingActions(t.Context())
+
+	pending, err := restartedQueue.pendingActions()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestActionQueue_RequiresIdle(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{
+		ID:           ulid.New(),
+		ValidUntil:   getValidUntil(),
+		RequiresIdle: true,
+		Type:         testActorType,
+	}
+
+	mockActor := mocks.NewActor(t)
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("InModernStandby").Return(false).Once()
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.systemIdle = func(context.Context) (bool, error) { return false, errors.New("test error") }
+	actionqueue.RegisterActor(testActorType, mockActor)
+
+	// Not idle (or can't tell): the action is held
+	require.NoError(t, actionqueue.Update(bytes.NewReader(mustJsonMarshal(t, []action{testAction}))))
+
+	// Modern standby counts as idle
+	mockKnapsack.On("InModernStandby").Return(true).Once()
+	mockActor.On("Do", mock.Anything).Return(nil).Once()
+	actionqueue.runPendingActions(t.Context())
+
+	record, _, err := actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.False(t, record.ProcessedAt.IsZero())
+}
+
+func TestActionQueue_MaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	testAction := action{
+		ID:          ulid.New(),
+		ValidUntil:  getValidUntil(),
+		MaxAttempts: 2,
+		Type:        testActorType,
+	}
+	testActionsRaw := mustJsonMarshal(t, []action{testAction})
+
+	mockActor := mocks.NewActor(t)
+	mockActor.On("Do", mock.Anything).Return(errors.New("test error")).Twice()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, mockActor)
+
+	require.Error(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+
+	// The failed action is held for retry, but not until the retry delay has passed
+	actionqueue.runPendingActions(t.Context())
+	record, _, err := actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, record.Result.Attempts)
+	require.NotEmpty(t, record.RawAction)
+
+	// Nor if the control server sends it again in the meantime
+	require.NoError(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+	record, _, err = actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, record.Result.Attempts)
+	require.NotEmpty(t, record.RawAction)
+
+	record.Result.FinishedAt = time.Now().Add(-1 * retryDelay(1)).Add(-1 * time.Second)
+	actionqueue.storeActionRecord(record)
+	actionqueue.runPendingActions(t.Context())
+
+	// Out of attempts: the action is given up on, even if the control server sends it again
+	record, _, err = actionqueue.actionRecord(testAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, record.Result.Attempts)
+	require.Equal(t, ActionStatusFailed, record.Result.Status)
+	require.False(t, record.ProcessedAt.IsZero())
+	require.Empty(t, record.RawAction)
+
+	require.NoError(t, actionqueue.Update(bytes.NewReader(testActionsRaw)))
+}
+
+func TestActionQueue_ExpiresPendingActions(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	actionqueue := New(mockKnapsack)
+	actionqueue.RegisterActor(testActorType, mocks.NewActor(t))
+
+	expiredAction := action{
+		ID:         ulid.New(),
+		ValidUntil: time.Now().Add(-1 * time.Minute).Unix(),
+		NotBefore:  time.Now().Add(-2 * time.Minute).Unix(),
+		Type:       testActorType,
+	}
+	expiredAction.RawAction = mustJsonMarshal(t, expiredAction)
+	actionqueue.storeActionRecord(expiredAction)
+
+	actionqueue.runPendingActions(t.Context())
+
+	record, _, err := actionqueue.actionRecord(expiredAction.ID)
+	require.NoError(t, err)
+	require.Equal(t, ActionStatusExpired, record.Result.Status)
+	require.False(t, record.ProcessedAt.IsZero())
+	require.Empty(t, record.RawAction)
+}
+
+func Test_retryDelay(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, minRetryDelay, retryDelay(1))
+	require.Equal(t, 2*minRetryDelay, retryDelay(2))
+	require.Equal(t, maxRetryDelay, retryDelay(100))
+}
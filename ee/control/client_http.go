@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/kolide/krypto/pkg/echelper"
@@ -29,6 +30,7 @@ type HTTPClient struct {
 	client     *http.Client
 	insecure   bool
 	disableTLS bool
+	tokenLock  sync.RWMutex // token is read by WaitForUpdates while GetConfig may be refreshing it
 	token      string
 	slogger    *slog.Logger
 }
@@ -43,6 +45,11 @@ const (
 	HeaderKey2       = "X-Kolide-Key2"
 
 	defaultRequestTimeout = 30 * time.Second
+
+	// updatesHoldTime is how long we ask the control server to hold an updates request open
+	// before responding with no updates; updatesRequestTimeout allows extra time beyond that.
+	updatesHoldTime       = 60 * time.Second
+	updatesRequestTimeout = updatesHoldTime + defaultRequestTimeout
 )
 
 type configResponse struct {
@@ -50,6 +57,12 @@ type configResponse struct {
 	Config json.RawMessage `json:"config"`
 }
 
+// updatesMessage is both the request and response body for WaitForUpdates: a map of
+// subsystem name to hash.
+type updatesMessage struct {
+	Subsystems map[string]string `json:"subsystems"`
+}
+
 func NewControlHTTPClient(addr string, client *http.Client, logger *slog.Logger, opts ...HTTPClientOption) (*HTTPClient, error) {
 	baseURL, err := url.Parse(fmt.Sprintf("https://%s", addr))
 	if err != nil {
@@ -94,24 +107,9 @@ func (c *HTTPClient) GetConfig(ctx context.Context) (io.Reader, error) {
 	configReq.Header.Set("Accept", "application/json")
 
 	// Calculate first signature
-	localDbKeys := agent.LocalDbKeys()
-	if localDbKeys.Public() == nil {
-		return nil, errors.New("cannot request control data without local keys")
-	}
-	ecdsaPubKey, ok := localDbKeys.Public().(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("local db keys in unexpected format (expected ECDSA, got %T)", localDbKeys.Public())
-	}
-	key1, err := echelper.PublicEcdsaToB64Der(ecdsaPubKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not get key header from local db keys: %w", err)
+	if err := c.setLocalKeyHeader(configReq, challenge); err != nil {
+		return nil, fmt.Errorf("cannot request control data: %w", err)
 	}
-	sig1, err := signatureHeaderValue(localDbKeys, challenge)
-	if err != nil {
-		return nil, fmt.Errorf("could not get signature header from local db keys: %w", err)
-	}
-	configReq.Header.Set(HeaderKey, string(key1))
-	configReq.Header.Set(HeaderSignature, sig1)
 
 	if err := c.setHardwareKeyHeader(configReq, challenge); err != nil {
 		c.slogger.Log(ctx, slog.LevelWarn,
@@ -131,12 +129,37 @@ func (c *HTTPClient) GetConfig(ctx context.Context) (io.Reader, error) {
 	}
 
 	// Set the auth token for use when fetching objects by their hashes later
+	c.tokenLock.Lock()
 	c.token = cfgResp.Token
+	c.tokenLock.Unlock()
 
 	reader := bytes.NewReader(cfgResp.Config)
 	return reader, nil
 }
 
+func (c *HTTPClient) setLocalKeyHeader(req *http.Request, data []byte) error {
+	localDbKeys := agent.LocalDbKeys()
+	if localDbKeys.Public() == nil {
+		return errors.New("no local keys")
+	}
+	ecdsaPubKey, ok := localDbKeys.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("local db keys in unexpected format (expected ECDSA, got %T)", localDbKeys.Public())
+	}
+	key1, err := echelper.PublicEcdsaToB64Der(ecdsaPubKey)
+	if err != nil {
+		return fmt.Errorf("could not get key header from local db keys: %w", err)
+	}
+	sig1, err := signatureHeaderValue(localDbKeys, data)
+	if err != nil {
+		return fmt.Errorf("could not get signature header from local db keys: %w", err)
+	}
+
+	req.Header.Set(HeaderKey, string(key1))
+	req.Header.Set(HeaderSignature, sig1)
+	return nil
+}
+
 func (c *HTTPClient) setHardwareKeyHeader(req *http.Request, challenge []byte) error {
 	if runtime.GOOS == "darwin" {
 		// Hardware key signing not supported on darwin
@@ -169,29 +192,49 @@ func (c *HTTPClient) setHardwareKeyHeader(req *http.Request, challenge []byte) e
 }
 
 func (c *HTTPClient) GetSubsystemData(ctx context.Context, hash string) (io.Reader, error) {
+	data, _, err := c.GetSignedSubsystemData(ctx, hash)
+	return data, err
+}
+
+// GetSignedSubsystemData fetches the subsystem data for the given hash, along with the detached
+// signature over it, if the control server provided one.
+func (c *HTTPClient) GetSignedSubsystemData(ctx context.Context, hash string) (io.Reader, *payloadSignature, error) {
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
 
-	if c.token == "" {
-		return nil, errors.New("token is nil, cannot request subsystem data")
+	token := c.currentToken()
+	if token == "" {
+		return nil, nil, errors.New("token is nil, cannot request subsystem data")
 	}
 
 	dataReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(fmt.Sprintf("/api/agent/object/%s", hash)).String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("could not create subsystem data request: %w", err)
+		return nil, nil, fmt.Errorf("could not create subsystem data request: %w", err)
 	}
 
-	dataReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	dataReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	dataReq.Header.Set("Content-Type", "application/json")
 	dataReq.Header.Set("Accept", "application/json")
 
-	dataRaw, err := c.do(dataReq)
+	dataRaw, respHeaders, err := c.doWithResponseHeaders(dataReq, defaultRequestTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("could not make subsystem data request: %w", err)
+		return nil, nil, fmt.Errorf("could not make subsystem data request: %w", err)
+	}
+
+	var sig *payloadSignature
+	if rawSig := respHeaders.Get(HeaderPayloadSignature); rawSig != "" {
+		decodedSig, err := base64.StdEncoding.DecodeString(rawSig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not decode payload signature: %w", err)
+		}
+		sig = &payloadSignature{
+			KeyID:     respHeaders.Get(HeaderPayloadKeyID),
+			Signature: decodedSig,
+		}
 	}
 
 	reader := bytes.NewReader(dataRaw)
-	return reader, nil
+	return reader, sig, nil
 }
 
 // SendMessage sends a message to the server using JSON-RPC format
@@ -199,7 +242,8 @@ func (c *HTTPClient) SendMessage(ctx context.Context, method string, params any)
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
 
-	if c.token == "" {
+	token := c.currentToken()
+	if token == "" {
 		return errors.New("token is nil, cannot send message to server")
 	}
 
@@ -228,7 +272,7 @@ func (c *HTTPClient) SendMessage(ctx context.Context, method string, params any)
 		return fmt.Errorf("could not create server message: %w", err)
 	}
 
-	dataReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	dataReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	dataReq.Header.Set("Content-Type", "application/json")
 	dataReq.Header.Set("Accept", "application/json")
 
@@ -238,13 +282,114 @@ func (c *HTTPClient) SendMessage(ctx context.Context, method string, params any)
 	return err
 }
 
+// SendActionResults reports the results of processed actions to the control server. The request
+// body is signed with our local and hardware keys, so that the server can verify that results
+// came from this device. A nil error means that the server has acknowledged the results.
+func (c *HTTPClient) SendActionResults(ctx context.Context, results any) error {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	token := c.currentToken()
+	if token == "" {
+		return errors.New("token is nil, cannot send action results to server")
+	}
+
+	body, err := json.Marshal(map[string]any{"results": results})
+	if err != nil {
+		return fmt.Errorf("could not marshal action results: %w", err)
+	}
+
+	resultsReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url("/api/agent/actions/results").String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create action results request: %w", err)
+	}
+
+	resultsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	resultsReq.Header.Set("Content-Type", "application/json")
+	resultsReq.Header.Set("Accept", "application/json")
+
+	if err := c.setLocalKeyHeader(resultsReq, body); err != nil {
+		return fmt.Errorf("could not sign action results: %w", err)
+	}
+	if err := c.setHardwareKeyHeader(resultsReq, body); err != nil {
+		c.slogger.Log(ctx, slog.LevelWarn,
+			"failed to set hardware key header on action results, not fatal moving on",
+			"err", err,
+		)
+	}
+
+	if _, err := c.do(resultsReq); err != nil {
+		return fmt.Errorf("could not make action results request: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForUpdates long-polls the control server for subsystem updates. The server holds the
+// request open until any subsystem's hash differs from the given known hashes, or until
+// updatesHoldTime elapses; it returns the updated subsystems and their new hashes, which
+// will be empty if nothing changed.
+func (c *HTTPClient) WaitForUpdates(ctx context.Context, knownHashes map[string]string) (map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	token := c.currentToken()
+	if token == "" {
+		return nil, errors.New("token is nil, cannot wait for updates")
+	}
+
+	body, err := json.Marshal(updatesMessage{Subsystems: knownHashes})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal known hashes: %w", err)
+	}
+
+	updatesUrl := c.url("/api/agent/config/updates")
+	updatesUrl.RawQuery = url.Values{"hold": []string{fmt.Sprintf("%d", int(updatesHoldTime.Seconds()))}}.Encode()
+	updatesReq, err := http.NewRequestWithContext(ctx, http.MethodPost, updatesUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not create updates request: %w", err)
+	}
+
+	updatesReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	updatesReq.Header.Set("Content-Type", "application/json")
+	updatesReq.Header.Set("Accept", "application/json")
+
+	updatesRaw, err := c.doWithTimeout(updatesReq, updatesRequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not make updates request: %w", err)
+	}
+
+	var updates updatesMessage
+	if err := json.Unmarshal(updatesRaw, &updates); err != nil {
+		return nil, fmt.Errorf("could not unmarshal updates response: %w", err)
+	}
+
+	return updates.Subsystems, nil
+}
+
+func (c *HTTPClient) currentToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+
+	return c.token
+}
+
 // TODO: this should probably just return a io.Reader
 func (c *HTTPClient) do(req *http.Request) ([]byte, error) {
+	return c.doWithTimeout(req, defaultRequestTimeout)
+}
+
+func (c *HTTPClient) doWithTimeout(req *http.Request, timeout time.Duration) ([]byte, error) {
+	respBytes, _, err := c.doWithResponseHeaders(req, timeout)
+	return respBytes, err
+}
+
+func (c *HTTPClient) doWithResponseHeaders(req *http.Request, timeout time.Duration) ([]byte, http.Header, error) {
 	req, span := observability.StartHttpRequestSpan(req)
 	defer span.End()
 
 	// Ensure we set a timeout on the request
-	ctx, cancel := context.WithTimeout(req.Context(), defaultRequestTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 	req = req.WithContext(ctx)
 
@@ -253,20 +398,20 @@ func (c *HTTPClient) do(req *http.Request) ([]byte, error) {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making http request: %w", err)
+		return nil, nil, fmt.Errorf("error making http request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("got non-200 status code %d from control server at %s", resp.StatusCode, resp.Request.URL)
+		return nil, nil, fmt.Errorf("got non-200 status code %d from control server at %s", resp.StatusCode, resp.Request.URL)
 	}
 
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("could not read response body from control server at %s: %w", resp.Request.URL, err)
+		return nil, nil, fmt.Errorf("could not read response body from control server at %s: %w", resp.Request.URL, err)
 	}
 
-	return respBytes, nil
+	return respBytes, resp.Header, nil
 }
 
 func (c *HTTPClient) url(path string) *url.URL {
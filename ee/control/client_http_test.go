@@ -0,0 +1,119 @@
+package control
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kolide/launcher/ee/agent"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForUpdates(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/agent/config/updates", r.URL.Path)
+		require.Equal(t, "60", r.URL.Query().Get("hold"))
+		require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		var req updatesMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		resp := updatesMessage{Subsystems: map[string]string{}}
+		if req.Subsystems["desktop"] != "502a42f0" {
+			resp.Subsystems["desktop"] = "502a42f0"
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewControlHTTPClient(strings.TrimPrefix(srv.URL, "http://"), &http.Client{}, multislogger.NewNopLogger(), WithDisableTLS())
+	require.NoError(t, err)
+
+	// We can't wait for updates until we've fetched a token via GetConfig
+	_, err = client.WaitForUpdates(t.Context(), nil)
+	require.Error(t, err)
+
+	client.token = "test-token"
+
+	updates, err := client.WaitForUpdates(t.Context(), map[string]string{"desktop": "402a42ef"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"desktop": "502a42f0"}, updates)
+
+	updates, err = client.WaitForUpdates(t.Context(), map[string]string{"desktop": "502a42f0"})
+	require.NoError(t, err)
+	require.Empty(t, updates)
+}
+
+func TestSendActionResults(t *testing.T) {
+	t.Parallel()
+
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/agent/actions/results", r.URL.Path)
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		require.NotEmpty(t, r.Header.Get(HeaderKey))
+		require.NotEmpty(t, r.Header.Get(HeaderSignature))
+
+		var req struct {
+			Results []map[string]string `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received += len(req.Results)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	// Results are signed with our local keys
+	require.NoError(t, agent.SetupKeys(t.Context(), multislogger.NewNopLogger(), inmemory.NewStore()))
+
+	client, err := NewControlHTTPClient(strings.TrimPrefix(srv.URL, "http://"), &http.Client{}, multislogger.NewNopLogger(), WithDisableTLS())
+	require.NoError(t, err)
+
+	results := []map[string]string{{"action_id": "abcd", "status": "succeeded"}}
+
+	// We can't send results until we've fetched a token via GetConfig
+	require.Error(t, client.SendActionResults(t.Context(), results))
+
+	client.token = "test-token"
+	require.NoError(t, client.SendActionResults(t.Context(), results))
+	require.Equal(t, 1, received)
+}
+
+func TestGetSignedSubsystemData(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		if r.URL.Path == "/api/agent/object/signed" {
+			w.Header().Set(HeaderPayloadKeyID, "test-key")
+			w.Header().Set(HeaderPayloadSignature, "c2lnbmF0dXJl")
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewControlHTTPClient(strings.TrimPrefix(srv.URL, "http://"), &http.Client{}, multislogger.NewNopLogger(), WithDisableTLS())
+	require.NoError(t, err)
+	client.token = "test-token"
+
+	data, sig, err := client.GetSignedSubsystemData(t.Context(), "signed")
+	require.NoError(t, err)
+	require.Equal(t, &payloadSignature{KeyID: "test-key", Signature: []byte("signature")}, sig)
+	rawData, err := io.ReadAll(data)
+	require.NoError(t, err)
+	require.Equal(t, `{"status":"ok"}`, string(rawData))
+
+	_, sig, err = client.GetSignedSubsystemData(t.Context(), "unsigned")
+	require.NoError(t, err)
+	require.Nil(t, sig)
+}
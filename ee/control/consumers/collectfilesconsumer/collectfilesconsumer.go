@@ -0,0 +1,381 @@
+package collectfilesconsumer
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/debug/shipper"
+	"github.com/kolide/launcher/ee/gowrapper"
+	"github.com/kolide/launcher/pkg/launcher"
+)
+
+const (
+	// CollectFilesActorType identifies this action/actor type, which collects files matching
+	// the given glob patterns and uploads them for investigation. This actor type belongs to
+	// the action subsystem.
+	CollectFilesActorType = "collect_files"
+
+	defaultMaxFileBytes  = 10 * 1024 * 1024
+	maxFileBytesLimit    = 50 * 1024 * 1024
+	defaultMaxTotalBytes = 50 * 1024 * 1024
+	maxTotalBytesLimit   = 200 * 1024 * 1024
+	defaultMaxFiles      = 50
+	maxFilesLimit        = 500
+
+	// asyncTimeout generously bounds collecting the files and uploading them; the upload itself
+	// times out after a few minutes.
+	asyncTimeout = 30 * time.Minute
+
+	manifestFileName = "manifest.json"
+	// maxResultManifestEntries bounds the manifest entries included in the action result; the
+	// full manifest is always included in the upload.
+	maxResultManifestEntries = 100
+)
+
+const (
+	fileStatusCollected = "collected"
+	fileStatusSkipped   = "skipped"
+)
+
+// uploadStream receives the zip of collected files, and uploads it on Close.
+type uploadStream interface {
+	io.WriteCloser
+	Name() string
+}
+
+// actionFinisher records the outcome of the collect_files action once the files are uploaded.
+type actionFinisher interface {
+	FinishAction(actionID string, payload json.RawMessage, actionErr error) error
+}
+
+type CollectFilesConsumer struct {
+	knapsack       types.Knapsack
+	actionFinisher actionFinisher
+	slogger        *slog.Logger
+	// newUploadStream is assigned to a field so it can be mocked in tests
+	newUploadStream func(note, uploadRequestURL string) (uploadStream, error)
+}
+
+type collectFilesAction struct {
+	ID               string   `json:"id"`
+	Patterns         []string `json:"patterns"`                  // absolute glob patterns, see filepath.Match
+	MaxFileBytes     int64    `json:"max_file_bytes,omitempty"`  // files larger than this are skipped
+	MaxTotalBytes    int64    `json:"max_total_bytes,omitempty"` // stop collecting once we reach this many bytes
+	MaxFiles         int      `json:"max_files,omitempty"`       // stop collecting once we reach this many files
+	Note             string   `json:"note"`
+	UploadRequestURL string   `json:"upload_request_url"`
+}
+
+// manifestEntry describes a file matched by the action's patterns, and whether we collected it.
+type manifestEntry struct {
+	Path    string    `json:"path"`
+	Status  string    `json:"status"` // fileStatusCollected or fileStatusSkipped
+	Reason  string    `json:"reason,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime,omitzero"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
+
+// collectFilesResult is the payload returned as the action's result.
+type collectFilesResult struct {
+	UploadName        string          `json:"upload_name"`
+	CollectedCount    int             `json:"collected_count"`
+	SkippedCount      int             `json:"skipped_count"`
+	TotalBytes        int64           `json:"total_bytes"`
+	Files             []manifestEntry `json:"files"`
+	ManifestTruncated bool            `json:"manifest_truncated"`
+}
+
+func New(knapsack types.Knapsack, actionFinisher actionFinisher) *CollectFilesConsumer {
+	return &CollectFilesConsumer{
+		knapsack:       knapsack,
+		actionFinisher: actionFinisher,
+		slogger:        knapsack.Slogger().With("component", "collect_files_consumer"),
+		newUploadStream: func(note, uploadRequestURL string) (uploadStream, error) {
+			return shipper.New(knapsack, shipper.WithNote(note), shipper.WithUploadRequestURL(uploadRequestURL))
+		},
+	}
+}
+
+// Do implements the `actionqueue.actor` interface. The action queue calls DoAsync instead, so
+// that the collection's summary is reported once the files are uploaded.
+func (c *CollectFilesConsumer) Do(data io.Reader) error {
+	return c.DoAsync(data)
+}
+
+// AsyncTimeout implements the `actionqueue.asyncActor` interface.
+func (c *CollectFilesConsumer) AsyncTimeout() time.Duration {
+	return asyncTimeout
+}
+
+// DoAsync implements the `actionqueue.asyncActor` interface. It checks the action, then returns
+// while the files matching the action's patterns are collected and uploaded in the background, so
+// that a large collection does not hold up other actions or control server updates. Once the
+// upload completes, a summary of the collection is reported through the action queue.
+func (c *CollectFilesConsumer) DoAsync(data io.Reader) error {
+	var action collectFilesAction
+	if err := json.NewDecoder(data).Decode(&action); err != nil {
+		return fmt.Errorf("decoding collect_files action: %w", err)
+	}
+
+	if len(action.Patterns) == 0 {
+		return errors.New("collect_files action has no patterns")
+	}
+	for _, pattern := range action.Patterns {
+		if !filepath.IsAbs(pattern) {
+			return fmt.Errorf("pattern %s is not an absolute path", pattern)
+		}
+	}
+
+	c.slogger.Log(context.TODO(), slog.LevelInfo,
+		"received collect_files request",
+		"action_id", action.ID,
+		"note", action.Note,
+		"patterns", action.Patterns,
+	)
+
+	gowrapper.Go(context.TODO(), c.slogger, func() {
+		result, err := c.collect(action)
+		c.finishAction(action.ID, result, err)
+	})
+
+	return nil
+}
+
+// collect collects the files matching the action's patterns, within its limits and excluding
+// denied paths, into a zip with a manifest, and uploads the zip. It returns a summary of the
+// collection.
+func (c *CollectFilesConsumer) collect(action collectFilesAction) (json.RawMessage, error) {
+	maxFileBytes := limit(action.MaxFileBytes, defaultMaxFileBytes, maxFileBytesLimit)
+	maxTotalBytes := limit(action.MaxTotalBytes, defaultMaxTotalBytes, maxTotalBytesLimit)
+	maxFiles := int(limit(int64(action.MaxFiles), defaultMaxFiles, maxFilesLimit))
+
+	stream, err := c.newUploadStream(action.Note, action.UploadRequestURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload stream: %w", err)
+	}
+
+	manifest, zipErr := c.writeZip(stream, matchingPaths(action.Patterns), maxFileBytes, maxTotalBytes, maxFiles)
+	if err := errors.Join(zipErr, stream.Close()); err != nil {
+		return nil, fmt.Errorf("collecting and uploading files: %w", err)
+	}
+
+	result := collectFilesResult{
+		UploadName: stream.Name(),
+		Files:      manifest,
+	}
+	for _, entry := range manifest {
+		if entry.Status == fileStatusCollected {
+			result.CollectedCount += 1
+			result.TotalBytes += entry.Size
+		} else {
+			result.SkippedCount += 1
+		}
+	}
+	if len(result.Files) > maxResultManifestEntries {
+		result.Files = result.Files[:maxResultManifestEntries]
+		result.ManifestTruncated = true
+	}
+
+	c.slogger.Log(context.TODO(), slog.LevelInfo,
+		"completed collect_files request",
+		"action_id", action.ID,
+		"note", action.Note,
+		"upload_name", result.UploadName,
+		"collected_count", result.CollectedCount,
+		"skipped_count", result.SkippedCount,
+	)
+
+	return json.Marshal(result)
+}
+
+// finishAction reports the outcome of the collect_files action.
+func (c *CollectFilesConsumer) finishAction(actionID string, result json.RawMessage, actionErr error) {
+	if c.actionFinisher == nil || actionID == "" {
+		return
+	}
+
+	if err := c.actionFinisher.FinishAction(actionID, result, actionErr); err != nil {
+		c.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not record collect_files result",
+			"action_id", actionID,
+			"err", err,
+		)
+	}
+}
+
+// writeZip writes the given files, followed by a manifest describing them, to a zip in w.
+func (c *CollectFilesConsumer) writeZip(w io.Writer, paths []string, maxFileBytes, maxTotalBytes int64, maxFiles int) ([]manifestEntry, error) {
+	z := zip.NewWriter(w)
+
+	manifest := make([]manifestEntry, 0, len(paths))
+	var totalBytes int64
+	collectedCount := 0
+
+	for _, path := range paths {
+		entry := manifestEntry{Path: path, Status: fileStatusSkipped}
+
+		resolvedPath, info, reason := c.checkFile(path, maxFileBytes)
+		if info != nil {
+			entry.Size = info.Size()
+			entry.ModTime = info.ModTime().UTC()
+		}
+
+		switch {
+		case reason != "":
+			entry.Reason = reason
+		case collectedCount >= maxFiles:
+			entry.Reason = "max_files reached"
+		case totalBytes+entry.Size > maxTotalBytes:
+			entry.Reason = "max_total_bytes reached"
+		default:
+			hash, err := addFileToZip(z, path, resolvedPath, info)
+			if err != nil {
+				entry.Reason = fmt.Sprintf("could not read file: %s", err)
+				break
+			}
+
+			entry.Status = fileStatusCollected
+			entry.SHA256 = hash
+			totalBytes += entry.Size
+			collectedCount += 1
+		}
+
+		manifest = append(manifest, entry)
+	}
+
+	manifestOut, err := z.Create(manifestFileName)
+	if err != nil {
+		return manifest, errors.Join(fmt.Errorf("creating manifest in zip: %w", err), z.Close())
+	}
+	enc := json.NewEncoder(manifestOut)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return manifest, errors.Join(fmt.Errorf("writing manifest: %w", err), z.Close())
+	}
+
+	return manifest, z.Close()
+}
+
+// checkFile returns the path with any symlinks resolved, the file's info, and the reason
+// the file should be skipped, if any.
+func (c *CollectFilesConsumer) checkFile(path string, maxFileBytes int64) (string, os.FileInfo, string) {
+	// Check both the path and its target, so that symlinks can't be used to bypass the deny-list
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, fmt.Sprintf("could not resolve path: %s", err)
+	}
+	launcherPaths := c.launcherPaths()
+	if isDenied(path, launcherPaths...) || isDenied(resolvedPath, launcherPaths...) {
+		return "", nil, "path is on deny-list"
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if err != nil {
+		return "", nil, fmt.Sprintf("could not stat file: %s", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", info, "not a regular file"
+	}
+	if info.Size() > maxFileBytes {
+		return "", info, "exceeds max_file_bytes"
+	}
+
+	return resolvedPath, info, ""
+}
+
+// launcherPaths returns launcher's own files and directories, which hold our keys, database,
+// enrollment secret, and configuration, so that we never collect them.
+func (c *CollectFilesConsumer) launcherPaths() []string {
+	paths := []string{
+		c.knapsack.RootDirectory(),
+		c.knapsack.EnrollSecretPath(),
+	}
+	if configFilePath := launcher.ConfigFilePath(os.Args); configFilePath != "" {
+		paths = append(paths, filepath.Dir(configFilePath))
+	}
+
+	return paths
+}
+
+// addFileToZip adds the file at resolvedPath -- which checkFile found and checked as info -- to
+// the zip, under files/ and the name of the originally matched path, and returns its SHA256 hash.
+// We copy at most the size we saw in info, in case the file is growing.
+func addFileToZip(z *zip.Writer, path string, resolvedPath string, info os.FileInfo) (string, error) {
+	f, err := os.Open(resolvedPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Make sure we opened the file we checked, and that it wasn't swapped out (e.g. for a
+	// symlink to a denied path) in the meantime
+	openedInfo, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("stat of opened file: %w", err)
+	}
+	if !os.SameFile(info, openedInfo) {
+		return "", errors.New("file changed after it was checked")
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return "", fmt.Errorf("creating zip header: %w", err)
+	}
+	header.Name = zipEntryName(path)
+	header.Method = zip.Deflate
+
+	out, err := z.CreateHeader(header)
+	if err != nil {
+		return "", fmt.Errorf("creating zip entry: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(f, info.Size())); err != nil {
+		return "", fmt.Errorf("copying file: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// zipEntryName returns a relative, slash-separated name for the file at path,
+// e.g. `C:\Users\me\file.txt` becomes `files/C/Users/me/file.txt`.
+func zipEntryName(path string) string {
+	name := filepath.ToSlash(path)
+	name = strings.ReplaceAll(name, ":", "")
+	return "files/" + strings.TrimLeft(name, "/")
+}
+
+// matchingPaths returns the sorted, deduplicated paths matching any of the given patterns.
+func matchingPaths(patterns []string) []string {
+	paths := make([]string, 0)
+	for _, pattern := range patterns {
+		// The only possible error is ErrBadPattern; a bad pattern just matches nothing
+		matches, _ := filepath.Glob(filepath.Clean(pattern))
+		paths = append(paths, matches...)
+	}
+
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
+// limit returns the requested value, or the default if unset, capped at max.
+func limit(requested, defaultValue, maxValue int64) int64 {
+	if requested <= 0 {
+		return defaultValue
+	}
+	return min(requested, maxValue)
+}
@@ -0,0 +1,312 @@
+package collectfilesconsumer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+type testUploadStream struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (s *testUploadStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *testUploadStream) Name() string {
+	return "test-upload"
+}
+
+type finishedAction struct {
+	id     string
+	result json.RawMessage
+	err    error
+}
+
+type testActionFinisher struct {
+	lock     sync.Mutex
+	finished []finishedAction
+}
+
+func (f *testActionFinisher) FinishAction(actionID string, result json.RawMessage, actionErr error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.finished = append(f.finished, finishedAction{id: actionID, result: result, err: actionErr})
+	return nil
+}
+
+func (f *testActionFinisher) finishedActions() []finishedAction {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]finishedAction{}, f.finished...)
+}
+
+// waitForFinish waits for the consumer to finish the given action in the background, and returns
+// its outcome.
+func waitForFinish(t *testing.T, finisher *testActionFinisher, actionID string) finishedAction {
+	require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+	finished := finisher.finishedActions()
+	require.Len(t, finished, 1)
+	require.Equal(t, actionID, finished[0].id)
+	return finished[0]
+}
+
+func TestDoAsync(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rootDir := t.TempDir()
+
+	allowedContents := []byte("launchagent contents")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "allowed.plist"), allowedContents, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "too_big.plist"), bytes.Repeat([]byte("a"), 100), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.pem"), []byte("key"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir.plist"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "launcher.db"), []byte("db"), 0600))
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink(filepath.Join(dir, "secret.pem"), filepath.Join(dir, "link.plist")))
+	}
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("RootDirectory").Return(rootDir)
+	mockKnapsack.On("EnrollSecretPath").Return(filepath.Join(dir, "secret"))
+
+	stream := &testUploadStream{}
+	finisher := &testActionFinisher{}
+	c := New(mockKnapsack, finisher)
+	c.newUploadStream = func(_, _ string) (uploadStream, error) {
+		return stream, nil
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("enroll secret"), 0600))
+
+	action, err := json.Marshal(collectFilesAction{
+		ID: "test-action",
+		Patterns: []string{
+			filepath.Join(dir, "secret"),
+			filepath.Join(dir, "*.plist"),
+			filepath.Join(dir, "*.pem"),
+			filepath.Join(rootDir, "*"),
+		},
+		MaxFileBytes: 50,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, c.DoAsync(bytes.NewReader(action)))
+	finished := waitForFinish(t, finisher, "test-action")
+	require.NoError(t, finished.err)
+	require.True(t, stream.closed)
+
+	var result collectFilesResult
+	require.NoError(t, json.Unmarshal(finished.result, &result))
+	require.Equal(t, "test-upload", result.UploadName)
+	require.Equal(t, 1, result.CollectedCount)
+	require.Equal(t, int64(len(allowedContents)), result.TotalBytes)
+
+	reasons := make(map[string]string)
+	for _, entry := range result.Files {
+		reasons[filepath.Base(entry.Path)] = entry.Reason
+	}
+	require.Equal(t, "", reasons["allowed.plist"])
+	require.Equal(t, "exceeds max_file_bytes", reasons["too_big.plist"])
+	require.Equal(t, "path is on deny-list", reasons["secret.pem"])
+	require.Equal(t, "path is on deny-list", reasons["launcher.db"])
+	require.Equal(t, "path is on deny-list", reasons["secret"])
+	require.Equal(t, "not a regular file", reasons["subdir.plist"])
+	if runtime.GOOS != "windows" {
+		require.Equal(t, "path is on deny-list", reasons["link.plist"])
+	}
+
+	// Confirm the zip holds the collected file and the manifest
+	z, err := zip.NewReader(bytes.NewReader(stream.Bytes()), int64(stream.Len()))
+	require.NoError(t, err)
+
+	entries := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		entries[f.Name] = contents
+	}
+	require.Len(t, entries, 2)
+	require.Equal(t, allowedContents, entries[zipEntryName(filepath.Join(dir, "allowed.plist"))])
+
+	var manifest []manifestEntry
+	require.NoError(t, json.Unmarshal(entries[manifestFileName], &manifest))
+	require.Len(t, manifest, len(result.Files))
+
+	expectedHash := sha256.Sum256(allowedContents)
+	for _, entry := range manifest {
+		if entry.Status == fileStatusCollected {
+			require.Equal(t, hex.EncodeToString(expectedHash[:]), entry.SHA256)
+			require.False(t, entry.ModTime.IsZero())
+		}
+	}
+}
+
+func TestCollect_Limits(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("0123456789"), 0600))
+	}
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("RootDirectory").Return(t.TempDir())
+	mockKnapsack.On("EnrollSecretPath").Return("")
+
+	for _, tt := range []struct {
+		testCaseName string
+		action       collectFilesAction
+	}{
+		{
+			testCaseName: "max files",
+			action:       collectFilesAction{Patterns: []string{filepath.Join(dir, "*.log")}, MaxFiles: 2},
+		},
+		{
+			testCaseName: "max total bytes",
+			action:       collectFilesAction{Patterns: []string{filepath.Join(dir, "*.log")}, MaxTotalBytes: 25},
+		},
+	} {
+		t.Run(tt.testCaseName, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(mockKnapsack, nil)
+			c.newUploadStream = func(_, _ string) (uploadStream, error) {
+				return &testUploadStream{}, nil
+			}
+
+			rawResult, err := c.collect(tt.action)
+			require.NoError(t, err)
+
+			var result collectFilesResult
+			require.NoError(t, json.Unmarshal(rawResult, &result))
+			require.Equal(t, 2, result.CollectedCount)
+			require.Equal(t, 1, result.SkippedCount)
+		})
+	}
+}
+
+func TestDoAsync_Errors(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	for _, tt := range []struct {
+		testCaseName string
+		action       string
+	}{
+		{testCaseName: "malformed action", action: `{"patterns": `},
+		{testCaseName: "no patterns", action: `{"patterns": []}`},
+		{testCaseName: "relative pattern", action: `{"patterns": ["some/relative/*.log"]}`},
+	} {
+		t.Run(tt.testCaseName, func(t *testing.T) {
+			t.Parallel()
+
+			finisher := &testActionFinisher{}
+			c := New(mockKnapsack, finisher)
+
+			// Bad actions are rejected without starting a collection
+			require.Error(t, c.Do(strings.NewReader(tt.action)))
+			require.Empty(t, finisher.finishedActions())
+		})
+	}
+}
+
+func TestDoAsync_UploadFailureIsReported(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	finisher := &testActionFinisher{}
+	c := New(mockKnapsack, finisher)
+	c.newUploadStream = func(_, _ string) (uploadStream, error) {
+		return nil, errors.New("test error")
+	}
+
+	action := `{"id": "test-action", "patterns": ["` + strings.ReplaceAll(filepath.Join(t.TempDir(), "*"), `\`, `\\`) + `"]}`
+	require.NoError(t, c.DoAsync(strings.NewReader(action)))
+	require.ErrorContains(t, waitForFinish(t, finisher, "test-action").err, "test error")
+}
+
+func Test_isDenied(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isDenied("/etc/shadow"))
+	require.True(t, isDenied("/home/me/.ssh/config"))
+	require.True(t, isDenied("/home/me/.ssh/id_ed25519"))
+	require.True(t, isDenied("/opt/app/server.key"))
+	require.True(t, isDenied("/var/kolide-k2/k2device.kolide.com/launcher.db", "/var/kolide-k2/k2device.kolide.com"))
+	require.True(t, isDenied("/etc/kolide-k2/secret", "/etc/kolide-k2/secret"))
+	require.True(t, isDenied("/etc/master.passwd"))
+	require.True(t, isDenied("/etc/krb5.keytab"))
+	require.True(t, isDenied("/home/me/.config/google-chrome/Default/Login Data"))
+	require.True(t, isDenied("/home/me/.config/google-chrome/Default/Cookies"))
+	require.True(t, isDenied("/home/me/.mozilla/firefox/abc.default/logins.json"))
+	require.True(t, isDenied("/Users/me/AppData/Roaming/Microsoft/Protect/S-1-5-21/key"))
+	require.True(t, isDenied("/Users/me/AppData/Local/Microsoft/Credentials/ABC123"))
+	require.False(t, isDenied("/home/me/.zsh_history"))
+	require.False(t, isDenied("/etc/shadowsocks.conf"))
+	require.False(t, isDenied("/Library/LaunchAgents/com.example.plist"))
+	require.False(t, isDenied("/Users/me/AppData/Roaming/Microsoft/Windows/Start Menu/app.lnk"))
+}
+
+func Test_addFileToZip_FileSwappedAfterCheck(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "allowed.plist")
+	require.NoError(t, os.WriteFile(path, []byte("launchagent contents"), 0600))
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("RootDirectory").Return(t.TempDir())
+	mockKnapsack.On("EnrollSecretPath").Return("")
+	c := New(mockKnapsack, nil)
+
+	resolvedPath, info, reason := c.checkFile(path, defaultMaxFileBytes)
+	require.Empty(t, reason)
+
+	// Replace the checked file before it's collected
+	swapped := filepath.Join(dir, "swapped")
+	require.NoError(t, os.WriteFile(swapped, []byte("something else"), 0600))
+	require.NoError(t, os.Rename(swapped, path))
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	_, err := addFileToZip(z, path, resolvedPath, info)
+	require.ErrorContains(t, err, "file changed after it was checked")
+	require.NoError(t, z.Close())
+}
+
+func Test_windowsDeniedPaths(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []string{`C:\Windows\System32\config`, `C:\Windows\repair`}, windowsDeniedPaths(""))
+	require.Equal(t, []string{`D:\WINNT\System32\config`, `D:\WINNT\repair`}, windowsDeniedPaths(`D:\WINNT\`))
+}
@@ -0,0 +1,143 @@
+package collectfilesconsumer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// deniedPaths are files and directories that we never collect, regardless of the patterns
+// in the action, because they hold credentials or other secrets.
+var deniedPaths = append([]string{
+	// Linux
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d",
+	"/etc/ssh",
+	"/root/.ssh",
+	// macOS and BSDs
+	"/etc/master.passwd",
+	"/Library/Keychains",
+	"/private/etc/master.passwd",
+	"/private/etc/sudoers",
+	"/private/etc/ssh",
+	"/private/var/db/dslocal",
+	"/private/var/db/SystemKey",
+}, windowsDeniedPaths(os.Getenv("SystemRoot"))...)
+
+// windowsDeniedPaths returns the denied paths under the Windows directory, which is given by
+// %SystemRoot% -- usually, but not always, C:\Windows.
+func windowsDeniedPaths(systemRoot string) []string {
+	if systemRoot == "" {
+		systemRoot = `C:\Windows`
+	}
+	systemRoot = strings.TrimRight(systemRoot, `\`)
+
+	return []string{
+		systemRoot + `\System32\config`,
+		systemRoot + `\repair`,
+	}
+}
+
+// deniedDirNames are directory names that we never collect from, wherever they appear.
+var deniedDirNames = []string{
+	".ssh",
+	".gnupg",
+	".aws",
+	".kube",
+	".password-store",
+	"Keychains",
+}
+
+// deniedDirSequences are slash-separated runs of directory names that we never collect from,
+// wherever they appear -- e.g. the DPAPI master keys and saved credentials in each Windows user's
+// AppData, and the system's under %SystemRoot%\System32.
+var deniedDirSequences = []string{
+	"Microsoft/Protect",
+	"Microsoft/Credentials",
+	"Microsoft/Vault",
+}
+
+// deniedFileNamePatterns are file name patterns (see filepath.Match) that we never collect.
+var deniedFileNamePatterns = []string{
+	"id_rsa*",
+	"id_dsa*",
+	"id_ecdsa*",
+	"id_ed25519*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"*.kdbx",
+	".netrc",
+	".pgpass",
+	"*.keychain",
+	"*.keychain-db",
+	"*.keytab",
+	// Browser credential and cookie stores
+	"Login Data*",
+	"Cookies*",
+	"cookies.sqlite*",
+	"logins.json",
+	"key[34].db",
+}
+
+// isDenied reports whether the given cleaned, absolute path is on the deny-list, or is one of
+// the given additional denied paths (e.g. launcher's own files) or under one of them.
+func isDenied(path string, additionalDeniedPaths ...string) bool {
+	for _, denied := range append(additionalDeniedPaths, deniedPaths...) {
+		if denied == "" {
+			continue
+		}
+		if pathEqual(path, denied) || hasPathPrefix(path, denied) {
+			return true
+		}
+	}
+
+	dir, base := filepath.Split(path)
+	for _, component := range strings.Split(filepath.ToSlash(dir), "/") {
+		for _, deniedDir := range deniedDirNames {
+			if pathEqual(component, deniedDir) {
+				return true
+			}
+		}
+	}
+
+	slashDir := normalizeCase(filepath.ToSlash(dir))
+	for _, deniedSequence := range deniedDirSequences {
+		if strings.Contains(slashDir, "/"+normalizeCase(deniedSequence)+"/") {
+			return true
+		}
+	}
+
+	for _, pattern := range deniedFileNamePatterns {
+		if matched, _ := filepath.Match(normalizeCase(pattern), normalizeCase(base)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasPathPrefix(path, dir string) bool {
+	dir = filepath.Clean(dir)
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(normalizeCase(path), normalizeCase(dir))
+}
+
+func pathEqual(a, b string) bool {
+	return normalizeCase(a) == normalizeCase(b)
+}
+
+// normalizeCase lowercases paths on platforms with case-insensitive filesystems by default,
+// so that the deny-list can't be bypassed with a differently-cased pattern.
+func normalizeCase(path string) string {
+	if runtime.GOOS == "linux" {
+		return path
+	}
+	return strings.ToLower(path)
+}
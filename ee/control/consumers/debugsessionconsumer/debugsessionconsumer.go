@@ -0,0 +1,424 @@
+package debugsessionconsumer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/debug/shipper"
+	"github.com/kolide/launcher/ee/gowrapper"
+	"github.com/kolide/launcher/pkg/backoff"
+)
+
+const (
+	// DebugSessionActorType identifies this action/actor type, which temporarily raises
+	// launcher's log level, captures the resulting debug logs, and uploads them when the
+	// session ends. This actor type belongs to the action subsystem.
+	DebugSessionActorType = "debug_session"
+
+	defaultSessionDuration = 15 * time.Minute
+	maxSessionDuration     = 1 * time.Hour
+
+	sessionFileName = "session.json"
+	logsFileName    = "launcher_debug.log"
+
+	// activeSessionKey is the key in the launcher history store under which we persist the
+	// session in progress, so that we can resume it after a launcher restart.
+	activeSessionKey = "debug_session"
+)
+
+// uploadStream receives the zip of captured logs, and uploads it on Close.
+type uploadStream interface {
+	io.WriteCloser
+	Name() string
+}
+
+type osqueryRestarter interface {
+	RestartWithReason(ctx context.Context, reason string) error
+}
+
+// actionFinisher records the outcome of the debug session action once its logs are uploaded.
+type actionFinisher interface {
+	FinishAction(actionID string, payload json.RawMessage, actionErr error) error
+}
+
+type DebugSessionConsumer struct {
+	knapsack         types.Knapsack
+	capture          *LogCapture
+	osqueryRestarter osqueryRestarter
+	actionFinisher   actionFinisher
+	slogger          *slog.Logger
+	// newUploadStream is assigned to a field so it can be mocked in tests
+	newUploadStream func(note, uploadRequestURL string) (uploadStream, error)
+	// sessionActive is true from the time a session starts until its logs are uploaded
+	sessionActive bool
+	sessionLock   sync.Mutex
+}
+
+type debugSessionAction struct {
+	ID               string `json:"id"`
+	DurationSeconds  int    `json:"duration_seconds,omitempty"` // defaults to defaultSessionDuration, capped at maxSessionDuration
+	OsqueryVerbose   bool   `json:"osquery_verbose,omitempty"`  // also restart osquery in verbose mode for the session
+	Note             string `json:"note"`
+	UploadRequestURL string `json:"upload_request_url"`
+}
+
+// sessionSummary describes the session, and is included in the upload alongside its logs.
+type sessionSummary struct {
+	Note            string    `json:"note"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	OsqueryVerbose  bool      `json:"osquery_verbose"`
+	LogCount        int       `json:"log_count"`
+	DroppedLogCount int       `json:"dropped_log_count"` // logs dropped because the buffer was full
+	// Resumed is set when launcher restarted during the session; logs captured before the
+	// restart are lost.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// activeSession is the session in progress, as persisted in the launcher history store.
+type activeSession struct {
+	Action    debugSessionAction `json:"action"`
+	StartedAt time.Time          `json:"started_at"`
+	EndsAt    time.Time          `json:"ends_at"`
+}
+
+// uploadResult is reported to the control server as the debug session action's result.
+type uploadResult struct {
+	UploadName      string `json:"upload_name"`
+	LogCount        int    `json:"log_count"`
+	DroppedLogCount int    `json:"dropped_log_count"`
+}
+
+func New(knapsack types.Knapsack, capture *LogCapture, osqueryRestarter osqueryRestarter, actionFinisher actionFinisher) *DebugSessionConsumer {
+	return &DebugSessionConsumer{
+		knapsack:         knapsack,
+		capture:          capture,
+		osqueryRestarter: osqueryRestarter,
+		actionFinisher:   actionFinisher,
+		slogger:          knapsack.Slogger().With("component", "debug_session_consumer"),
+		newUploadStream: func(note, uploadRequestURL string) (uploadStream, error) {
+			return shipper.New(knapsack, shipper.WithNote(note), shipper.WithUploadRequestURL(uploadRequestURL))
+		},
+	}
+}
+
+// Do implements the `actionqueue.actor` interface. The action queue calls DoAsync instead, so
+// that the session's outcome is reported once its logs are uploaded.
+func (d *DebugSessionConsumer) Do(data io.Reader) error {
+	return d.DoAsync(data)
+}
+
+// AsyncTimeout implements the `actionqueue.asyncActor` interface. Sessions last at most
+// maxSessionDuration, which leaves as long again to upload the session's logs.
+func (d *DebugSessionConsumer) AsyncTimeout() time.Duration {
+	return 2 * maxSessionDuration
+}
+
+// DoAsync implements the `actionqueue.asyncActor` interface. It starts the debug session and
+// returns immediately; the session's logs are uploaded in the background once it ends, and the
+// outcome is reported through the action queue.
+func (d *DebugSessionConsumer) DoAsync(data io.Reader) error {
+	var action debugSessionAction
+	if err := json.NewDecoder(data).Decode(&action); err != nil {
+		return fmt.Errorf("decoding debug_session action: %w", err)
+	}
+
+	duration := time.Duration(action.DurationSeconds) * time.Second
+	if duration <= 0 {
+		duration = defaultSessionDuration
+	}
+	duration = min(duration, maxSessionDuration)
+
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if d.sessionActive {
+		return errors.New("a debug session is already in progress")
+	}
+	d.sessionActive = true
+
+	d.slogger.Log(context.TODO(), slog.LevelInfo,
+		"starting debug session",
+		"note", action.Note,
+		"duration", duration.String(),
+		"osquery_verbose", action.OsqueryVerbose,
+	)
+
+	startedAt := time.Now().UTC()
+	session := activeSession{
+		Action:    action,
+		StartedAt: startedAt,
+		EndsAt:    startedAt.Add(duration),
+	}
+	d.setActiveSession(&session)
+
+	d.capture.start()
+
+	// The overrides expire on their own, so launcher reverts to its usual log level even
+	// if we never get to end the session
+	d.knapsack.SetLogShippingLevelOverride("debug", duration)
+	if action.OsqueryVerbose {
+		d.knapsack.SetOsqueryVerboseOverride(true, duration)
+		d.restartOsquery("debug session started")
+	}
+
+	gowrapper.Go(context.TODO(), d.slogger, func() {
+		<-time.After(duration)
+		d.endSession(session, false)
+	})
+
+	return nil
+}
+
+// ResumeSession picks up a debug session that was in progress when launcher last stopped.
+// It should be called at startup, before osquery launches. If the session has not yet
+// ended, its log level overrides are restored and it resumes capturing logs, to be uploaded
+// when it ends; otherwise, the logs it captured are lost, and we report that it failed.
+func (d *DebugSessionConsumer) ResumeSession() {
+	session, err := d.activeSession()
+	if err != nil {
+		d.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not read debug session in progress",
+			"err", err,
+		)
+		d.setActiveSession(nil)
+		return
+	}
+	if session == nil {
+		return
+	}
+
+	remaining := time.Until(session.EndsAt)
+	if remaining <= 0 {
+		d.slogger.Log(context.TODO(), slog.LevelWarn,
+			"launcher stopped during debug session, captured logs were lost",
+			"note", session.Action.Note,
+		)
+		d.setActiveSession(nil)
+		d.finishAction(session.Action.ID, nil, errors.New("launcher stopped before the debug session ended, and its captured logs were lost"))
+		return
+	}
+
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if d.sessionActive {
+		return
+	}
+	d.sessionActive = true
+
+	d.slogger.Log(context.TODO(), slog.LevelInfo,
+		"resuming debug session after launcher restart",
+		"note", session.Action.Note,
+		"remaining", remaining.String(),
+	)
+
+	d.capture.start()
+
+	// osquery hasn't launched yet, so it will pick up the verbose override without a restart
+	d.knapsack.SetLogShippingLevelOverride("debug", remaining)
+	if session.Action.OsqueryVerbose {
+		d.knapsack.SetOsqueryVerboseOverride(true, remaining)
+	}
+
+	gowrapper.Go(context.TODO(), d.slogger, func() {
+		<-time.After(remaining)
+		d.endSession(*session, true)
+	})
+}
+
+// endSession stops capturing logs, returns osquery to its usual verbosity, uploads
+// the captured logs, and reports the outcome.
+func (d *DebugSessionConsumer) endSession(session activeSession, resumed bool) {
+	defer func() {
+		d.sessionLock.Lock()
+		d.sessionActive = false
+		d.sessionLock.Unlock()
+	}()
+
+	action := session.Action
+	entries, dropped := d.capture.stop()
+	summary := sessionSummary{
+		Note:            action.Note,
+		StartedAt:       session.StartedAt,
+		EndedAt:         time.Now().UTC(),
+		OsqueryVerbose:  action.OsqueryVerbose,
+		LogCount:        len(entries),
+		DroppedLogCount: dropped,
+		Resumed:         resumed,
+	}
+
+	if action.OsqueryVerbose {
+		// Give the verbose override a moment to expire, so that osquery doesn't restart
+		// in verbose mode again. If verbose mode is also set by flag, we'll time out, and
+		// restarting is harmless.
+		_ = backoff.WaitFor(func() error {
+			if d.knapsack.OsqueryVerbose() {
+				return errors.New("osquery verbose override still active")
+			}
+			return nil
+		}, 5*time.Second, 250*time.Millisecond)
+		d.restartOsquery("debug session ended")
+	}
+
+	uploadName, err := d.upload(action, summary, entries)
+
+	// Whatever the outcome, we're done with this session
+	d.setActiveSession(nil)
+
+	if err != nil {
+		d.slogger.Log(context.TODO(), slog.LevelError,
+			"failed to upload debug session logs",
+			"note", action.Note,
+			"err", err,
+		)
+		d.finishAction(action.ID, nil, err)
+		return
+	}
+
+	d.slogger.Log(context.TODO(), slog.LevelInfo,
+		"completed debug session",
+		"note", action.Note,
+		"upload_name", uploadName,
+		"log_count", summary.LogCount,
+		"dropped_log_count", summary.DroppedLogCount,
+	)
+
+	result, err := json.Marshal(uploadResult{
+		UploadName:      uploadName,
+		LogCount:        summary.LogCount,
+		DroppedLogCount: summary.DroppedLogCount,
+	})
+	if err != nil {
+		// Still report success, without the details
+		result = nil
+	}
+	d.finishAction(action.ID, result, nil)
+}
+
+// upload zips up the session summary and captured logs, and uploads them. It returns the
+// name of the upload.
+func (d *DebugSessionConsumer) upload(action debugSessionAction, summary sessionSummary, entries [][]byte) (string, error) {
+	stream, err := d.newUploadStream(action.Note, action.UploadRequestURL)
+	if err != nil {
+		return "", fmt.Errorf("creating upload stream: %w", err)
+	}
+
+	zipErr := writeZip(stream, summary, entries)
+	if err := errors.Join(zipErr, stream.Close()); err != nil {
+		return "", fmt.Errorf("uploading logs: %w", err)
+	}
+
+	return stream.Name(), nil
+}
+
+// finishAction reports the outcome of the debug session action.
+func (d *DebugSessionConsumer) finishAction(actionID string, result json.RawMessage, actionErr error) {
+	if d.actionFinisher == nil || actionID == "" {
+		return
+	}
+
+	if err := d.actionFinisher.FinishAction(actionID, result, actionErr); err != nil {
+		d.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not record debug session result",
+			"action_id", actionID,
+			"err", err,
+		)
+	}
+}
+
+// activeSession returns the persisted session in progress, if any.
+func (d *DebugSessionConsumer) activeSession() (*activeSession, error) {
+	store := d.knapsack.LauncherHistoryStore()
+	if store == nil {
+		return nil, nil
+	}
+
+	rawSession, err := store.Get([]byte(activeSessionKey))
+	if err != nil {
+		return nil, fmt.Errorf("getting debug session from store: %w", err)
+	}
+	if len(rawSession) == 0 {
+		return nil, nil
+	}
+
+	var session activeSession
+	if err := json.Unmarshal(rawSession, &session); err != nil {
+		return nil, fmt.Errorf("unmarshalling debug session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// setActiveSession persists the session in progress, or clears it if session is nil.
+func (d *DebugSessionConsumer) setActiveSession(session *activeSession) {
+	store := d.knapsack.LauncherHistoryStore()
+	if store == nil {
+		return
+	}
+
+	var err error
+	if session == nil {
+		err = store.Delete([]byte(activeSessionKey))
+	} else {
+		var rawSession []byte
+		if rawSession, err = json.Marshal(session); err == nil {
+			err = store.Set([]byte(activeSessionKey), rawSession)
+		}
+	}
+	if err != nil {
+		d.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not persist debug session",
+			"err", err,
+		)
+	}
+}
+
+func (d *DebugSessionConsumer) restartOsquery(reason string) {
+	if d.osqueryRestarter == nil {
+		return
+	}
+
+	if err := d.osqueryRestarter.RestartWithReason(context.TODO(), reason); err != nil {
+		d.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not restart osquery for debug session",
+			"reason", reason,
+			"err", err,
+		)
+	}
+}
+
+// writeZip writes the session summary and its captured logs to a zip in w.
+func writeZip(w io.Writer, summary sessionSummary, entries [][]byte) error {
+	z := zip.NewWriter(w)
+
+	summaryOut, err := z.Create(sessionFileName)
+	if err != nil {
+		return errors.Join(fmt.Errorf("creating session summary in zip: %w", err), z.Close())
+	}
+	enc := json.NewEncoder(summaryOut)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		return errors.Join(fmt.Errorf("writing session summary: %w", err), z.Close())
+	}
+
+	logsOut, err := z.Create(logsFileName)
+	if err != nil {
+		return errors.Join(fmt.Errorf("creating logs in zip: %w", err), z.Close())
+	}
+	for _, entry := range entries {
+		if _, err := logsOut.Write(entry); err != nil {
+			return errors.Join(fmt.Errorf("writing logs: %w", err), z.Close())
+		}
+	}
+
+	return z.Close()
+}
@@ -0,0 +1,310 @@
+package debugsessionconsumer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type testUploadStream struct {
+	bytes.Buffer
+	lock   sync.Mutex
+	closed bool
+}
+
+func (s *testUploadStream) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *testUploadStream) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+func (s *testUploadStream) Name() string {
+	return "test-upload"
+}
+
+type testRestarter struct {
+	lock    sync.Mutex
+	reasons []string
+}
+
+func (r *testRestarter) RestartWithReason(_ context.Context, reason string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reasons = append(r.reasons, reason)
+	return nil
+}
+
+type finishedAction struct {
+	id     string
+	result json.RawMessage
+	err    error
+}
+
+type testActionFinisher struct {
+	lock     sync.Mutex
+	finished []finishedAction
+}
+
+func (f *testActionFinisher) FinishAction(actionID string, result json.RawMessage, actionErr error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.finished = append(f.finished, finishedAction{id: actionID, result: result, err: actionErr})
+	return nil
+}
+
+func (f *testActionFinisher) finishedActions() []finishedAction {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]finishedAction{}, f.finished...)
+}
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	capture := NewLogCapture()
+	slogger := multislogger.New(capture.SlogHandler()).Logger
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(slogger)
+	mockKnapsack.On("SetLogShippingLevelOverride", "debug", 1*time.Second).Return().Once()
+	mockKnapsack.On("SetOsqueryVerboseOverride", true, 1*time.Second).Return().Once()
+	mockKnapsack.On("OsqueryVerbose").Return(false)
+	historyStore := inmemory.NewStore()
+	mockKnapsack.On("LauncherHistoryStore").Return(historyStore)
+
+	restarter := &testRestarter{}
+	finisher := &testActionFinisher{}
+	stream := &testUploadStream{}
+	d := New(mockKnapsack, capture, restarter, finisher)
+	d.newUploadStream = func(_, _ string) (uploadStream, error) {
+		return stream, nil
+	}
+
+	// Logs before the session starts are not captured
+	slogger.Log(t.Context(), slog.LevelDebug, "before session")
+
+	action, err := json.Marshal(debugSessionAction{
+		ID:              "test-action",
+		DurationSeconds: 1,
+		OsqueryVerbose:  true,
+		Note:            "test session",
+	})
+	require.NoError(t, err)
+	require.NoError(t, d.DoAsync(bytes.NewReader(action)))
+
+	// The session is persisted, in case launcher restarts
+	session, err := d.activeSession()
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	require.Equal(t, "test-action", session.Action.ID)
+
+	// Only one session at a time
+	require.Error(t, d.DoAsync(bytes.NewReader(action)))
+
+	slogger.Log(t.Context(), slog.LevelDebug, "during session")
+
+	require.Eventually(t, stream.isClosed, 10*time.Second, 50*time.Millisecond)
+
+	// Logs after the session ends are not captured
+	slogger.Log(t.Context(), slog.LevelDebug, "after session")
+
+	restarter.lock.Lock()
+	require.Equal(t, []string{"debug session started", "debug session ended"}, restarter.reasons)
+	restarter.lock.Unlock()
+
+	z, err := zip.NewReader(bytes.NewReader(stream.Bytes()), int64(stream.Len()))
+	require.NoError(t, err)
+	require.Len(t, z.File, 2)
+
+	summaryFile, err := z.Open(sessionFileName)
+	require.NoError(t, err)
+	var summary sessionSummary
+	require.NoError(t, json.NewDecoder(summaryFile).Decode(&summary))
+	require.Equal(t, "test session", summary.Note)
+	require.True(t, summary.OsqueryVerbose)
+	require.Zero(t, summary.DroppedLogCount)
+	require.True(t, summary.EndedAt.After(summary.StartedAt))
+
+	logsFile, err := z.Open(logsFileName)
+	require.NoError(t, err)
+	logs, err := io.ReadAll(logsFile)
+	require.NoError(t, err)
+	require.Equal(t, summary.LogCount, strings.Count(string(logs), "\n"))
+	require.Contains(t, string(logs), "during session")
+	require.NotContains(t, string(logs), "before session")
+	require.NotContains(t, string(logs), "after session")
+
+	// We can start a new session once the previous one has been uploaded
+	require.Eventually(t, func() bool {
+		d.sessionLock.Lock()
+		defer d.sessionLock.Unlock()
+		return !d.sessionActive
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The upload is reported as the action's result, and the session is no longer persisted
+	finished := finisher.finishedActions()
+	require.Len(t, finished, 1)
+	require.Equal(t, "test-action", finished[0].id)
+	require.NoError(t, finished[0].err)
+	var result uploadResult
+	require.NoError(t, json.Unmarshal(finished[0].result, &result))
+	require.Equal(t, "test-upload", result.UploadName)
+	require.Equal(t, summary.LogCount, result.LogCount)
+	session, err = d.activeSession()
+	require.NoError(t, err)
+	require.Nil(t, session)
+}
+
+func TestDo_UploadFailureIsReported(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("SetLogShippingLevelOverride", "debug", 1*time.Second).Return().Once()
+	mockKnapsack.On("LauncherHistoryStore").Return(inmemory.NewStore())
+
+	finisher := &testActionFinisher{}
+	d := New(mockKnapsack, NewLogCapture(), nil, finisher)
+	d.newUploadStream = func(_, _ string) (uploadStream, error) {
+		return nil, errors.New("test error")
+	}
+
+	action, err := json.Marshal(debugSessionAction{ID: "test-action", DurationSeconds: 1})
+	require.NoError(t, err)
+	require.NoError(t, d.DoAsync(bytes.NewReader(action)))
+
+	require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+	finished := finisher.finishedActions()
+	require.Equal(t, "test-action", finished[0].id)
+	require.ErrorContains(t, finished[0].err, "test error")
+}
+
+func TestResumeSession(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name            string
+		endsIn          time.Duration
+		expectResumed   bool
+		expectUploadErr bool
+	}{
+		{
+			name:          "session still in progress",
+			endsIn:        1 * time.Second,
+			expectResumed: true,
+		},
+		{
+			name:            "session ended while launcher was stopped",
+			endsIn:          -1 * time.Minute,
+			expectUploadErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			capture := NewLogCapture()
+			slogger := multislogger.New(capture.SlogHandler()).Logger
+
+			mockKnapsack := typesmocks.NewKnapsack(t)
+			mockKnapsack.On("Slogger").Return(slogger)
+			mockKnapsack.On("LauncherHistoryStore").Return(inmemory.NewStore())
+			if tt.expectResumed {
+				mockKnapsack.On("SetLogShippingLevelOverride", "debug", mock.Anything).Return().Once()
+				mockKnapsack.On("SetOsqueryVerboseOverride", true, mock.Anything).Return().Once()
+				mockKnapsack.On("OsqueryVerbose").Return(false)
+			}
+
+			restarter := &testRestarter{}
+			finisher := &testActionFinisher{}
+			stream := &testUploadStream{}
+			d := New(mockKnapsack, capture, restarter, finisher)
+			d.newUploadStream = func(_, _ string) (uploadStream, error) {
+				return stream, nil
+			}
+
+			// Persist a session, as if launcher had stopped while it was in progress
+			startedAt := time.Now().UTC().Add(-5 * time.Minute)
+			d.setActiveSession(&activeSession{
+				Action:    debugSessionAction{ID: "test-action", Note: "test session", OsqueryVerbose: true},
+				StartedAt: startedAt,
+				EndsAt:    time.Now().UTC().Add(tt.endsIn),
+			})
+
+			d.ResumeSession()
+
+			if tt.expectResumed {
+				slogger.Log(t.Context(), slog.LevelDebug, "after restart")
+				require.Eventually(t, stream.isClosed, 10*time.Second, 50*time.Millisecond)
+
+				z, err := zip.NewReader(bytes.NewReader(stream.Bytes()), int64(stream.Len()))
+				require.NoError(t, err)
+				summaryFile, err := z.Open(sessionFileName)
+				require.NoError(t, err)
+				var summary sessionSummary
+				require.NoError(t, json.NewDecoder(summaryFile).Decode(&summary))
+				require.True(t, summary.Resumed)
+				require.True(t, summary.StartedAt.Equal(startedAt))
+				require.Positive(t, summary.LogCount)
+			}
+
+			require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+			finished := finisher.finishedActions()
+			require.Len(t, finished, 1)
+			require.Equal(t, "test-action", finished[0].id)
+			if tt.expectUploadErr {
+				require.Error(t, finished[0].err)
+				require.False(t, stream.isClosed(), "nothing to upload")
+			} else {
+				require.NoError(t, finished[0].err)
+			}
+
+			session, err := d.activeSession()
+			require.NoError(t, err)
+			require.Nil(t, session)
+		})
+	}
+}
+
+func TestLogCapture_DropsOldestLogs(t *testing.T) {
+	t.Parallel()
+
+	capture := NewLogCapture()
+	capture.maxBytes = 10
+	capture.start()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		_, err := capture.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	entries, dropped := capture.stop()
+	require.Equal(t, [][]byte{[]byte("bbbb\n"), []byte("cccc\n")}, entries)
+	require.Equal(t, 1, dropped)
+
+	// The buffer is empty, and the handler disabled, once the session stops
+	require.False(t, capture.SlogHandler().Enabled(t.Context(), slog.LevelError))
+	entries, dropped = capture.stop()
+	require.Empty(t, entries)
+	require.Zero(t, dropped)
+}
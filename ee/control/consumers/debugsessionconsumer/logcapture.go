@@ -0,0 +1,115 @@
+package debugsessionconsumer
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultMaxCapturedBytes bounds the logs held in memory for a single debug session;
+// once we reach it, the oldest logs are dropped to make room.
+const defaultMaxCapturedBytes = 20 * 1024 * 1024
+
+// LogCapture holds launcher's debug logs in a ring buffer while a debug session is active.
+// Its handler is added to launcher's slogger at startup, and discards everything until a
+// session starts.
+type LogCapture struct {
+	active   atomic.Bool
+	lock     sync.Mutex
+	entries  [][]byte // each entry is a single JSON log line
+	size     int
+	maxBytes int
+	dropped  int
+}
+
+func NewLogCapture() *LogCapture {
+	return &LogCapture{
+		entries:  make([][]byte, 0),
+		maxBytes: defaultMaxCapturedBytes,
+	}
+}
+
+// SlogHandler returns a handler that writes debug-level logs to the capture while a
+// session is active.
+func (lc *LogCapture) SlogHandler() slog.Handler {
+	return &captureHandler{
+		Handler: slog.NewJSONHandler(lc, &slog.HandlerOptions{
+			Level:     slog.LevelDebug,
+			AddSource: true,
+		}),
+		capture: lc,
+	}
+}
+
+// Write implements io.Writer for the JSON handler, which writes one log line per call.
+func (lc *LogCapture) Write(p []byte) (int, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	lc.entries = append(lc.entries, slices.Clone(p))
+	lc.size += len(p)
+
+	for lc.size > lc.maxBytes && len(lc.entries) > 0 {
+		lc.size -= len(lc.entries[0])
+		lc.entries = lc.entries[1:]
+		lc.dropped += 1
+	}
+
+	return len(p), nil
+}
+
+// start clears anything left over from a previous session and begins capturing logs.
+func (lc *LogCapture) start() {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	lc.reset()
+	lc.active.Store(true)
+}
+
+// stop stops capturing logs, and returns the captured logs along with the count of
+// logs dropped because the buffer was full.
+func (lc *LogCapture) stop() ([][]byte, int) {
+	lc.active.Store(false)
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	entries, dropped := lc.entries, lc.dropped
+	lc.reset()
+
+	return entries, dropped
+}
+
+// reset empties the buffer; the caller must hold lc.lock.
+func (lc *LogCapture) reset() {
+	lc.entries = make([][]byte, 0)
+	lc.size = 0
+	lc.dropped = 0
+}
+
+// captureHandler wraps the capture's JSON handler so that it's only enabled during a session.
+type captureHandler struct {
+	slog.Handler
+	capture *LogCapture
+}
+
+func (h *captureHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.capture.active.Load() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &captureHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		capture: h.capture,
+	}
+}
+
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	return &captureHandler{
+		Handler: h.Handler.WithGroup(name),
+		capture: h.capture,
+	}
+}
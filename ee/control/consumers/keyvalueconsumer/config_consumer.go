@@ -11,13 +11,18 @@ import (
 
 type ConfigConsumer struct {
 	updater types.Updater
+	opts    consumerOptions
 }
 
-func NewConfigConsumer(updater types.Updater) *ConfigConsumer {
+func NewConfigConsumer(updater types.Updater, opts ...Option) *ConfigConsumer {
 	c := &ConfigConsumer{
 		updater: updater,
 	}
 
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
 	return c
 }
 
@@ -26,22 +31,28 @@ func (c *ConfigConsumer) Update(data io.Reader) error {
 		return errors.New("key value consumer is nil")
 	}
 
-	var kvPairs map[string]any
-	if err := json.NewDecoder(data).Decode(&kvPairs); err != nil {
-		return fmt.Errorf("failed to decode key-value json: %w", err)
-	}
+	return update(c.updater, data, jsonCodec, c.opts)
+}
 
-	kvStringPairs := make(map[string]string)
-	for k, v := range kvPairs {
+// jsonCodec stores each value as its JSON encoding.
+var jsonCodec = valueCodec{
+	encode: func(value json.RawMessage) (string, error) {
+		// Round-trip the value so that it's stored in a consistent, compact form
+		var v any
+		if err := json.Unmarshal(value, &v); err != nil {
+			return "", fmt.Errorf("unable to unmarshal value: %w", err)
+		}
 		b, err := json.Marshal(v)
 		if err != nil {
-			return fmt.Errorf("unable to marshal value for `%s`: %w", k, err)
+			return "", fmt.Errorf("unable to marshal value: %w", err)
 		}
-		kvStringPairs[k] = string(b)
-	}
-
-	// Turn the map into a slice of key, value, ... and send it to the thing storing this data
-	_, err := c.updater.Update(kvStringPairs)
-
-	return err
+		return string(b), nil
+	},
+	decode: func(storedValue string) (any, error) {
+		var v any
+		if err := json.Unmarshal([]byte(storedValue), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	},
 }
@@ -11,13 +11,18 @@ import (
 
 type KeyValueConsumer struct {
 	updater types.Updater
+	opts    consumerOptions
 }
 
-func New(updater types.Updater) *KeyValueConsumer {
+func New(updater types.Updater, opts ...Option) *KeyValueConsumer {
 	c := &KeyValueConsumer{
 		updater: updater,
 	}
 
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
 	return c
 }
 
@@ -26,13 +31,19 @@ func (c *KeyValueConsumer) Update(data io.Reader) error {
 		return errors.New("key value consumer is nil")
 	}
 
-	var kvPairs map[string]string
-	if err := json.NewDecoder(data).Decode(&kvPairs); err != nil {
-		return fmt.Errorf("failed to decode key-value json: %w", err)
-	}
-
-	// Turn the map into a slice of key, value, ... and send it to the thing storing this data
-	_, err := c.updater.Update(kvPairs)
+	return update(c.updater, data, stringCodec, c.opts)
+}
 
-	return err
+// stringCodec stores JSON string values as-is; other value types are not allowed.
+var stringCodec = valueCodec{
+	encode: func(value json.RawMessage) (string, error) {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return "", fmt.Errorf("value must be a string: %w", err)
+		}
+		return s, nil
+	},
+	decode: func(storedValue string) (any, error) {
+		return storedValue, nil
+	},
 }
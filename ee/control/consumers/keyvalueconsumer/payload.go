@@ -0,0 +1,394 @@
+package keyvalueconsumer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"github.com/kolide/launcher/ee/agent/types"
+)
+
+const (
+	// payloadVersionKey marks a structured payload. Payloads without it are a flat object of
+	// key-value pairs that replaces the whole store.
+	payloadVersionKey       = "kv_payload_version"
+	supportedPayloadVersion = 1
+)
+
+// structuredPayload lets the control server send either the whole store (Data) or an
+// incremental, JSON-patch style update (Patch) against the store contents identified by
+// BaseHash. Exactly one of the two must be present.
+//
+// If a JSON schema is included, the resulting store contents must satisfy it before anything
+// is written, and it is persisted as the store's schema: later payloads that omit a schema,
+// including legacy payloads, are validated against it. A `null` schema removes it.
+type structuredPayload struct {
+	Version  int                        `json:"kv_payload_version"`
+	Schema   json.RawMessage            `json:"schema,omitempty"`
+	Data     map[string]json.RawMessage `json:"data,omitempty"`
+	Patch    []patchOperation           `json:"patch,omitempty"`
+	BaseHash string                     `json:"base_hash,omitempty"` // see storeHash
+}
+
+// patchOperation is a JSON patch (RFC 6902) operation. Because stores are flat, paths must
+// refer to a top-level key, e.g. `/desktop_enabled`; move and copy are not supported.
+type patchOperation struct {
+	Op    string          `json:"op"` // one of add, replace, remove, test
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// valueCodec converts between the JSON values in a payload and the string values in a store.
+type valueCodec struct {
+	encode func(value json.RawMessage) (string, error)
+	decode func(storedValue string) (any, error)
+}
+
+// patchableStore is a store that can be read, and updated incrementally.
+type patchableStore interface {
+	types.Iterator
+	types.Patcher
+}
+
+// Option configures a KeyValueConsumer or ConfigConsumer.
+type Option func(*consumerOptions)
+
+type consumerOptions struct {
+	schemaStore types.GetterSetterDeleter
+	storeName   string
+}
+
+// WithSchemaStore persists the last accepted schema for the consumer's store in schemaStore,
+// under storeName, so that payloads without a schema are still validated.
+func WithSchemaStore(schemaStore types.GetterSetterDeleter, storeName string) Option {
+	return func(o *consumerOptions) {
+		o.schemaStore = schemaStore
+		o.storeName = storeName
+	}
+}
+
+// update applies the payload read from data to the updater. Payloads are validated in full
+// before any write, so an invalid update is rejected without changing the store.
+func update(updater types.Updater, data io.Reader, codec valueCodec, opts consumerOptions) error {
+	var rawPayload map[string]json.RawMessage
+	if err := json.NewDecoder(data).Decode(&rawPayload); err != nil {
+		return fmt.Errorf("failed to decode key-value json: %w", err)
+	}
+
+	if _, ok := rawPayload[payloadVersionKey]; !ok {
+		kvPairs, err := encodeAll(rawPayload, codec)
+		if err != nil {
+			return err
+		}
+
+		schema, err := opts.storedSchema()
+		if err != nil {
+			return err
+		}
+		if err := validate(schema, kvPairs, codec); err != nil {
+			return err
+		}
+
+		_, err = updater.Update(kvPairs)
+		return err
+	}
+
+	var payload structuredPayload
+	if err := remarshal(rawPayload, &payload); err != nil {
+		return fmt.Errorf("failed to decode structured key-value payload: %w", err)
+	}
+	if payload.Version != supportedPayloadVersion {
+		return fmt.Errorf("unsupported key-value payload version %d", payload.Version)
+	}
+
+	// Require data or patch to be explicitly present -- a payload with neither must not be
+	// mistaken for a full update that empties the store.
+	hasData, hasPatch := present(rawPayload, "data"), present(rawPayload, "patch")
+	switch {
+	case hasData && hasPatch:
+		return errors.New("structured key-value payload cannot contain both data and patch")
+	case !hasData && !hasPatch:
+		return errors.New("structured key-value payload must contain either data or patch")
+	}
+
+	schema := payload.Schema
+	_, schemaGiven := rawPayload["schema"]
+	if !schemaGiven {
+		var err error
+		if schema, err = opts.storedSchema(); err != nil {
+			return err
+		}
+	}
+
+	if hasPatch {
+		if err := applyPatch(updater, payload, schema, codec); err != nil {
+			return err
+		}
+	} else {
+		kvPairs, err := encodeAll(payload.Data, codec)
+		if err != nil {
+			return err
+		}
+		if err := validate(schema, kvPairs, codec); err != nil {
+			return err
+		}
+
+		if _, err := updater.Update(kvPairs); err != nil {
+			return err
+		}
+	}
+
+	if schemaGiven {
+		return opts.storeSchema(schema)
+	}
+
+	return nil
+}
+
+// applyPatch applies the payload's patch operations to the store's current contents, validates
+// the result, and then writes only the changed keys. The store must match the patch's base hash,
+// unless the patch has already been applied (e.g. when the control service replays or re-fetches
+// the payload), in which case there is nothing to do.
+func applyPatch(updater types.Updater, payload structuredPayload, schema json.RawMessage, codec valueCodec) error {
+	store, ok := updater.(patchableStore)
+	if !ok {
+		return errors.New("store does not support patch updates")
+	}
+	if payload.BaseHash == "" {
+		return errors.New("patch payload must contain base_hash")
+	}
+
+	current := make(map[string]string)
+	if err := store.ForEach(func(k, v []byte) error {
+		current[string(k)] = string(v)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("reading current store contents: %w", err)
+	}
+
+	if currentHash := storeHash(current); currentHash != payload.BaseHash {
+		if alreadyApplied(payload.Patch, current, codec) {
+			return nil
+		}
+		return fmt.Errorf("store contents (%s) do not match patch base_hash %s", currentHash, payload.BaseHash)
+	}
+
+	patched := maps.Clone(current)
+	for i, op := range payload.Patch {
+		if err := op.apply(patched, codec); err != nil {
+			return fmt.Errorf("applying patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	if err := validate(schema, patched, codec); err != nil {
+		return err
+	}
+
+	changed := make(map[string]string)
+	for key, value := range patched {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			changed[key] = value
+		}
+	}
+	deleted := make([]string, 0)
+	for key := range current {
+		if _, ok := patched[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	return store.Patch(changed, deleted)
+}
+
+func (op patchOperation) apply(kvPairs map[string]string, codec valueCodec) error {
+	key, err := keyFromPath(op.Path)
+	if err != nil {
+		return err
+	}
+
+	_, exists := kvPairs[key]
+
+	switch op.Op {
+	case "add", "replace":
+		if op.Op == "replace" && !exists {
+			return errors.New("key does not exist")
+		}
+		if len(op.Value) == 0 {
+			return errors.New("missing value")
+		}
+		value, err := codec.encode(op.Value)
+		if err != nil {
+			return err
+		}
+		kvPairs[key] = value
+	case "remove":
+		if !exists {
+			return errors.New("key does not exist")
+		}
+		delete(kvPairs, key)
+	case "test":
+		if !exists {
+			return errors.New("key does not exist")
+		}
+		expected, err := codec.encode(op.Value)
+		if err != nil {
+			return err
+		}
+		if kvPairs[key] != expected {
+			return errors.New("test failed: value does not match")
+		}
+	default:
+		return fmt.Errorf("unsupported op %s", op.Op)
+	}
+
+	return nil
+}
+
+// alreadyApplied reports whether the store contents already reflect every change the patch makes,
+// so that applying it again would be a no-op.
+func alreadyApplied(ops []patchOperation, current map[string]string, codec valueCodec) bool {
+	expected := maps.Clone(current)
+	for _, op := range ops {
+		key, err := keyFromPath(op.Path)
+		if err != nil {
+			return false
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			value, err := codec.encode(op.Value)
+			if err != nil {
+				return false
+			}
+			expected[key] = value
+		case "remove":
+			delete(expected, key)
+		}
+	}
+
+	return maps.Equal(expected, current)
+}
+
+// storeHash identifies the given store contents, for use as a patch's base_hash: it is the
+// hex-encoded SHA-256 digest of the contents as a JSON object of string values, with sorted keys.
+func storeHash(kvPairs map[string]string) string {
+	// Marshalling a map[string]string can't fail, and sorts the keys
+	b, _ := json.Marshal(kvPairs)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// present reports whether the given key is present in the payload, and not null.
+func present(rawPayload map[string]json.RawMessage, key string) bool {
+	raw, ok := rawPayload[key]
+	return ok && string(raw) != "null"
+}
+
+// storedSchema returns the last accepted schema for the consumer's store, if any.
+func (o consumerOptions) storedSchema() (json.RawMessage, error) {
+	if o.schemaStore == nil {
+		return nil, nil
+	}
+
+	schema, err := o.schemaStore.Get([]byte(o.storeName))
+	if err != nil {
+		return nil, fmt.Errorf("getting stored schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// storeSchema persists the schema accepted for the consumer's store, removing it if it is null.
+func (o consumerOptions) storeSchema(schema json.RawMessage) error {
+	if o.schemaStore == nil {
+		return nil
+	}
+
+	if len(schema) == 0 || string(schema) == "null" {
+		if err := o.schemaStore.Delete([]byte(o.storeName)); err != nil {
+			return fmt.Errorf("deleting stored schema: %w", err)
+		}
+		return nil
+	}
+
+	if err := o.schemaStore.Set([]byte(o.storeName), schema); err != nil {
+		return fmt.Errorf("storing schema: %w", err)
+	}
+
+	return nil
+}
+
+// keyFromPath returns the store key referred to by the given JSON pointer.
+func keyFromPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return "", errors.New("path must start with /")
+	}
+
+	segment := strings.TrimPrefix(path, "/")
+	if strings.Contains(segment, "/") {
+		return "", errors.New("nested paths are not supported")
+	}
+	if segment == "" {
+		return "", errors.New("path must refer to a key")
+	}
+
+	// Unescape in this order, per RFC 6901
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~"), nil
+}
+
+func escapePathSegment(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// validate checks the given store contents against the schema, if there is one.
+func validate(schema json.RawMessage, kvPairs map[string]string, codec valueCodec) error {
+	if len(schema) == 0 || string(schema) == "null" {
+		return nil
+	}
+
+	instance := make(map[string]any, len(kvPairs))
+	for key, storedValue := range kvPairs {
+		value, err := codec.decode(storedValue)
+		if err != nil {
+			return fmt.Errorf("decoding value for `%s`: %w", key, err)
+		}
+		instance[key] = value
+	}
+
+	if err := validateSchema(schema, instance); err != nil {
+		return fmt.Errorf("rejecting update that does not match schema: %w", err)
+	}
+
+	return nil
+}
+
+func encodeAll(rawPairs map[string]json.RawMessage, codec valueCodec) (map[string]string, error) {
+	kvPairs := make(map[string]string, len(rawPairs))
+	for key, rawValue := range rawPairs {
+		value, err := codec.encode(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode value for `%s`: %w", key, err)
+		}
+		kvPairs[key] = value
+	}
+
+	return kvPairs, nil
+}
+
+// remarshal decodes the already-decoded payload into the given struct.
+func remarshal(rawPayload map[string]json.RawMessage, v any) error {
+	b, err := json.Marshal(rawPayload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
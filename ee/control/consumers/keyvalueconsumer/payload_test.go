@@ -0,0 +1,248 @@
+package keyvalueconsumer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"mode": {"type": "string", "enum": ["on", "off"]},
+		"interval": {"type": "string", "pattern": "^[0-9]+s$"}
+	},
+	"required": ["mode"],
+	"additionalProperties": false
+}`
+
+func TestKeyValueConsumer_Update(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name             string
+		initial          map[string]string
+		payload          string
+		expectedErr      bool
+		expectedContents map[string]string
+	}{
+		{
+			name:             "legacy payload replaces store",
+			initial:          map[string]string{"a": "1", "b": "2"},
+			payload:          `{"a": "3", "c": "4"}`,
+			expectedContents: map[string]string{"a": "3", "c": "4"},
+		},
+		{
+			name:             "legacy payload must have string values",
+			initial:          map[string]string{"a": "1"},
+			payload:          `{"a": 3}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"a": "1"},
+		},
+		{
+			name:             "structured data payload replaces store",
+			initial:          map[string]string{"a": "1", "b": "2"},
+			payload:          `{"kv_payload_version": 1, "data": {"a": "3"}}`,
+			expectedContents: map[string]string{"a": "3"},
+		},
+		{
+			name:             "structured data payload is validated",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "schema": ` + testSchema + `, "data": {"mode": "sometimes"}}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "patch payload updates only the given keys",
+			initial:          map[string]string{"mode": "on", "interval": "10s", "unrelated": "x"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "BASE_HASH", "patch": [{"op": "replace", "path": "/mode", "value": "off"}, {"op": "remove", "path": "/interval"}, {"op": "add", "path": "/new~1key", "value": "y"}]}`,
+			expectedContents: map[string]string{"mode": "off", "unrelated": "x", "new/key": "y"},
+		},
+		{
+			name:             "patch payload is valid against schema",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "BASE_HASH", "schema": ` + testSchema + `, "patch": [{"op": "test", "path": "/mode", "value": "on"}, {"op": "add", "path": "/interval", "value": "30s"}]}`,
+			expectedContents: map[string]string{"mode": "on", "interval": "30s"},
+		},
+		{
+			name:             "patch payload invalid against schema is rejected entirely",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "BASE_HASH", "schema": ` + testSchema + `, "patch": [{"op": "add", "path": "/interval", "value": "30s"}, {"op": "remove", "path": "/mode"}]}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "patch payload with failing test op is rejected entirely",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "BASE_HASH", "patch": [{"op": "add", "path": "/interval", "value": "30s"}, {"op": "test", "path": "/mode", "value": "off"}]}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "patch payload with nested path is rejected",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "BASE_HASH", "patch": [{"op": "add", "path": "/mode/nested", "value": "off"}]}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "patch payload must match base hash",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "` + storeHash(map[string]string{"mode": "off"}) + `", "patch": [{"op": "add", "path": "/interval", "value": "30s"}]}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "patch payload must have base hash",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "patch": [{"op": "add", "path": "/interval", "value": "30s"}]}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "already-applied patch payload is a no-op",
+			initial:          map[string]string{"mode": "off", "interval": "30s"},
+			payload:          `{"kv_payload_version": 1, "base_hash": "` + storeHash(map[string]string{"mode": "on", "old": "x"}) + `", "patch": [{"op": "test", "path": "/mode", "value": "on"}, {"op": "replace", "path": "/mode", "value": "off"}, {"op": "remove", "path": "/old"}, {"op": "add", "path": "/interval", "value": "30s"}]}`,
+			expectedContents: map[string]string{"mode": "off", "interval": "30s"},
+		},
+		{
+			name:             "structured payload without data or patch",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "schema": ` + testSchema + `}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "structured payload with null patch",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "patch": null}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "structured payload with empty data empties store",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "data": {}}`,
+			expectedContents: map[string]string{},
+		},
+		{
+			name:             "schema with unsupported keyword is rejected",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "schema": {"type": "object", "oneOf": [{"required": ["mode"]}]}, "data": {"mode": "off"}}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "schema with nested unsupported keyword is rejected",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "schema": {"type": "object", "properties": {"interval": {"type": "string", "format": "duration"}}}, "data": {"mode": "off"}}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "schema with annotations is accepted",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "schema": {"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "modes", "type": "object", "properties": {"mode": {"type": "string", "description": "the mode"}}}, "data": {"mode": "off"}}`,
+			expectedContents: map[string]string{"mode": "off"},
+		},
+		{
+			name:             "unsupported payload version",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 2, "data": {"mode": "off"}}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+		{
+			name:             "data and patch together",
+			initial:          map[string]string{"mode": "on"},
+			payload:          `{"kv_payload_version": 1, "data": {"mode": "off"}, "patch": []}`,
+			expectedErr:      true,
+			expectedContents: map[string]string{"mode": "on"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := inmemory.NewStore()
+			_, err := store.Update(tt.initial)
+			require.NoError(t, err)
+
+			payload := strings.ReplaceAll(tt.payload, "BASE_HASH", storeHash(tt.initial))
+			err = New(store).Update(strings.NewReader(payload))
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.expectedContents, storeContents(t, store))
+		})
+	}
+}
+
+func TestConfigConsumer_Update(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore()
+	_, err := store.Update(map[string]string{"tables": `[{"name":"a"}]`})
+	require.NoError(t, err)
+
+	schema := `{"type": "object", "properties": {"tables": {"type": "array", "minItems": 1, "items": {"type": "object", "required": ["name"]}}}}`
+	consumer := NewConfigConsumer(store)
+
+	// Patch values are stored as compact JSON, matching full updates
+	require.NoError(t, consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "base_hash": "`+storeHash(storeContents(t, store))+`", "schema": `+schema+`, "patch": [{"op": "replace", "path": "/tables", "value": [{"name": "a"}, {"name": "b"}]}]}`)))
+	require.Equal(t, map[string]string{"tables": `[{"name":"a"},{"name":"b"}]`}, storeContents(t, store))
+
+	// Invalid tables are rejected
+	err = consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "base_hash": "` + storeHash(storeContents(t, store)) + `", "schema": ` + schema + `, "patch": [{"op": "replace", "path": "/tables", "value": [{"table": "c"}]}]}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "/tables/0: missing required property name")
+	require.Equal(t, map[string]string{"tables": `[{"name":"a"},{"name":"b"}]`}, storeContents(t, store))
+}
+
+func TestUpdate_PersistsSchema(t *testing.T) {
+	t.Parallel()
+
+	store := inmemory.NewStore()
+	schemaStore := inmemory.NewStore()
+	consumer := New(store, WithSchemaStore(schemaStore, "test_store"))
+
+	// Accepting a payload with a schema persists it
+	require.NoError(t, consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "schema": `+testSchema+`, "data": {"mode": "on"}}`)))
+	storedSchema, err := schemaStore.Get([]byte("test_store"))
+	require.NoError(t, err)
+	require.JSONEq(t, testSchema, string(storedSchema))
+
+	// Later payloads without a schema, including legacy ones, are validated against it
+	require.Error(t, consumer.Update(strings.NewReader(`{"mode": "sometimes"}`)))
+	require.Error(t, consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "base_hash": "`+storeHash(storeContents(t, store))+`", "patch": [{"op": "add", "path": "/unknown", "value": "x"}]}`)))
+	require.Equal(t, map[string]string{"mode": "on"}, storeContents(t, store))
+	require.NoError(t, consumer.Update(strings.NewReader(`{"mode": "off"}`)))
+	require.Equal(t, map[string]string{"mode": "off"}, storeContents(t, store))
+
+	// A rejected payload's schema is not persisted
+	require.Error(t, consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "schema": {"required": ["other"]}, "data": {"mode": "on"}}`)))
+	storedSchema, err = schemaStore.Get([]byte("test_store"))
+	require.NoError(t, err)
+	require.JSONEq(t, testSchema, string(storedSchema))
+
+	// A null schema removes it
+	require.NoError(t, consumer.Update(strings.NewReader(`{"kv_payload_version": 1, "schema": null, "data": {"mode": "off"}}`)))
+	storedSchema, err = schemaStore.Get([]byte("test_store"))
+	require.NoError(t, err)
+	require.Empty(t, storedSchema)
+	require.NoError(t, consumer.Update(strings.NewReader(`{"mode": "sometimes"}`)))
+}
+
+func storeContents(t *testing.T, store types.Iterator) map[string]string {
+	contents := make(map[string]string)
+	require.NoError(t, store.ForEach(func(k, v []byte) error {
+		contents[string(k)] = string(v)
+		return nil
+	}))
+	return contents
+}
@@ -0,0 +1,314 @@
+package keyvalueconsumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON Schema that we support for validating key-value
+// payloads: type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum and maximum. Schemas
+// using any other keyword, apart from annotations, are rejected, so that a constraint
+// we don't understand can't let invalid data through.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Const                json.RawMessage        `json:"const,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties,omitempty"` // either a boolean or a schema
+	Items                *jsonSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+}
+
+// supportedSchemaKeywords are the keywords that jsonSchema validates, plus annotations, which
+// don't affect validation.
+var supportedSchemaKeywords = map[string]bool{
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"items":                true,
+	"minItems":             true,
+	"maxItems":             true,
+	"minLength":            true,
+	"maxLength":            true,
+	"pattern":              true,
+	"minimum":              true,
+	"maximum":              true,
+	// Annotations
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"deprecated":  true,
+	"readOnly":    true,
+	"writeOnly":   true,
+}
+
+// checkSchemaKeywords returns an error if the schema, or any schema nested in it, uses a
+// keyword that jsonSchema doesn't support.
+func checkSchemaKeywords(path string, rawSchema json.RawMessage) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(rawSchema, &keywords); err != nil {
+		return fmt.Errorf("%s: schema must be an object: %w", displayPath(path), err)
+	}
+
+	// Sort the keywords so that errors are reported in a stable order
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !supportedSchemaKeywords[name] {
+			return fmt.Errorf("%s: unsupported schema keyword %s", displayPath(path), name)
+		}
+	}
+
+	if rawProperties, ok := keywords["properties"]; ok {
+		var properties map[string]json.RawMessage
+		if err := json.Unmarshal(rawProperties, &properties); err != nil {
+			return fmt.Errorf("%s: properties must be an object: %w", displayPath(path), err)
+		}
+		propertyNames := make([]string, 0, len(properties))
+		for name := range properties {
+			propertyNames = append(propertyNames, name)
+		}
+		sort.Strings(propertyNames)
+
+		for _, name := range propertyNames {
+			if err := checkSchemaKeywords(path+"/properties/"+escapePathSegment(name), properties[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if items, ok := keywords["items"]; ok {
+		if err := checkSchemaKeywords(path+"/items", items); err != nil {
+			return err
+		}
+	}
+
+	// additionalProperties may also be a boolean
+	if additional, ok := keywords["additionalProperties"]; ok {
+		var allowed bool
+		if json.Unmarshal(additional, &allowed) != nil {
+			if err := checkSchemaKeywords(path+"/additionalProperties", additional); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// schemaTypes holds the schema's `type`, which may be given as a single type or a list of types.
+type schemaTypes []string
+
+func (st *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*st = schemaTypes{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings: %w", err)
+	}
+	*st = multiple
+	return nil
+}
+
+// validateSchema checks that the instance satisfies the given schema, returning all violations.
+// The instance is expected to have been decoded by encoding/json, so numbers are float64s.
+func validateSchema(rawSchema json.RawMessage, instance any) error {
+	if err := checkSchemaKeywords("", rawSchema); err != nil {
+		return fmt.Errorf("unsupported schema: %w", err)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(rawSchema, &schema); err != nil {
+		return fmt.Errorf("parsing schema: %w", err)
+	}
+
+	return errors.Join(schema.validate("", instance)...)
+}
+
+func (s *jsonSchema) validate(path string, instance any) []error {
+	if s == nil {
+		return nil
+	}
+
+	// If the type doesn't match, the other keywords can't meaningfully be checked
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(instance, t) }) {
+		return []error{fmt.Errorf("%s: expected type %s, got %s", displayPath(path), strings.Join(s.Type, " or "), typeName(instance))}
+	}
+
+	violations := make([]error, 0)
+	addViolation := func(format string, args ...any) {
+		violations = append(violations, fmt.Errorf("%s: %s", displayPath(path), fmt.Sprintf(format, args...)))
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, instance) }) {
+		addViolation("value is not one of the allowed values")
+	}
+
+	if len(s.Const) > 0 {
+		var constValue any
+		if err := json.Unmarshal(s.Const, &constValue); err != nil {
+			addViolation("invalid const in schema: %s", err)
+		} else if !reflect.DeepEqual(constValue, instance) {
+			addViolation("value does not match const")
+		}
+	}
+
+	switch v := instance.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			addViolation("length %d is less than minLength %d", length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			addViolation("length %d is greater than maxLength %d", length, *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				addViolation("invalid pattern in schema: %s", err)
+			} else if !re.MatchString(v) {
+				addViolation("value does not match pattern %s", s.Pattern)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			addViolation("%v is less than minimum %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			addViolation("%v is greater than maximum %v", v, *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			addViolation("%d items is fewer than minItems %d", len(v), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			addViolation("%d items is more than maxItems %d", len(v), *s.MaxItems)
+		}
+		for i, item := range v {
+			violations = append(violations, s.Items.validate(fmt.Sprintf("%s/%d", path, i), item)...)
+		}
+	case map[string]any:
+		for _, required := range s.Required {
+			if _, ok := v[required]; !ok {
+				addViolation("missing required property %s", required)
+			}
+		}
+
+		additional, err := s.additionalPropertiesSchema()
+		if err != nil {
+			addViolation("invalid additionalProperties in schema: %s", err)
+		}
+
+		// Sort the keys so that violations are reported in a stable order
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			propertyPath := path + "/" + escapePathSegment(key)
+			if propertySchema, ok := s.Properties[key]; ok {
+				violations = append(violations, propertySchema.validate(propertyPath, v[key])...)
+				continue
+			}
+			if additional == nil {
+				addViolation("property %s is not allowed", key)
+				continue
+			}
+			violations = append(violations, additional.validate(propertyPath, v[key])...)
+		}
+	}
+
+	return violations
+}
+
+// additionalPropertiesSchema returns the schema that properties not listed in `properties` must
+// satisfy, or nil if they're not allowed at all.
+func (s *jsonSchema) additionalPropertiesSchema() (*jsonSchema, error) {
+	if len(s.AdditionalProperties) == 0 {
+		return &jsonSchema{}, nil
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+		if allowed {
+			return &jsonSchema{}, nil
+		}
+		return nil, nil
+	}
+
+	var additional jsonSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &additional); err != nil {
+		// Fail closed, so that a bad schema doesn't let unexpected properties through
+		return nil, err
+	}
+	return &additional, nil
+}
+
+func hasType(instance any, t string) bool {
+	switch t {
+	case "integer":
+		v, ok := instance.(float64)
+		return ok && v == math.Trunc(v)
+	default:
+		return typeName(instance) == t
+	}
+}
+
+func typeName(instance any) string {
+	switch instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", instance)
+	}
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
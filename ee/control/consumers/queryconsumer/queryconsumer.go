@@ -0,0 +1,221 @@
+package queryconsumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/gowrapper"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/kolide/launcher/pkg/backoff"
+)
+
+const (
+	// QueryActorType identifies this action/actor type, which runs an ad hoc osquery query
+	// and reports its results via the action's result. This actor type belongs to the
+	// action subsystem.
+	QueryActorType = "query"
+
+	defaultMaxRows = 1000
+	// maxBytesLimit keeps the marshalled rows, plus the rest of the result payload, within
+	// the actionqueue's result payload limit.
+	maxBytesLimit  = 60 * 1024
+	defaultTimeout = 30 * time.Second
+	maxTimeout     = 5 * time.Minute
+)
+
+type querier interface {
+	Query(query string) ([]map[string]string, error)
+}
+
+// actionFinisher records the outcome of the query action once the query completes.
+type actionFinisher interface {
+	FinishAction(actionID string, payload json.RawMessage, actionErr error) error
+}
+
+type QueryConsumer struct {
+	knapsack       types.Knapsack
+	querier        querier
+	actionFinisher actionFinisher
+	slogger        *slog.Logger
+}
+
+type queryAction struct {
+	ID             string `json:"id"`
+	Query          string `json:"query"`
+	MaxRows        int    `json:"max_rows,omitempty"`        // defaults to defaultMaxRows
+	MaxBytes       int    `json:"max_bytes,omitempty"`       // defaults to, and is capped at, maxBytesLimit
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // defaults to defaultTimeout, capped at maxTimeout
+}
+
+// queryResult is the payload returned as the action's result.
+type queryResult struct {
+	Rows       []map[string]string `json:"rows"`
+	RowCount   int                 `json:"row_count"` // the total number of rows returned by the query, before truncation
+	Truncated  bool                `json:"truncated"` // whether rows were dropped to stay within the row/byte budget
+	DurationMs int64               `json:"duration_ms"`
+}
+
+func New(knapsack types.Knapsack, querier querier, actionFinisher actionFinisher) *QueryConsumer {
+	return &QueryConsumer{
+		knapsack:       knapsack,
+		querier:        querier,
+		actionFinisher: actionFinisher,
+		slogger:        knapsack.Slogger().With("component", "query_consumer"),
+	}
+}
+
+// Do implements the `actionqueue.actor` interface. The action queue calls DoAsync instead, so
+// that the query's results are reported once it completes.
+func (q *QueryConsumer) Do(data io.Reader) error {
+	return q.DoAsync(data)
+}
+
+// AsyncTimeout implements the `actionqueue.asyncActor` interface. Queries give up after at most
+// maxTimeout; the extra minute leaves time to report the result.
+func (q *QueryConsumer) AsyncTimeout() time.Duration {
+	return maxTimeout + 1*time.Minute
+}
+
+// DoAsync implements the `actionqueue.asyncActor` interface. It starts the query given in the
+// action and returns immediately, so that a slow query does not hold up other actions or
+// control server updates. Once the query completes, its results, truncated to the action's row
+// and byte budget, are reported through the action queue. This path does not depend on osquery's
+// distributed query plumbing, so it still works for hosts where distributed queries are stuck.
+func (q *QueryConsumer) DoAsync(data io.Reader) error {
+	var action queryAction
+	if err := json.NewDecoder(data).Decode(&action); err != nil {
+		return fmt.Errorf("decoding query action: %w", err)
+	}
+
+	if action.Query == "" {
+		return errors.New("query action has no query")
+	}
+
+	maxRows := action.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+	maxBytes := action.MaxBytes
+	if maxBytes <= 0 || maxBytes > maxBytesLimit {
+		maxBytes = maxBytesLimit
+	}
+	timeout := time.Duration(action.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timeout = min(timeout, maxTimeout)
+
+	q.slogger.Log(context.TODO(), slog.LevelInfo,
+		"running ad hoc query from control server",
+		"action_id", action.ID,
+		"max_rows", maxRows,
+		"max_bytes", maxBytes,
+		"timeout", timeout.String(),
+	)
+
+	gowrapper.Go(context.TODO(), q.slogger, func() {
+		result, err := q.runQuery(action.Query, maxRows, maxBytes, timeout)
+		q.finishAction(action.ID, result, err)
+	})
+
+	return nil
+}
+
+// runQuery runs the given query, and returns its results truncated to the given budget.
+func (q *QueryConsumer) runQuery(query string, maxRows, maxBytes int, timeout time.Duration) (json.RawMessage, error) {
+	start := time.Now()
+	rows, err := q.queryWithTimeout(query, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("running query: %w", err)
+	}
+
+	result := budgetRows(rows, maxRows, maxBytes)
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	return json.Marshal(result)
+}
+
+// finishAction reports the outcome of the query action.
+func (q *QueryConsumer) finishAction(actionID string, result json.RawMessage, actionErr error) {
+	if q.actionFinisher == nil || actionID == "" {
+		return
+	}
+
+	if err := q.actionFinisher.FinishAction(actionID, result, actionErr); err != nil {
+		q.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not record query result",
+			"action_id", actionID,
+			"err", err,
+		)
+	}
+}
+
+// queryWithTimeout runs the given query with the same retries as localserver, bypassing the
+// table cache so that responders see fresh data. The querier can't be canceled, so on timeout,
+// the query continues in the background and its results are discarded.
+func (q *QueryConsumer) queryWithTimeout(query string, timeout time.Duration) ([]map[string]string, error) {
+	type queryResponse struct {
+		rows []map[string]string
+		err  error
+	}
+	responseChan := make(chan queryResponse, 1)
+
+	gowrapper.Go(context.TODO(), q.slogger, func() {
+		var rows []map[string]string
+		var err error
+
+		tablewrapper.WithFreshResults(func() {
+			backoff.WaitFor(func() error {
+				rows, err = q.querier.Query(query)
+				return err
+			}, 1*time.Second, 250*time.Millisecond)
+		})
+
+		responseChan <- queryResponse{rows: rows, err: err}
+	})
+
+	select {
+	case resp := <-responseChan:
+		return resp.rows, resp.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("query did not complete within %s", timeout.String())
+	}
+}
+
+// budgetRows returns as many of the given rows as fit within maxRows and maxBytes.
+func budgetRows(rows []map[string]string, maxRows, maxBytes int) queryResult {
+	result := queryResult{
+		Rows:     make([]map[string]string, 0),
+		RowCount: len(rows),
+	}
+
+	usedBytes := 0
+	for _, row := range rows {
+		if len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+
+		rawRow, err := json.Marshal(row)
+		if err != nil {
+			continue
+		}
+
+		// Count the separating comma too
+		if usedBytes+len(rawRow)+1 > maxBytes {
+			result.Truncated = true
+			break
+		}
+
+		usedBytes += len(rawRow) + 1
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result
+}
@@ -0,0 +1,225 @@
+package queryconsumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/control/actionqueue"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+type testQuerier struct {
+	rows  []map[string]string
+	err   error
+	delay time.Duration
+}
+
+func (tq *testQuerier) Query(_ string) ([]map[string]string, error) {
+	time.Sleep(tq.delay)
+	return tq.rows, tq.err
+}
+
+type finishedAction struct {
+	id     string
+	result json.RawMessage
+	err    error
+}
+
+type testActionFinisher struct {
+	lock     sync.Mutex
+	finished []finishedAction
+}
+
+func (f *testActionFinisher) FinishAction(actionID string, result json.RawMessage, actionErr error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.finished = append(f.finished, finishedAction{id: actionID, result: result, err: actionErr})
+	return nil
+}
+
+func (f *testActionFinisher) finishedActions() []finishedAction {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]finishedAction{}, f.finished...)
+}
+
+func testRows(count int) []map[string]string {
+	rows := make([]map[string]string, count)
+	for i := range count {
+		rows[i] = map[string]string{"name": fmt.Sprintf("row-%d", i)}
+	}
+	return rows
+}
+
+func TestDoAsync(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		testCaseName      string
+		action            string
+		querier           *testQuerier
+		expectStartErr    bool
+		expectErr         bool
+		expectedRows      int
+		expectedRowCount  int
+		expectedTruncated bool
+	}{
+		{
+			testCaseName:     "all rows returned",
+			action:           `{"id": "test-action", "query": "select name from test"}`,
+			querier:          &testQuerier{rows: testRows(5)},
+			expectedRows:     5,
+			expectedRowCount: 5,
+		},
+		{
+			testCaseName:      "truncated by row budget",
+			action:            `{"id": "test-action", "query": "select name from test", "max_rows": 2}`,
+			querier:           &testQuerier{rows: testRows(5)},
+			expectedRows:      2,
+			expectedRowCount:  5,
+			expectedTruncated: true,
+		},
+		{
+			testCaseName:      "truncated by byte budget",
+			action:            `{"id": "test-action", "query": "select name from test", "max_bytes": 40}`,
+			querier:           &testQuerier{rows: testRows(5)},
+			expectedRows:      2,
+			expectedRowCount:  5,
+			expectedTruncated: true,
+		},
+		{
+			testCaseName:   "no query",
+			action:         `{"id": "test-action", "max_rows": 2}`,
+			querier:        &testQuerier{},
+			expectStartErr: true,
+		},
+		{
+			testCaseName:   "malformed action",
+			action:         `{"query": `,
+			querier:        &testQuerier{},
+			expectStartErr: true,
+		},
+		{
+			testCaseName: "timeout",
+			action:       `{"id": "test-action", "query": "select name from test", "timeout_seconds": 1}`,
+			querier:      &testQuerier{rows: testRows(1), delay: 3 * time.Second},
+			expectErr:    true,
+		},
+	} {
+		t.Run(tt.testCaseName, func(t *testing.T) {
+			t.Parallel()
+
+			mockKnapsack := typesmocks.NewKnapsack(t)
+			mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+			finisher := &testActionFinisher{}
+			qc := New(mockKnapsack, tt.querier, finisher)
+
+			err := qc.DoAsync(strings.NewReader(tt.action))
+			if tt.expectStartErr {
+				require.Error(t, err)
+				require.Empty(t, finisher.finishedActions())
+				return
+			}
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+			finished := finisher.finishedActions()
+			require.Len(t, finished, 1)
+			require.Equal(t, "test-action", finished[0].id)
+			if tt.expectErr {
+				require.Error(t, finished[0].err)
+				return
+			}
+			require.NoError(t, finished[0].err)
+
+			var result queryResult
+			require.NoError(t, json.Unmarshal(finished[0].result, &result))
+			require.Len(t, result.Rows, tt.expectedRows)
+			require.Equal(t, tt.expectedRowCount, result.RowCount)
+			require.Equal(t, tt.expectedTruncated, result.Truncated)
+		})
+	}
+}
+
+func TestDo_QueryError(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	finisher := &testActionFinisher{}
+	qc := New(mockKnapsack, &testQuerier{err: errors.New("test error")}, finisher)
+
+	// The query fails in the background, so the failure is reported rather than returned
+	require.NoError(t, qc.Do(strings.NewReader(`{"id": "test-action", "query": "select name from test", "timeout_seconds": 5}`)))
+	require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+	finished := finisher.finishedActions()
+	require.Equal(t, "test-action", finished[0].id)
+	require.ErrorContains(t, finished[0].err, "test error")
+}
+
+func TestDoAsync_DoesNotWaitForQuery(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	finisher := &testActionFinisher{}
+	qc := New(mockKnapsack, &testQuerier{rows: testRows(1), delay: 2 * time.Second}, finisher)
+
+	start := time.Now()
+	require.NoError(t, qc.DoAsync(strings.NewReader(`{"id": "test-action", "query": "select name from test"}`)))
+	require.Less(t, time.Since(start), 1*time.Second)
+	require.Empty(t, finisher.finishedActions())
+
+	require.Eventually(t, func() bool { return len(finisher.finishedActions()) > 0 }, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, finisher.finishedActions()[0].err)
+}
+
+func TestDoAsync_WithActionQueue(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	store := inmemory.NewStore()
+	aq := actionqueue.New(mockKnapsack, actionqueue.WithStore(store))
+	aq.RegisterActor(QueryActorType, New(mockKnapsack, &testQuerier{rows: testRows(3)}, aq))
+
+	// The query finishes right away, likely before the action queue is done starting it
+	actionID := ulid.New()
+	rawActions := fmt.Sprintf(`[{"id": "%s", "type": "%s", "valid_until": %d, "query": "select name from test"}]`,
+		actionID, QueryActorType, time.Now().Add(time.Hour).Unix())
+	require.NoError(t, aq.Update(strings.NewReader(rawActions)))
+
+	var record struct {
+		ProcessedAt time.Time `json:"processed_at"`
+		Result      struct {
+			Status  string          `json:"status"`
+			Payload json.RawMessage `json:"payload"`
+		} `json:"result"`
+	}
+	require.Eventually(t, func() bool {
+		rawRecord, err := store.Get([]byte(actionID))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(rawRecord, &record))
+		return record.Result.Status != actionqueue.ActionStatusRunning
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, actionqueue.ActionStatusSucceeded, record.Result.Status)
+	require.False(t, record.ProcessedAt.IsZero())
+
+	var result queryResult
+	require.NoError(t, json.Unmarshal(record.Result.Payload, &result))
+	require.Len(t, result.Rows, 3)
+}
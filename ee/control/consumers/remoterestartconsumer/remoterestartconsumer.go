@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,6 +24,21 @@ const (
 	// restartDelay is the delay after receiving action before triggering the restart.
 	// We have a delay to allow the actionqueue.
 	restartDelay = 15 * time.Second
+
+	// restartHistoryKey is the key in the launcher history store under which we record
+	// the most recent remote restarts.
+	restartHistoryKey = "remote_restarts"
+	// maxRestartHistory is the number of remote restarts we keep in the launcher history store.
+	maxRestartHistory = 20
+)
+
+// The subsystems that can be restarted individually, rather than restarting launcher.
+const (
+	SubsystemLauncher    = "launcher" // the default, restarts the whole launcher process
+	SubsystemOsquery     = "osquery"
+	SubsystemDesktop     = "desktop"
+	SubsystemLocalserver = "localserver"
+	SubsystemLogShipper  = "log_shipper"
 )
 
 var (
@@ -35,10 +51,36 @@ type RemoteRestartConsumer struct {
 	signalRestart chan error
 	interrupt     chan struct{}
 	interrupted   atomic.Bool
+	restarters    map[string]subsystemRestarter
+	restartersMu  sync.RWMutex
+	historyMu     sync.Mutex
+}
+
+// subsystemRestarter is a rungroup actor that can restart itself in place, without
+// restarting launcher.
+type subsystemRestarter interface {
+	Restart(ctx context.Context) error
+}
+
+// reasonRestarter is an optional interface for subsystem restarters that record why they
+// restarted, e.g. the osquery runner, which notes the reason in osquery instance history.
+type reasonRestarter interface {
+	RestartWithReason(ctx context.Context, reason string) error
 }
 
 type remoteRestartAction struct {
-	RunID string `json:"run_id"` // the run ID for the launcher run to restart
+	RunID     string `json:"run_id"`              // the run ID for the launcher run to restart
+	Subsystem string `json:"subsystem,omitempty"` // the subsystem to restart; defaults to SubsystemLauncher
+	Reason    string `json:"reason,omitempty"`    // why the control server requested the restart
+}
+
+// restartRecord describes a remote restart, and is stored in the launcher history store.
+type restartRecord struct {
+	Subsystem   string    `json:"subsystem"`
+	Reason      string    `json:"reason"`
+	RunID       string    `json:"run_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	Error       string    `json:"error,omitempty"`
 }
 
 func New(knapsack types.Knapsack) *RemoteRestartConsumer {
@@ -47,14 +89,25 @@ func New(knapsack types.Knapsack) *RemoteRestartConsumer {
 		slogger:       knapsack.Slogger().With("component", "remote_restart_consumer"),
 		signalRestart: make(chan error, 1),
 		interrupt:     make(chan struct{}, 1),
+		restarters:    make(map[string]subsystemRestarter),
 	}
 }
 
+// RegisterSubsystemRestarter allows remote restart actions to restart the given subsystem
+// on its own, via the given restarter.
+func (r *RemoteRestartConsumer) RegisterSubsystemRestarter(subsystem string, restarter subsystemRestarter) {
+	r.restartersMu.Lock()
+	defer r.restartersMu.Unlock()
+
+	r.restarters[subsystem] = restarter
+}
+
 // Do implements the `actionqueue.actor` interface, and allows the actionqueue
 // to pass `remote_restart` type actions to this consumer. The actionqueue validates
 // that this action has not already been performed and that this action is still
 // valid (i.e. not expired). `Do` additionally validates that the `run_id` given in
-// the action matches the current launcher run ID.
+// the action matches the current launcher run ID. If the action names a subsystem,
+// only that subsystem is restarted.
 func (r *RemoteRestartConsumer) Do(data io.Reader) error {
 	var restartAction remoteRestartAction
 
@@ -79,6 +132,12 @@ func (r *RemoteRestartConsumer) Do(data io.Reader) error {
 		return nil
 	}
 
+	if restartAction.Subsystem != "" && restartAction.Subsystem != SubsystemLauncher {
+		return r.restartSubsystem(restartAction)
+	}
+
+	r.recordRestart(restartAction, nil)
+
 	// Perform the restart by signaling actor shutdown, but delay slightly to give
 	// the actionqueue a chance to process all actions and store their statuses.
 	gowrapper.Go(context.TODO(), r.slogger, func() {
@@ -107,6 +166,110 @@ func (r *RemoteRestartConsumer) Do(data io.Reader) error {
 	return nil
 }
 
+// restartSubsystem restarts the subsystem named in the action in place, and records the restart.
+func (r *RemoteRestartConsumer) restartSubsystem(restartAction remoteRestartAction) error {
+	r.restartersMu.RLock()
+	restarter, ok := r.restarters[restartAction.Subsystem]
+	r.restartersMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("no restarter registered for subsystem %s", restartAction.Subsystem)
+	}
+
+	r.slogger.Log(context.TODO(), slog.LevelInfo,
+		"received remote restart action for subsystem, restarting",
+		"subsystem", restartAction.Subsystem,
+		"reason", restartAction.Reason,
+	)
+
+	var restartErr error
+	if rr, ok := restarter.(reasonRestarter); ok {
+		reason := restartAction.Reason
+		if reason == "" {
+			reason = "remote restart requested"
+		}
+		restartErr = rr.RestartWithReason(context.TODO(), reason)
+	} else {
+		restartErr = restarter.Restart(context.TODO())
+	}
+	r.recordRestart(restartAction, restartErr)
+	if restartErr != nil {
+		return fmt.Errorf("restarting subsystem %s: %w", restartAction.Subsystem, restartErr)
+	}
+
+	return nil
+}
+
+// recordRestart adds the restart to the history in the launcher history store,
+// keeping only the most recent restarts.
+func (r *RemoteRestartConsumer) recordRestart(restartAction remoteRestartAction, restartErr error) {
+	r.historyMu.Lock()
+	defer r.historyMu.Unlock()
+
+	store := r.knapsack.LauncherHistoryStore()
+	if store == nil {
+		return
+	}
+
+	record := restartRecord{
+		Subsystem:   restartAction.Subsystem,
+		Reason:      restartAction.Reason,
+		RunID:       restartAction.RunID,
+		RequestedAt: time.Now().UTC(),
+	}
+	if record.Subsystem == "" {
+		record.Subsystem = SubsystemLauncher
+	}
+	if restartErr != nil {
+		record.Error = restartErr.Error()
+	}
+
+	history, err := restartHistory(store)
+	if err != nil {
+		r.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not read remote restart history, starting over",
+			"err", err,
+		)
+		history = make([]restartRecord, 0)
+	}
+
+	history = append(history, record)
+	if len(history) > maxRestartHistory {
+		history = history[len(history)-maxRestartHistory:]
+	}
+
+	rawHistory, err := json.Marshal(history)
+	if err == nil {
+		err = store.Set([]byte(restartHistoryKey), rawHistory)
+	}
+	if err != nil {
+		r.slogger.Log(context.TODO(), slog.LevelError,
+			"could not record remote restart in launcher history",
+			"subsystem", record.Subsystem,
+			"err", err,
+		)
+	}
+}
+
+// restartHistory returns the most recent remote restarts recorded in the given launcher
+// history store, oldest first.
+func restartHistory(store types.Getter) ([]restartRecord, error) {
+	rawHistory, err := store.Get([]byte(restartHistoryKey))
+	if err != nil {
+		return nil, fmt.Errorf("getting remote restart history: %w", err)
+	}
+
+	history := make([]restartRecord, 0)
+	if len(rawHistory) == 0 {
+		return history, nil
+	}
+	if err := json.Unmarshal(rawHistory, &history); err != nil {
+		return nil, fmt.Errorf("unmarshalling remote restart history: %w", err)
+	}
+
+	return history, nil
+}
+
 // Execute allows the remote restart consumer to run in the main launcher rungroup.
 // It waits until it receives a remote restart action from `Do`, or until it receives
 // a `Interrupt` request.
@@ -2,6 +2,7 @@ package remoterestartconsumer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
 	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
 	"github.com/kolide/launcher/pkg/log/multislogger"
 	"github.com/kolide/launcher/pkg/threadsafebuffer"
@@ -23,6 +25,7 @@ func TestDo(t *testing.T) {
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
 	mockKnapsack.On("GetRunID").Return(currentRunId)
+	mockKnapsack.On("LauncherHistoryStore").Return(inmemory.NewStore())
 
 	remoteRestarter := New(mockKnapsack)
 
@@ -41,6 +44,98 @@ func TestDo(t *testing.T) {
 	require.Len(t, remoteRestarter.signalRestart, 1, "expected restarter to signal for restart but channel is empty after delay")
 }
 
+type testSubsystemRestarter struct {
+	restartCount int
+	restartErr   error
+}
+
+func (r *testSubsystemRestarter) Restart(_ context.Context) error {
+	r.restartCount += 1
+	return r.restartErr
+}
+
+type testReasonRestarter struct {
+	testSubsystemRestarter
+	reasons []string
+}
+
+func (r *testReasonRestarter) RestartWithReason(ctx context.Context, reason string) error {
+	r.reasons = append(r.reasons, reason)
+	return r.Restart(ctx)
+}
+
+func TestDo_RestartsSubsystem(t *testing.T) {
+	t.Parallel()
+
+	currentRunId := ulid.New()
+	historyStore := inmemory.NewStore()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("GetRunID").Return(currentRunId)
+	mockKnapsack.On("LauncherHistoryStore").Return(historyStore)
+
+	remoteRestarter := New(mockKnapsack)
+	osqueryRestarter := &testReasonRestarter{}
+	remoteRestarter.RegisterSubsystemRestarter(SubsystemOsquery, osqueryRestarter)
+	desktopRestarter := &testSubsystemRestarter{restartErr: errors.New("test error")}
+	remoteRestarter.RegisterSubsystemRestarter(SubsystemDesktop, desktopRestarter)
+
+	for _, testAction := range []remoteRestartAction{
+		{RunID: currentRunId, Subsystem: SubsystemOsquery, Reason: "stuck distributed queries"},
+		{RunID: currentRunId, Subsystem: SubsystemDesktop, Reason: "menu not updating"},
+		{RunID: currentRunId, Subsystem: SubsystemLocalserver, Reason: "not registered"},
+	} {
+		testActionRaw, err := json.Marshal(testAction)
+		require.NoError(t, err)
+
+		err = remoteRestarter.Do(bytes.NewReader(testActionRaw))
+		if testAction.Subsystem == SubsystemOsquery {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	require.Equal(t, 1, osqueryRestarter.restartCount)
+	require.Equal(t, []string{"stuck distributed queries"}, osqueryRestarter.reasons, "reason should be passed along to restarters that record it")
+	require.Equal(t, 1, desktopRestarter.restartCount)
+
+	// Subsystem restarts happen right away, and never restart launcher
+	time.Sleep(restartDelay + 2*time.Second)
+	require.Len(t, remoteRestarter.signalRestart, 0, "restarter should not have signaled for a launcher restart")
+
+	// We record the restarts we attempted, but not the ones for unregistered subsystems
+	history, err := restartHistory(historyStore)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, SubsystemOsquery, history[0].Subsystem)
+	require.Equal(t, "stuck distributed queries", history[0].Reason)
+	require.Empty(t, history[0].Error)
+	require.Equal(t, SubsystemDesktop, history[1].Subsystem)
+	require.Contains(t, history[1].Error, "test error")
+}
+
+func TestRecordRestart_KeepsMostRecent(t *testing.T) {
+	t.Parallel()
+
+	historyStore := inmemory.NewStore()
+
+	mockKnapsack := typesmocks.NewKnapsack(t)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("LauncherHistoryStore").Return(historyStore)
+
+	remoteRestarter := New(mockKnapsack)
+	for i := 0; i < maxRestartHistory+5; i += 1 {
+		remoteRestarter.recordRestart(remoteRestartAction{RunID: ulid.New()}, nil)
+	}
+
+	history, err := restartHistory(historyStore)
+	require.NoError(t, err)
+	require.Len(t, history, maxRestartHistory)
+	require.Equal(t, SubsystemLauncher, history[0].Subsystem)
+}
+
 func TestDo_DoesNotSignalRestartWhenRunIDDoesNotMatch(t *testing.T) {
 	t.Parallel()
 
@@ -96,6 +191,7 @@ func TestDo_DoesNotRestartIfInterruptedDuringDelay(t *testing.T) {
 	mockKnapsack := typesmocks.NewKnapsack(t)
 	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
 	mockKnapsack.On("GetRunID").Return(currentRunId)
+	mockKnapsack.On("LauncherHistoryStore").Return(inmemory.NewStore())
 
 	remoteRestarter := New(mockKnapsack)
 
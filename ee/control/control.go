@@ -1,6 +1,7 @@
 package control
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"github.com/kolide/kit/version"
 	"github.com/kolide/launcher/ee/agent/flags/keys"
 	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/gowrapper"
 	"github.com/kolide/launcher/ee/observability"
 	"go.uber.org/atomic"
 	"golang.org/x/exp/slices"
@@ -36,6 +38,14 @@ type ControlService struct {
 	lastFetched     map[string]string
 	consumers       map[string]consumer
 	subscribers     map[string][]subscriber
+	// pushEnabledChanged signals listenForUpdates that ControlPushEnabled has changed
+	pushEnabledChanged chan struct{}
+	verifyPayloads     bool
+	verifier           *payloadVerifier
+	// quarantined holds the payloads we've rejected, by subsystem; guarded by fetchMutex
+	quarantined map[string]quarantinedPayload
+	// subsystemCache holds the last applied payload for each subsystem, for replay at startup
+	subsystemCache types.KVStore
 }
 
 // consumer is an interface for something that consumes control server data updates. The
@@ -64,24 +74,42 @@ type dataProvider interface {
 
 func New(k types.Knapsack, fetcher dataProvider, opts ...Option) *ControlService {
 	cs := &ControlService{
-		slogger:         k.Slogger().With("component", "control"),
-		knapsack:        k,
-		requestInterval: atomic.NewDuration(k.ControlRequestInterval()),
-		fetcher:         fetcher,
-		lastFetched:     make(map[string]string),
-		consumers:       make(map[string]consumer),
-		subscribers:     make(map[string][]subscriber),
+		slogger:            k.Slogger().With("component", "control"),
+		knapsack:           k,
+		requestInterval:    atomic.NewDuration(k.ControlRequestInterval()),
+		fetcher:            fetcher,
+		lastFetched:        make(map[string]string),
+		consumers:          make(map[string]consumer),
+		subscribers:        make(map[string][]subscriber),
+		pushEnabledChanged: make(chan struct{}, 1),
+		quarantined:        make(map[string]quarantinedPayload),
 	}
 
 	for _, opt := range opts {
 		opt(cs)
 	}
 
+	if cs.verifyPayloads {
+		cs.verifier = newPayloadVerifier(cs.slogger, cs.store)
+		cs.consumers[SigningKeysSubsystem] = cs.verifier
+		if !cs.verifier.hasKeys() {
+			cs.slogger.Log(context.TODO(), slog.LevelWarn,
+				"no trusted control payload signing keys, all control payloads will be rejected while signature enforcement is enabled",
+			)
+		}
+	}
+
 	cs.requestTicker = time.NewTicker(cs.requestInterval.Load())
 
 	// Observe ControlRequestInterval changes to know when to accelerate/decelerate fetching frequency
 	cs.knapsack.RegisterChangeObserver(cs, keys.ControlRequestInterval)
 
+	// If our fetcher can receive updates from the control server, observe ControlPushEnabled
+	// to know when to start or stop listening for them
+	if _, ok := cs.fetcher.(updatesProvider); ok {
+		cs.knapsack.RegisterChangeObserver(cs, keys.ControlPushEnabled)
+	}
+
 	return cs
 }
 
@@ -101,16 +129,41 @@ func (cs *ControlService) Start(ctx context.Context) {
 		"control service started",
 	)
 
+	// Listen for updates pushed by the control server, in addition to polling below
+	if provider, ok := cs.fetcher.(updatesProvider); ok {
+		gowrapper.Go(ctx, cs.slogger, func() {
+			cs.listenForUpdates(ctx, provider)
+		})
+	}
+
 	startUpMessageSuccess := false
+	fetchSucceeded := false
+	replayAttempted := false
+	consecutiveFetchFailures := 0
 
 	for {
 		fetchErr := cs.Fetch(context.TODO())
+		if fetchErr == nil {
+			fetchSucceeded = true
+			consecutiveFetchFailures = 0
+		}
+
 		switch {
 		case fetchErr != nil:
+			consecutiveFetchFailures += 1
 			cs.slogger.Log(ctx, slog.LevelWarn,
 				"failed to fetch data from control server. Not fatal, moving on",
 				"err", fetchErr,
+				"consecutive_failures", consecutiveFetchFailures,
 			)
+
+			// If we haven't been able to reach the control server since startup, fall back to the
+			// last known data for each subsystem so consumers aren't left waiting -- but only once
+			// the outage has persisted, rather than on a single transient error
+			if !fetchSucceeded && !replayAttempted && consecutiveFetchFailures >= replayAfterFetchFailures {
+				cs.replayCachedPayloads(ctx)
+				replayAttempted = true
+			}
 		case !startUpMessageSuccess:
 			if err := cs.SendMessage("startup", cs.startupData(ctx)); err != nil {
 				cs.slogger.Log(ctx, slog.LevelWarn,
@@ -210,6 +263,14 @@ func (cs *ControlService) FlagsChanged(ctx context.Context, flagKeys ...keys.Fla
 	if slices.Contains(flagKeys, keys.ControlRequestInterval) {
 		cs.requestIntervalChanged(ctx, cs.knapsack.ControlRequestInterval())
 	}
+
+	if slices.Contains(flagKeys, keys.ControlPushEnabled) {
+		// Non-blocking: if a signal is already pending, listenForUpdates will see the latest value
+		select {
+		case cs.pushEnabledChanged <- struct{}{}:
+		default:
+		}
+	}
 }
 
 func (cs *ControlService) requestIntervalChanged(ctx context.Context, newInterval time.Duration) {
@@ -303,16 +364,7 @@ func (cs *ControlService) Fetch(ctx context.Context) error {
 			continue
 		}
 
-		lastHash, ok := cs.lastFetched[subsystem]
-		if !ok && cs.store != nil {
-			// Try to get the stored hash. If we can't get it, no worries, it means we don't have a last hash value,
-			// and we can just move on.
-			if storedHash, err := cs.store.Get([]byte(subsystem)); err == nil {
-				lastHash = string(storedHash)
-			}
-		}
-
-		if hash == lastHash && !cs.knapsack.ForceControlSubsystems() && !fetchFull {
+		if hash == cs.lastHash(subsystem) && !cs.knapsack.ForceControlSubsystems() && !fetchFull {
 			// The last fetched update is still fresh
 			// Nothing to do, skip to the next subsystem
 			continue
@@ -324,6 +376,7 @@ func (cs *ControlService) Fetch(ctx context.Context) error {
 				"subsystem", subsystem,
 				"err", err,
 			)
+			cs.cacheError(ctx, subsystem, err)
 			continue
 		}
 	}
@@ -338,7 +391,11 @@ func (cs *ControlService) fetchAndUpdate(ctx context.Context, subsystem, hash st
 
 	slogger := cs.slogger.With("subsystem", subsystem)
 
-	data, err := cs.fetcher.GetSubsystemData(ctx, hash)
+	if cs.verifier != nil && cs.isQuarantined(subsystem, hash) {
+		return fmt.Errorf("payload %s is quarantined", hash)
+	}
+
+	data, sig, err := cs.getSubsystemData(ctx, hash)
 	if err != nil {
 		return fmt.Errorf("failed to get control data: %w", err)
 	}
@@ -347,6 +404,23 @@ func (cs *ControlService) fetchAndUpdate(ctx context.Context, subsystem, hash st
 		return errors.New("control data is nil")
 	}
 
+	if cs.verifier != nil {
+		data, err = cs.verifyPayload(ctx, subsystem, hash, data, sig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hold on to the payload so we can replay it if the control server is unreachable later
+	var payload []byte
+	if cs.subsystemCache != nil {
+		payload, err = io.ReadAll(data)
+		if err != nil {
+			return fmt.Errorf("reading control data: %w", err)
+		}
+		data = bytes.NewReader(payload)
+	}
+
 	// Consumer and subscriber(s) notified now
 	if err := cs.update(ctx, subsystem, data); err != nil {
 		// Returning the error so we don't store the hash and we can try again next time
@@ -359,6 +433,12 @@ func (cs *ControlService) fetchAndUpdate(ctx context.Context, subsystem, hash st
 
 	// Remember the hash of the last fetched version of this subsystem's data
 	cs.lastFetched[subsystem] = hash
+	delete(cs.quarantined, subsystem)
+	if subsystem == SigningKeysSubsystem {
+		// Payloads we rejected may verify against the new keys
+		clear(cs.quarantined)
+	}
+	cs.cacheAppliedPayload(ctx, subsystem, hash, payload, sig, SubsystemSourceNetwork)
 
 	// can't store hash if we dont have store
 	if cs.store == nil {
@@ -376,6 +456,26 @@ func (cs *ControlService) fetchAndUpdate(ctx context.Context, subsystem, hash st
 	return nil
 }
 
+// lastHash returns the hash of the last fetched version of the given subsystem's data, or
+// an empty string if we don't have one. The caller must hold fetchMutex.
+func (cs *ControlService) lastHash(subsystem string) string {
+	if lastHash, ok := cs.lastFetched[subsystem]; ok {
+		return lastHash
+	}
+
+	if cs.store == nil {
+		return ""
+	}
+
+	// Try to get the stored hash. If we can't get it, no worries, it means we don't have a last hash value,
+	// and we can just move on.
+	storedHash, err := cs.store.Get([]byte(subsystem))
+	if err != nil {
+		return ""
+	}
+	return string(storedHash)
+}
+
 // knownSubsystem checks our registered consumers and subscribers to see if the given
 // subsystem is one that has been registered with the control service.
 func (cs *ControlService) knownSubsystem(subsystem string) bool {
@@ -407,6 +507,23 @@ func (cs *ControlService) SendMessage(method string, params any) error {
 	return cs.fetcher.SendMessage(context.TODO(), method, params)
 }
 
+// actionResultsSender is an optional interface for a dataProvider that can report action
+// results to the control server.
+type actionResultsSender interface {
+	SendActionResults(ctx context.Context, results any) error
+}
+
+// SendActionResults reports the results of processed actions to the control server, if our
+// fetcher supports it.
+func (cs *ControlService) SendActionResults(ctx context.Context, results any) error {
+	sender, ok := cs.fetcher.(actionResultsSender)
+	if !ok {
+		return errors.New("control data provider does not support sending action results")
+	}
+
+	return sender.SendActionResults(ctx, results)
+}
+
 // Updates all registered consumers and subscribers of subsystem updates
 func (cs *ControlService) update(ctx context.Context, subsystem string, reader io.Reader) error {
 	_, span := observability.StartSpan(ctx, "subsystem", subsystem)
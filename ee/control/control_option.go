@@ -12,3 +12,20 @@ func WithStore(store types.GetterSetter) Option {
 		c.store = store
 	}
 }
+
+// WithPayloadVerification enables verification of detached signatures over subsystem payloads.
+// Payloads that fail verification are rejected when EnforceControlPayloadSignatures is set, and
+// logged otherwise.
+func WithPayloadVerification() Option {
+	return func(c *ControlService) {
+		c.verifyPayloads = true
+	}
+}
+
+// WithSubsystemCache sets the store used to cache the last applied payload for each subsystem.
+// Cached payloads are replayed to consumers at startup if the control server is unreachable.
+func WithSubsystemCache(store types.KVStore) Option {
+	return func(c *ControlService) {
+		c.subsystemCache = store
+	}
+}
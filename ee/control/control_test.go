@@ -14,6 +14,7 @@ import (
 	"github.com/kolide/launcher/ee/agent/knapsack"
 	"github.com/kolide/launcher/ee/agent/storage"
 	storageci "github.com/kolide/launcher/ee/agent/storage/ci"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
 	typesMocks "github.com/kolide/launcher/ee/agent/types/mocks"
 	"github.com/kolide/launcher/ee/control/consumers/keyvalueconsumer"
 	"github.com/kolide/launcher/pkg/log/multislogger"
@@ -604,3 +605,135 @@ func TestInterrupt_Multiple(t *testing.T) {
 
 	require.Equal(t, expectedInterrupts, receivedInterrupts)
 }
+
+// updatesTestClient is a TestClient that also supports waiting for pushed updates
+type updatesTestClient struct {
+	*TestClient
+	updates      chan map[string]string
+	waitRequests chan map[string]string
+}
+
+func (c *updatesTestClient) WaitForUpdates(ctx context.Context, knownHashes map[string]string) (map[string]string, error) {
+	c.waitRequests <- knownHashes
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case updates := <-c.updates:
+		return updates, nil
+	}
+}
+
+func TestListenForUpdates(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlPushEnabled)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("ControlPushEnabled").Return(false).Once()
+	mockKnapsack.On("ControlPushEnabled").Return(true)
+
+	testClient, _ := NewControlTestClient(map[string]string{}, map[string]any{"502a42f0": "status", "602a42f1": "status2"})
+	client := &updatesTestClient{
+		TestClient:   testClient,
+		updates:      make(chan map[string]string),
+		waitRequests: make(chan map[string]string),
+	}
+	cs := New(mockKnapsack, client, WithStore(&mockStore{keyValues: map[string]string{"desktop": "402a42ef"}}))
+	desktopConsumer := &mockConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	listenerDone := make(chan struct{})
+	go func() {
+		cs.listenForUpdates(ctx, client)
+		close(listenerDone)
+	}()
+
+	// Push is disabled at first, so we don't expect a request until the flag changes
+	select {
+	case <-client.waitRequests:
+		t.Fatal("did not expect to wait for updates while push is disabled")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cs.FlagsChanged(t.Context(), keys.ControlPushEnabled)
+
+	// We send our last known hashes, then apply updates to known subsystems only
+	knownHashes := <-client.waitRequests
+	require.Equal(t, map[string]string{"desktop": "402a42ef"}, knownHashes)
+	client.updates <- map[string]string{"desktop": "502a42f0"}
+
+	knownHashes = <-client.waitRequests
+	require.Equal(t, map[string]string{"desktop": "502a42f0"}, knownHashes)
+	require.Equal(t, 1, desktopConsumer.updates)
+
+	// An update we already have is not fetched again
+	client.updates <- map[string]string{"desktop": "502a42f0"}
+	<-client.waitRequests
+	require.Equal(t, 1, desktopConsumer.updates)
+
+	// An update for an unknown subsystem is skipped without backing off, and we tell the control
+	// server we have it, so that it doesn't keep answering our requests with it
+	client.updates <- map[string]string{"unknown": "602a42f1"}
+	select {
+	case knownHashes = <-client.waitRequests:
+	case <-time.After(1 * time.Second):
+		t.Fatal("did not expect to back off after an update for an unknown subsystem")
+	}
+	require.Equal(t, map[string]string{"desktop": "502a42f0", "unknown": "602a42f1"}, knownHashes)
+	require.NotContains(t, testClient.hashRequestCounts, "602a42f1")
+
+	cancel()
+	select {
+	case <-listenerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not exit after context was canceled")
+	}
+}
+
+func TestListenForUpdates_BacksOffWhenUpdateFails(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlPushEnabled)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("ControlPushEnabled").Return(true)
+
+	testClient, _ := NewControlTestClient(map[string]string{}, map[string]any{"502a42f0": "status"})
+	client := &updatesTestClient{
+		TestClient:   testClient,
+		updates:      make(chan map[string]string),
+		waitRequests: make(chan map[string]string),
+	}
+	cache := inmemory.NewStore()
+	cs := New(mockKnapsack, client, WithStore(&mockStore{keyValues: map[string]string{}}), WithSubsystemCache(cache))
+	desktopConsumer := &mockConsumer{updateErr: errors.New("bad update")}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go cs.listenForUpdates(ctx, client)
+
+	<-client.waitRequests
+	client.updates <- map[string]string{"desktop": "502a42f0"}
+
+	// We don't immediately ask for updates again, since we'd just be told about the same failing update
+	select {
+	case <-client.waitRequests:
+		t.Fatal("expected to back off after an update we could not apply")
+	case <-time.After(1 * time.Second):
+	}
+
+	// The failure is recorded in the subsystem cache
+	entries, err := ReadSubsystemCache(cache)
+	require.NoError(t, err)
+	require.Contains(t, entries["desktop"].LastError, "bad update")
+	cs.fetchMutex.Lock()
+	require.Equal(t, 1, desktopConsumer.updates)
+	cs.fetchMutex.Unlock()
+}
@@ -0,0 +1,346 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/ee/agent/types"
+)
+
+// pinnedSigningKeysJson holds the signing keys trusted for control server payloads when we
+// have not yet received a key rollover document. The checked-in keyset is empty; release builds
+// set pinnedSigningKeysB64 instead, see pinnedSigningKeys.
+//
+//go:embed assets/control_signing_keys.json
+var pinnedSigningKeysJson []byte
+
+// pinnedSigningKeysB64 is the base64-encoded pinned keyset, set at build time via
+// `make -control-signing-keys <path>`, which validates the keyset before embedding it with
+// -ldflags "-X github.com/kolide/launcher/ee/control.pinnedSigningKeysB64=...".
+var pinnedSigningKeysB64 string
+
+// pinnedSigningKeys returns the keyset embedded at build time, falling back to the checked-in keyset.
+func pinnedSigningKeys() ([]byte, error) {
+	if pinnedSigningKeysB64 == "" {
+		return pinnedSigningKeysJson, nil
+	}
+
+	return base64.StdEncoding.DecodeString(pinnedSigningKeysB64)
+}
+
+const (
+	// HeaderPayloadSignature holds the base64-encoded detached signature over a subsystem payload.
+	HeaderPayloadSignature = "X-Kolide-Payload-Signature"
+	// HeaderPayloadKeyID identifies the signing key used to create HeaderPayloadSignature.
+	HeaderPayloadKeyID = "X-Kolide-Payload-Key-Id"
+
+	// SigningKeysSubsystem delivers key rollover documents, which replace our trusted signing keys.
+	// Its payloads must always be signed by a currently-trusted key, regardless of enforcement.
+	SigningKeysSubsystem = "signing_keys"
+
+	// signingKeysStoreKey is the key under which we persist the most recent key rollover document.
+	signingKeysStoreKey = "signing_keys_document"
+
+	// payloadSignatureContext is prepended, along with the subsystem name, to the payload before
+	// signing, so that a signed payload for one subsystem can't be replayed as another's.
+	payloadSignatureContext = "kolide-control-payload-v1"
+)
+
+var errPayloadNotSigned = errors.New("payload is not signed")
+
+// payloadSignature is a detached signature over a subsystem payload.
+type payloadSignature struct {
+	KeyID     string
+	Signature []byte
+}
+
+// signedDataProvider is an optional interface for a dataProvider that can return the detached
+// signature for subsystem data along with the data itself.
+type signedDataProvider interface {
+	GetSignedSubsystemData(ctx context.Context, hash string) (io.Reader, *payloadSignature, error)
+}
+
+// signingKeyset is the set of keys trusted to sign control server payloads, either shipped with
+// launcher or delivered via a key rollover document.
+type signingKeyset struct {
+	Version int          `json:"version"`
+	Keys    []signingKey `json:"keys"`
+}
+
+type signingKey struct {
+	ID        string `json:"id"`
+	PublicKey string `json:"public_key"` // base64-encoded DER ECDSA P-256 public key
+}
+
+// payloadVerifier verifies detached signatures over control server payloads against our trusted
+// signing keys. It also acts as the consumer for SigningKeysSubsystem, rotating our trusted keys
+// when it receives a (verified) key rollover document with a newer version.
+type payloadVerifier struct {
+	slogger *slog.Logger
+	store   types.GetterSetter
+	lock    sync.RWMutex
+	version int
+	keys    map[string]*ecdsa.PublicKey
+}
+
+// newPayloadVerifier returns a verifier trusting our pinned signing keys, or the keys from the most
+// recent key rollover document if we have one. If no keys can be loaded, it trusts no keys, so that
+// verification fails closed.
+func newPayloadVerifier(slogger *slog.Logger, store types.GetterSetter) *payloadVerifier {
+	v := &payloadVerifier{
+		slogger: slogger.With("component", "payload_verifier"),
+		store:   store,
+		keys:    make(map[string]*ecdsa.PublicKey),
+	}
+
+	var pinned signingKeyset
+	rawPinned, err := pinnedSigningKeys()
+	if err == nil {
+		err = json.Unmarshal(rawPinned, &pinned)
+	}
+	if err != nil {
+		v.slogger.Log(context.TODO(), slog.LevelError,
+			"could not unmarshal pinned signing keys",
+			"err", err,
+		)
+	} else if err := v.setKeyset(pinned); err != nil {
+		v.slogger.Log(context.TODO(), slog.LevelError,
+			"could not load pinned signing keys",
+			"err", err,
+		)
+	}
+
+	// Prefer a previously-received rollover document, if it's newer than our pinned keys
+	if store == nil {
+		return v
+	}
+	storedKeysetRaw, err := store.Get([]byte(signingKeysStoreKey))
+	if err != nil || len(storedKeysetRaw) == 0 {
+		return v
+	}
+
+	var stored signingKeyset
+	if err := json.Unmarshal(storedKeysetRaw, &stored); err != nil {
+		v.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not unmarshal stored signing keys, using pinned keys",
+			"err", err,
+		)
+		return v
+	}
+	if stored.Version > v.version {
+		if err := v.setKeyset(stored); err != nil {
+			v.slogger.Log(context.TODO(), slog.LevelWarn,
+				"could not load stored signing keys, using pinned keys",
+				"err", err,
+			)
+		}
+	}
+
+	return v
+}
+
+// setKeyset replaces our trusted keys with the given keyset.
+func (v *payloadVerifier) setKeyset(keyset signingKeyset) error {
+	keys := make(map[string]*ecdsa.PublicKey, len(keyset.Keys))
+	for _, k := range keyset.Keys {
+		if k.ID == "" {
+			return errors.New("signing key has no ID")
+		}
+
+		pub, err := echelper.PublicB64DerToEcdsaKey([]byte(k.PublicKey))
+		if err != nil {
+			return fmt.Errorf("parsing signing key %s: %w", k.ID, err)
+		}
+		keys[k.ID] = pub
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.version = keyset.Version
+	v.keys = keys
+
+	return nil
+}
+
+// hasKeys reports whether we trust any signing keys at all.
+func (v *payloadVerifier) hasKeys() bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return len(v.keys) > 0
+}
+
+// verify checks the given signature over the given subsystem's payload.
+func (v *payloadVerifier) verify(subsystem string, payload []byte, sig *payloadSignature) error {
+	if sig == nil || len(sig.Signature) == 0 {
+		return errPayloadNotSigned
+	}
+
+	v.lock.RLock()
+	pub, ok := v.keys[sig.KeyID]
+	v.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("payload signed by unknown key %s", sig.KeyID)
+	}
+
+	if err := echelper.VerifySignature(pub, signedPayloadMessage(subsystem, payload), sig.Signature); err != nil {
+		return fmt.Errorf("verifying signature by key %s: %w", sig.KeyID, err)
+	}
+
+	return nil
+}
+
+// Update implements the consumer interface for SigningKeysSubsystem. By the time it is called,
+// the control service has verified the key rollover document's signature against our current keys.
+func (v *payloadVerifier) Update(data io.Reader) error {
+	var keyset signingKeyset
+	if err := json.NewDecoder(data).Decode(&keyset); err != nil {
+		return fmt.Errorf("decoding key rollover document: %w", err)
+	}
+
+	v.lock.RLock()
+	currentVersion := v.version
+	v.lock.RUnlock()
+
+	if keyset.Version <= currentVersion {
+		v.slogger.Log(context.TODO(), slog.LevelDebug,
+			"ignoring key rollover document that is not newer than current keys",
+			"version", keyset.Version,
+			"current_version", currentVersion,
+		)
+		return nil
+	}
+	if len(keyset.Keys) == 0 {
+		return errors.New("key rollover document has no keys")
+	}
+
+	if err := v.setKeyset(keyset); err != nil {
+		return fmt.Errorf("applying key rollover document: %w", err)
+	}
+
+	v.slogger.Log(context.TODO(), slog.LevelInfo,
+		"rotated control payload signing keys",
+		"version", keyset.Version,
+		"key_count", len(keyset.Keys),
+	)
+
+	if v.store == nil {
+		return nil
+	}
+
+	rawKeyset, err := json.Marshal(keyset)
+	if err != nil {
+		return fmt.Errorf("marshalling key rollover document: %w", err)
+	}
+	if err := v.store.Set([]byte(signingKeysStoreKey), rawKeyset); err != nil {
+		return fmt.Errorf("storing key rollover document: %w", err)
+	}
+
+	return nil
+}
+
+// signedPayloadMessage returns the message that the control server signs for the given payload.
+func signedPayloadMessage(subsystem string, payload []byte) []byte {
+	message := make([]byte, 0, len(payloadSignatureContext)+len(subsystem)+len(payload)+2)
+	message = append(message, payloadSignatureContext...)
+	message = append(message, '\n')
+	message = append(message, subsystem...)
+	message = append(message, '\n')
+	return append(message, payload...)
+}
+
+// quarantinedPayload records a subsystem payload that we rejected because it failed verification.
+type quarantinedPayload struct {
+	Hash          string
+	Reason        string
+	QuarantinedAt time.Time
+}
+
+// getSubsystemData fetches the data for the given hash, along with its signature if we are
+// verifying payloads and our fetcher supports it.
+func (cs *ControlService) getSubsystemData(ctx context.Context, hash string) (io.Reader, *payloadSignature, error) {
+	if provider, ok := cs.fetcher.(signedDataProvider); ok && cs.verifier != nil {
+		return provider.GetSignedSubsystemData(ctx, hash)
+	}
+
+	data, err := cs.fetcher.GetSubsystemData(ctx, hash)
+	return data, nil, err
+}
+
+// verifyPayload verifies the signature over the given subsystem payload, returning a reader for the
+// payload if it should be applied. Payloads that fail verification are quarantined and rejected if
+// enforcement is on (always, for SigningKeysSubsystem); otherwise, they are logged and applied.
+// If enforcement is on but we trust no keys at all -- e.g. a build without pinned keys -- every
+// payload is rejected, but not quarantined, since nothing could verify. The caller must hold fetchMutex.
+func (cs *ControlService) verifyPayload(ctx context.Context, subsystem, hash string, data io.Reader, sig *payloadSignature) (io.Reader, error) {
+	payload, err := io.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading control data: %w", err)
+	}
+
+	verifyErr := cs.verifier.verify(subsystem, payload, sig)
+	if verifyErr == nil {
+		return bytes.NewReader(payload), nil
+	}
+
+	if subsystem != SigningKeysSubsystem && !cs.knapsack.EnforceControlPayloadSignatures() {
+		// Unsigned payloads are expected until the control server signs everything; a bad signature is not
+		level := slog.LevelWarn
+		if errors.Is(verifyErr, errPayloadNotSigned) {
+			level = slog.LevelDebug
+		}
+		cs.slogger.Log(ctx, level,
+			"could not verify control payload signature, applying anyway because enforcement is off",
+			"subsystem", subsystem,
+			"err", verifyErr,
+		)
+		return bytes.NewReader(payload), nil
+	}
+
+	// Without trusted keys, nothing can verify; don't quarantine, so that the payload is picked
+	// up again once we have keys or enforcement is turned off.
+	if !cs.verifier.hasKeys() {
+		cs.slogger.Log(ctx, slog.LevelError,
+			"no trusted control payload signing keys, rejecting control payload: signature enforcement is enabled, so no control payloads can be applied",
+			"subsystem", subsystem,
+			"hash", hash,
+		)
+		return nil, fmt.Errorf("rejecting unverified payload, no trusted signing keys: %w", verifyErr)
+	}
+
+	cs.slogger.Log(ctx, slog.LevelError,
+		"could not verify control payload signature, quarantining payload",
+		"subsystem", subsystem,
+		"hash", hash,
+		"err", verifyErr,
+	)
+	cs.quarantined[subsystem] = quarantinedPayload{
+		Hash:          hash,
+		Reason:        verifyErr.Error(),
+		QuarantinedAt: time.Now().UTC(),
+	}
+
+	return nil, fmt.Errorf("rejecting unverified payload: %w", verifyErr)
+}
+
+// isQuarantined reports whether we have already rejected the given payload, and would reject it
+// again. The caller must hold fetchMutex.
+func (cs *ControlService) isQuarantined(subsystem, hash string) bool {
+	q, ok := cs.quarantined[subsystem]
+	if !ok || q.Hash != hash || !cs.verifier.hasKeys() {
+		return false
+	}
+
+	return subsystem == SigningKeysSubsystem || cs.knapsack.EnforceControlPayloadSignatures()
+}
@@ -0,0 +1,201 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	typesMocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// signedTestClient is a dataProvider that serves subsystem payloads along with their signatures
+type signedTestClient struct {
+	payloads   map[string][]byte
+	signatures map[string]*payloadSignature
+	fetches    int
+}
+
+func (c *signedTestClient) GetConfig(_ context.Context) (io.Reader, error) {
+	return nil, nil
+}
+
+func (c *signedTestClient) GetSubsystemData(ctx context.Context, hash string) (io.Reader, error) {
+	data, _, err := c.GetSignedSubsystemData(ctx, hash)
+	return data, err
+}
+
+func (c *signedTestClient) GetSignedSubsystemData(_ context.Context, hash string) (io.Reader, *payloadSignature, error) {
+	c.fetches += 1
+	return bytes.NewReader(c.payloads[hash]), c.signatures[hash], nil
+}
+
+func (c *signedTestClient) SendMessage(_ context.Context, _ string, _ any) error {
+	return nil
+}
+
+func (c *signedTestClient) add(t *testing.T, hash, subsystem string, payload []byte, keyID string, key *ecdsa.PrivateKey) {
+	c.payloads[hash] = payload
+	if key == nil {
+		return
+	}
+
+	sig, err := echelper.Sign(key, signedPayloadMessage(subsystem, payload))
+	require.NoError(t, err)
+	c.signatures[hash] = &payloadSignature{KeyID: keyID, Signature: sig}
+}
+
+func testSigningKey(t *testing.T) (*ecdsa.PrivateKey, signingKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := echelper.PublicEcdsaToB64Der(&key.PublicKey)
+	require.NoError(t, err)
+	return key, signingKey{ID: "key-" + string(pub[len(pub)-8:]), PublicKey: string(pub)}
+}
+
+func TestPayloadVerification(t *testing.T) {
+	t.Parallel()
+
+	trustedKey, trustedSigningKey := testSigningKey(t)
+	untrustedKey, untrustedSigningKey := testSigningKey(t)
+
+	// Seed the store with a keyset newer than our (empty) pinned keys
+	storedKeyset, err := json.Marshal(signingKeyset{Version: 1, Keys: []signingKey{trustedSigningKey}})
+	require.NoError(t, err)
+	store := &mockStore{keyValues: map[string]string{signingKeysStoreKey: string(storedKeyset)}}
+
+	enforce := false
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("EnforceControlPayloadSignatures").Return(func() bool { return enforce })
+
+	client := &signedTestClient{payloads: make(map[string][]byte), signatures: make(map[string]*payloadSignature)}
+	cs := New(mockKnapsack, client, WithStore(store), WithPayloadVerification())
+	desktopConsumer := &mockConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+
+	// Signed by a trusted key: applied
+	client.add(t, "signed", "desktop", []byte(`{"status":"signed"}`), trustedSigningKey.ID, trustedKey)
+	require.NoError(t, cs.fetchAndUpdate(t.Context(), "desktop", "signed"))
+	require.Equal(t, 1, desktopConsumer.updates)
+
+	// Signed for a different subsystem: can't be replayed
+	client.add(t, "replayed", "katc_config", []byte(`{"status":"replayed"}`), trustedSigningKey.ID, trustedKey)
+	// Unsigned, and signed by an untrusted key
+	client.add(t, "unsigned", "desktop", []byte(`{"status":"unsigned"}`), "", nil)
+	client.add(t, "untrusted", "desktop", []byte(`{"status":"untrusted"}`), untrustedSigningKey.ID, untrustedKey)
+
+	// With enforcement off, unverified payloads are applied anyway
+	require.NoError(t, cs.fetchAndUpdate(t.Context(), "desktop", "unsigned"))
+	require.Equal(t, 2, desktopConsumer.updates)
+
+	// With enforcement on, they're rejected and quarantined
+	enforce = true
+	for _, hash := range []string{"replayed", "unsigned", "untrusted"} {
+		require.Error(t, cs.fetchAndUpdate(t.Context(), "desktop", hash), hash)
+		require.Equal(t, 2, desktopConsumer.updates)
+		require.Equal(t, hash, cs.quarantined["desktop"].Hash)
+	}
+	require.Equal(t, "unsigned", cs.lastFetched["desktop"])
+
+	// We don't refetch a quarantined payload
+	fetches := client.fetches
+	require.Error(t, cs.fetchAndUpdate(t.Context(), "desktop", "untrusted"))
+	require.Equal(t, fetches, client.fetches)
+
+	// Rotate to the previously-untrusted key, via a rollover document signed by the trusted key
+	rollover, err := json.Marshal(signingKeyset{Version: 2, Keys: []signingKey{untrustedSigningKey}})
+	require.NoError(t, err)
+	client.add(t, "rollover", SigningKeysSubsystem, rollover, trustedSigningKey.ID, trustedKey)
+	require.NoError(t, cs.fetchAndUpdate(t.Context(), SigningKeysSubsystem, "rollover"))
+	require.Empty(t, cs.quarantined)
+
+	// The new keyset is persisted
+	persisted := newPayloadVerifier(multislogger.NewNopLogger(), store)
+	require.Equal(t, 2, persisted.version)
+
+	// The previously-quarantined payload now verifies; the old key is no longer trusted
+	require.NoError(t, cs.fetchAndUpdate(t.Context(), "desktop", "untrusted"))
+	require.Equal(t, 3, desktopConsumer.updates)
+	require.Error(t, cs.fetchAndUpdate(t.Context(), "desktop", "signed"))
+}
+
+func TestPayloadVerification_RolloverAlwaysRequiresSignature(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	_, attackerSigningKey := testSigningKey(t)
+	rollover, err := json.Marshal(signingKeyset{Version: 100, Keys: []signingKey{attackerSigningKey}})
+	require.NoError(t, err)
+
+	client := &signedTestClient{payloads: make(map[string][]byte), signatures: make(map[string]*payloadSignature)}
+	client.add(t, "rollover", SigningKeysSubsystem, rollover, "", nil)
+
+	cs := New(mockKnapsack, client, WithStore(&mockStore{keyValues: map[string]string{}}), WithPayloadVerification())
+
+	// Even with enforcement off, an unsigned rollover document is rejected
+	require.Error(t, cs.fetchAndUpdate(t.Context(), SigningKeysSubsystem, "rollover"))
+	require.Equal(t, 0, cs.verifier.version)
+}
+
+func TestPayloadVerification_NoTrustedKeys(t *testing.T) {
+	t.Parallel()
+
+	enforce := true
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("EnforceControlPayloadSignatures").Return(func() bool { return enforce })
+
+	client := &signedTestClient{payloads: make(map[string][]byte), signatures: make(map[string]*payloadSignature)}
+	client.add(t, "flags", "agent_flags", []byte(`{"enforce_control_payload_signatures":"false"}`), "", nil)
+
+	// With the (empty) checked-in pinned keys and no stored rollover document, we trust no keys
+	cs := New(mockKnapsack, client, WithStore(&mockStore{keyValues: map[string]string{}}), WithPayloadVerification())
+	require.False(t, cs.verifier.hasKeys())
+	flagsConsumer := &mockConsumer{}
+	require.NoError(t, cs.RegisterConsumer("agent_flags", flagsConsumer))
+
+	// With enforcement on, the payload is rejected rather than applied unverified -- but not
+	// quarantined, since nothing could verify
+	require.Error(t, cs.fetchAndUpdate(t.Context(), "agent_flags", "flags"))
+	require.Equal(t, 0, flagsConsumer.updates)
+	require.Empty(t, cs.quarantined)
+
+	// With enforcement off, it's applied
+	enforce = false
+	require.NoError(t, cs.fetchAndUpdate(t.Context(), "agent_flags", "flags"))
+	require.Equal(t, 1, flagsConsumer.updates)
+}
+
+func TestPinnedSigningKeys(t *testing.T) { //nolint:paralleltest // modifies pinnedSigningKeysB64
+	_, pinnedKey := testSigningKey(t)
+	rawKeyset, err := json.Marshal(signingKeyset{Version: 1, Keys: []signingKey{pinnedKey}})
+	require.NoError(t, err)
+
+	pinnedSigningKeysB64 = base64.StdEncoding.EncodeToString(rawKeyset)
+	t.Cleanup(func() { pinnedSigningKeysB64 = "" })
+
+	v := newPayloadVerifier(multislogger.NewNopLogger(), nil)
+	require.True(t, v.hasKeys())
+	require.Equal(t, 1, v.version)
+	require.Contains(t, v.keys, pinnedKey.ID)
+}
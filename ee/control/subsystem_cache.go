@@ -0,0 +1,204 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+)
+
+const (
+	// SubsystemSourceNetwork indicates that a subsystem's payload was fetched from the control server.
+	SubsystemSourceNetwork = "network"
+	// SubsystemSourceReplay indicates that a subsystem's payload was replayed from the local cache,
+	// because the control server was unreachable at startup.
+	SubsystemSourceReplay = "replay"
+
+	// replayAfterFetchFailures is the number of consecutive failed fetches at startup after which
+	// we replay cached payloads.
+	replayAfterFetchFailures = 3
+)
+
+// SubsystemCacheEntry records the last payload successfully applied for a subsystem, and the
+// last error we saw when fetching or applying its data. It is stored as JSON in the subsystem
+// cache, keyed by subsystem.
+type SubsystemCacheEntry struct {
+	Hash      string    `json:"hash"`
+	Payload   []byte    `json:"payload,omitempty"`
+	AppliedAt time.Time `json:"applied_at,omitzero"`
+	Source    string    `json:"source,omitempty"` // SubsystemSourceNetwork or SubsystemSourceReplay
+	// SignatureKeyID and Signature hold the payload's detached signature, if it had one, so that
+	// it can be re-verified before it is replayed.
+	SignatureKeyID string    `json:"signature_key_id,omitempty"`
+	Signature      []byte    `json:"signature,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastErrorAt    time.Time `json:"last_error_at,omitzero"`
+}
+
+// ReadSubsystemCache returns all entries in the given subsystem cache, by subsystem.
+func ReadSubsystemCache(store types.Iterator) (map[string]SubsystemCacheEntry, error) {
+	entries := make(map[string]SubsystemCacheEntry)
+	if err := store.ForEach(func(k, v []byte) error {
+		var entry SubsystemCacheEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("unmarshalling cache entry for subsystem %s: %w", string(k), err)
+		}
+		entries[string(k)] = entry
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// cacheEntry returns the cached entry for the given subsystem, or an empty entry if we don't have one.
+func (cs *ControlService) cacheEntry(subsystem string) SubsystemCacheEntry {
+	var entry SubsystemCacheEntry
+
+	raw, err := cs.subsystemCache.Get([]byte(subsystem))
+	if err != nil || len(raw) == 0 {
+		return entry
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		cs.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not unmarshal cached subsystem entry, discarding",
+			"subsystem", subsystem,
+			"err", err,
+		)
+		return SubsystemCacheEntry{}
+	}
+
+	return entry
+}
+
+func (cs *ControlService) setCacheEntry(ctx context.Context, subsystem string, entry SubsystemCacheEntry) {
+	raw, err := json.Marshal(entry)
+	if err == nil {
+		err = cs.subsystemCache.Set([]byte(subsystem), raw)
+	}
+	if err != nil {
+		cs.slogger.Log(ctx, slog.LevelError,
+			"failed to store subsystem cache entry",
+			"subsystem", subsystem,
+			"err", err,
+		)
+	}
+}
+
+// cacheAppliedPayload records the payload we just applied for the given subsystem, along with its
+// signature, if any.
+func (cs *ControlService) cacheAppliedPayload(ctx context.Context, subsystem, hash string, payload []byte, sig *payloadSignature, source string) {
+	if cs.subsystemCache == nil {
+		return
+	}
+
+	entry := SubsystemCacheEntry{
+		Hash:      hash,
+		Payload:   payload,
+		AppliedAt: time.Now().UTC(),
+		Source:    source,
+	}
+	if sig != nil {
+		entry.SignatureKeyID = sig.KeyID
+		entry.Signature = sig.Signature
+	}
+	cs.setCacheEntry(ctx, subsystem, entry)
+}
+
+// cachedSignature returns the signature stored with the given cache entry, if any.
+func (entry SubsystemCacheEntry) cachedSignature() *payloadSignature {
+	if len(entry.Signature) == 0 {
+		return nil
+	}
+
+	return &payloadSignature{
+		KeyID:     entry.SignatureKeyID,
+		Signature: entry.Signature,
+	}
+}
+
+// cacheError records an error fetching or applying the given subsystem's data, retaining
+// the last applied payload.
+func (cs *ControlService) cacheError(ctx context.Context, subsystem string, cacheErr error) {
+	if cs.subsystemCache == nil {
+		return
+	}
+
+	entry := cs.cacheEntry(subsystem)
+	entry.LastError = cacheErr.Error()
+	entry.LastErrorAt = time.Now().UTC()
+	cs.setCacheEntry(ctx, subsystem, entry)
+}
+
+// replayCachedPayloads applies the last known payload for each registered subsystem to its
+// consumer and subscribers. We do this at startup when we can't reach the control server, so
+// that consumers don't have to wait for a successful fetch to see data. Cached payloads are
+// re-verified against our current signing keys and enforcement setting before they're replayed.
+func (cs *ControlService) replayCachedPayloads(ctx context.Context) {
+	if cs.subsystemCache == nil {
+		return
+	}
+
+	cs.fetchMutex.Lock()
+	defer cs.fetchMutex.Unlock()
+
+	entries, err := ReadSubsystemCache(cs.subsystemCache)
+	if err != nil {
+		cs.slogger.Log(ctx, slog.LevelWarn,
+			"could not read subsystem cache for replay",
+			"err", err,
+		)
+		return
+	}
+
+	for subsystem, entry := range entries {
+		// A successful fetch may have raced with us; never replay over fresher data
+		if _, alreadyFetched := cs.lastFetched[subsystem]; alreadyFetched {
+			continue
+		}
+		if !cs.knownSubsystem(subsystem) || len(entry.Payload) == 0 {
+			continue
+		}
+
+		var data io.Reader = bytes.NewReader(entry.Payload)
+		if cs.verifier != nil {
+			if cs.isQuarantined(subsystem, entry.Hash) {
+				continue
+			}
+
+			data, err = cs.verifyPayload(ctx, subsystem, entry.Hash, data, entry.cachedSignature())
+			if err != nil {
+				cs.slogger.Log(ctx, slog.LevelWarn,
+					"not replaying cached subsystem payload",
+					"subsystem", subsystem,
+					"err", err,
+				)
+				cs.cacheError(ctx, subsystem, fmt.Errorf("verifying cached payload: %w", err))
+				continue
+			}
+		}
+
+		if err := cs.update(ctx, subsystem, data); err != nil {
+			cs.slogger.Log(ctx, slog.LevelWarn,
+				"failed to replay cached subsystem payload",
+				"subsystem", subsystem,
+				"err", err,
+			)
+			cs.cacheError(ctx, subsystem, fmt.Errorf("replaying cached payload: %w", err))
+			continue
+		}
+
+		cs.slogger.Log(ctx, slog.LevelInfo,
+			"replayed cached subsystem payload",
+			"subsystem", subsystem,
+			"hash", entry.Hash,
+		)
+		cs.cacheAppliedPayload(ctx, subsystem, entry.Hash, entry.Payload, entry.cachedSignature(), SubsystemSourceReplay)
+	}
+}
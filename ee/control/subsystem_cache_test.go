@@ -0,0 +1,225 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	"github.com/kolide/launcher/ee/agent/types"
+	typesMocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingConsumer struct {
+	payloads  []string
+	updateErr error
+}
+
+func (rc *recordingConsumer) Update(data io.Reader) error {
+	payload, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	rc.payloads = append(rc.payloads, string(payload))
+	return rc.updateErr
+}
+
+type unreachableDataProvider struct {
+	nopDataProvider
+}
+
+func (dp unreachableDataProvider) GetConfig(_ context.Context) (io.Reader, error) {
+	return nil, errors.New("control server unreachable")
+}
+
+// flakyDataProvider fails to fetch the config the first `failures` times, and returns an
+// empty config after that
+type flakyDataProvider struct {
+	nopDataProvider
+	failures int64
+	fetches  *atomic.Int64
+}
+
+func (dp flakyDataProvider) GetConfig(_ context.Context) (io.Reader, error) {
+	if dp.fetches.Add(1) <= dp.failures {
+		return nil, errors.New("control server unreachable")
+	}
+	return strings.NewReader("{}"), nil
+}
+
+func TestSubsystemCache_RecordsAppliedPayloadsAndErrors(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	subsystems := map[string]string{"desktop": "502a42f0", "katc_config": "7ab3c1d2"}
+	hashData := map[string]any{"502a42f0": "status", "7ab3c1d2": "config"}
+	data, err := NewControlTestClient(subsystems, hashData)
+	require.NoError(t, err)
+
+	cache := inmemory.NewStore()
+	cs := New(mockKnapsack, data, WithStore(&mockStore{keyValues: make(map[string]string)}), WithSubsystemCache(cache))
+	desktopConsumer := &recordingConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+	katcConsumer := &recordingConsumer{updateErr: errors.New("test error")}
+	require.NoError(t, cs.RegisterConsumer("katc_config", katcConsumer))
+
+	require.NoError(t, cs.Fetch(t.Context()))
+
+	entries, err := ReadSubsystemCache(cache)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	desktopEntry := entries["desktop"]
+	require.Equal(t, "502a42f0", desktopEntry.Hash)
+	require.Equal(t, desktopConsumer.payloads[0], string(desktopEntry.Payload))
+	require.Equal(t, SubsystemSourceNetwork, desktopEntry.Source)
+	require.False(t, desktopEntry.AppliedAt.IsZero())
+	require.Empty(t, desktopEntry.LastError)
+
+	// We never successfully applied katc_config, so we only have its error
+	katcEntry := entries["katc_config"]
+	require.Empty(t, katcEntry.Hash)
+	require.Empty(t, katcEntry.Payload)
+	require.Contains(t, katcEntry.LastError, "test error")
+	require.False(t, katcEntry.LastErrorAt.IsZero())
+}
+
+func TestSubsystemCache_ReplayWhenUnreachable(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(10 * time.Millisecond)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	// Seed the cache as a previous launcher run would have
+	cache := inmemory.NewStore()
+	seed := New(mockKnapsack, nopDataProvider{}, WithSubsystemCache(cache))
+	seed.cacheAppliedPayload(t.Context(), "desktop", "502a42f0", []byte(`"status"`), nil, SubsystemSourceNetwork)
+	seed.cacheAppliedPayload(t.Context(), "unregistered", "1f2e3d4c", []byte(`"other"`), nil, SubsystemSourceNetwork)
+
+	cs := New(mockKnapsack, unreachableDataProvider{}, WithSubsystemCache(cache))
+	desktopConsumer := &recordingConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+	desktopSubscriber := &mockSubscriber{}
+	cs.RegisterSubscriber("desktop", desktopSubscriber)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go cs.Start(ctx)
+	t.Cleanup(cancel)
+
+	require.Eventually(t, func() bool {
+		entries, err := ReadSubsystemCache(cache)
+		return err == nil && entries["desktop"].Source == SubsystemSourceReplay
+	}, 5*time.Second, 50*time.Millisecond)
+	cancel()
+
+	cs.fetchMutex.Lock()
+	defer cs.fetchMutex.Unlock()
+	require.Equal(t, []string{`"status"`}, desktopConsumer.payloads)
+	require.Equal(t, 1, desktopSubscriber.pings)
+
+	entries, err := ReadSubsystemCache(cache)
+	require.NoError(t, err)
+	require.Equal(t, "502a42f0", entries["desktop"].Hash)
+	require.Equal(t, SubsystemSourceNetwork, entries["unregistered"].Source)
+}
+
+func TestSubsystemCache_NoReplayOnTransientFailure(t *testing.T) {
+	t.Parallel()
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(10 * time.Millisecond)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("GetRunID").Return("test-run").Maybe()
+	mockKnapsack.On("CurrentEnrollmentStatus").Return(types.Enrolled, nil).Maybe()
+	mockKnapsack.On("ServerProvidedDataStore").Return(inmemory.NewStore()).Maybe()
+	mockKnapsack.On("GetEnrollmentDetails").Return(types.EnrollmentDetails{}).Maybe()
+
+	cache := inmemory.NewStore()
+	seed := New(mockKnapsack, nopDataProvider{}, WithSubsystemCache(cache))
+	seed.cacheAppliedPayload(t.Context(), "desktop", "502a42f0", []byte(`"status"`), nil, SubsystemSourceNetwork)
+
+	// A single failed fetch, below the replay threshold, followed by a successful one
+	fetches := &atomic.Int64{}
+	cs := New(mockKnapsack, flakyDataProvider{failures: replayAfterFetchFailures - 1, fetches: fetches}, WithSubsystemCache(cache))
+	desktopConsumer := &recordingConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go cs.Start(ctx)
+	t.Cleanup(cancel)
+
+	require.Eventually(t, func() bool {
+		return fetches.Load() > replayAfterFetchFailures+2
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	cs.fetchMutex.Lock()
+	defer cs.fetchMutex.Unlock()
+	require.Empty(t, desktopConsumer.payloads)
+
+	entries, err := ReadSubsystemCache(cache)
+	require.NoError(t, err)
+	require.Equal(t, SubsystemSourceNetwork, entries["desktop"].Source)
+}
+
+func TestSubsystemCache_ReplayReverifiesPayloads(t *testing.T) {
+	t.Parallel()
+
+	trustedKey, trustedSigningKey := testSigningKey(t)
+	storedKeyset, err := json.Marshal(signingKeyset{Version: 1, Keys: []signingKey{trustedSigningKey}})
+	require.NoError(t, err)
+
+	mockKnapsack := typesMocks.NewKnapsack(t)
+	mockKnapsack.On("RegisterChangeObserver", mock.Anything, keys.ControlRequestInterval)
+	mockKnapsack.On("ControlRequestInterval").Return(60 * time.Second)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+	mockKnapsack.On("EnforceControlPayloadSignatures").Return(true)
+
+	// Seed the cache with a signed payload, and with one that was tampered with after it was signed
+	cache := inmemory.NewStore()
+	seed := New(mockKnapsack, nopDataProvider{}, WithSubsystemCache(cache))
+	for subsystem, payload := range map[string]string{"desktop": `"status"`, "katc_config": `"config"`} {
+		sig, err := echelper.Sign(trustedKey, signedPayloadMessage(subsystem, []byte(payload)))
+		require.NoError(t, err)
+		if subsystem == "katc_config" {
+			payload = `"tampered"`
+		}
+		seed.cacheAppliedPayload(t.Context(), subsystem, subsystem+"-hash", []byte(payload), &payloadSignature{KeyID: trustedSigningKey.ID, Signature: sig}, SubsystemSourceNetwork)
+	}
+
+	store := &mockStore{keyValues: map[string]string{signingKeysStoreKey: string(storedKeyset)}}
+	cs := New(mockKnapsack, unreachableDataProvider{}, WithStore(store), WithSubsystemCache(cache), WithPayloadVerification())
+	desktopConsumer := &recordingConsumer{}
+	require.NoError(t, cs.RegisterConsumer("desktop", desktopConsumer))
+	katcConsumer := &recordingConsumer{}
+	require.NoError(t, cs.RegisterConsumer("katc_config", katcConsumer))
+
+	cs.replayCachedPayloads(t.Context())
+
+	require.Equal(t, []string{`"status"`}, desktopConsumer.payloads)
+	require.Empty(t, katcConsumer.payloads)
+
+	entries, err := ReadSubsystemCache(cache)
+	require.NoError(t, err)
+	require.Equal(t, SubsystemSourceReplay, entries["desktop"].Source)
+	require.NotEmpty(t, entries["desktop"].Signature)
+	require.Equal(t, SubsystemSourceNetwork, entries["katc_config"].Source)
+	require.Contains(t, entries["katc_config"].LastError, "verifying cached payload")
+}
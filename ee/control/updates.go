@@ -0,0 +1,172 @@
+package control
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kolide/launcher/ee/observability"
+)
+
+const (
+	// How long to wait before reconnecting after the updates connection fails; the delay doubles
+	// on each consecutive failure, up to maxUpdatesRetryDelay. Polling continues in the meantime.
+	minUpdatesRetryDelay = 5 * time.Second
+	maxUpdatesRetryDelay = 5 * time.Minute
+
+	// If the control server responds without any updates faster than this, it isn't holding
+	// our requests open -- we back off rather than hammering it.
+	minUpdatesRequestDuration = 1 * time.Second
+)
+
+// updatesProvider is an optional interface for a dataProvider that can hold a connection open to
+// the control server, so that the server can notify us of subsystem updates as they happen rather
+// than waiting for our next poll.
+type updatesProvider interface {
+	WaitForUpdates(ctx context.Context, knownHashes map[string]string) (map[string]string, error)
+}
+
+// listenForUpdates repeatedly waits for subsystem updates from the control server while
+// ControlPushEnabled is set, fetching updated subsystems as soon as they're announced. On
+// failure, it backs off before reconnecting; interval polling in `Start` covers the gap.
+// It returns when ctx is canceled.
+func (cs *ControlService) listenForUpdates(ctx context.Context, provider updatesProvider) {
+	retryDelay := minUpdatesRetryDelay
+	connected := false
+	// The hashes of updates to subsystems that launcher doesn't handle. We report these as
+	// known, so that the control server doesn't keep answering our requests with them.
+	unknownHashes := make(map[string]string)
+
+	for {
+		if !cs.knapsack.ControlPushEnabled() {
+			connected = false
+			select {
+			case <-ctx.Done():
+				return
+			case <-cs.pushEnabledChanged:
+				continue
+			}
+		}
+
+		requestStart := time.Now()
+		knownHashes := cs.knownHashes()
+		for subsystem, hash := range unknownHashes {
+			knownHashes[subsystem] = hash
+		}
+		updates, err := provider.WaitForUpdates(ctx, knownHashes)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil && len(updates) == 0 && time.Since(requestStart) < minUpdatesRequestDuration {
+			cs.slogger.Log(ctx, slog.LevelDebug,
+				"control server responded to updates request without holding it open",
+			)
+		} else if err != nil {
+			if connected {
+				cs.slogger.Log(ctx, slog.LevelInfo,
+					"lost connection for control server updates, falling back to polling",
+					"err", err,
+				)
+			} else {
+				cs.slogger.Log(ctx, slog.LevelDebug,
+					"could not wait for control server updates",
+					"err", err,
+					"retry_delay", retryDelay.String(),
+				)
+			}
+			connected = false
+		} else {
+			if !connected {
+				cs.slogger.Log(ctx, slog.LevelInfo,
+					"connected to control server for updates",
+				)
+			}
+			connected = true
+
+			if cs.applyUpdates(ctx, updates, unknownHashes) {
+				retryDelay = minUpdatesRetryDelay
+				continue
+			}
+
+			// The control server will keep reporting the updates we couldn't apply, and answer
+			// our next request immediately -- back off rather than hammering it.
+			cs.slogger.Log(ctx, slog.LevelDebug,
+				"could not apply all subsystem updates from control server",
+				"retry_delay", retryDelay.String(),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+			retryDelay = min(retryDelay*2, maxUpdatesRetryDelay)
+		}
+	}
+}
+
+// knownHashes returns the last fetched hash for each registered subsystem, so that the
+// control server can tell us only about subsystems that have changed since.
+func (cs *ControlService) knownHashes() map[string]string {
+	cs.fetchMutex.Lock()
+	defer cs.fetchMutex.Unlock()
+
+	hashes := make(map[string]string)
+	for subsystem := range cs.consumers {
+		if hash := cs.lastHash(subsystem); hash != "" {
+			hashes[subsystem] = hash
+		}
+	}
+	for subsystem := range cs.subscribers {
+		if hash := cs.lastHash(subsystem); hash != "" {
+			hashes[subsystem] = hash
+		}
+	}
+
+	return hashes
+}
+
+// applyUpdates fetches the given updated subsystems, and notifies their consumers and subscribers.
+// Updates to subsystems that launcher doesn't handle are skipped, and their hashes recorded in
+// unknownHashes. It reports whether we are now up to date with every subsystem we handle.
+func (cs *ControlService) applyUpdates(ctx context.Context, updates map[string]string, unknownHashes map[string]string) bool {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	// Unlike `Fetch`, we wait for the lock -- we don't want to drop updates
+	cs.fetchMutex.Lock()
+	defer cs.fetchMutex.Unlock()
+
+	allApplied := true
+	for subsystem, hash := range updates {
+		if !cs.knownSubsystem(subsystem) {
+			cs.slogger.Log(ctx, slog.LevelDebug,
+				"received update for unknown subsystem from control server, skipping",
+				"subsystem", subsystem,
+			)
+			unknownHashes[subsystem] = hash
+			continue
+		}
+		if hash == cs.lastHash(subsystem) {
+			continue
+		}
+
+		cs.slogger.Log(ctx, slog.LevelDebug,
+			"received subsystem update from control server",
+			"subsystem", subsystem,
+		)
+
+		if err := cs.fetchAndUpdate(ctx, subsystem, hash); err != nil {
+			cs.slogger.Log(ctx, slog.LevelDebug,
+				"failed to fetch updated object. will retry on next fetch",
+				"subsystem", subsystem,
+				"err", err,
+			)
+			cs.cacheError(ctx, subsystem, err)
+			allApplied = false
+		}
+	}
+
+	return allApplied
+}
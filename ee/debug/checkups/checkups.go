@@ -110,6 +110,8 @@ func checkupsFor(k types.Knapsack, target targetBits) []checkupInt {
 		{&osqDataCollector{k: k}, doctorSupported | flareSupported},
 		{&intuneCheckup{}, flareSupported},
 		{&osqRestartCheckup{k: k}, doctorSupported | flareSupported},
+		{&osqRollbackCheckup{k: k}, doctorSupported | flareSupported},
+		{&tableStatsCheckup{k: k}, flareSupported},
 		{&uninstallHistoryCheckup{k: k}, flareSupported},
 		{&desktopMenu{k: k}, flareSupported},
 		{&coredumpCheckup{}, doctorSupported | flareSupported},
@@ -0,0 +1,52 @@
+package checkups
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/tuf"
+)
+
+type (
+	osqRollbackCheckup struct {
+		k       types.Knapsack
+		status  Status
+		summary string
+		data    map[string]any
+	}
+)
+
+func (orc *osqRollbackCheckup) Data() any             { return orc.data }
+func (orc *osqRollbackCheckup) ExtraFileName() string { return "" }
+func (orc *osqRollbackCheckup) Name() string          { return "Osquery Rollbacks" }
+func (orc *osqRollbackCheckup) Status() Status        { return orc.status }
+func (orc *osqRollbackCheckup) Summary() string       { return orc.summary }
+
+func (orc *osqRollbackCheckup) Run(ctx context.Context, extraFH io.Writer) error {
+	orc.data = make(map[string]any)
+
+	badVersions, err := tuf.BadVersions("osqueryd", orc.k.RootDirectory(), orc.k.UpdateDirectory())
+	if err != nil {
+		orc.status = Erroring
+		orc.summary = "Unable to read osqueryd versions marked bad"
+		orc.data["error"] = err.Error()
+		return nil
+	}
+
+	if len(badVersions) == 0 {
+		orc.status = Passing
+		orc.summary = "osqueryd has not been rolled back"
+		return nil
+	}
+
+	latest := badVersions[len(badVersions)-1]
+	orc.status = Warning
+	orc.summary = fmt.Sprintf("osqueryd has been rolled back from %d version(s) after crash loops, most recently %s at %s",
+		len(badVersions), latest.Version, latest.MarkedAt.Format(time.RFC3339))
+	orc.data["bad_versions"] = badVersions
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package checkups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+)
+
+type tableStatsCheckup struct {
+	k       types.Knapsack
+	status  Status
+	summary string
+	data    map[string]any
+}
+
+func (tsc *tableStatsCheckup) Data() any             { return tsc.data }
+func (tsc *tableStatsCheckup) ExtraFileName() string { return "table_stats.json" }
+func (tsc *tableStatsCheckup) Name() string          { return "Launcher Table Stats" }
+func (tsc *tableStatsCheckup) Status() Status        { return tsc.status }
+func (tsc *tableStatsCheckup) Summary() string       { return tsc.summary }
+
+func (tsc *tableStatsCheckup) Run(ctx context.Context, extraFH io.Writer) error {
+	tsc.data = make(map[string]any)
+
+	// When running in situ, the stats are in memory; otherwise, fall back to the last persisted stats.
+	stats := tablewrapper.Stats()
+	if len(stats) == 0 {
+		store := tsc.k.TableStatsStore()
+		if store == nil {
+			// We are probably running standalone instead of in situ
+			tsc.status = Informational
+			tsc.summary = "table stats not available"
+			return nil
+		}
+
+		var err error
+		stats, err = tablewrapper.StoredStats(store)
+		if err != nil {
+			tsc.status = Erroring
+			tsc.summary = "unable to read table stats"
+			tsc.data["error"] = err.Error()
+			return nil
+		}
+	}
+
+	var withErrors, withTimeouts, withRejections int
+	for _, s := range stats {
+		if s.Errors > 0 {
+			withErrors += 1
+		}
+		if s.Timeouts > 0 {
+			withTimeouts += 1
+		}
+		if s.WorkerRejections > 0 {
+			withRejections += 1
+		}
+	}
+	tsc.data["table_count"] = len(stats)
+	tsc.data["tables_with_errors"] = withErrors
+	tsc.data["tables_with_timeouts"] = withTimeouts
+	tsc.data["tables_with_worker_rejections"] = withRejections
+
+	tsc.status = Informational
+	tsc.summary = fmt.Sprintf("stats for %d tables: %d with errors, %d with timeouts, %d with worker rejections",
+		len(stats), withErrors, withTimeouts, withRejections)
+
+	if err := json.NewEncoder(extraFH).Encode(stats); err != nil {
+		return fmt.Errorf("writing table stats: %w", err)
+	}
+
+	return nil
+}
@@ -124,6 +124,8 @@ type DesktopUsersProcessesRunner struct {
 	menuRefreshInterval time.Duration
 	interrupt           chan struct{}
 	interrupted         atomic.Bool
+	// respawn signals the execute loop to spawn desktop processes immediately, after a restart
+	respawn chan struct{}
 	// uidProcs is a map of uid to desktop process
 	uidProcs     map[string]processRecord
 	uidProcsLock *sync.Mutex
@@ -178,6 +180,7 @@ func (pr processRecord) String() string {
 func New(k types.Knapsack, messenger runnerserver.Messenger, opts ...desktopUsersProcessesRunnerOption) (*DesktopUsersProcessesRunner, error) {
 	runner := &DesktopUsersProcessesRunner{
 		interrupt:           make(chan struct{}),
+		respawn:             make(chan struct{}, 1),
 		uidProcs:            make(map[string]processRecord),
 		uidProcsLock:        &sync.Mutex{},
 		updateInterval:      atomic.NewDuration(k.DesktopUpdateInterval()),
@@ -274,6 +277,8 @@ func (r *DesktopUsersProcessesRunner) Execute() error {
 		case <-osUpdateCheckTicker.C:
 			r.checkOsUpdate()
 			continue
+		case <-r.respawn:
+			continue
 		case <-r.interrupt:
 			r.slogger.Log(context.TODO(), slog.LevelDebug,
 				"interrupt received, exiting desktop execute loop",
@@ -317,6 +322,30 @@ func (r *DesktopUsersProcessesRunner) Interrupt(_ error) {
 	)
 }
 
+// Restart shuts down all desktop processes, and then signals the execute loop to spawn
+// new ones right away, without waiting for the next update interval.
+func (r *DesktopUsersProcessesRunner) Restart(ctx context.Context) error {
+	if r.interrupted.Load() {
+		return errors.New("desktop runner is shutting down")
+	}
+
+	r.slogger.Log(ctx, slog.LevelInfo,
+		"restarting desktop processes",
+	)
+
+	killCtx, cancel := context.WithTimeout(ctx, r.interruptTimeout+3*time.Second)
+	defer cancel()
+	r.killDesktopProcesses(killCtx)
+
+	select {
+	case r.respawn <- struct{}{}:
+	default:
+		// A respawn is already pending
+	}
+
+	return nil
+}
+
 func (r *DesktopUsersProcessesRunner) DetectPresence(reason string, interval time.Duration) (time.Duration, error) {
 	r.uidProcsLock.Lock()
 	defer r.uidProcsLock.Unlock()
@@ -0,0 +1,242 @@
+package fakek2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kolide/krypto/pkg/echelper"
+	"github.com/kolide/launcher/ee/control"
+)
+
+// maxControlMessageBytes matches the limit the control client enforces on messages.
+const maxControlMessageBytes = 1024
+
+// ControlMessage is a JSON-RPC message sent by launcher to the control server.
+type ControlMessage struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// controlServer implements the control server protocol: a client fetches a challenge,
+// then exchanges the challenge signed by its keys for a bearer token and a map of
+// subsystem name to object hash. Objects are then fetched by hash with the token.
+type controlServer struct {
+	slogger *slog.Logger
+
+	mu         sync.Mutex
+	challenges map[string]struct{}
+	tokens     map[string]struct{}
+	subsystems map[string]string // subsystem -> object hash
+	objects    map[string][]byte // object hash -> data
+	msgs       []ControlMessage
+	keys       []string
+}
+
+func newControlServer(slogger *slog.Logger) *controlServer {
+	return &controlServer{
+		slogger:    slogger.With("api", "control"),
+		challenges: make(map[string]struct{}),
+		tokens:     make(map[string]struct{}),
+		subsystems: make(map[string]string),
+		objects:    make(map[string][]byte),
+	}
+}
+
+func (c *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/agent/config", c.serveChallenge)
+	mux.HandleFunc("POST /api/agent/config", c.serveConfig)
+	mux.HandleFunc("GET /api/agent/object/{hash}", c.serveObject)
+	mux.HandleFunc("POST /api/agent/message", c.serveMessage)
+
+	return mux
+}
+
+func (c *controlServer) serveChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := randomHex(32)
+
+	c.mu.Lock()
+	c.challenges[challenge] = struct{}{}
+	c.mu.Unlock()
+
+	_, _ = w.Write([]byte(challenge))
+}
+
+func (c *controlServer) serveConfig(w http.ResponseWriter, r *http.Request) {
+	challenge := r.Header.Get(control.HeaderChallenge)
+
+	c.mu.Lock()
+	_, ok := c.challenges[challenge]
+	delete(c.challenges, challenge)
+	c.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown challenge", http.StatusUnauthorized)
+		return
+	}
+
+	key := r.Header.Get(control.HeaderKey)
+	if err := verifyChallenge(challenge, key, r.Header.Get(control.HeaderSignature)); err != nil {
+		c.slogger.Log(r.Context(), slog.LevelWarn,
+			"rejected control request with invalid signature",
+			"err", err,
+		)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// The hardware key is optional, but must be valid when present
+	if key2 := r.Header.Get(control.HeaderKey2); key2 != "" {
+		if err := verifyChallenge(challenge, key2, r.Header.Get(control.HeaderSignature2)); err != nil {
+			c.slogger.Log(r.Context(), slog.LevelWarn,
+				"rejected control request with invalid hardware key signature",
+				"err", err,
+			)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	token := randomHex(16)
+
+	c.mu.Lock()
+	c.tokens[token] = struct{}{}
+	c.keys = append(c.keys, key)
+	config, err := json.Marshal(c.subsystems)
+	c.mu.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"token":  token,
+		"config": json.RawMessage(config),
+	})
+}
+
+func (c *controlServer) serveObject(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(r) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	c.mu.Lock()
+	data, ok := c.objects[r.PathValue("hash")]
+	c.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func (c *controlServer) serveMessage(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(r) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxControlMessageBytes+1))
+	if err != nil || len(body) > maxControlMessageBytes {
+		http.Error(w, "invalid message body", http.StatusBadRequest)
+		return
+	}
+
+	var msg ControlMessage
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Method == "" {
+		http.Error(w, "invalid message body", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.msgs = append(c.msgs, msg)
+	c.mu.Unlock()
+
+	writeJSON(w, map[string]any{})
+}
+
+func (c *controlServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok = c.tokens[token]
+	return ok
+}
+
+func (c *controlServer) setSubsystem(subsystem string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshalling subsystem data: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[hash] = raw
+	c.subsystems[subsystem] = hash
+
+	return nil
+}
+
+func (c *controlServer) removeSubsystem(subsystem string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subsystems, subsystem)
+}
+
+func (c *controlServer) messages() []ControlMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ControlMessage(nil), c.msgs...)
+}
+
+func (c *controlServer) authenticatedKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.keys...)
+}
+
+// verifyChallenge checks that sig, a base64 signature, is a signature over challenge
+// by key, a base64 DER encoded ECDSA public key.
+func verifyChallenge(challenge, key, sig string) error {
+	pubKey, err := echelper.PublicB64DerToEcdsaKey([]byte(key))
+	if err != nil {
+		return fmt.Errorf("parsing key: %w", err)
+	}
+
+	rawSig, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	if err := echelper.VerifySignature(pubKey, []byte(challenge), rawSig); err != nil {
+		return fmt.Errorf("verifying signature: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,82 @@
+package fakek2
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kolide/launcher/ee/agent/types"
+)
+
+// ingestHandler serves the agent-ingester API, rooted at IngestPath.
+func (s *Server) ingestHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /logs", func(w http.ResponseWriter, r *http.Request) {
+		var req types.PublishOsqueryLogsRequest
+		if !s.decodeIngestRequest(w, r, &req) {
+			return
+		}
+
+		s.mu.Lock()
+		s.ingestedLogs[req.LogType] = append(s.ingestedLogs[req.LogType], s.reassemble(r.Context(), s.ingestReassm, req.Logs)...)
+		s.mu.Unlock()
+
+		ingestedBytes := 0
+		for _, log := range req.Logs {
+			ingestedBytes += len(log)
+		}
+		writeIngestResponse(w, http.StatusOK, types.OsqueryPublicationResponse{
+			Status:        "success",
+			IngestedBytes: int64(ingestedBytes),
+			LogCount:      len(req.Logs),
+		})
+	})
+
+	mux.HandleFunc("POST /results", func(w http.ResponseWriter, r *http.Request) {
+		var req types.PublishOsqueryResultsRequest
+		if !s.decodeIngestRequest(w, r, &req) {
+			return
+		}
+
+		s.mu.Lock()
+		s.ingestResults = append(s.ingestResults, req.Results...)
+		s.mu.Unlock()
+
+		writeIngestResponse(w, http.StatusOK, types.OsqueryPublicationResponse{
+			Status:   "success",
+			LogCount: len(req.Results),
+		})
+	})
+
+	return mux
+}
+
+// decodeIngestRequest authenticates r and decodes its body into v, writing an error
+// response and returning false on failure.
+func (s *Server) decodeIngestRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" || (s.ingestToken != "" && token != s.ingestToken) {
+		writeIngestResponse(w, http.StatusUnauthorized, types.OsqueryPublicationResponse{
+			Status:  "error",
+			Message: "invalid token",
+		})
+		return false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeIngestResponse(w, http.StatusBadRequest, types.OsqueryPublicationResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
+
+func writeIngestResponse(w http.ResponseWriter, status int, resp types.OsqueryPublicationResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,135 @@
+package fakek2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/kolide/launcher/ee/logchunk"
+	"github.com/kolide/launcher/pkg/service"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+)
+
+// Server implements service.KolideService, and serves it over JSON-RPC.
+var _ service.KolideService = (*Server)(nil)
+
+func (s *Server) RequestEnrollment(ctx context.Context, enrollSecret, hostIdentifier string, details service.EnrollmentDetails) (string, bool, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.enrollSecret != "" && enrollSecret != s.enrollSecret {
+		s.slogger.Log(ctx, slog.LevelInfo,
+			"rejected enrollment with incorrect secret",
+			"host_identifier", hostIdentifier,
+		)
+		return "", true, "", nil
+	}
+
+	nodeKey := "fake-node-key-" + randomHex(8)
+	s.nodeKeys[nodeKey] = hostIdentifier
+	s.nodeInvalid = false
+	s.enrollments = append(s.enrollments, Enrollment{
+		HostIdentifier: hostIdentifier,
+		NodeKey:        nodeKey,
+		Details:        details,
+	})
+
+	s.slogger.Log(ctx, slog.LevelInfo,
+		"enrolled node",
+		"host_identifier", hostIdentifier,
+	)
+
+	return nodeKey, false, "", nil
+}
+
+func (s *Server) RequestConfig(ctx context.Context, nodeKey string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validNodeKey(nodeKey) {
+		return "", true, nil
+	}
+
+	return s.config, false, nil
+}
+
+func (s *Server) PublishLogs(ctx context.Context, nodeKey string, logType logger.LogType, logs []string) (string, string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validNodeKey(nodeKey) {
+		return "", "", true, nil
+	}
+
+	s.logs[logType] = append(s.logs[logType], s.reassemble(ctx, s.reassembler, logs)...)
+	return "", "", false, nil
+}
+
+func (s *Server) RequestQueries(ctx context.Context, nodeKey string) (*distributed.GetQueriesResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validNodeKey(nodeKey) {
+		return nil, true, nil
+	}
+
+	queries := s.queries
+	s.queries = make(map[string]string)
+
+	return &distributed.GetQueriesResult{Queries: queries}, false, nil
+}
+
+func (s *Server) PublishResults(ctx context.Context, nodeKey string, results []distributed.Result) (string, string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.validNodeKey(nodeKey) {
+		return "", "", true, nil
+	}
+
+	s.results = append(s.results, results...)
+	return "", "", false, nil
+}
+
+func (s *Server) CheckHealth(ctx context.Context) (int32, error) {
+	return 1, nil
+}
+
+// validNodeKey must be called with s.mu held.
+func (s *Server) validNodeKey(nodeKey string) bool {
+	if s.nodeInvalid {
+		return false
+	}
+
+	_, ok := s.nodeKeys[nodeKey]
+	return ok
+}
+
+// reassemble returns the complete logs among logs, holding chunks in r until
+// their log is complete. Invalid chunks are logged and discarded.
+func (s *Server) reassemble(ctx context.Context, r *logchunk.Reassembler, logs []string) []string {
+	complete := make([]string, 0, len(logs))
+	for _, line := range logs {
+		log, ok, err := r.Add(line)
+		if err != nil {
+			s.slogger.Log(ctx, slog.LevelWarn,
+				"discarding invalid log chunk",
+				"err", err,
+			)
+			continue
+		}
+		if ok {
+			complete = append(complete, log)
+		}
+	}
+
+	return complete
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,142 @@
+package fakek2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+)
+
+// AdminPath is the path the admin API, for scripting a running server from
+// outside the process, is served under.
+const AdminPath = "/fake-k2"
+
+// Script describes changes to apply to a running server. Unset fields are left unchanged.
+type Script struct {
+	// Config is the osquery config to serve.
+	Config json.RawMessage `json:"config,omitempty"`
+	// Queries are distributed queries, keyed by name, to queue.
+	Queries map[string]string `json:"queries,omitempty"`
+	// Subsystems maps control subsystem name to the data to publish for it. A null
+	// value removes the subsystem.
+	Subsystems map[string]json.RawMessage `json:"subsystems,omitempty"`
+	// TufTargets maps TUF target path to the local file to serve as that target.
+	TufTargets map[string]string `json:"tuf_targets,omitempty"`
+	// NodeInvalid, when set, is passed to SetNodeInvalid.
+	NodeInvalid *bool `json:"node_invalid,omitempty"`
+}
+
+// State is a snapshot of everything the server has received.
+type State struct {
+	Enrollments     []Enrollment         `json:"enrollments"`
+	Logs            map[string][]string  `json:"logs"`
+	Results         []distributed.Result `json:"results"`
+	IngestedLogs    map[string][]string  `json:"ingested_logs"`
+	IngestedResults []distributed.Result `json:"ingested_results"`
+	ControlMessages []ControlMessage     `json:"control_messages"`
+}
+
+// LoadScript reads a Script from a JSON file.
+func LoadScript(path string) (*Script, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(raw, &script); err != nil {
+		return nil, fmt.Errorf("parsing script %s: %w", path, err)
+	}
+
+	return &script, nil
+}
+
+// Apply applies script to the server.
+func (s *Server) Apply(script *Script) error {
+	if len(script.Config) > 0 {
+		s.SetConfig(string(script.Config))
+	}
+
+	if len(script.Queries) > 0 {
+		s.QueueQueries(script.Queries)
+	}
+
+	for subsystem, data := range script.Subsystems {
+		if string(data) == "null" {
+			s.RemoveSubsystem(subsystem)
+			continue
+		}
+		if err := s.SetSubsystem(subsystem, data); err != nil {
+			return fmt.Errorf("setting subsystem %s: %w", subsystem, err)
+		}
+	}
+
+	for target, path := range script.TufTargets {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading tuf target %s: %w", target, err)
+		}
+		if err := s.AddTufTarget(target, data, nil); err != nil {
+			return err
+		}
+	}
+
+	if script.NodeInvalid != nil {
+		s.SetNodeInvalid(*script.NodeInvalid)
+	}
+
+	return nil
+}
+
+// State returns a snapshot of everything the server has received.
+func (s *Server) State() State {
+	state := State{
+		Enrollments:     s.Enrollments(),
+		Logs:            make(map[string][]string),
+		Results:         s.Results(),
+		IngestedLogs:    make(map[string][]string),
+		IngestedResults: s.IngestedResults(),
+		ControlMessages: s.ControlMessages(),
+	}
+
+	for _, typ := range []logger.LogType{logger.LogTypeString, logger.LogTypeSnapshot, logger.LogTypeStatus, logger.LogTypeHealth, logger.LogTypeInit} {
+		if logs := s.Logs(typ); len(logs) > 0 {
+			state.Logs[typ.String()] = logs
+		}
+		if logs := s.IngestedLogs(typ); len(logs) > 0 {
+			state.IngestedLogs[typ.String()] = logs
+		}
+	}
+
+	return state
+}
+
+// adminHandler serves the admin API, rooted at AdminPath: POST /script applies a
+// Script, and GET /state returns the server's State.
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /script", func(w http.ResponseWriter, r *http.Request) {
+		var script Script
+		if err := json.NewDecoder(r.Body).Decode(&script); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Apply(&script); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, map[string]any{})
+	})
+
+	mux.HandleFunc("GET /state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.State())
+	})
+
+	return mux
+}
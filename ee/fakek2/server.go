@@ -0,0 +1,245 @@
+// Package fakek2 is an in-process stand-in for the Kolide cloud, for running
+// launcher end-to-end without network access. A single Server speaks:
+//
+//   - the JSON-RPC osquery remote API (enrollment, config, logs, distributed queries)
+//   - the control server protocol, including signed-challenge authentication
+//   - a TUF metadata repository and download mirror
+//   - the agent-ingester osquery log publication API
+//
+// Everything the server returns can be scripted while it runs, either through
+// Server's methods or the admin API under AdminPath, and everything launcher
+// sends it is recorded for inspection.
+package fakek2
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/kolide/launcher/ee/compression"
+	"github.com/kolide/launcher/ee/logchunk"
+	"github.com/kolide/launcher/pkg/service"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+)
+
+const (
+	// IngestPath is the path the agent-ingester API is served under; launcher's
+	// osquery publisher URL should be set to the server's base URL plus IngestPath.
+	IngestPath = "/ingest"
+)
+
+// Server is a fake Kolide cloud. The zero value is not usable; use New.
+type Server struct {
+	slogger      *slog.Logger
+	enrollSecret string
+	ingestToken  string
+
+	mu            sync.Mutex
+	nodeKeys      map[string]string // node key -> host identifier
+	enrollments   []Enrollment
+	config        string
+	nodeInvalid   bool
+	queries       map[string]string
+	logs          map[logger.LogType][]string
+	results       []distributed.Result
+	reassembler   *logchunk.Reassembler
+	ingestedLogs  map[logger.LogType][]string
+	ingestReassm  *logchunk.Reassembler
+	ingestResults []distributed.Result
+
+	control *controlServer
+	tuf     *tufRepo
+}
+
+// Enrollment records a successful enrollment request.
+type Enrollment struct {
+	HostIdentifier string
+	NodeKey        string
+	Details        service.EnrollmentDetails
+}
+
+type Option func(*Server)
+
+// WithSlogger sets the logger the server reports requests to.
+func WithSlogger(slogger *slog.Logger) Option {
+	return func(s *Server) {
+		s.slogger = slogger
+	}
+}
+
+// WithEnrollSecret requires enrollment requests to present the given secret.
+// By default, any secret is accepted.
+func WithEnrollSecret(secret string) Option {
+	return func(s *Server) {
+		s.enrollSecret = secret
+	}
+}
+
+// WithIngestToken requires agent-ingester requests to present the given bearer token.
+// By default, any non-empty token is accepted.
+func WithIngestToken(token string) Option {
+	return func(s *Server) {
+		s.ingestToken = token
+	}
+}
+
+// New returns a Server with an empty osquery config, no subsystems, and a freshly
+// generated TUF repository with no targets.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		slogger:      slog.New(slog.DiscardHandler),
+		nodeKeys:     make(map[string]string),
+		config:       "{}",
+		queries:      make(map[string]string),
+		logs:         make(map[logger.LogType][]string),
+		reassembler:  logchunk.NewReassembler(),
+		ingestedLogs: make(map[logger.LogType][]string),
+		ingestReassm: logchunk.NewReassembler(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.slogger = s.slogger.With("component", "fake_k2")
+	s.control = newControlServer(s.slogger)
+
+	var err error
+	if s.tuf, err = newTufRepo(); err != nil {
+		return nil, fmt.Errorf("creating tuf repo: %w", err)
+	}
+
+	return s, nil
+}
+
+// Handler returns the http.Handler serving every API. All APIs share one handler
+// so that launcher's various server URLs can all point at the same address.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/api/agent/", s.control.handler())
+	mux.Handle(IngestPath+"/", http.StripPrefix(IngestPath, s.ingestHandler()))
+	mux.Handle("/repository/", s.tuf.metadataHandler())
+	mux.Handle("/kolide/", s.tuf.mirrorHandler())
+	mux.Handle(AdminPath+"/", http.StripPrefix(AdminPath, s.adminHandler()))
+
+	mux.Handle("/", service.NewJSONRPCServer(
+		service.MakeServerEndpoints(s),
+		log.NewNopLogger(),
+	))
+
+	return compression.Handler(s.logRequests(mux))
+}
+
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.slogger.Log(r.Context(), slog.LevelDebug,
+			"received request",
+			"method", r.Method,
+			"path", r.URL.Path,
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetConfig sets the osquery config returned to RequestConfig.
+func (s *Server) SetConfig(config string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// QueueQueries queues distributed queries, keyed by name, to be returned on the
+// next RequestQueries call.
+func (s *Server) QueueQueries(queries map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, query := range queries {
+		s.queries[name] = query
+	}
+}
+
+// SetNodeInvalid makes every authenticated osquery request report an invalid node
+// key, forcing launcher to re-enroll, until it is set back to false. Existing node
+// keys are forgotten.
+func (s *Server) SetNodeInvalid(invalid bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodeInvalid = invalid
+	if invalid {
+		s.nodeKeys = make(map[string]string)
+	}
+}
+
+// Enrollments returns every successful enrollment, in order.
+func (s *Server) Enrollments() []Enrollment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Enrollment(nil), s.enrollments...)
+}
+
+// Logs returns the logs of the given type received over JSON-RPC, with any
+// chunked logs reassembled.
+func (s *Server) Logs(typ logger.LogType) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logs[typ]...)
+}
+
+// Results returns the distributed query results received over JSON-RPC.
+func (s *Server) Results() []distributed.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]distributed.Result(nil), s.results...)
+}
+
+// IngestedLogs returns the logs of the given type received by the agent-ingester
+// API, with any chunked logs reassembled.
+func (s *Server) IngestedLogs(typ logger.LogType) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ingestedLogs[typ]...)
+}
+
+// IngestedResults returns the distributed query results received by the agent-ingester API.
+func (s *Server) IngestedResults() []distributed.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]distributed.Result(nil), s.ingestResults...)
+}
+
+// SetSubsystem publishes data for a control subsystem. Launcher fetches it the
+// next time it polls the control server and sees the changed hash.
+func (s *Server) SetSubsystem(subsystem string, data any) error {
+	return s.control.setSubsystem(subsystem, data)
+}
+
+// RemoveSubsystem stops publishing a control subsystem.
+func (s *Server) RemoveSubsystem(subsystem string) {
+	s.control.removeSubsystem(subsystem)
+}
+
+// ControlMessages returns the messages launcher has sent to the control server.
+func (s *Server) ControlMessages() []ControlMessage {
+	return s.control.messages()
+}
+
+// ControlKeys returns the public keys, as base64 DER, that have successfully
+// authenticated to the control server.
+func (s *Server) ControlKeys() []string {
+	return s.control.authenticatedKeys()
+}
+
+// AddTufTarget adds a target to the TUF repository and mirror, and publishes new
+// metadata. custom is the target's custom metadata, and may be nil.
+func (s *Server) AddTufTarget(path string, data []byte, custom []byte) error {
+	return s.tuf.addTarget(path, data, custom)
+}
+
+// TufRootJSON returns the root metadata of the TUF repository, for initializing a client.
+func (s *Server) TufRootJSON() ([]byte, error) {
+	return s.tuf.rootJSON()
+}
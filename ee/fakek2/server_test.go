@@ -0,0 +1,234 @@
+package fakek2
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kolide/launcher/ee/agent"
+	"github.com/kolide/launcher/ee/agent/storage"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	"github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/control"
+	"github.com/kolide/launcher/ee/logchunk"
+	"github.com/kolide/launcher/ee/osquerypublisher"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/kolide/launcher/pkg/service"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*Server, *url.URL) {
+	s, err := New(opts...)
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(s.Handler())
+	t.Cleanup(httpServer.Close)
+
+	u, err := url.Parse(httpServer.URL)
+	require.NoError(t, err)
+
+	return s, u
+}
+
+func TestOsqueryRemoteAPI(t *testing.T) {
+	t.Parallel()
+
+	s, u := newTestServer(t, WithEnrollSecret("secret"))
+
+	k := mocks.NewKnapsack(t)
+	k.On("KolideServerURL").Return(u.Host)
+	k.On("InsecureTransportTLS").Return(true)
+	k.On("Slogger").Return(multislogger.NewNopLogger())
+	svc := service.NewJSONRPCClient(k, nil)
+
+	// Enrollment requires the configured secret
+	_, invalid, _, err := svc.RequestEnrollment(t.Context(), "wrong", "host", service.EnrollmentDetails{})
+	require.NoError(t, err)
+	require.True(t, invalid)
+
+	nodeKey, invalid, _, err := svc.RequestEnrollment(t.Context(), "secret", "host", service.EnrollmentDetails{Hostname: "myhost"})
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.NotEmpty(t, nodeKey)
+	require.Len(t, s.Enrollments(), 1)
+	require.Equal(t, "myhost", s.Enrollments()[0].Details.Hostname)
+
+	// Config
+	s.SetConfig(`{"schedule":{}}`)
+	config, invalid, err := svc.RequestConfig(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.Equal(t, `{"schedule":{}}`, config)
+
+	// Distributed queries are handed out once
+	s.QueueQueries(map[string]string{"q1": "select 1"})
+	queries, _, err := svc.RequestQueries(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"q1": "select 1"}, queries.Queries)
+	queries, _, err = svc.RequestQueries(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.Empty(t, queries.Queries)
+
+	_, _, _, err = svc.PublishResults(t.Context(), nodeKey, []distributed.Result{{QueryName: "q1", Rows: []map[string]string{{"1": "1"}}}})
+	require.NoError(t, err)
+	require.Len(t, s.Results(), 1)
+
+	// Logs, with a chunked log reassembled
+	bigLog := `{"name":"big","data":"` + strings.Repeat("a", 4000) + `"}`
+	chunks, err := logchunk.Split(bigLog, 1024)
+	require.NoError(t, err)
+	_, _, _, err = svc.PublishLogs(t.Context(), nodeKey, logger.LogTypeString, append([]string{`{"name":"small"}`}, chunks...))
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"name":"small"}`, bigLog}, s.Logs(logger.LogTypeString))
+
+	// Invalidating node keys forces re-enrollment
+	s.SetNodeInvalid(true)
+	_, invalid, err = svc.RequestConfig(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.True(t, invalid)
+
+	newNodeKey, _, _, err := svc.RequestEnrollment(t.Context(), "secret", "host", service.EnrollmentDetails{})
+	require.NoError(t, err)
+	_, invalid, err = svc.RequestConfig(t.Context(), newNodeKey)
+	require.NoError(t, err)
+	require.False(t, invalid)
+
+	health, err := svc.CheckHealth(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), health)
+}
+
+func TestControlAPI(t *testing.T) {
+	t.Parallel()
+
+	s, u := newTestServer(t)
+	require.NoError(t, agent.SetupKeys(t.Context(), multislogger.NewNopLogger(), inmemory.NewStore()))
+
+	require.NoError(t, s.SetSubsystem("desktop", map[string]bool{"enabled": true}))
+
+	c, err := control.NewControlHTTPClient(u.Host, &http.Client{}, multislogger.NewNopLogger(), control.WithDisableTLS())
+	require.NoError(t, err)
+
+	configReader, err := c.GetConfig(t.Context())
+	require.NoError(t, err)
+	var config map[string]string
+	require.NoError(t, json.NewDecoder(configReader).Decode(&config))
+	require.Contains(t, config, "desktop")
+	require.Len(t, s.ControlKeys(), 1)
+
+	dataReader, err := c.GetSubsystemData(t.Context(), config["desktop"])
+	require.NoError(t, err)
+	data, err := io.ReadAll(dataReader)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"enabled":true}`, string(data))
+
+	require.NoError(t, c.SendMessage(t.Context(), "ping", map[string]string{"a": "b"}))
+	require.Len(t, s.ControlMessages(), 1)
+	require.Equal(t, "ping", s.ControlMessages()[0].Method)
+
+	// Requests without a valid signature are rejected
+	req, err := http.NewRequest(http.MethodPost, u.JoinPath("/api/agent/config").String(), nil)
+	require.NoError(t, err)
+	req.Header.Set(control.HeaderChallenge, "made-up")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestIngestAPI(t *testing.T) {
+	t.Parallel()
+
+	s, u := newTestServer(t, WithIngestToken("ingest-token"))
+
+	tokenStore := inmemory.NewStore()
+	require.NoError(t, tokenStore.Set(storage.AgentIngesterAuthTokenKey, []byte("ingest-token")))
+
+	k := mocks.NewKnapsack(t)
+	k.On("OsqueryPublisherURL").Return(u.JoinPath(IngestPath).String())
+	k.On("OsqueryPublisherPercentEnabled").Return(100)
+	k.On("TokenStore").Return(tokenStore)
+	publisher := osquerypublisher.NewLogPublisherClient(multislogger.NewNopLogger(), k, osquerypublisher.NewPublisherHTTPClient())
+
+	resp, err := publisher.PublishLogs(t.Context(), logger.LogTypeStatus, []string{`{"status":"ok"}`})
+	require.NoError(t, err)
+	require.Equal(t, "success", resp.Status)
+	require.Equal(t, []string{`{"status":"ok"}`}, s.IngestedLogs(logger.LogTypeStatus))
+
+	_, err = publisher.PublishResults(t.Context(), []distributed.Result{{QueryName: "q"}})
+	require.NoError(t, err)
+	require.Len(t, s.IngestedResults(), 1)
+
+	// Wrong token
+	req, err := http.NewRequest(http.MethodPost, u.JoinPath(IngestPath, "logs").String(), strings.NewReader("{}"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer wrong")
+	httpResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+}
+
+func TestTufAPI(t *testing.T) {
+	t.Parallel()
+
+	s, u := newTestServer(t)
+
+	target := "launcher/linux/amd64/launcher-1.2.3.tar.gz"
+	require.NoError(t, s.AddTufTarget(target, []byte("launcher tarball"), []byte(`{"version":"1.2.3"}`)))
+
+	root, err := s.TufRootJSON()
+	require.NoError(t, err)
+
+	remote, err := client.HTTPRemoteStore(u.String(), &client.HTTPRemoteOptions{MetadataPath: "/repository"}, http.DefaultClient)
+	require.NoError(t, err)
+	c := client.NewClient(client.MemoryLocalStore(), remote)
+	require.NoError(t, c.Init(root))
+
+	targets, err := c.Update()
+	require.NoError(t, err)
+	require.Contains(t, targets, target)
+
+	// The mirror serves the same file
+	resp, err := http.Get(u.JoinPath("/kolide", target).String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal([]byte("launcher tarball"), body))
+}
+
+func TestAdminAPI(t *testing.T) {
+	t.Parallel()
+
+	s, u := newTestServer(t)
+
+	script := `{"config":{"schedule":{}},"queries":{"q":"select 1"},"subsystems":{"desktop":{"enabled":true}}}`
+	resp, err := http.Post(u.JoinPath(AdminPath, "script").String(), "application/json", strings.NewReader(script))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	s.mu.Lock()
+	require.JSONEq(t, `{"schedule":{}}`, s.config)
+	require.Equal(t, map[string]string{"q": "select 1"}, s.queries)
+	s.mu.Unlock()
+	s.control.mu.Lock()
+	require.Contains(t, s.control.subsystems, "desktop")
+	s.control.mu.Unlock()
+
+	resp, err = http.Get(u.JoinPath(AdminPath, "state").String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var state State
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+	require.Empty(t, state.Enrollments)
+}
@@ -0,0 +1,154 @@
+package fakek2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/go-tuf"
+)
+
+// tufRepo is an in-memory TUF repository with freshly generated keys. Because its root
+// is not the one embedded in launcher, launcher's autoupdater will reject its metadata;
+// it exists so that autoupdate requests succeed at the HTTP level, and so that tests
+// can drive a TUF client initialized with TufRootJSON.
+type tufRepo struct {
+	mu    sync.Mutex
+	repo  *tuf.Repo
+	meta  map[string][]byte
+	files map[string][]byte
+}
+
+func newTufRepo() (*tufRepo, error) {
+	t := &tufRepo{
+		files: make(map[string][]byte),
+	}
+
+	var err error
+	t.repo, err = tuf.NewRepo(tuf.MemoryStore(nil, t.files))
+	if err != nil {
+		return nil, fmt.Errorf("creating repo: %w", err)
+	}
+	if err := t.repo.Init(false); err != nil {
+		return nil, fmt.Errorf("initializing repo: %w", err)
+	}
+
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		if _, err := t.repo.GenKeyWithExpires(role, metadataExpiry()); err != nil {
+			return nil, fmt.Errorf("generating %s key: %w", role, err)
+		}
+	}
+
+	if err := t.repo.AddTargetsWithExpires(nil, nil, metadataExpiry()); err != nil {
+		return nil, fmt.Errorf("adding targets: %w", err)
+	}
+	if err := t.commit(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *tufRepo) addTarget(path string, data []byte, custom []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	path = strings.TrimPrefix(path, "/")
+	t.files[path] = data
+	if err := t.repo.AddTargetWithExpires(path, custom, metadataExpiry()); err != nil {
+		delete(t.files, path)
+		return fmt.Errorf("adding target %s: %w", path, err)
+	}
+
+	return t.commit()
+}
+
+// commit must be called with t.mu held, or before t is shared.
+func (t *tufRepo) commit() error {
+	if err := t.repo.SnapshotWithExpires(metadataExpiry()); err != nil {
+		return fmt.Errorf("taking snapshot: %w", err)
+	}
+	if err := t.repo.TimestampWithExpires(metadataExpiry()); err != nil {
+		return fmt.Errorf("taking timestamp: %w", err)
+	}
+	if err := t.repo.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+
+	meta, err := t.repo.GetMeta()
+	if err != nil {
+		return fmt.Errorf("getting metadata: %w", err)
+	}
+
+	t.meta = make(map[string][]byte, len(meta))
+	for name, raw := range meta {
+		t.meta[name] = raw
+	}
+
+	return nil
+}
+
+// metadataExpiry is far enough out that a long-running server's metadata doesn't
+// expire, unlike go-tuf's default of a day for timestamps.
+func metadataExpiry() time.Time {
+	return time.Now().AddDate(1, 0, 0)
+}
+
+func (t *tufRepo) rootJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, ok := t.meta["root.json"]
+	if !ok {
+		return nil, errors.New("no root metadata")
+	}
+	return root, nil
+}
+
+// metadataHandler serves metadata under /repository/, and targets under
+// /repository/targets/, as the TUF server does.
+func (t *tufRepo) metadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/repository/")
+		if target, ok := strings.CutPrefix(name, "targets/"); ok {
+			t.serveTarget(w, r, target)
+			return
+		}
+
+		t.mu.Lock()
+		data, ok := t.meta[name]
+		t.mu.Unlock()
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// mirrorHandler serves targets under /kolide/, as the download mirror does.
+func (t *tufRepo) mirrorHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.serveTarget(w, r, strings.TrimPrefix(r.URL.Path, "/kolide/"))
+	})
+}
+
+func (t *tufRepo) serveTarget(w http.ResponseWriter, r *http.Request, target string) {
+	t.mu.Lock()
+	data, ok := t.files[target]
+	t.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeContent(w, r, target, time.Time{}, bytes.NewReader(data))
+}
@@ -40,7 +40,7 @@ func sqliteData(ctx context.Context, slogger *slog.Logger, sourcePaths []string,
 				continue
 			}
 
-			rowsFromDb, err := querySqliteDb(ctx, slogger, sqliteDb, query)
+			rowsFromDb, err := QuerySqliteDb(ctx, slogger, sqliteDb, query)
 			if err != nil {
 				slogger.Log(ctx, slog.LevelWarn,
 					"could not query sqlite database at path",
@@ -67,8 +67,11 @@ func sourcePatternToGlobbablePattern(sourcePattern string) string {
 	return strings.ReplaceAll(sourcePattern, "%", `*`)
 }
 
-// querySqliteDb queries the database at the given path, returning rows of results
-func querySqliteDb(ctx context.Context, slogger *slog.Logger, path string, query string) ([]map[string][]byte, error) {
+// QuerySqliteDb queries the database at the given path, returning rows of results.
+// The database is opened read-only and immutable, so it is safe to use against
+// databases that applications hold open. It is exported for use by tables that
+// need to read application sqlite databases outside of a KATC config.
+func QuerySqliteDb(ctx context.Context, slogger *slog.Logger, path string, query string) ([]map[string][]byte, error) {
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
 
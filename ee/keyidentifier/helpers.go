@@ -41,3 +41,9 @@ func readSizedString(r *bytes.Reader) (string, error) {
 func pemEncryptedBlock(block *pem.Block) bool {
 	return strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
 }
+
+// isSecurityKeyType tells whether an ssh key type is one of the FIDO
+// security key (sk-*) types, whose private half lives on hardware.
+func isSecurityKeyType(keyType string) bool {
+	return strings.HasPrefix(keyType, "sk-")
+}
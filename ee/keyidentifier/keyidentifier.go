@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -11,6 +12,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/kolide/launcher/pkg/log/multislogger"
 
@@ -27,6 +29,23 @@ type KeyInfo struct {
 	Parser            string // what parser we used to determine information
 	FingerprintSHA256 string // the fingerprint of the key, as a SHA256 hash
 	FingerprintMD5    string // the fingerprint of the key, as an MD5 hash
+	Cipher            string // cipher used to encrypt the key, if known
+	Kdf               string // key derivation function used for the passphrase, if known
+	KdfRounds         int    // number of KDF rounds (or iterations, or cost)
+	HardwareBacked    bool   // is this a FIDO security key (sk-*) backed key
+	Certificate       *CertificateInfo
+}
+
+// CertificateInfo holds the details of an OpenSSH certificate.
+type CertificateInfo struct {
+	CertType                   string    // user or host
+	KeyId                      string    // the certificate's key id
+	Serial                     uint64    // the certificate's serial number
+	Principals                 []string  // principals the certificate is valid for. Empty means any
+	ValidAfter                 time.Time // start of the validity window. Zero if unbounded
+	ValidBefore                time.Time // end of the validity window. Zero if unbounded
+	SigningCAType              string    // key type of the signing CA
+	SigningCAFingerprintSHA256 string    // the fingerprint of the signing CA, as a SHA256 hash
 }
 
 // keyidentifier attempts to identify a key. It uses a set of
@@ -92,6 +111,8 @@ func (kIdentifier *KeyIdentifier) Identify(keyBytes []byte) (*KeyInfo, error) {
 		return ParseSshComPrivateKey(keyBytes)
 	case bytes.HasPrefix(keyBytes, []byte(ssh1LegacyBegin)):
 		return ParseSsh1PrivateKey(keyBytes)
+	case isOpenSSHCertificate(keyBytes):
+		return ParseOpenSSHCertificate(keyBytes)
 	}
 
 	// If nothing else fits. treat it like a pem
@@ -137,6 +158,7 @@ func (kIdentifier *KeyIdentifier) attemptPem(keyBytes []byte) (*KeyInfo, error)
 
 	case "PRIVATE KEY":
 		// RFC5208 - https://tools.ietf.org/html/rfc5208
+		ki.Format = "pkcs8"
 		ki.Encrypted = boolPtr(x509.IsEncryptedPEMBlock(block)) // nolint:staticcheck
 		if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
 			switch assertedKey := key.(type) {
@@ -146,10 +168,32 @@ func (kIdentifier *KeyIdentifier) attemptPem(keyBytes []byte) (*KeyInfo, error)
 			case *ecdsa.PrivateKey:
 				ki.Bits = assertedKey.PublicKey.Curve.Params().BitSize
 				ki.Type = "ecdsa"
+			case ed25519.PrivateKey:
+				ki.Bits = 256
+				ki.Type = "ed25519"
 			}
 		}
 		return ki, nil
 
+	case "ENCRYPTED PRIVATE KEY":
+		// RFC5958 - https://tools.ietf.org/html/rfc5958. The key type
+		// is inside the encrypted blob, so we can only describe the
+		// encryption.
+		encryptedKi, err := ParsePKCS8EncryptedPrivateKey(block.Bytes)
+		if err == nil {
+			return encryptedKi, nil
+		}
+		kIdentifier.slogger.Log(context.TODO(), slog.LevelDebug,
+			"ParsePKCS8EncryptedPrivateKey failed to parse",
+			"err", err,
+		)
+
+		// Even if we can't parse the encryption parameters, the block type
+		// tells us what this is.
+		ki.Format = "pkcs8"
+		ki.Encrypted = boolPtr(true)
+		return ki, nil
+
 	case "EC PRIVATE KEY":
 		// set the Type here, since parsing fails on encrypted keys
 		ki.Type = "ecdsa"
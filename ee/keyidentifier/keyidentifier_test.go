@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kolide/launcher/pkg/log/multislogger"
 	"github.com/stretchr/testify/require"
@@ -99,6 +100,154 @@ func testIdentifyFile(t *testing.T, kIdentifer *KeyIdentifier, specFilePath stri
 	actual.Comment = ""
 	actual.Parser = ""
 	actual.Encryption = ""
+	actual.Cipher = ""
+	actual.Kdf = ""
+	actual.KdfRounds = 0
 
 	require.EqualValues(t, &expected.KeyInfo, actual)
 }
+
+func TestIdentifyFormats(t *testing.T) {
+	t.Parallel()
+
+	kIdentifier, err := New(WithSlogger(multislogger.NewNopLogger()))
+	require.NoError(t, err)
+
+	var tests = []struct {
+		path     string
+		expected *KeyInfo
+	}{
+		{
+			path: "ed25519-bcrypt-32",
+			expected: &KeyInfo{
+				Type:              "ssh-ed25519",
+				Format:            "openssh-new",
+				Encryption:        "aes256-ctr-bcrypt",
+				Encrypted:         boolPtr(true),
+				Comment:           "",
+				Parser:            "ParseOpenSSHPrivateKey",
+				FingerprintSHA256: "HESZYRV/YEvl6RRGHGwL+8HDgoUFjtP+jpAs5YESHCA",
+				FingerprintMD5:    "0c:da:7c:91:21:25:6d:eb:3a:bf:52:11:38:da:dc:13",
+				Cipher:            "aes256-ctr",
+				Kdf:               "bcrypt",
+				KdfRounds:         32,
+			},
+		},
+		{
+			path: "sk-ed25519",
+			expected: &KeyInfo{
+				Type:              "sk-ssh-ed25519@openssh.com",
+				Format:            "openssh-new",
+				Encrypted:         boolPtr(false),
+				Parser:            "ParseOpenSSHPrivateKey",
+				FingerprintSHA256: "j3TBVNnnctFV9FmUVNEN8E2cfZN+dksT7gBqCIwfRfU",
+				FingerprintMD5:    "ac:4e:6f:00:4c:9f:7b:9c:0b:d2:b8:0d:99:10:b7:57",
+				HardwareBacked:    true,
+			},
+		},
+		{
+			path: "ed25519-user-cert.pub",
+			expected: &KeyInfo{
+				Type:              "ssh-ed25519",
+				Format:            "openssh-cert",
+				Comment:           "user key",
+				Parser:            "ParseOpenSSHCertificate",
+				FingerprintSHA256: "HESZYRV/YEvl6RRGHGwL+8HDgoUFjtP+jpAs5YESHCA",
+				FingerprintMD5:    "0c:da:7c:91:21:25:6d:eb:3a:bf:52:11:38:da:dc:13",
+				Certificate: &CertificateInfo{
+					CertType:                   "user",
+					KeyId:                      "alice@example.com",
+					Serial:                     42,
+					Principals:                 []string{"alice", "root"},
+					ValidAfter:                 time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					ValidBefore:                time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+					SigningCAType:              "ssh-ed25519",
+					SigningCAFingerprintSHA256: "oBV1cxmkNODGRC9MT6K/IWyO2NPLqWhCA/V/zTZnGCs",
+				},
+			},
+		},
+		{
+			path: "pkcs8-ed25519.pem",
+			expected: &KeyInfo{
+				Type:      "ed25519",
+				Format:    "pkcs8",
+				Bits:      256,
+				Encrypted: boolPtr(false),
+				Parser:    "attemptPem",
+			},
+		},
+		{
+			path: "pkcs8-pbkdf2-aes256.pem",
+			expected: &KeyInfo{
+				Format:     "pkcs8",
+				Encryption: "aes256-cbc-pbkdf2",
+				Encrypted:  boolPtr(true),
+				Parser:     "ParsePKCS8EncryptedPrivateKey",
+				Cipher:     "aes256-cbc",
+				Kdf:        "pbkdf2",
+				KdfRounds:  100000,
+			},
+		},
+		{
+			path: "pkcs8-scrypt.pem",
+			expected: &KeyInfo{
+				Format:     "pkcs8",
+				Encryption: "aes256-cbc-scrypt",
+				Encrypted:  boolPtr(true),
+				Parser:     "ParsePKCS8EncryptedPrivateKey",
+				Cipher:     "aes256-cbc",
+				Kdf:        "scrypt",
+				KdfRounds:  16384,
+			},
+		},
+		{
+			path: "pkcs8-pbes1-3des.pem",
+			expected: &KeyInfo{
+				Format:     "pkcs8",
+				Encryption: "pbeWithSHAAnd3-KeyTripleDES-CBC",
+				Encrypted:  boolPtr(true),
+				Parser:     "ParsePKCS8EncryptedPrivateKey",
+				Cipher:     "pbeWithSHAAnd3-KeyTripleDES-CBC",
+				Kdf:        "pbes1",
+				KdfRounds:  2048,
+			},
+		},
+		{
+			path: "pkcs8-truncated.pem",
+			expected: &KeyInfo{
+				Format:    "pkcs8",
+				Encrypted: boolPtr(true),
+				Parser:    "attemptPem",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := kIdentifier.IdentifyFile(filepath.Join("testdata", "formats", tt.path))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestIdentifyHostCertificate(t *testing.T) {
+	t.Parallel()
+
+	kIdentifier, err := New(WithSlogger(multislogger.NewNopLogger()))
+	require.NoError(t, err)
+
+	actual, err := kIdentifier.IdentifyFile(filepath.Join("testdata", "formats", "ecdsa-host-cert.pub"))
+	require.NoError(t, err)
+
+	require.Equal(t, "ecdsa-sha2-nistp256", actual.Type)
+	require.False(t, actual.HardwareBacked)
+	require.Nil(t, actual.Encrypted)
+	require.NotNil(t, actual.Certificate)
+	require.Equal(t, "host", actual.Certificate.CertType)
+	require.Empty(t, actual.Certificate.Principals)
+	require.True(t, actual.Certificate.ValidAfter.IsZero())
+	require.True(t, actual.Certificate.ValidBefore.IsZero())
+}
@@ -42,13 +42,28 @@ func ParseOpenSSHPrivateKey(keyBytes []byte) (*KeyInfo, error) {
 	if w.KdfName != "none" || w.CipherName != "none" {
 		ki.Encrypted = boolPtr(true)
 		ki.Encryption = fmt.Sprintf("%s-%s", w.CipherName, w.KdfName)
+		ki.Cipher = w.CipherName
+		ki.Kdf = w.KdfName
 	} else {
 		ki.Encrypted = boolPtr(false)
 	}
 
+	// bcrypt is the only KDF openssh supports. Its options are the
+	// salt, followed by the number of rounds.
+	if w.KdfName == "bcrypt" {
+		var kdfOpts struct {
+			Salt   string
+			Rounds uint32
+		}
+		if err := ssh.Unmarshal([]byte(w.KdfOpts), &kdfOpts); err == nil {
+			ki.KdfRounds = int(kdfOpts.Rounds)
+		}
+	}
+
 	// If we can parse the public key. extract info
 	if pubKey, err := ssh.ParsePublicKey(w.PubKey); err == nil {
 		ki.Type = pubKey.Type()
+		ki.HardwareBacked = isSecurityKeyType(ki.Type)
 		ki.FingerprintSHA256 = strings.TrimPrefix(ssh.FingerprintSHA256(pubKey), "SHA256:")
 		ki.FingerprintMD5 = strings.TrimPrefix(ssh.FingerprintLegacyMD5(pubKey), "MD5:")
 		// We ought be able to get the size of the key, but I don't see
@@ -0,0 +1,121 @@
+package keyidentifier
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Object identifiers for the PKCS#5 and PKCS#12 password based
+// encryption schemes. See RFC 8018 and RFC 7292.
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+
+	pbes1Ciphers = map[string]string{
+		"1.2.840.113549.1.5.3":    "pbeWithMD5AndDES-CBC",
+		"1.2.840.113549.1.5.10":   "pbeWithSHA1AndDES-CBC",
+		"1.2.840.113549.1.12.1.3": "pbeWithSHAAnd3-KeyTripleDES-CBC",
+		"1.2.840.113549.1.12.1.6": "pbeWithSHAAnd40BitRC2-CBC",
+	}
+
+	pbes2Ciphers = map[string]string{
+		"1.2.840.113549.3.7":      "des-ede3-cbc",
+		"2.16.840.1.101.3.4.1.2":  "aes128-cbc",
+		"2.16.840.1.101.3.4.1.22": "aes192-cbc",
+		"2.16.840.1.101.3.4.1.42": "aes256-cbc",
+	}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes1Params struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+}
+
+type scryptParams struct {
+	Salt            []byte
+	CostParameter   int
+	BlockSize       int
+	Parallelization int
+}
+
+// ParsePKCS8EncryptedPrivateKey returns key information from the DER
+// bytes of an `ENCRYPTED PRIVATE KEY` pem block. The key itself is
+// encrypted, so we can only report on the encryption parameters.
+func ParsePKCS8EncryptedPrivateKey(der []byte) (*KeyInfo, error) {
+	var epki encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &epki); err != nil {
+		return nil, fmt.Errorf("asn1 unmarshal: %w", err)
+	}
+
+	ki := &KeyInfo{
+		Format:    "pkcs8",
+		Parser:    "ParsePKCS8EncryptedPrivateKey",
+		Encrypted: boolPtr(true),
+	}
+
+	if cipher, ok := pbes1Ciphers[epki.Algo.Algorithm.String()]; ok {
+		var params pbes1Params
+		if _, err := asn1.Unmarshal(epki.Algo.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("asn1 unmarshal pbes1 params: %w", err)
+		}
+		ki.Cipher = cipher
+		ki.Kdf = "pbes1"
+		ki.KdfRounds = params.Iterations
+		ki.Encryption = cipher
+		return ki, nil
+	}
+
+	if !epki.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unknown encryption algorithm %s", epki.Algo.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(epki.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("asn1 unmarshal pbes2 params: %w", err)
+	}
+
+	ki.Cipher = params.EncryptionScheme.Algorithm.String()
+	if cipher, ok := pbes2Ciphers[ki.Cipher]; ok {
+		ki.Cipher = cipher
+	}
+
+	switch {
+	case params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2):
+		var kdfParams pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+			return nil, fmt.Errorf("asn1 unmarshal pbkdf2 params: %w", err)
+		}
+		ki.Kdf = "pbkdf2"
+		ki.KdfRounds = kdfParams.Iterations
+	case params.KeyDerivationFunc.Algorithm.Equal(oidScrypt):
+		var kdfParams scryptParams
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+			return nil, fmt.Errorf("asn1 unmarshal scrypt params: %w", err)
+		}
+		ki.Kdf = "scrypt"
+		ki.KdfRounds = kdfParams.CostParameter
+	default:
+		ki.Kdf = params.KeyDerivationFunc.Algorithm.String()
+	}
+
+	ki.Encryption = fmt.Sprintf("%s-%s", ki.Cipher, ki.Kdf)
+
+	return ki, nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
 	"github.com/kolide/launcher/pkg/backoff"
 	"github.com/osquery/osquery-go/plugin/distributed"
 	"go.opentelemetry.io/otel/trace"
@@ -138,14 +139,18 @@ func sendClientError(w http.ResponseWriter, span trace.Span, err error) {
 	observability.SetError(span, err)
 }
 
+// queryWithRetries runs the given query, bypassing the table cache -- localserver requests
+// must always see fresh data.
 func queryWithRetries(querier Querier, query string) ([]map[string]string, error) {
 	var results []map[string]string
 	var err error
 
-	backoff.WaitFor(func() error {
-		results, err = querier.Query(query)
-		return err
-	}, 1*time.Second, 250*time.Millisecond)
+	tablewrapper.WithFreshResults(func() {
+		backoff.WaitFor(func() error {
+			results, err = querier.Query(query)
+			return err
+		}, 1*time.Second, 250*time.Millisecond)
+	})
 
 	return results, err
 }
@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kolide/krypto/pkg/echelper"
@@ -44,6 +45,7 @@ type localServer struct {
 	slogger                *slog.Logger
 	knapsack               types.Knapsack
 	srv                    *http.Server
+	srvLock                sync.Mutex
 	identifiers            identifiers
 	hostIdentifier         *atomic.String
 	ecLimiter, dt4aLimiter *rate.Limiter
@@ -53,6 +55,7 @@ type localServer struct {
 	kolideServer           string
 	cancel                 context.CancelFunc
 	interrupted            *atomic.Bool
+	restarting             *atomic.Bool
 
 	myLocalDbSigner crypto.Signer
 	serverEcKey     *ecdsa.PublicKey
@@ -83,6 +86,7 @@ func New(ctx context.Context, k types.Knapsack, presenceDetector presenceDetecto
 		kolideServer:    k.KolideServerURL(),
 		myLocalDbSigner: agent.LocalDbKeys(),
 		interrupted:     &atomic.Bool{},
+		restarting:      &atomic.Bool{},
 	}
 
 	// TODO: As there may be things that adjust the keys during runtime, we need to persist that across
@@ -155,14 +159,22 @@ func New(ctx context.Context, k types.Knapsack, presenceDetector presenceDetecto
 	// curl localhost:40978/id
 	// rootMux.Handle("/id", ls.requestIdHandler())
 
-	srv := &http.Server{
-		Handler: otelhttp.NewHandler(
-			ls.requestLoggingHandler(
-				ls.preflightCorsHandler(
-					rootMux,
-				)), "localserver", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
-				return r.URL.Path
-			})),
+	ls.srv = newHTTPServer(otelhttp.NewHandler(
+		ls.requestLoggingHandler(
+			ls.preflightCorsHandler(
+				rootMux,
+			)), "localserver", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return r.URL.Path
+		})))
+
+	return ls, nil
+}
+
+// newHTTPServer returns a server for the given handler. A server can't be reused once
+// it's shut down, so we create a new one on each restart.
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
 		ReadTimeout:       500 * time.Millisecond,
 		ReadHeaderTimeout: 50 * time.Millisecond,
 		// WriteTimeout very high due to retry logic in the scheduledquery endpoint
@@ -170,10 +182,6 @@ func New(ctx context.Context, k types.Knapsack, presenceDetector presenceDetecto
 		// MaxHeaderBytes size chosen intentionally to allow for tools that add to header
 		MaxHeaderBytes: 8192,
 	}
-
-	ls.srv = srv
-
-	return ls, nil
 }
 
 func (ls *localServer) SetQuerier(querier Querier) {
@@ -319,26 +327,60 @@ func (ls *localServer) Start() error {
 		}
 	})
 
-	l, err := ls.startListener()
-	if err != nil {
-		return fmt.Errorf("starting listener: %w", err)
-	}
+	for {
+		l, err := ls.startListener()
+		if err != nil {
+			return fmt.Errorf("starting listener: %w", err)
+		}
 
-	if len(ls.tlsCerts) > 0 {
-		ls.slogger.Log(ctx, slog.LevelDebug,
-			"using TLS",
-		)
+		if len(ls.tlsCerts) > 0 {
+			ls.slogger.Log(ctx, slog.LevelDebug,
+				"using TLS",
+			)
 
-		tlsConfig := &tls.Config{Certificates: ls.tlsCerts}
+			tlsConfig := &tls.Config{Certificates: ls.tlsCerts}
 
-		l = tls.NewListener(l, tlsConfig)
-	} else {
-		ls.slogger.Log(ctx, slog.LevelDebug,
-			"not using TLS",
+			l = tls.NewListener(l, tlsConfig)
+		} else {
+			ls.slogger.Log(ctx, slog.LevelDebug,
+				"not using TLS",
+			)
+		}
+
+		ls.srvLock.Lock()
+		srv := ls.srv
+		ls.srvLock.Unlock()
+
+		serveErr := srv.Serve(l)
+
+		// If the server was shut down for a restart, rather than an interrupt, serve again on a new server
+		if !errors.Is(serveErr, http.ErrServerClosed) || ls.interrupted.Load() || !ls.restarting.Swap(false) {
+			return serveErr
+		}
+
+		ls.slogger.Log(ctx, slog.LevelInfo,
+			"restarting localserver",
 		)
+
+		ls.srvLock.Lock()
+		ls.srv = newHTTPServer(srv.Handler)
+		ls.srvLock.Unlock()
+	}
+}
+
+// Restart shuts down the http server, so that Start serves again on a new server and listener.
+func (ls *localServer) Restart(ctx context.Context) error {
+	if ls.interrupted.Load() {
+		return errors.New("localserver is shutting down")
+	}
+
+	ls.restarting.Store(true)
+	if err := ls.Stop(); err != nil {
+		ls.restarting.Store(false)
+		return fmt.Errorf("stopping localserver for restart: %w", err)
 	}
 
-	return ls.srv.Serve(l)
+	return nil
 }
 
 func (ls *localServer) Stop() error {
@@ -350,7 +392,11 @@ func (ls *localServer) Stop() error {
 	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
 	defer cancel()
 
-	if err := ls.srv.Shutdown(ctx); err != nil {
+	ls.srvLock.Lock()
+	srv := ls.srv
+	ls.srvLock.Unlock()
+
+	if err := srv.Shutdown(ctx); err != nil {
 		ls.slogger.Log(ctx, slog.LevelError,
 			"shutting down",
 			"err", err,
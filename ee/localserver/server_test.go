@@ -88,3 +88,54 @@ func TestInterrupt_Multiple(t *testing.T) {
 
 	k.AssertExpectations(t)
 }
+
+func TestRestart(t *testing.T) {
+	t.Parallel()
+
+	k := typesmocks.NewKnapsack(t)
+	k.On("KolideServerURL").Return("localserver")
+	var logBytes threadsafebuffer.ThreadSafeBuffer
+	slogger := slog.New(slog.NewTextHandler(&logBytes, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	k.On("Slogger").Return(slogger)
+	k.On("Enrollments").Return([]types.Enrollment{}, nil).Maybe()
+	testConfigStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String())
+	require.NoError(t, err, "could not create test config store")
+	k.On("ConfigStore").Return(testConfigStore).Maybe()
+	tokenStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.TokenStore.String())
+	require.NoError(t, err)
+	k.On("TokenStore").Return(tokenStore)
+	osqPublisher := osquerypublisher.NewLogPublisherClient(slogger, k, http.DefaultClient)
+	k.On("OsqueryPublisher").Return(osqPublisher)
+
+	ls, err := New(t.Context(), k, nil)
+	require.NoError(t, err)
+
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- ls.Start()
+	}()
+	time.Sleep(2 * time.Second)
+
+	// Restarting should not cause Start to return
+	require.NoError(t, ls.Restart(t.Context()))
+	select {
+	case err := <-startErr:
+		t.Fatalf("localserver start returned after restart: %v; logs: \n%s\n", err, logBytes.String())
+	case <-time.After(2 * time.Second):
+	}
+	require.Contains(t, logBytes.String(), "restarting localserver")
+
+	// Interrupting should still cause Start to return
+	ls.Interrupt(errors.New("test error"))
+	select {
+	case err := <-startErr:
+		require.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("localserver start did not return after interrupt; logs: \n%s\n", logBytes.String())
+	}
+
+	// We can't restart once interrupted
+	require.Error(t, ls.Restart(t.Context()))
+}
@@ -18,9 +18,10 @@ import (
 // OsqueryLogAdapater creates an io.Writer implementation useful for attaching
 // to the osquery stdout/stderr
 type OsqueryLogAdapter struct {
-	slogger       *slog.Logger
-	level         slog.Level
-	rootDirectory string
+	slogger        *slog.Logger
+	level          slog.Level
+	rootDirectory  string
+	onWatchdogKill func(msg string)
 }
 
 type Option func(*OsqueryLogAdapter)
@@ -31,10 +32,19 @@ func WithLevel(level slog.Level) Option {
 	}
 }
 
+// WithWatchdogKillHandler sets a function to call whenever osquery logs that its watchdog
+// is stopping a worker or extension for exceeding its resource limits.
+func WithWatchdogKillHandler(onWatchdogKill func(msg string)) Option {
+	return func(l *OsqueryLogAdapter) {
+		l.onWatchdogKill = onWatchdogKill
+	}
+}
+
 var (
-	callerRegexp  = regexp.MustCompile(`[\w.]+:\d+]`)
-	pidRegex      = regexp.MustCompile(`Refusing to kill non-osqueryd process (\d+)`)
-	logLevelRegex = regexp.MustCompile(`^[EWI]\d{4}`) // Looks like the log level followed by a two-digit month and two-digit date, e.g. E0801, I0804
+	watchdogKillRegex = regexp.MustCompile(`\(\d+\) stopping: .*limits? exceeded`) // e.g. osqueryd worker (123) stopping: Memory limits exceeded: 300MB
+	callerRegexp      = regexp.MustCompile(`[\w.]+:\d+]`)
+	pidRegex          = regexp.MustCompile(`Refusing to kill non-osqueryd process (\d+)`)
+	logLevelRegex     = regexp.MustCompile(`^[EWI]\d{4}`) // Looks like the log level followed by a two-digit month and two-digit date, e.g. E0801, I0804
 )
 
 func extractOsqueryCaller(msg string) string {
@@ -77,6 +87,10 @@ func (l *OsqueryLogAdapter) Write(p []byte) (int, error) {
 	}
 
 	msg := strings.TrimSpace(string(p))
+	if l.onWatchdogKill != nil && watchdogKillRegex.MatchString(msg) {
+		l.onWatchdogKill(msg)
+	}
+
 	caller := extractOsqueryCaller(msg)
 	level := l.extractLogLevel(msg)
 	l.slogger.Log(context.TODO(), level,
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"testing"
 
+	"github.com/kolide/launcher/pkg/log/multislogger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +106,26 @@ func Test_extractLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestWrite_watchdogKill(t *testing.T) {
+	t.Parallel()
+
+	var watchdogKills []string
+	adapter := NewOsqueryLogAdapter(multislogger.NewNopLogger(), t.TempDir(), WithWatchdogKillHandler(func(msg string) {
+		watchdogKills = append(watchdogKills, msg)
+	}))
+
+	for _, msg := range []string{
+		"I0804 10:12:20.279402 1880748032 config.cpp:1334] Refreshing configuration state",
+		"W0804 10:12:21.279402 1880748032 watcher.cpp:393] osqueryd worker (4242) stopping: Maximum sustainable CPU utilization limit exceeded: 12 sec",
+		"W0804 10:12:22.279402 1880748032 watcher.cpp:393] osqueryd worker (4243) stopping: Memory limits exceeded: 312MB",
+		"I0804 10:12:23.279402 1880748032 watcher.cpp:393] osqueryd worker (4244) stopping: Shutdown requested",
+	} {
+		_, err := adapter.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+
+	require.Len(t, watchdogKills, 2)
+	require.Contains(t, watchdogKills[0], "CPU utilization limit exceeded")
+	require.Contains(t, watchdogKills[1], "Memory limits exceeded")
+}
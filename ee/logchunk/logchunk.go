@@ -0,0 +1,169 @@
+// Package logchunk splits osquery log lines that are too large to publish in a
+// single batch into a series of chunk envelopes, and reassembles them server-side.
+//
+// Each chunk is itself a log line, a JSON object of the form
+//
+//	{"kolide_log_chunk":{"id":"...","index":0,"count":3,"data":"<base64>"}}
+//
+// where id is derived from the SHA256 of the complete log, so that chunks of a
+// log that is re-sent after a partial failure reassemble to the same log.
+package logchunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	envelopePrefix = `{"kolide_log_chunk":`
+
+	// envelopeOverhead is a generous allowance for everything in an envelope
+	// other than the base64 encoded data.
+	envelopeOverhead = 256
+
+	// defaultMaxPending bounds the number of partially received logs a Reassembler holds.
+	defaultMaxPending = 64
+)
+
+// Chunk is one piece of a split log.
+type Chunk struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	Count int    `json:"count"`
+	Data  []byte `json:"data"`
+}
+
+type envelope struct {
+	Chunk *Chunk `json:"kolide_log_chunk"`
+}
+
+// Split splits log into chunk envelopes, each no larger than maxBytes.
+func Split(log string, maxBytes int) ([]string, error) {
+	payloadSize := (maxBytes - envelopeOverhead) * 3 / 4
+	if payloadSize <= 0 {
+		return nil, fmt.Errorf("max chunk size %d is too small", maxBytes)
+	}
+
+	id := logID(log)
+	count := (len(log) + payloadSize - 1) / payloadSize
+
+	chunks := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*payloadSize, len(log))
+		raw, err := json.Marshal(envelope{Chunk: &Chunk{
+			ID:    id,
+			Index: i,
+			Count: count,
+			Data:  []byte(log[i*payloadSize : end]),
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("marshalling chunk: %w", err)
+		}
+		chunks = append(chunks, string(raw))
+	}
+
+	return chunks, nil
+}
+
+// Parse returns the chunk carried by line, if it is a chunk envelope.
+func Parse(line string) (*Chunk, bool) {
+	if !strings.HasPrefix(line, envelopePrefix) {
+		return nil, false
+	}
+
+	var e envelope
+	if err := json.Unmarshal([]byte(line), &e); err != nil || e.Chunk == nil {
+		return nil, false
+	}
+
+	return e.Chunk, true
+}
+
+func logID(log string) string {
+	sum := sha256.Sum256([]byte(log))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Reassembler collects chunks until a log is complete. It is safe for concurrent use.
+type Reassembler struct {
+	mu         sync.Mutex
+	pending    map[string][][]byte
+	order      []string
+	maxPending int
+}
+
+func NewReassembler() *Reassembler {
+	return &Reassembler{
+		pending:    make(map[string][][]byte),
+		maxPending: defaultMaxPending,
+	}
+}
+
+// Add processes a log line. Lines that are not chunks are returned unchanged. Chunks
+// are held until the final piece arrives, at which point the complete log is returned.
+// The bool reports whether a complete log is being returned.
+func (r *Reassembler) Add(line string) (string, bool, error) {
+	chunk, ok := Parse(line)
+	if !ok {
+		return line, true, nil
+	}
+
+	if chunk.Count <= 0 || chunk.Index < 0 || chunk.Index >= chunk.Count {
+		return "", false, fmt.Errorf("invalid chunk %d of %d", chunk.Index, chunk.Count)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parts, ok := r.pending[chunk.ID]
+	if !ok {
+		parts = make([][]byte, chunk.Count)
+		r.track(chunk.ID)
+	}
+	if len(parts) != chunk.Count {
+		return "", false, fmt.Errorf("chunk %s has count %d, expected %d", chunk.ID, chunk.Count, len(parts))
+	}
+	parts[chunk.Index] = chunk.Data
+	r.pending[chunk.ID] = parts
+
+	var sb strings.Builder
+	for _, part := range parts {
+		if part == nil {
+			return "", false, nil
+		}
+		sb.Write(part)
+	}
+
+	r.forget(chunk.ID)
+
+	log := sb.String()
+	if logID(log) != chunk.ID {
+		return "", false, errors.New("reassembled log does not match chunk id")
+	}
+
+	return log, true, nil
+}
+
+// track records a new pending id, evicting the oldest if there are too many.
+func (r *Reassembler) track(id string) {
+	r.order = append(r.order, id)
+	for len(r.order) > r.maxPending {
+		delete(r.pending, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *Reassembler) forget(id string) {
+	delete(r.pending, id)
+	for i, pendingID := range r.order {
+		if pendingID == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
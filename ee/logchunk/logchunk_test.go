@@ -0,0 +1,96 @@
+package logchunk
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAndReassemble(t *testing.T) {
+	t.Parallel()
+
+	log := `{"name":"pack:kolide:big","columns":{"data":"` + strings.Repeat("héllo wörld ", 2000) + `"}}`
+
+	chunks, err := Split(log, 1024)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		require.LessOrEqual(t, len(chunk), 1024)
+	}
+
+	// Deliver out of order, with a duplicate
+	order := rand.Perm(len(chunks))
+	order = append([]int{order[0]}, order...)
+
+	r := NewReassembler()
+	var completed []string
+	for _, i := range order {
+		out, ok, err := r.Add(chunks[i])
+		require.NoError(t, err)
+		if ok {
+			completed = append(completed, out)
+		}
+	}
+
+	require.Equal(t, []string{log}, completed)
+	require.Empty(t, r.pending, "completed logs should not be held")
+}
+
+func TestReassembler_PassesThroughRegularLogs(t *testing.T) {
+	t.Parallel()
+
+	r := NewReassembler()
+	out, ok, err := r.Add(`{"name":"q"}`)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"name":"q"}`, out)
+}
+
+func TestReassembler_EvictsOldest(t *testing.T) {
+	t.Parallel()
+
+	r := NewReassembler()
+	r.maxPending = 2
+
+	var firstChunks [][]string
+	for i := range 3 {
+		chunks, err := Split(fmt.Sprintf("log %d %s", i, strings.Repeat("x", 2000)), 512)
+		require.NoError(t, err)
+		_, ok, err := r.Add(chunks[0])
+		require.NoError(t, err)
+		require.False(t, ok)
+		firstChunks = append(firstChunks, chunks)
+	}
+
+	require.Len(t, r.pending, 2)
+
+	// The first log was evicted, so it can't be completed without resending its first chunk
+	for _, chunk := range firstChunks[0][1:] {
+		_, ok, err := r.Add(chunk)
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+}
+
+func TestReassembler_Invalid(t *testing.T) {
+	t.Parallel()
+
+	r := NewReassembler()
+	_, _, err := r.Add(`{"kolide_log_chunk":{"id":"abc","index":3,"count":2,"data":""}}`)
+	require.Error(t, err)
+
+	// Data that doesn't match the id
+	_, ok, err := r.Add(`{"kolide_log_chunk":{"id":"abc","index":0,"count":1,"data":"aGVsbG8="}}`)
+	require.Error(t, err)
+	require.False(t, ok)
+}
+
+func TestSplit_TooSmall(t *testing.T) {
+	t.Parallel()
+
+	_, err := Split("log", 100)
+	require.Error(t, err)
+}
@@ -13,8 +13,10 @@ const (
 	unitByteGCP = "By" // Unfortunately, "B" isn't recognized by our metrics ingest -- we have to use "By" instead
 
 	// Custom units
-	unitRestart = "{restart}"
-	unitFailure = "{failure}"
+	unitRestart  = "{restart}"
+	unitFailure  = "{failure}"
+	unitLog      = "{log}"
+	unitRollback = "{rollback}"
 
 	// Define our meter names and descriptions. All meter names should have "launcher." prepended.
 	goMemoryUsageGaugeName                       = "launcher.memory.golang"
@@ -49,6 +51,10 @@ const (
 	autoupdateFailureCounterDescription          = "The number of TUF autoupdate failures"
 	checkupErrorCounterName                      = "launcher.checkup.error"
 	checkupErrorCounterDescription               = "The number of errors when running checkups"
+	osqueryLogDroppedCounterName                 = "launcher.osquery.log.dropped"
+	osqueryLogDroppedCounterDescription          = "The number of osquery logs dropped because they were too large to publish"
+	osqueryRollbackCounterName                   = "launcher.osquery.rollback"
+	osqueryRollbackCounterDescription            = "The number of times osqueryd was rolled back to a previous version after a crash loop"
 )
 
 var (
@@ -73,6 +79,8 @@ var (
 	TablewrapperTimeoutCounter        metric.Int64Counter
 	AutoupdateFailureCounter          metric.Int64Counter
 	CheckupErrorCounter               metric.Int64Counter
+	OsqueryLogDroppedCounter          metric.Int64Counter
+	OsqueryRollbackCounter            metric.Int64Counter
 )
 
 // Initialize all of our meters. All meter names should have "launcher." prepended,
@@ -138,6 +146,12 @@ func ReinitializeMetrics() {
 	CheckupErrorCounter = int64CounterOrNoop(checkupErrorCounterName,
 		metric.WithDescription(checkupErrorCounterDescription),
 		metric.WithUnit(unitFailure))
+	OsqueryLogDroppedCounter = int64CounterOrNoop(osqueryLogDroppedCounterName,
+		metric.WithDescription(osqueryLogDroppedCounterDescription),
+		metric.WithUnit(unitLog))
+	OsqueryRollbackCounter = int64CounterOrNoop(osqueryRollbackCounterName,
+		metric.WithDescription(osqueryRollbackCounterDescription),
+		metric.WithUnit(unitRollback))
 }
 
 // int64GaugeOrNoop is guaranteed to return an Int64Gauge -- if we cannot create
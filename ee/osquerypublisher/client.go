@@ -15,6 +15,7 @@ import (
 	"github.com/kolide/kit/contexts/uuid"
 	"github.com/kolide/launcher/ee/agent/storage"
 	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/compression"
 	"github.com/osquery/osquery-go/plugin/distributed"
 	osqlog "github.com/osquery/osquery-go/plugin/logger"
 )
@@ -67,6 +68,8 @@ func NewLogPublisherClient(logger *slog.Logger, k types.Knapsack, client Publish
 func NewPublisherHTTPClient() PublisherHTTPClient {
 	return &http.Client{
 		Timeout: 60 * time.Second,
+		// Compress request bodies once agent-ingester advertises that it accepts compressed requests
+		Transport: compression.NewTransport(http.DefaultTransport, compression.NewNegotiator()),
 	}
 }
 
@@ -175,6 +175,39 @@ func ChildProcessStatsForPid(ctx context.Context, pid int32) ([]*PerformanceStat
 	return stats, nil
 }
 
+// ProcessTreeStats gets memory and CPU stats for the given process and its direct children,
+// e.g. osqueryd and its worker. Unlike the functions above, it does not record any metrics.
+func ProcessTreeStats(ctx context.Context, pid int32) ([]*PerformanceStats, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	proc, err := process.NewProcessWithContext(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("getting process handle for pid %d: %w", pid, err)
+	}
+
+	ps, _, err := statsForProcess(ctx, proc)
+	if err != nil {
+		return nil, fmt.Errorf("gathering stats for process: %w", err)
+	}
+	stats := []*PerformanceStats{ps}
+
+	childProcesses, err := proc.ChildrenWithContext(ctx)
+	// As above, exit status 1 means that there were no child processes
+	if err != nil && !strings.Contains(err.Error(), "exit status 1") {
+		return nil, fmt.Errorf("getting child processes for pid %d: %w", pid, err)
+	}
+	for _, childProcess := range childProcesses {
+		ps, _, err := statsForProcess(ctx, childProcess)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, ps)
+	}
+
+	return stats, nil
+}
+
 func statsForProcess(ctx context.Context, proc *process.Process) (*PerformanceStats, *process.MemoryInfoStat, error) {
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
@@ -0,0 +1,64 @@
+package control_subsystems
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/control"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const tableName = "kolide_control_subsystems"
+
+type Table struct {
+	slogger *slog.Logger
+	store   types.Iterator
+}
+
+func TablePlugin(flags types.Flags, slogger *slog.Logger, store types.Iterator) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("subsystem"),
+		table.TextColumn("hash"),
+		table.BigIntColumn("applied_at"),
+		table.TextColumn("source"),
+		table.TextColumn("last_error"),
+		table.BigIntColumn("last_error_at"),
+	}
+
+	t := &Table{
+		slogger: slogger.With("table", tableName),
+		store:   store,
+	}
+
+	return tablewrapper.New(flags, slogger, tableName, columns, t.generate)
+}
+
+func (t *Table) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx, "table_name", tableName)
+	defer span.End()
+
+	entries, err := control.ReadSubsystemCache(t.store)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelInfo, "failure reading subsystem cache", "err", err)
+		return nil, fmt.Errorf("reading subsystem cache: %w", err)
+	}
+
+	results := make([]map[string]string, 0, len(entries))
+	for subsystem, entry := range entries {
+		results = append(results, map[string]string{
+			"subsystem":     subsystem,
+			"hash":          entry.Hash,
+			"applied_at":    tablehelpers.UnixOrEmpty(entry.AppliedAt),
+			"source":        entry.Source,
+			"last_error":    entry.LastError,
+			"last_error_at": tablehelpers.UnixOrEmpty(entry.LastErrorAt),
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,194 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+var (
+	ErrUnsupportedKeyType = errors.New("unsupported JWK key type")
+	ErrUnsupportedCurve   = errors.New("unsupported JWK curve")
+)
+
+// JSONWebKey is the subset of RFC 7517 that we need to verify signatures.
+type JSONWebKey struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid"`
+	Alg string   `json:"alg,omitempty"`
+	Use string   `json:"use,omitempty"`
+	Crv string   `json:"crv,omitempty"`
+	N   string   `json:"n,omitempty"`
+	E   string   `json:"e,omitempty"`
+	X   string   `json:"x,omitempty"`
+	Y   string   `json:"y,omitempty"`
+	X5c []string `json:"x5c,omitempty"`
+}
+
+// JSONWebKeySet is an RFC 7517 JWK Set.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Lookup returns the key with the given key id.
+func (s *JSONWebKeySet) Lookup(kid string) (*JSONWebKey, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	for i := range s.Keys {
+		if s.Keys[i].Kid == kid {
+			return &s.Keys[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// PublicKey decodes the JWK into a crypto PublicKey. If the key only carries
+// a certificate chain, the public key of the leaf certificate is used.
+func (k *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurve, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding EC x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding EC y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurve, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding Ed25519 key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 key has wrong length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	if len(k.X5c) > 0 {
+		chain, err := parseX5C(k.X5c)
+		if err != nil {
+			return nil, err
+		}
+		return chain[0].PublicKey, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, k.Kty)
+}
+
+// decodeBigInt decodes the base64url encoded, unsigned big-endian integers used in JWKs.
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// parseSigningKeys interprets the signing_keys column. It may be a JSON
+// map of key id to PEM, a JWKS document, or the path to a JWKS document.
+// At most one of the returned values is set.
+func parseSigningKeys(signingKeys string) (map[string]string, *JSONWebKeySet, error) {
+	signingKeys = strings.TrimSpace(signingKeys)
+	if signingKeys == "" || signingKeys == "null" {
+		return nil, nil, nil
+	}
+
+	raw := []byte(signingKeys)
+	if !strings.HasPrefix(signingKeys, "{") {
+		fileData, err := os.ReadFile(signingKeys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading JWKS file: %w", err)
+		}
+		raw = fileData
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("unmarshaling signing keys: %w", err)
+	}
+
+	if keys, ok := doc["keys"]; ok && strings.HasPrefix(strings.TrimSpace(string(keys)), "[") {
+		var jwks JSONWebKeySet
+		if err := json.Unmarshal(raw, &jwks); err != nil {
+			return nil, nil, fmt.Errorf("unmarshaling JWKS: %w", err)
+		}
+		return nil, &jwks, nil
+	}
+
+	var keyMap map[string]string
+	if err := json.Unmarshal(raw, &keyMap); err != nil {
+		return nil, nil, fmt.Errorf("unmarshaling signing key map: %w", err)
+	}
+
+	return keyMap, nil, nil
+}
+
+// parseX5C decodes an x5c certificate chain. Unlike most JWT fields, x5c
+// entries are standard (not url) base64. The leaf certificate is first.
+func parseX5C(x5c []string) ([]*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("empty x5c chain")
+	}
+
+	chain := make([]*x509.Certificate, 0, len(x5c))
+	for _, encoded := range x5c {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x5c certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing x5c certificate: %w", err)
+		}
+		chain = append(chain, cert)
+	}
+
+	return chain, nil
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"crypto"
 	"crypto/x509"
-	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"log/slog"
 	"maps"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/kolide/launcher/ee/agent/types"
@@ -31,6 +32,18 @@ const (
 	Invalid = "invalid"
 )
 
+// States for the alg_status, exp_status, nbf_status, and x5c_status values. These
+// are reported independently of `verified`.
+const (
+	StatusValid       = "valid"
+	StatusInvalid     = "invalid"
+	StatusMissing     = "missing"
+	StatusExpired     = "expired"
+	StatusNotYetValid = "not_yet_valid"
+	StatusMismatch    = "mismatch"
+	StatusUntrusted   = "untrusted"
+)
+
 // Values for include_raw_jwt column.
 var (
 	allowedIncludeValues = []string{"true", "1"}
@@ -42,6 +55,8 @@ var (
 	ErrMatchingKeyId    = errors.New("no key id matched the JWT header key id")
 	ErrParsingPemBlock  = errors.New("error parsing PEM block containing the public key")
 	ErrParsingPublicKey = errors.New("error parsing the public key from the PEM block")
+	ErrAlgMismatch      = errors.New("JWT header alg does not match the signing key alg")
+	ErrX5CChain         = errors.New("x5c certificate chain did not verify against the CA bundle")
 )
 
 type Table struct {
@@ -52,6 +67,7 @@ func TablePlugin(flags types.Flags, slogger *slog.Logger) *table.Plugin {
 	columns := dataflattentable.Columns(
 		table.TextColumn("path"),
 		table.TextColumn("signing_keys"),
+		table.TextColumn("ca_bundle"),
 		table.TextColumn("include_raw_jwt"),
 	)
 
@@ -75,72 +91,127 @@ func (t *Table) generate(ctx context.Context, queryContext table.QueryContext) (
 
 	for _, path := range paths {
 		for _, keyJSON := range tablehelpers.GetConstraints(queryContext, "signing_keys", tablehelpers.WithDefaults("")) {
-			for _, includeRawJWT := range tablehelpers.GetConstraints(queryContext, "include_raw_jwt", tablehelpers.WithAllowedValues(allowedIncludeValues), tablehelpers.WithDefaults("false")) {
-				for _, dataQuery := range tablehelpers.GetConstraints(queryContext, "query", tablehelpers.WithDefaults("*")) {
-					rawData, err := os.ReadFile(path)
-					if len(rawData) == 0 || err != nil {
-						t.slogger.Log(ctx, slog.LevelInfo, "error reading JWT data file", "err", err)
-						continue
-					}
+			for _, caBundle := range tablehelpers.GetConstraints(queryContext, "ca_bundle", tablehelpers.WithDefaults("")) {
+				for _, includeRawJWT := range tablehelpers.GetConstraints(queryContext, "include_raw_jwt", tablehelpers.WithAllowedValues(allowedIncludeValues), tablehelpers.WithDefaults("false")) {
+					for _, dataQuery := range tablehelpers.GetConstraints(queryContext, "query", tablehelpers.WithDefaults("*")) {
+						rawData, err := os.ReadFile(path)
+						if len(rawData) == 0 || err != nil {
+							t.slogger.Log(ctx, slog.LevelInfo, "error reading JWT data file", "err", err)
+							continue
+						}
 
-					// Parse provided JWT signing keys into an map for verification in the JWT parser
-					var keyMap map[string]string
-					if err := json.Unmarshal([]byte(keyJSON), &keyMap); err != nil {
-						t.slogger.Log(ctx, slog.LevelInfo, "error unmarshaling JWT signing keys", "err", err)
-					}
+						data, err := t.parseToken(ctx, strings.TrimSpace(string(rawData)), keyJSON, caBundle)
+						if err != nil {
+							t.slogger.Log(ctx, slog.LevelInfo, "error parsing JWT", "err", err)
+							continue
+						}
 
-					data := map[string]any{}
-					token, err := jwt.ParseWithClaims(string(rawData), jwt.MapClaims{}, JWTKeyFunc(keyMap))
-					if err != nil {
-						t.slogger.Log(ctx, slog.LevelInfo, "error parsing token", "err", err)
+						if includeRawJWT != "false" {
+							data["raw_jwt"] = string(rawData)
+						}
 
-						if errors.Is(err, ErrParsingPemBlock) || errors.Is(err, ErrParsingPublicKey) {
-							data["verified"] = Invalid
+						flattenOpts := []dataflatten.FlattenOpts{
+							dataflatten.WithSlogger(t.slogger),
+							dataflatten.WithQuery(strings.Split(dataQuery, "/")),
+						}
+
+						flattened, err := dataflatten.Flatten(data, flattenOpts...)
+						if err != nil {
+							t.slogger.Log(ctx, slog.LevelInfo, "failure flattening JWT data", "err", err)
+							continue
 						}
-					} else {
-						data["verified"] = Valid
-					}
 
-					claims, ok := token.Claims.(jwt.MapClaims)
-					if !ok {
-						t.slogger.Log(ctx, slog.LevelInfo, "error parsing JWT claims")
-						continue
+						rowData := map[string]string{
+							"path":            path,
+							"signing_keys":    keyJSON,
+							"ca_bundle":       caBundle,
+							"include_raw_jwt": includeRawJWT,
+						}
+
+						results = append(results, dataflattentable.ToMap(flattened, dataQuery, rowData)...)
 					}
+				}
+			}
+		}
+	}
 
-					parsedClaims := map[string]any{}
-					maps.Copy(parsedClaims, claims)
+	return results, nil
+}
 
-					data["header"] = token.Header
-					data["claims"] = parsedClaims
+// parseToken parses a single JWT, and returns the data to be flattened into
+// rows. Signature verification, algorithm, and time validity are each
+// reported separately, so that an expired but correctly signed token is
+// distinguishable from a forged one. Encrypted (JWE) tokens can't be
+// inspected without the decryption key, so only their header is reported.
+func (t *Table) parseToken(ctx context.Context, rawToken string, keyJSON string, caBundle string) (map[string]any, error) {
+	if header, ok := parseJWEHeader(rawToken); ok {
+		return map[string]any{
+			"encrypted": true,
+			"header":    header,
+		}, nil
+	}
 
-					if includeRawJWT != "false" {
-						data["raw_jwt"] = string(rawData)
-					}
+	v := &verifier{}
 
-					flattenOpts := []dataflatten.FlattenOpts{
-						dataflatten.WithSlogger(t.slogger),
-						dataflatten.WithQuery(strings.Split(dataQuery, "/")),
-					}
+	// Parse provided JWT signing keys for verification in the JWT parser
+	keyMap, jwks, err := parseSigningKeys(keyJSON)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelInfo, "error parsing JWT signing keys", "err", err)
+	}
+	v.keyMap = keyMap
+	v.jwks = jwks
 
-					flattened, err := dataflatten.Flatten(data, flattenOpts...)
-					if err != nil {
-						t.slogger.Log(ctx, slog.LevelInfo, "failure flattening JWT data", "err", err)
-						continue
-					}
+	if caBundle != "" {
+		roots, err := loadCABundle(caBundle)
+		if err != nil {
+			t.slogger.Log(ctx, slog.LevelInfo, "error loading CA bundle", "err", err)
+		}
+		v.roots = roots
+	}
 
-					rowData := map[string]string{
-						"path":            path,
-						"signing_keys":    keyJSON,
-						"include_raw_jwt": includeRawJWT,
-					}
+	data := map[string]any{
+		"encrypted": false,
+	}
 
-					results = append(results, dataflattentable.ToMap(flattened, dataQuery, rowData)...)
-				}
-			}
+	// Claims are validated below, so that they can be reported independently
+	// of the signature.
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(rawToken, jwt.MapClaims{}, v.keyFunc)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelInfo, "error parsing token", "err", err)
+
+		if errors.Is(err, ErrParsingPemBlock) || errors.Is(err, ErrParsingPublicKey) ||
+			errors.Is(err, ErrAlgMismatch) || errors.Is(err, ErrX5CChain) ||
+			errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			data["verified"] = Invalid
 		}
+	} else {
+		data["verified"] = Valid
 	}
 
-	return results, nil
+	if token == nil {
+		return nil, fmt.Errorf("token could not be parsed: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("error parsing JWT claims")
+	}
+
+	parsedClaims := map[string]any{}
+	maps.Copy(parsedClaims, claims)
+
+	data["header"] = token.Header
+	data["claims"] = parsedClaims
+	data["alg_status"] = v.algStatus(token)
+	data["exp_status"] = expStatus(claims, time.Now())
+	data["nbf_status"] = nbfStatus(claims, time.Now())
+
+	if v.x5cStatus != "" {
+		data["x5c_status"] = v.x5cStatus
+	}
+
+	return data, nil
 }
 
 // JWTKeyFunc handles taking in an array of public keys to validate against the JWT signature.
@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verifier finds the key for a token, and records how it was found, so that
+// can be reported alongside the signature verification result.
+type verifier struct {
+	keyMap    map[string]string
+	jwks      *JSONWebKeySet
+	roots     *x509.CertPool
+	keyAlg    string
+	x5cStatus string
+}
+
+// keyFunc is a jwt.Keyfunc. An x5c certificate chain in the token header is
+// only trusted when it verifies against the supplied CA bundle; otherwise we
+// fall back to looking up the key id in the supplied signing keys.
+func (v *verifier) keyFunc(token *jwt.Token) (any, error) {
+	if rawX5C, ok := token.Header["x5c"]; ok {
+		if v.roots == nil {
+			v.x5cStatus = StatusUntrusted
+		} else {
+			chain, err := headerX5C(rawX5C)
+			if err != nil {
+				v.x5cStatus = StatusInvalid
+				return nil, fmt.Errorf("%w: %w", ErrX5CChain, err)
+			}
+
+			if err := verifyChain(chain, v.roots); err != nil {
+				v.x5cStatus = StatusInvalid
+				return nil, fmt.Errorf("%w: %w", ErrX5CChain, err)
+			}
+
+			v.x5cStatus = StatusValid
+			return chain[0].PublicKey, nil
+		}
+	}
+
+	if v.jwks == nil {
+		return JWTKeyFunc(v.keyMap)(token)
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, ErrMissingKeyId
+	}
+
+	jwk, ok := v.jwks.Lookup(kid)
+	if !ok {
+		return nil, ErrMatchingKeyId
+	}
+
+	v.keyAlg = jwk.Alg
+	if jwk.Alg != "" && jwk.Alg != token.Method.Alg() {
+		return nil, ErrAlgMismatch
+	}
+
+	pubKey, err := jwk.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParsingPublicKey, err)
+	}
+
+	return pubKey, nil
+}
+
+// algStatus reports whether the token's alg is one we'd accept. `none`, and
+// algorithms we don't know, are invalid. So is an alg that disagrees with the
+// one declared on the JWK that matched.
+func (v *verifier) algStatus(token *jwt.Token) string {
+	alg, ok := token.Header["alg"].(string)
+	switch {
+	case !ok || alg == "":
+		return StatusMissing
+	case alg == "none" || jwt.GetSigningMethod(alg) == nil:
+		return StatusInvalid
+	case v.keyAlg != "" && v.keyAlg != alg:
+		return StatusMismatch
+	}
+
+	return StatusValid
+}
+
+func expStatus(claims jwt.MapClaims, now time.Time) string {
+	exp, err := claims.GetExpirationTime()
+	switch {
+	case err != nil:
+		return StatusInvalid
+	case exp == nil:
+		return StatusMissing
+	case now.After(exp.Time):
+		return StatusExpired
+	}
+
+	return StatusValid
+}
+
+func nbfStatus(claims jwt.MapClaims, now time.Time) string {
+	nbf, err := claims.GetNotBefore()
+	switch {
+	case err != nil:
+		return StatusInvalid
+	case nbf == nil:
+		return StatusMissing
+	case now.Before(nbf.Time):
+		return StatusNotYetValid
+	}
+
+	return StatusValid
+}
+
+// headerX5C converts the x5c header, as decoded from JSON, into a certificate chain.
+func headerX5C(rawX5C any) ([]*x509.Certificate, error) {
+	entries, ok := rawX5C.([]any)
+	if !ok {
+		return nil, errors.New("x5c header is not an array")
+	}
+
+	x5c := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		s, ok := entry.(string)
+		if !ok {
+			return nil, errors.New("x5c header contains a non-string entry")
+		}
+		x5c = append(x5c, s)
+	}
+
+	return parseX5C(x5c)
+}
+
+// verifyChain checks that the leaf certificate chains up to one of roots,
+// using the remaining certificates as intermediates.
+func verifyChain(chain []*x509.Certificate, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+
+	return err
+}
+
+// loadCABundle reads a file of PEM encoded CA certificates.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("no certificates found in CA bundle")
+	}
+
+	return roots, nil
+}
+
+// parseJWEHeader returns the header of an encrypted JWE token, in either the
+// compact serialization (five segments) or the JSON serialization. The second
+// return value is false if the token is not a JWE.
+func parseJWEHeader(rawToken string) (map[string]any, bool) {
+	if strings.HasPrefix(rawToken, "{") {
+		var jweJSON struct {
+			Protected   string         `json:"protected"`
+			Unprotected map[string]any `json:"unprotected"`
+			Ciphertext  string         `json:"ciphertext"`
+		}
+		if err := json.Unmarshal([]byte(rawToken), &jweJSON); err != nil || jweJSON.Ciphertext == "" {
+			return nil, false
+		}
+
+		header := decodeSegmentHeader(jweJSON.Protected)
+		if header == nil {
+			header = map[string]any{}
+		}
+		for k, v := range jweJSON.Unprotected {
+			header[k] = v
+		}
+		return header, true
+	}
+
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 5 {
+		return nil, false
+	}
+
+	header := decodeSegmentHeader(parts[0])
+	if _, ok := header["enc"]; !ok {
+		return nil, false
+	}
+
+	return header, true
+}
+
+func decodeSegmentHeader(segment string) map[string]any {
+	headerBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil
+	}
+
+	var header map[string]any
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil
+	}
+
+	return header
+}
@@ -0,0 +1,42 @@
+package language_packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collectCargo reads the crates installed by `cargo install` from the
+// .crates2.json tracking file in a cargo home directory.
+func collectCargo(cargoHome string) ([]packageInfo, error) {
+	raw, err := os.ReadFile(filepath.Join(cargoHome, ".crates2.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading .crates2.json: %w", err)
+	}
+
+	var crates2 struct {
+		Installs map[string]json.RawMessage `json:"installs"`
+	}
+	if err := json.Unmarshal(raw, &crates2); err != nil {
+		return nil, fmt.Errorf("unmarshalling .crates2.json: %w", err)
+	}
+
+	var pkgs []packageInfo
+	for packageId := range crates2.Installs {
+		// Keys are cargo package ids: `<name> <version> (<source>)`
+		fields := strings.Fields(packageId)
+		if len(fields) < 2 {
+			continue
+		}
+
+		pkgs = append(pkgs, packageInfo{
+			name:    fields[0],
+			version: fields[1],
+			path:    filepath.Join(cargoHome, "bin"),
+		})
+	}
+
+	return pkgs, nil
+}
@@ -0,0 +1,42 @@
+package language_packages
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gemspecFilename matches the `<name>-<version>[-<platform>].gemspec` files that
+// RubyGems writes into its specifications directory. Versions always start with
+// a digit, which lets us split names that themselves contain dashes.
+var gemspecFilename = regexp.MustCompile(`^(.+?)-(\d[^-]*)(?:-(.+))?\.gemspec$`)
+
+// collectGems reads gem names and versions from a specifications directory.
+// The gemspec files are ruby code, so rather than evaluate them, we rely on
+// the filename, which RubyGems derives from the spec's full name.
+func collectGems(specificationsDir string) ([]packageInfo, error) {
+	gemspecPaths, err := filepath.Glob(filepath.Join(specificationsDir, "*.gemspec"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for gemspecs: %w", err)
+	}
+
+	// The gems themselves live in a sibling directory of specifications
+	gemsDir := filepath.Join(filepath.Dir(specificationsDir), "gems")
+
+	var pkgs []packageInfo
+	for _, gemspecPath := range gemspecPaths {
+		match := gemspecFilename.FindStringSubmatch(filepath.Base(gemspecPath))
+		if match == nil {
+			continue
+		}
+
+		pkgs = append(pkgs, packageInfo{
+			name:    match[1],
+			version: match[2],
+			path:    filepath.Join(gemsDir, strings.TrimSuffix(filepath.Base(gemspecPath), ".gemspec")),
+		})
+	}
+
+	return pkgs, nil
+}
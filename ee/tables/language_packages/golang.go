@@ -0,0 +1,40 @@
+package language_packages
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// collectGoBinaries reads the build info embedded in each Go binary in a
+// GOBIN directory. This is the same data `go version -m` reports, read
+// directly from the binary rather than by executing the go tool.
+func collectGoBinaries(binDir string) ([]packageInfo, error) {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading bin dir: %w", err)
+	}
+
+	var pkgs []packageInfo
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		binPath := filepath.Join(binDir, entry.Name())
+		info, err := buildinfo.ReadFile(binPath)
+		if err != nil {
+			// Not a Go binary
+			continue
+		}
+
+		pkgs = append(pkgs, packageInfo{
+			name:    info.Path,
+			version: info.Main.Version,
+			path:    binPath,
+		})
+	}
+
+	return pkgs, nil
+}
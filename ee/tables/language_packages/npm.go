@@ -0,0 +1,46 @@
+package language_packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// collectNpm reads the package.json of each package in a global
+// node_modules directory, including scoped (@scope/name) packages.
+func collectNpm(nodeModulesDir string) ([]packageInfo, error) {
+	packageJsonPaths, err := filepath.Glob(filepath.Join(nodeModulesDir, "*", "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for package.json: %w", err)
+	}
+
+	scopedPackageJsonPaths, err := filepath.Glob(filepath.Join(nodeModulesDir, "@*", "*", "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for scoped package.json: %w", err)
+	}
+
+	var pkgs []packageInfo
+	for _, packageJsonPath := range append(packageJsonPaths, scopedPackageJsonPaths...) {
+		raw, err := os.ReadFile(packageJsonPath)
+		if err != nil {
+			continue
+		}
+
+		var packageJson struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(raw, &packageJson); err != nil || packageJson.Name == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, packageInfo{
+			name:    packageJson.Name,
+			version: packageJson.Version,
+			path:    filepath.Dir(packageJsonPath),
+		})
+	}
+
+	return pkgs, nil
+}
@@ -0,0 +1,77 @@
+package language_packages
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collectPython reads the METADATA (wheel installs) or PKG-INFO (egg installs)
+// of each distribution in a site-packages directory.
+func collectPython(sitePackagesDir string) ([]packageInfo, error) {
+	metadataPaths, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.dist-info", "METADATA"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for dist-info: %w", err)
+	}
+
+	eggInfoPaths, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.egg-info", "PKG-INFO"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for egg-info: %w", err)
+	}
+
+	var pkgs []packageInfo
+	for _, metadataPath := range append(metadataPaths, eggInfoPaths...) {
+		name, version, err := readPythonMetadata(metadataPath)
+		if err != nil || name == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, packageInfo{
+			name:    name,
+			version: version,
+			path:    filepath.Dir(metadataPath),
+		})
+	}
+
+	return pkgs, nil
+}
+
+// readPythonMetadata pulls the name and version out of the RFC 822 style
+// core metadata headers. The headers end at the first blank line, after which
+// comes the long description, so we stop there.
+func readPythonMetadata(metadataPath string) (string, string, error) {
+	f, err := os.Open(metadataPath)
+	if err != nil {
+		return "", "", fmt.Errorf("opening metadata: %w", err)
+	}
+	defer f.Close()
+
+	var name, version string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "Name":
+			name = strings.TrimSpace(value)
+		case "Version":
+			version = strings.TrimSpace(value)
+		}
+
+		if name != "" && version != "" {
+			break
+		}
+	}
+
+	return name, version, scanner.Err()
+}
@@ -0,0 +1,202 @@
+package language_packages
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const tableName = "kolide_language_packages"
+
+// packageInfo is a single installed package, as found on disk.
+type packageInfo struct {
+	name    string
+	version string
+	path    string
+}
+
+// ecosystem describes where a language package manager keeps globally
+// installed packages, and how to read them. None of the package managers
+// are executed -- everything comes from on-disk metadata.
+type ecosystem struct {
+	name string
+	// userDirs are globs, relative to each user's home directory, matching
+	// the directories that collect reads. These are the package managers'
+	// default locations only: launcher can't see the environment of each
+	// user's shell, so installs relocated with variables such as GOBIN,
+	// GOPATH, or CARGO_HOME are not found.
+	userDirs []string
+	// systemDirs are absolute globs for system-wide installs. These are
+	// reported without a user.
+	systemDirs []string
+	collect    func(dir string) ([]packageInfo, error)
+}
+
+var ecosystems = []ecosystem{
+	{
+		name: "npm",
+		userDirs: []string{
+			".npm-global/lib/node_modules",
+			".nvm/versions/node/*/lib/node_modules",
+			".local/share/fnm/node-versions/*/installation/lib/node_modules",
+			".fnm/node-versions/*/installation/lib/node_modules",
+			"Library/Application Support/fnm/node-versions/*/installation/lib/node_modules",
+			"AppData/Roaming/npm/node_modules",
+		},
+		systemDirs: []string{
+			"/usr/lib/node_modules",
+			"/usr/local/lib/node_modules",
+			"/opt/homebrew/lib/node_modules",
+		},
+		collect: collectNpm,
+	},
+	{
+		name: "pip",
+		userDirs: []string{
+			".local/lib/python*/site-packages",
+			".local/pipx/venvs/*/lib/python*/site-packages",
+			".local/share/pipx/venvs/*/lib/python*/site-packages",
+			"Library/Python/*/lib/python/site-packages",
+			"AppData/Roaming/Python/Python*/site-packages",
+		},
+		systemDirs: []string{
+			"/usr/lib/python3*/site-packages",
+			"/usr/lib/python3/dist-packages",
+			"/usr/local/lib/python3*/site-packages",
+			"/usr/local/lib/python3*/dist-packages",
+			"/opt/homebrew/lib/python3*/site-packages",
+		},
+		collect: collectPython,
+	},
+	{
+		name: "gem",
+		userDirs: []string{
+			".gem/ruby/*/specifications",
+			".local/share/gem/ruby/*/specifications",
+		},
+		systemDirs: []string{
+			"/var/lib/gems/*/specifications",
+			"/usr/lib/ruby/gems/*/specifications",
+			"/usr/share/gems/specifications",
+			"/usr/local/lib/ruby/gems/*/specifications",
+			"/opt/homebrew/lib/ruby/gems/*/specifications",
+		},
+		collect: collectGems,
+	},
+	{
+		name: "cargo",
+		// Default CARGO_HOME
+		userDirs: []string{
+			".cargo",
+		},
+		collect: collectCargo,
+	},
+	{
+		name: "go",
+		// Default GOPATH, with GOBIN unset
+		userDirs: []string{
+			"go/bin",
+		},
+		collect: collectGoBinaries,
+	},
+}
+
+type Table struct {
+	slogger *slog.Logger
+}
+
+func TablePlugin(flags types.Flags, slogger *slog.Logger) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("user"),
+		table.TextColumn("ecosystem"),
+		table.TextColumn("name"),
+		table.TextColumn("version"),
+		table.TextColumn("path"),
+	}
+
+	t := &Table{
+		slogger: slogger.With("table", tableName),
+	}
+
+	return tablewrapper.New(flags, slogger, tableName, columns, t.generate)
+}
+
+func (t *Table) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx, "table_name", tableName)
+	defer span.End()
+
+	var results []map[string]string
+
+	ecosystemConstraints := tablehelpers.GetConstraints(queryContext, "ecosystem")
+	userConstraints := tablehelpers.GetConstraints(queryContext, "user")
+
+	for _, e := range ecosystems {
+		if len(ecosystemConstraints) > 0 && !slices.Contains(ecosystemConstraints, e.name) {
+			continue
+		}
+
+		// System installs have no user, so skip them if the query asks for specific users
+		if len(userConstraints) == 0 {
+			results = append(results, t.generateForDirs(ctx, e, "", e.systemDirs)...)
+		}
+
+		for _, homeDir := range tablehelpers.UserHomeDirs(userConstraints...) {
+			userDirs := make([]string, len(e.userDirs))
+			for i, userDir := range e.userDirs {
+				userDirs[i] = filepath.Join(homeDir.Path, userDir)
+			}
+
+			results = append(results, t.generateForDirs(ctx, e, homeDir.Username, userDirs)...)
+		}
+	}
+
+	return results, nil
+}
+
+func (t *Table) generateForDirs(ctx context.Context, e ecosystem, username string, dirPatterns []string) []map[string]string {
+	var results []map[string]string
+
+	for _, dirPattern := range dirPatterns {
+		dirs, err := filepath.Glob(dirPattern)
+		if err != nil {
+			t.slogger.Log(ctx, slog.LevelDebug,
+				"bad package directory pattern",
+				"pattern", dirPattern,
+				"err", err,
+			)
+			continue
+		}
+
+		for _, dir := range dirs {
+			pkgs, err := e.collect(dir)
+			if err != nil {
+				t.slogger.Log(ctx, slog.LevelDebug,
+					"could not collect packages",
+					"ecosystem", e.name,
+					"dir", dir,
+					"err", err,
+				)
+				continue
+			}
+
+			for _, pkg := range pkgs {
+				results = append(results, map[string]string{
+					"user":      username,
+					"ecosystem": e.name,
+					"name":      pkg.name,
+					"version":   pkg.version,
+					"path":      pkg.path,
+				})
+			}
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,116 @@
+package language_packages
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	// Not parallel -- modifies tablehelpers.HomeDirLocations
+
+	homeRoot := t.TempDir()
+	homeDir := filepath.Join(homeRoot, "testuser")
+	originalLocations := tablehelpers.HomeDirLocations
+	tablehelpers.HomeDirLocations = map[string][]string{runtime.GOOS: {homeRoot}}
+	t.Cleanup(func() { tablehelpers.HomeDirLocations = originalLocations })
+
+	writeFile(t, filepath.Join(homeDir, ".nvm/versions/node/v20.11.0/lib/node_modules/typescript/package.json"), `{"name": "typescript", "version": "5.3.3"}`)
+	writeFile(t, filepath.Join(homeDir, ".nvm/versions/node/v20.11.0/lib/node_modules/@angular/cli/package.json"), `{"name": "@angular/cli", "version": "17.1.0"}`)
+	writeFile(t, filepath.Join(homeDir, ".nvm/versions/node/v20.11.0/lib/node_modules/broken/package.json"), `{`)
+
+	writeFile(t, filepath.Join(homeDir, ".local/lib/python3.12/site-packages/requests-2.31.0.dist-info/METADATA"), "Metadata-Version: 2.1\nName: requests\nVersion: 2.31.0\nSummary: Python HTTP for Humans.\n\nName: not-a-header\n")
+	writeFile(t, filepath.Join(homeDir, ".local/lib/python3.12/site-packages/legacy.egg-info/PKG-INFO"), "Metadata-Version: 1.0\nName: legacy\nVersion: 0.1\n")
+
+	writeFile(t, filepath.Join(homeDir, ".gem/ruby/3.2.0/specifications/rails-html-sanitizer-1.6.0.gemspec"), "")
+	writeFile(t, filepath.Join(homeDir, ".gem/ruby/3.2.0/specifications/nokogiri-1.16.0-x86_64-linux.gemspec"), "")
+	writeFile(t, filepath.Join(homeDir, ".gem/ruby/3.2.0/specifications/README"), "")
+
+	writeFile(t, filepath.Join(homeDir, ".cargo/.crates2.json"), `{"installs": {
+		"ripgrep 14.1.0 (registry+https://github.com/rust-lang/crates.io-index)": {"bins": ["rg"]},
+		"cargo-audit 0.18.3 (registry+https://github.com/rust-lang/crates.io-index)": {"bins": ["cargo-audit"]}
+	}}`)
+
+	// The test binary is a go binary, so it will have build info
+	writeFile(t, filepath.Join(homeDir, "go/bin/not-go"), "#!/bin/sh\n")
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+	copyFile(t, testBinary, filepath.Join(homeDir, "go/bin/gotool"))
+
+	languagePackagesTable := &Table{slogger: multislogger.NewNopLogger()}
+
+	// Constraining on user skips system installs, which vary by test machine
+	rows, err := languagePackagesTable.generate(t.Context(), tablehelpers.MockQueryContext(map[string][]string{
+		"user": {"testuser"},
+	}))
+	require.NoError(t, err)
+
+	versions := make(map[string]string)
+	for _, row := range rows {
+		require.Equal(t, "testuser", row["user"])
+		require.NotEmpty(t, row["path"])
+		versions[row["ecosystem"]+":"+row["name"]] = row["version"]
+	}
+
+	require.Equal(t, "5.3.3", versions["npm:typescript"])
+	require.Equal(t, "17.1.0", versions["npm:@angular/cli"])
+	require.Equal(t, "2.31.0", versions["pip:requests"])
+	require.Equal(t, "0.1", versions["pip:legacy"])
+	require.Equal(t, "1.6.0", versions["gem:rails-html-sanitizer"])
+	require.Equal(t, "1.16.0", versions["gem:nokogiri"])
+	require.Equal(t, "14.1.0", versions["cargo:ripgrep"])
+	require.Equal(t, "0.18.3", versions["cargo:cargo-audit"])
+
+	goPackages := 0
+	for _, row := range rows {
+		if row["ecosystem"] == "go" {
+			goPackages += 1
+			require.Equal(t, filepath.Join(homeDir, "go/bin/gotool"), row["path"])
+		}
+	}
+	require.Equal(t, 1, goPackages, "only the go binary should be reported")
+	require.Len(t, rows, 9)
+
+	// Constraining on ecosystem returns only that ecosystem's packages
+	rows, err = languagePackagesTable.generate(t.Context(), tablehelpers.MockQueryContext(map[string][]string{
+		"user":      {"testuser"},
+		"ecosystem": {"cargo"},
+	}))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	for _, row := range rows {
+		require.Equal(t, "cargo", row["ecosystem"])
+	}
+
+	// Unknown users, and usernames that would escape the home root, return nothing
+	rows, err = languagePackagesTable.generate(t.Context(), tablehelpers.MockQueryContext(map[string][]string{
+		"user": {"nobody", "../" + filepath.Base(homeRoot) + "/testuser"},
+	}))
+	require.NoError(t, err)
+	require.Empty(t, rows)
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0755))
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer in.Close()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(dst), 0755))
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+}
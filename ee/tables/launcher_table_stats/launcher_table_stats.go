@@ -0,0 +1,65 @@
+package launcher_table_stats
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const tableName = "kolide_launcher_table_stats"
+
+func TablePlugin(flags types.Flags, slogger *slog.Logger) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.BigIntColumn("calls"),
+		table.BigIntColumn("errors"),
+		table.BigIntColumn("timeouts"),
+		table.BigIntColumn("worker_rejections"),
+		table.BigIntColumn("cache_hits"),
+		table.BigIntColumn("rows_returned"),
+		table.BigIntColumn("p50_latency_ms"),
+		table.BigIntColumn("p95_latency_ms"),
+		table.BigIntColumn("max_latency_ms"),
+		table.TextColumn("last_error"),
+		table.BigIntColumn("last_error_at"),
+		table.BigIntColumn("last_called_at"),
+		table.BigIntColumn("first_recorded_at"),
+	}
+	return tablewrapper.New(flags, slogger, tableName, columns, generate())
+}
+
+func generate() table.GenerateFunc {
+	return func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		_, span := observability.StartSpan(ctx, "table_name", tableName)
+		defer span.End()
+
+		stats := tablewrapper.Stats()
+		results := make([]map[string]string, 0, len(stats))
+		for _, s := range stats {
+			results = append(results, map[string]string{
+				"name":              s.Name,
+				"calls":             strconv.FormatInt(s.Calls, 10),
+				"errors":            strconv.FormatInt(s.Errors, 10),
+				"timeouts":          strconv.FormatInt(s.Timeouts, 10),
+				"worker_rejections": strconv.FormatInt(s.WorkerRejections, 10),
+				"cache_hits":        strconv.FormatInt(s.CacheHits, 10),
+				"rows_returned":     strconv.FormatInt(s.RowsReturned, 10),
+				"p50_latency_ms":    strconv.FormatInt(s.P50Latency.Milliseconds(), 10),
+				"p95_latency_ms":    strconv.FormatInt(s.P95Latency.Milliseconds(), 10),
+				"max_latency_ms":    strconv.FormatInt(s.MaxLatency.Milliseconds(), 10),
+				"last_error":        s.LastError,
+				"last_error_at":     tablehelpers.UnixOrEmpty(s.LastErrorAt),
+				"last_called_at":    tablehelpers.UnixOrEmpty(s.LastCalledAt),
+				"first_recorded_at": tablehelpers.UnixOrEmpty(s.FirstRecordedAt),
+			})
+		}
+
+		return results, nil
+	}
+}
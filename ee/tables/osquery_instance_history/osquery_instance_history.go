@@ -22,6 +22,13 @@ func TablePlugin(k types.Knapsack, slogger *slog.Logger) *table.Plugin {
 		table.TextColumn("instance_id"),
 		table.TextColumn("version"),
 		table.TextColumn("errors"),
+		table.TextColumn("binary_path"),
+		table.TextColumn("exit_reason"),
+		table.TextColumn("exit_detail"),
+		table.TextColumn("exit_code"),
+		table.TextColumn("exit_signal"),
+		table.BigIntColumn("peak_rss_bytes"),
+		table.DoubleColumn("peak_cpu_percent"),
 	}
 	return tablewrapper.New(k, slogger, "kolide_launcher_osquery_instance_history", columns, generate(k))
 }
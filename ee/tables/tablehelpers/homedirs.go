@@ -0,0 +1,78 @@
+package tablehelpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HomeDirLocations are the directories that hold user home directories, by platform.
+// Platforms not listed use HomeDirDefaultLocation. Tests may add to these to point
+// tables at temporary home directories.
+var HomeDirLocations = map[string][]string{
+	"windows": {"/Users"}, // windows10 uses /Users
+	"darwin":  {"/Users"},
+}
+
+var HomeDirDefaultLocation = []string{"/home"}
+
+// UserHomeDir is a home directory found on disk, along with the name of the
+// user it belongs to.
+type UserHomeDir struct {
+	Username string
+	Path     string
+}
+
+// UserHomeDirs returns the user home directories under the platform's usual
+// home roots. If usernames are given, only those users' home directories are
+// returned; usernames that could refer to a path outside the home roots are
+// ignored. This does not consult the user database, so it works for tables
+// that only need to read files out of home directories.
+func UserHomeDirs(usernames ...string) []UserHomeDir {
+	roots, ok := HomeDirLocations[runtime.GOOS]
+	if !ok {
+		roots = HomeDirDefaultLocation
+	}
+
+	var homeDirs []UserHomeDir
+	for _, root := range roots {
+		if len(usernames) > 0 {
+			for _, username := range usernames {
+				if !validUsername(username) {
+					continue
+				}
+				homeDir := filepath.Join(root, username)
+				if stat, err := os.Stat(homeDir); err == nil && stat.IsDir() {
+					homeDirs = append(homeDirs, UserHomeDir{Username: username, Path: homeDir})
+				}
+			}
+			continue
+		}
+
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			// This root doesn't exist on this machine. Move on
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			homeDirs = append(homeDirs, UserHomeDir{Username: entry.Name(), Path: filepath.Join(root, entry.Name())})
+		}
+	}
+
+	return homeDirs
+}
+
+// validUsername reports whether username can safely be joined to a home root -- usernames
+// usually come from query constraints, so must not be able to escape it.
+func validUsername(username string) bool {
+	if username == "" || username == "." {
+		return false
+	}
+
+	return !strings.ContainsAny(username, `/\`) && !strings.Contains(username, "..")
+}
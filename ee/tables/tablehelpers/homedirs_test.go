@@ -0,0 +1,38 @@
+package tablehelpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHomeDirs(t *testing.T) {
+	// Not parallel -- modifies HomeDirLocations
+
+	homeRoot := t.TempDir()
+	for _, dir := range []string{"alice", "bob", "secrets"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(homeRoot, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(homeRoot, "not_a_home"), []byte("x"), 0644))
+
+	originalLocations := HomeDirLocations
+	HomeDirLocations = map[string][]string{runtime.GOOS: {filepath.Join(homeRoot, "home")}}
+	t.Cleanup(func() { HomeDirLocations = originalLocations })
+	require.NoError(t, os.MkdirAll(filepath.Join(homeRoot, "home", "carol"), 0755))
+
+	// All home directories
+	require.Equal(t, []UserHomeDir{{Username: "carol", Path: filepath.Join(homeRoot, "home", "carol")}}, UserHomeDirs())
+
+	// Only the requested users'
+	HomeDirLocations = map[string][]string{runtime.GOOS: {homeRoot}}
+	require.Equal(t, []UserHomeDir{{Username: "bob", Path: filepath.Join(homeRoot, "bob")}}, UserHomeDirs("bob", "dave", "not_a_home"))
+
+	// Usernames must not escape the home root
+	HomeDirLocations = map[string][]string{runtime.GOOS: {filepath.Join(homeRoot, "home")}}
+	for _, username := range []string{"", ".", "..", "../secrets", "../bob", `..\bob`, "carol/../../alice"} {
+		require.Empty(t, UserHomeDirs(username), username)
+	}
+}
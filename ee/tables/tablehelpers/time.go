@@ -0,0 +1,15 @@
+package tablehelpers
+
+import (
+	"strconv"
+	"time"
+)
+
+// UnixOrEmpty formats t as a unix timestamp for a table column, or returns an empty
+// string if t is unset.
+func UnixOrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
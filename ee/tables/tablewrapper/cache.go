@@ -0,0 +1,279 @@
+package tablewrapper
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const (
+	// maxCacheBytes bounds the approximate size of all cached results, across all tables.
+	maxCacheBytes = 32 * 1024 * 1024
+	// maxCacheEntryBytes bounds the approximate size of a single cached result; larger
+	// results are not cached.
+	maxCacheEntryBytes = 4 * 1024 * 1024
+	// maxCacheTTL bounds the TTLs the control server may set.
+	maxCacheTTL = 24 * time.Hour
+)
+
+type (
+	// resultCache caches table results by table name and query constraints, for the tables that
+	// the control server has assigned a TTL. It is shared across osquery instances, and evicts
+	// least-recently-used results when it grows beyond maxBytes.
+	resultCache struct {
+		lock     sync.Mutex
+		ttls     map[string]time.Duration
+		entries  map[string]*list.Element
+		lru      *list.List // front is most recently used
+		size     int
+		maxBytes int
+		// freshAfter is the start of the most recent WithFreshResults call; results generated
+		// before it are not served
+		freshAfter time.Time
+	}
+
+	cacheEntry struct {
+		key         string
+		tableName   string
+		rows        []map[string]string
+		generatedAt time.Time
+		expiresAt   time.Time
+		size        int
+	}
+)
+
+var cache = newResultCache(maxCacheBytes)
+
+func newResultCache(maxBytes int) *resultCache {
+	return &resultCache{
+		ttls:     make(map[string]time.Duration),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// WithFreshResults runs fn, ensuring that any table results it sees were generated after
+// it was called. Since osquery calls into our tables without any request context, we can't
+// tell which generate calls belong to fn; instead, results cached before the call are
+// discarded, and results generated since -- by fn or anyone else -- are served as usual. This
+// is how callers who need fresh data (e.g. localserver requests) opt out of stale results,
+// without turning off caching for other queries while they run.
+func WithFreshResults(fn func()) {
+	cache.discardGeneratedBefore(time.Now())
+
+	fn()
+}
+
+// cacheKey returns a key for the given table and query constraints. Constraints are
+// normalized, so that equivalent queries share a cache entry.
+func cacheKey(tableName string, queryContext table.QueryContext) string {
+	var sb strings.Builder
+	sb.WriteString(tableName)
+
+	for _, column := range slices.Sorted(maps.Keys(queryContext.Constraints)) {
+		constraints := make([]string, 0, len(queryContext.Constraints[column].Constraints))
+		for _, c := range queryContext.Constraints[column].Constraints {
+			constraints = append(constraints, fmt.Sprintf("%d:%q", c.Operator, c.Expression))
+		}
+		sort.Strings(constraints)
+
+		fmt.Fprintf(&sb, "|%q=%s", column, strings.Join(constraints, ","))
+	}
+
+	return sb.String()
+}
+
+// get returns the cached rows for the given key, if the table is cached and the rows
+// have not yet expired.
+func (c *resultCache) get(tableName string, key string) ([]map[string]string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.ttls[tableName]; !ok {
+		return nil, false
+	}
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) || entry.generatedAt.Before(c.freshAfter) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.rows, true
+}
+
+// set caches the given rows, generated by a call that started at generatedAt, if the table is
+// cached and the rows are not too large.
+func (c *resultCache) set(tableName string, key string, rows []map[string]string, generatedAt time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ttl, ok := c.ttls[tableName]
+	if !ok {
+		return
+	}
+
+	// Don't cache results that a WithFreshResults caller has already asked to discard
+	if generatedAt.Before(c.freshAfter) {
+		return
+	}
+
+	size := len(key)
+	for _, row := range rows {
+		for k, v := range row {
+			size += len(k) + len(v)
+		}
+	}
+	if size > maxCacheEntryBytes || size > c.maxBytes {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:         key,
+		tableName:   tableName,
+		rows:        rows,
+		generatedAt: generatedAt,
+		expiresAt:   generatedAt.Add(ttl),
+		size:        size,
+	})
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// discardGeneratedBefore stops serving results generated before the given time.
+func (c *resultCache) discardGeneratedBefore(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if t.After(c.freshAfter) {
+		c.freshAfter = t
+	}
+}
+
+// remove drops the given element; the caller must hold c.lock.
+func (c *resultCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// setTTLs replaces the per-table TTLs. Cached results for tables whose TTL changed are
+// dropped, so that results never outlive the current TTL.
+func (c *resultCache) setTTLs(ttls map[string]time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		tableName := elem.Value.(*cacheEntry).tableName
+		if ttls[tableName] != c.ttls[tableName] {
+			c.remove(elem)
+		}
+		elem = next
+	}
+
+	c.ttls = ttls
+}
+
+// parseCacheTTLs parses the TableCacheTTLs flag value, skipping invalid entries.
+func parseCacheTTLs(raw string) (map[string]time.Duration, []error) {
+	ttls := make(map[string]time.Duration)
+	if raw == "" {
+		return ttls, nil
+	}
+
+	var rawTTLs map[string]string
+	if err := json.Unmarshal([]byte(raw), &rawTTLs); err != nil {
+		return ttls, []error{fmt.Errorf("unmarshalling table cache TTLs: %w", err)}
+	}
+
+	var errs []error
+	for tableName, rawTTL := range rawTTLs {
+		ttl, err := time.ParseDuration(rawTTL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing cache TTL for table %s: %w", tableName, err))
+			continue
+		}
+		if ttl <= 0 {
+			continue
+		}
+		ttls[tableName] = min(ttl, maxCacheTTL)
+	}
+
+	return ttls, errs
+}
+
+// cacheConfigurer keeps the result cache's TTLs in sync with the TableCacheTTLs flag.
+type cacheConfigurer struct {
+	flags   types.Flags
+	slogger *slog.Logger
+}
+
+// ConfigureCache applies the current table cache TTLs, and keeps them updated as the
+// control server changes them.
+func ConfigureCache(ctx context.Context, flags types.Flags, slogger *slog.Logger) {
+	c := &cacheConfigurer{
+		flags:   flags,
+		slogger: slogger.With("component", "table_cache"),
+	}
+	c.apply(ctx)
+
+	flags.RegisterChangeObserver(c, keys.TableCacheTTLs)
+}
+
+// FlagsChanged satisfies the types.FlagsChangeObserver interface -- handles updates to
+// `TableCacheTTLs`.
+func (c *cacheConfigurer) FlagsChanged(ctx context.Context, flagKeys ...keys.FlagKey) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	if !slices.Contains(flagKeys, keys.TableCacheTTLs) {
+		return
+	}
+
+	c.apply(ctx)
+}
+
+func (c *cacheConfigurer) apply(ctx context.Context) {
+	ttls, errs := parseCacheTTLs(c.flags.TableCacheTTLs())
+	for _, err := range errs {
+		c.slogger.Log(ctx, slog.LevelWarn,
+			"invalid table cache TTL",
+			"err", err,
+		)
+	}
+
+	c.slogger.Log(ctx, slog.LevelInfo,
+		"setting table cache TTLs",
+		"cached_table_count", len(ttls),
+	)
+	cache.setTTLs(ttls)
+}
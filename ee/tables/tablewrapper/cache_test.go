@@ -0,0 +1,190 @@
+package tablewrapper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+
+	a := table.QueryContext{Constraints: map[string]table.ConstraintList{
+		"name": {Constraints: []table.Constraint{
+			{Operator: table.OperatorEquals, Expression: "b"},
+			{Operator: table.OperatorEquals, Expression: "a"},
+		}},
+		"path": {Constraints: []table.Constraint{{Operator: table.OperatorLike, Expression: "/tmp/%"}}},
+	}}
+	// Same constraints, different order
+	b := table.QueryContext{Constraints: map[string]table.ConstraintList{
+		"path": {Constraints: []table.Constraint{{Operator: table.OperatorLike, Expression: "/tmp/%"}}},
+		"name": {Constraints: []table.Constraint{
+			{Operator: table.OperatorEquals, Expression: "a"},
+			{Operator: table.OperatorEquals, Expression: "b"},
+		}},
+	}}
+	c := table.QueryContext{Constraints: map[string]table.ConstraintList{
+		"name": {Constraints: []table.Constraint{{Operator: table.OperatorEquals, Expression: "a"}}},
+	}}
+
+	require.Equal(t, cacheKey("t", a), cacheKey("t", b))
+	require.NotEqual(t, cacheKey("t", a), cacheKey("t", c))
+	require.NotEqual(t, cacheKey("t", a), cacheKey("u", a))
+	require.NotEqual(t, cacheKey("t", table.QueryContext{}), cacheKey("t", c))
+}
+
+func TestResultCache(t *testing.T) {
+	t.Parallel()
+
+	c := newResultCache(maxCacheBytes)
+	rows := []map[string]string{{"a": "1"}}
+
+	// Tables without a TTL are not cached
+	c.set("uncached", "uncached", rows, time.Now())
+	_, ok := c.get("uncached", "uncached")
+	require.False(t, ok)
+
+	c.setTTLs(map[string]time.Duration{"cached": time.Hour, "expires": time.Nanosecond})
+	c.set("cached", "cached", rows, time.Now())
+	cached, ok := c.get("cached", "cached")
+	require.True(t, ok)
+	require.Equal(t, rows, cached)
+
+	c.set("expires", "expires", rows, time.Now())
+	time.Sleep(time.Millisecond)
+	_, ok = c.get("expires", "expires")
+	require.False(t, ok)
+
+	// Changing a table's TTL drops its cached results
+	c.setTTLs(map[string]time.Duration{"cached": time.Minute})
+	_, ok = c.get("cached", "cached")
+	require.False(t, ok)
+	require.Zero(t, c.size)
+}
+
+func TestResultCache_freshResults(t *testing.T) {
+	t.Parallel()
+
+	c := newResultCache(maxCacheBytes)
+	c.setTTLs(map[string]time.Duration{"cached": time.Hour})
+	generatedAt := time.Now()
+	c.set("cached", "cached", []map[string]string{{"a": "1"}}, generatedAt)
+	c.set("cached", "in-flight", []map[string]string{{"a": "1"}}, generatedAt)
+
+	// Results generated before a fresh-results request are no longer served
+	c.discardGeneratedBefore(time.Now())
+	_, ok := c.get("cached", "cached")
+	require.False(t, ok)
+
+	// ...nor cached, if their generate call started before the request
+	c.set("cached", "in-flight", []map[string]string{{"a": "1"}}, generatedAt)
+	_, ok = c.get("cached", "in-flight")
+	require.False(t, ok)
+
+	// Results generated since are served as usual
+	c.set("cached", "cached", []map[string]string{{"a": "2"}}, time.Now())
+	rows, ok := c.get("cached", "cached")
+	require.True(t, ok)
+	require.Equal(t, []map[string]string{{"a": "2"}}, rows)
+
+	// An older request doesn't roll back the watermark
+	c.discardGeneratedBefore(generatedAt)
+	_, ok = c.get("cached", "cached")
+	require.True(t, ok)
+}
+
+func TestResultCache_memoryBounds(t *testing.T) {
+	t.Parallel()
+
+	c := newResultCache(1000)
+	c.setTTLs(map[string]time.Duration{"cached": time.Hour})
+	row := []map[string]string{{"a": strings.Repeat("x", 300)}}
+
+	for _, key := range []string{"one", "two", "three"} {
+		c.set("cached", key, row, time.Now())
+	}
+	_, ok := c.get("cached", "one")
+	require.True(t, ok)
+
+	// Adding a fourth entry evicts the least recently used -- "two", since we just read "one"
+	c.set("cached", "four", row, time.Now())
+	_, ok = c.get("cached", "two")
+	require.False(t, ok)
+	for _, key := range []string{"one", "three", "four"} {
+		_, ok := c.get("cached", key)
+		require.True(t, ok, key)
+	}
+	require.LessOrEqual(t, c.size, 1000)
+
+	// Entries larger than the whole cache are not cached
+	c.set("cached", "huge", []map[string]string{{"a": strings.Repeat("x", 2000)}}, time.Now())
+	_, ok = c.get("cached", "huge")
+	require.False(t, ok)
+}
+
+func TestParseCacheTTLs(t *testing.T) {
+	t.Parallel()
+
+	ttls, errs := parseCacheTTLs("")
+	require.Empty(t, ttls)
+	require.Empty(t, errs)
+
+	ttls, errs = parseCacheTTLs(`{"kolide_zypper_patches": "5m", "kolide_snap_upgradeable": "48h", "bad": "soon", "off": "0s"}`)
+	require.Equal(t, map[string]time.Duration{
+		"kolide_zypper_patches":   5 * time.Minute,
+		"kolide_snap_upgradeable": maxCacheTTL,
+	}, ttls)
+	require.Len(t, errs, 1)
+
+	ttls, errs = parseCacheTTLs(`not json`)
+	require.Empty(t, ttls)
+	require.Len(t, errs, 1)
+}
+
+func TestCall_servesFromCache(t *testing.T) { // nolint:paralleltest
+	tableName := "test_table_cached"
+	cache.setTTLs(map[string]time.Duration{tableName: time.Hour})
+	t.Cleanup(func() { cache.setTTLs(map[string]time.Duration{}) })
+
+	mockFlags := typesmocks.NewFlags(t)
+	mockFlags.On("TableGenerateTimeout").Return(4 * time.Minute)
+	mockFlags.On("RegisterChangeObserver", mock.Anything, keys.TableGenerateTimeout).Return()
+
+	calls := 0
+	w := New(mockFlags, multislogger.NewNopLogger(), tableName, nil, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		calls += 1
+		return []map[string]string{{"a": "1"}}, nil
+	})
+
+	request := map[string]string{"action": "generate", "context": "{}"}
+	for range 3 {
+		resp := w.Call(t.Context(), request)
+		require.Equal(t, int32(0), resp.Status.Code)
+		require.Len(t, resp.Response, 1)
+	}
+	require.Equal(t, 1, calls, "repeated identical calls are served from the cache")
+
+	// Different constraints are cached separately
+	w.Call(t.Context(), map[string]string{"action": "generate", "context": `{"constraints":[{"name":"a","list":[{"op":2,"expr":"1"}]}]}`})
+	require.Equal(t, 2, calls)
+
+	// Callers may require fresh results; results generated during their call are cached for others
+	WithFreshResults(func() {
+		w.Call(t.Context(), request)
+		w.Call(t.Context(), request)
+	})
+	require.Equal(t, 3, calls)
+	w.Call(t.Context(), request)
+	require.Equal(t, 3, calls)
+
+	require.Equal(t, int64(4), statsFor(t, tableName).CacheHits)
+}
@@ -0,0 +1,367 @@
+package tablewrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+)
+
+// latencySampleSize is the number of most recent calls per table that latency
+// percentiles are computed over.
+const latencySampleSize = 100
+
+// TableStats summarizes the execution history of a single launcher table. Counts are
+// cumulative since FirstRecordedAt; latencies cover the most recent calls.
+type TableStats struct {
+	Name             string        `json:"name"`
+	Calls            int64         `json:"calls"`
+	Errors           int64         `json:"errors"`
+	Timeouts         int64         `json:"timeouts"`
+	WorkerRejections int64         `json:"worker_rejections"`
+	CacheHits        int64         `json:"cache_hits"`
+	RowsReturned     int64         `json:"rows_returned"`
+	P50Latency       time.Duration `json:"p50_latency"`
+	P95Latency       time.Duration `json:"p95_latency"`
+	MaxLatency       time.Duration `json:"max_latency"`
+	LastError        string        `json:"last_error,omitempty"`
+	LastErrorAt      time.Time     `json:"last_error_at,omitempty"`
+	LastCalledAt     time.Time     `json:"last_called_at,omitempty"`
+	FirstRecordedAt  time.Time     `json:"first_recorded_at"`
+}
+
+// tableStats is the in-memory record for a single table.
+type tableStats struct {
+	lock            sync.Mutex
+	calls           int64
+	errors          int64
+	timeouts        int64
+	rejections      int64
+	cacheHits       int64
+	rows            int64
+	latencies       []time.Duration // ring buffer of the most recent latencies
+	nextLatency     int
+	lastError       string
+	lastErrorAt     time.Time
+	lastCalledAt    time.Time
+	firstRecordedAt time.Time
+}
+
+// storedTableStats is the persisted form of tableStats -- it keeps the latency samples,
+// so that percentiles survive restarts.
+type storedTableStats struct {
+	TableStats
+	Latencies []time.Duration `json:"latencies"`
+}
+
+// statsRegistry holds the stats for every table. It is shared across osquery instances,
+// so that stats accumulate across osquery restarts.
+var statsRegistry = struct {
+	sync.Mutex
+	tables map[string]*tableStats
+}{
+	tables: make(map[string]*tableStats),
+}
+
+// statsForTable returns the stats record for the given table, creating it if necessary.
+func statsForTable(name string) *tableStats {
+	statsRegistry.Lock()
+	defer statsRegistry.Unlock()
+
+	if ts, ok := statsRegistry.tables[name]; ok {
+		return ts
+	}
+
+	ts := &tableStats{
+		latencies:       make([]time.Duration, 0, latencySampleSize),
+		firstRecordedAt: time.Now().UTC(),
+	}
+	statsRegistry.tables[name] = ts
+	return ts
+}
+
+// recordCall records a call to the table's generate function that returned within the timeout.
+func (ts *tableStats) recordCall(latency time.Duration, rowCount int, err error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.calls += 1
+	ts.rows += int64(rowCount)
+	ts.lastCalledAt = time.Now().UTC()
+	ts.addLatency(latency)
+	if err != nil {
+		ts.errors += 1
+		ts.lastError = err.Error()
+		ts.lastErrorAt = ts.lastCalledAt
+	}
+}
+
+// recordTimeout records a call that did not return within the timeout.
+func (ts *tableStats) recordTimeout(timeout time.Duration, err error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.calls += 1
+	ts.timeouts += 1
+	ts.lastCalledAt = time.Now().UTC()
+	ts.addLatency(timeout)
+	ts.lastError = err.Error()
+	ts.lastErrorAt = ts.lastCalledAt
+}
+
+// recordRejection records a call that was rejected because no workers were available.
+func (ts *tableStats) recordRejection() {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.rejections += 1
+}
+
+// recordCacheHit records a call that was served from the result cache.
+func (ts *tableStats) recordCacheHit() {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.cacheHits += 1
+}
+
+// addLatency adds to the ring buffer of latencies; the caller must hold ts.lock.
+func (ts *tableStats) addLatency(latency time.Duration) {
+	if len(ts.latencies) < latencySampleSize {
+		ts.latencies = append(ts.latencies, latency)
+		return
+	}
+	ts.latencies[ts.nextLatency] = latency
+	ts.nextLatency = (ts.nextLatency + 1) % latencySampleSize
+}
+
+func (ts *tableStats) snapshot(name string) storedTableStats {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	s := storedTableStats{
+		TableStats: TableStats{
+			Name:             name,
+			Calls:            ts.calls,
+			Errors:           ts.errors,
+			Timeouts:         ts.timeouts,
+			WorkerRejections: ts.rejections,
+			CacheHits:        ts.cacheHits,
+			RowsReturned:     ts.rows,
+			LastError:        ts.lastError,
+			LastErrorAt:      ts.lastErrorAt,
+			LastCalledAt:     ts.lastCalledAt,
+			FirstRecordedAt:  ts.firstRecordedAt,
+		},
+		// Oldest first, so that order is preserved when restored
+		Latencies: slices.Concat(ts.latencies[ts.nextLatency:], ts.latencies[:ts.nextLatency]),
+	}
+	s.P50Latency, s.P95Latency, s.MaxLatency = latencyPercentiles(s.Latencies)
+
+	return s
+}
+
+// restore merges previously-persisted stats into this record.
+func (ts *tableStats) restore(stored storedTableStats) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.calls += stored.Calls
+	ts.errors += stored.Errors
+	ts.timeouts += stored.Timeouts
+	ts.rejections += stored.WorkerRejections
+	ts.cacheHits += stored.CacheHits
+	ts.rows += stored.RowsReturned
+	if !stored.FirstRecordedAt.IsZero() && stored.FirstRecordedAt.Before(ts.firstRecordedAt) {
+		ts.firstRecordedAt = stored.FirstRecordedAt
+	}
+	if stored.LastCalledAt.After(ts.lastCalledAt) {
+		ts.lastCalledAt = stored.LastCalledAt
+	}
+	if stored.LastErrorAt.After(ts.lastErrorAt) {
+		ts.lastError = stored.LastError
+		ts.lastErrorAt = stored.LastErrorAt
+	}
+
+	// Stored latencies are older than any we've recorded since startup
+	current := slices.Concat(ts.latencies[ts.nextLatency:], ts.latencies[:ts.nextLatency])
+	ts.latencies = make([]time.Duration, 0, latencySampleSize)
+	ts.nextLatency = 0
+	for _, latency := range slices.Concat(stored.Latencies, current) {
+		ts.addLatency(latency)
+	}
+}
+
+// latencyPercentiles returns the p50, p95, and max of the given latencies.
+func latencyPercentiles(latencies []time.Duration) (p50, p95, maxLatency time.Duration) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	percentile := func(p int) time.Duration {
+		// Nearest-rank method
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank-1, 0)]
+	}
+
+	return percentile(50), percentile(95), sorted[len(sorted)-1]
+}
+
+// Stats returns the current stats for every launcher table that has been created
+// in this process, sorted by table name.
+func Stats() []TableStats {
+	stored := storedStatsSnapshot()
+	stats := make([]TableStats, len(stored))
+	for i, s := range stored {
+		stats[i] = s.TableStats
+	}
+	return stats
+}
+
+func storedStatsSnapshot() []storedTableStats {
+	statsRegistry.Lock()
+	names := make([]string, 0, len(statsRegistry.tables))
+	records := make(map[string]*tableStats, len(statsRegistry.tables))
+	for name, ts := range statsRegistry.tables {
+		names = append(names, name)
+		records[name] = ts
+	}
+	statsRegistry.Unlock()
+
+	sort.Strings(names)
+	stored := make([]storedTableStats, len(names))
+	for i, name := range names {
+		stored[i] = records[name].snapshot(name)
+	}
+	return stored
+}
+
+// StoredStats returns the table stats last persisted to the given store, sorted by table
+// name. It's useful when the tables are running in another process, e.g. for flares.
+func StoredStats(store types.Iterator) ([]TableStats, error) {
+	var stats []TableStats
+	if err := store.ForEach(func(k, v []byte) error {
+		var s storedTableStats
+		if err := json.Unmarshal(v, &s); err != nil {
+			return fmt.Errorf("unmarshalling stats for table %s: %w", string(k), err)
+		}
+		s.Name = string(k)
+		s.P50Latency, s.P95Latency, s.MaxLatency = latencyPercentiles(s.Latencies)
+		stats = append(stats, s.TableStats)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading table stats: %w", err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats, nil
+}
+
+// statsPersister periodically writes table stats to a store, so that they survive
+// launcher restarts.
+type statsPersister struct {
+	slogger     *slog.Logger
+	store       types.KVStore
+	interval    time.Duration
+	interrupt   chan struct{}
+	interrupted atomic.Bool
+}
+
+// NewStatsPersister restores any previously-persisted stats from the store, and returns
+// an actor that persists stats every `interval` and on shutdown.
+func NewStatsPersister(slogger *slog.Logger, store types.KVStore, interval time.Duration) *statsPersister {
+	s := &statsPersister{
+		slogger:   slogger.With("component", "table_stats_persister"),
+		store:     store,
+		interval:  interval,
+		interrupt: make(chan struct{}, 1),
+	}
+
+	if err := s.restore(); err != nil {
+		s.slogger.Log(context.TODO(), slog.LevelWarn,
+			"could not restore table stats",
+			"err", err,
+		)
+	}
+
+	return s
+}
+
+func (s *statsPersister) Execute() error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.persist(context.TODO())
+		case <-s.interrupt:
+			s.persist(context.TODO())
+			return nil
+		}
+	}
+}
+
+func (s *statsPersister) Interrupt(_ error) {
+	// Only perform shutdown tasks on first call to interrupt -- no need to repeat on potential extra calls.
+	if s.interrupted.Swap(true) {
+		return
+	}
+
+	s.interrupt <- struct{}{}
+}
+
+func (s *statsPersister) restore() error {
+	return s.store.ForEach(func(k, v []byte) error {
+		var stored storedTableStats
+		if err := json.Unmarshal(v, &stored); err != nil {
+			// Skip this table, rather than losing all of them
+			s.slogger.Log(context.TODO(), slog.LevelWarn,
+				"could not unmarshal stored table stats",
+				"table_name", string(k),
+				"err", err,
+			)
+			return nil
+		}
+		statsForTable(string(k)).restore(stored)
+		return nil
+	})
+}
+
+func (s *statsPersister) persist(ctx context.Context) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	kvPairs := make(map[string]string)
+	for _, stored := range storedStatsSnapshot() {
+		raw, err := json.Marshal(stored)
+		if err != nil {
+			s.slogger.Log(ctx, slog.LevelWarn,
+				"could not marshal table stats",
+				"table_name", stored.Name,
+				"err", err,
+			)
+			continue
+		}
+		kvPairs[stored.Name] = string(raw)
+	}
+
+	if _, err := s.store.Update(kvPairs); err != nil {
+		observability.SetError(span, err)
+		s.slogger.Log(ctx, slog.LevelWarn,
+			"could not persist table stats",
+			"err", err,
+		)
+	}
+}
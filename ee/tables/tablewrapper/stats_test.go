@@ -0,0 +1,157 @@
+package tablewrapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/flags/keys"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func statsFor(t *testing.T, name string) TableStats {
+	for _, s := range Stats() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no stats for table %s", name)
+	return TableStats{}
+}
+
+func TestCall_recordsStats(t *testing.T) {
+	t.Parallel()
+
+	tableName := "test_table_stats_recorded"
+	overrideTimeout := 1 * time.Second
+
+	mockFlags := typesmocks.NewFlags(t)
+	mockFlags.On("TableGenerateTimeout").Return(4 * time.Minute)
+	mockFlags.On("RegisterChangeObserver", mock.Anything, keys.TableGenerateTimeout).Return()
+
+	calls := 0
+	w := New(mockFlags, multislogger.NewNopLogger(), tableName, nil, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		calls += 1
+		switch calls {
+		case 1:
+			return []map[string]string{{"a": "1"}, {"a": "2"}}, nil
+		case 2:
+			return nil, errors.New("test error")
+		default:
+			time.Sleep(2 * overrideTimeout)
+			return nil, nil
+		}
+	}, WithTableGenerateTimeout(overrideTimeout))
+
+	for range 3 {
+		w.Call(t.Context(), map[string]string{"action": "generate", "context": "{}"})
+	}
+
+	s := statsFor(t, tableName)
+	require.Equal(t, int64(3), s.Calls)
+	require.Equal(t, int64(1), s.Errors)
+	require.Equal(t, int64(1), s.Timeouts)
+	require.Equal(t, int64(2), s.RowsReturned)
+	require.Contains(t, s.LastError, "timed out")
+	require.Equal(t, overrideTimeout, s.MaxLatency)
+	require.False(t, s.LastCalledAt.IsZero())
+}
+
+func TestCall_recordsWorkerRejections(t *testing.T) {
+	t.Parallel()
+
+	tableName := "test_table_stats_rejections"
+
+	mockFlags := typesmocks.NewFlags(t)
+	mockFlags.On("TableGenerateTimeout").Return(4 * time.Minute)
+	mockFlags.On("RegisterChangeObserver", mock.Anything, keys.TableGenerateTimeout).Return()
+
+	wt := newWrappedTable(mockFlags, multislogger.NewNopLogger(), tableName, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return nil, nil
+	})
+	require.True(t, wt.workers.TryAcquire(numWorkers))
+	defer wt.workers.Release(numWorkers)
+
+	_, err := wt.generate(t.Context(), table.QueryContext{})
+	require.Error(t, err)
+
+	s := statsFor(t, tableName)
+	require.Equal(t, int64(0), s.Calls)
+	require.Equal(t, int64(1), s.WorkerRejections)
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	t.Parallel()
+
+	p50, p95, maxLatency := latencyPercentiles(nil)
+	require.Zero(t, p50)
+	require.Zero(t, p95)
+	require.Zero(t, maxLatency)
+
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i -= 1 {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	p50, p95, maxLatency = latencyPercentiles(latencies)
+	require.Equal(t, 50*time.Millisecond, p50)
+	require.Equal(t, 95*time.Millisecond, p95)
+	require.Equal(t, 100*time.Millisecond, maxLatency)
+}
+
+func TestTableStats_latencyRingBuffer(t *testing.T) {
+	t.Parallel()
+
+	ts := &tableStats{}
+	for i := 1; i <= latencySampleSize+10; i += 1 {
+		ts.recordCall(time.Duration(i)*time.Millisecond, 0, nil)
+	}
+
+	s := ts.snapshot("test")
+	require.Len(t, s.Latencies, latencySampleSize)
+	require.Equal(t, 11*time.Millisecond, s.Latencies[0], "oldest samples are dropped")
+	require.Equal(t, time.Duration(latencySampleSize+10)*time.Millisecond, s.MaxLatency)
+	require.Equal(t, int64(latencySampleSize+10), s.Calls)
+}
+
+func TestStatsPersister(t *testing.T) { // nolint:paralleltest
+	tableName := "test_table_stats_persisted"
+	store := inmemory.NewStore()
+
+	statsForTable(tableName).recordCall(10*time.Millisecond, 3, nil)
+
+	// Persist on shutdown
+	persister := NewStatsPersister(multislogger.NewNopLogger(), store, time.Hour)
+	go persister.Interrupt(nil)
+	require.NoError(t, persister.Execute())
+
+	stored, err := StoredStats(store)
+	require.NoError(t, err)
+	var found bool
+	for _, s := range stored {
+		if s.Name == tableName {
+			found = true
+			require.Equal(t, int64(1), s.Calls)
+			require.Equal(t, int64(3), s.RowsReturned)
+			require.Equal(t, 10*time.Millisecond, s.P50Latency)
+		}
+	}
+	require.True(t, found)
+
+	// Restoring merges the persisted stats into the in-memory stats
+	restored := &tableStats{}
+	var storedStats storedTableStats
+	storedStats.TableStats = statsFor(t, tableName)
+	storedStats.Latencies = []time.Duration{10 * time.Millisecond}
+	restored.recordCall(20*time.Millisecond, 1, nil)
+	restored.restore(storedStats)
+	s := restored.snapshot(tableName)
+	require.Equal(t, int64(2), s.Calls)
+	require.Equal(t, int64(4), s.RowsReturned)
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, s.Latencies)
+}
@@ -30,6 +30,7 @@ type wrappedTable struct {
 	genTimeout      time.Duration
 	genTimeoutLock  *sync.Mutex
 	workers         *semaphore.Weighted
+	stats           *tableStats
 }
 
 type tablePluginOption func(*wrappedTable)
@@ -65,6 +66,7 @@ func newWrappedTable(flags types.Flags, slogger *slog.Logger, name string, gen t
 		genTimeout:      flags.TableGenerateTimeout(),
 		genTimeoutLock:  &sync.Mutex{},
 		workers:         semaphore.NewWeighted(numWorkers),
+		stats:           statsForTable(name),
 	}
 
 	for _, opt := range opts {
@@ -104,7 +106,8 @@ func (wt *wrappedTable) FlagsChanged(ctx context.Context, flagKeys ...keys.FlagK
 }
 
 // generate wraps `wt.gen`, ensuring the function is traced and that it does not run for longer
-// than `wt.genTimeout`.
+// than `wt.genTimeout`. Successful results are cached, if the control server has set a cache TTL
+// for this table. It records the outcome of each call in the table's stats.
 func (wt *wrappedTable) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
 	ctx, span := observability.StartSpan(ctx, "table_name", wt.name, "table_generate_timeout", wt.genTimeout.String())
 	defer span.End()
@@ -117,10 +120,19 @@ func (wt *wrappedTable) generate(ctx context.Context, queryContext table.QueryCo
 	ctx, cancel := context.WithTimeout(ctx, genTimeout)
 	defer cancel()
 
+	// Serve from the cache, if this table is cached and we have fresh results for this query
+	key := cacheKey(wt.name, queryContext)
+	if rows, ok := cache.get(wt.name, key); ok {
+		span.AddEvent("cache_hit")
+		wt.stats.recordCacheHit()
+		return rows, nil
+	}
+
 	// A worker must be available for us to try to run the generate function --
 	// we don't want too many calls to the same table piling up.
 	if !wt.workers.TryAcquire(1) {
 		span.AddEvent("no_workers_available")
+		wt.stats.recordRejection()
 		return nil, fmt.Errorf("no workers available (limit %d)", numWorkers)
 	}
 
@@ -145,6 +157,10 @@ func (wt *wrappedTable) generate(ctx context.Context, queryContext table.QueryCo
 	// Wait for results up until the timeout
 	select {
 	case result := <-resultChan:
+		wt.stats.recordCall(time.Since(queryStartTime), len(result.rows), result.err)
+		if result.err == nil {
+			cache.set(wt.name, key, result.rows, queryStartTime)
+		}
 		return result.rows, result.err
 	case <-ctx.Done():
 		queriedColumns := columnsFromConstraints(queryContext)
@@ -158,6 +174,7 @@ func (wt *wrappedTable) generate(ctx context.Context, queryContext table.QueryCo
 		span.AddEvent("generate_timed_out")
 		err := fmt.Errorf("querying %s timed out after %s (queried columns: %v)", wt.name, genTimeout.String(), queriedColumns)
 		observability.SetError(span, err)
+		wt.stats.recordTimeout(genTimeout, err)
 		return nil, err
 	}
 }
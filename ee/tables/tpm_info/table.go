@@ -0,0 +1,173 @@
+//go:build linux
+
+package tpm_info
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/kolide/launcher/ee/tpmrunner"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const tableName = "kolide_tpm_info"
+
+// tpmPaths are tried in order. The resource manager is preferred, since /dev/tpm0
+// only allows a single open handle and launcher's own tpmrunner may be using it.
+var tpmPaths = []string{"/dev/tpmrm0", "/dev/tpm0"}
+
+type Table struct {
+	slogger *slog.Logger
+	store   types.Getter
+	openTpm func() (io.ReadWriteCloser, error)
+}
+
+// TablePlugin returns the kolide_tpm_info table. The store should be the one
+// that the tpmrunner keeps the launcher hardware key in.
+func TablePlugin(flags types.Flags, slogger *slog.Logger, store types.Getter) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.IntegerColumn("tpm_present"),
+		table.TextColumn("manufacturer"),
+		table.TextColumn("vendor_string"),
+		table.TextColumn("firmware_version"),
+		table.TextColumn("spec_family"),
+		table.IntegerColumn("spec_level"),
+		table.IntegerColumn("spec_revision"),
+		table.IntegerColumn("spec_year"),
+		table.IntegerColumn("owner_auth_set"),
+		table.IntegerColumn("in_lockout"),
+		table.IntegerColumn("lockout_counter"),
+		table.IntegerColumn("max_auth_fail"),
+		table.IntegerColumn("lockout_interval"),
+		table.IntegerColumn("lockout_recovery"),
+		table.TextColumn("pcr_banks"),
+		table.IntegerColumn("key_present"),
+		table.TextColumn("key_type"),
+		table.TextColumn("key_fingerprint_sha256"),
+		table.BigIntColumn("key_created_at"),
+		table.BigIntColumn("key_last_signed_at"),
+	}
+
+	t := &Table{
+		slogger: slogger.With("table", tableName),
+		store:   store,
+		openTpm: openDefaultTpm,
+	}
+
+	return tablewrapper.New(flags, slogger, tableName, columns, t.generate)
+}
+
+func openDefaultTpm() (io.ReadWriteCloser, error) {
+	var lastErr error
+	for _, path := range tpmPaths {
+		rw, err := tpm2.OpenTPM(path)
+		if err == nil {
+			return rw, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func (t *Table) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx, "table_name", tableName)
+	defer span.End()
+
+	row := map[string]string{
+		"tpm_present": "0",
+		"key_present": "0",
+	}
+
+	t.addTpmProperties(ctx, row)
+	t.addKeyStatus(ctx, row)
+
+	return []map[string]string{row}, nil
+}
+
+// addTpmProperties fills in the TPM columns. Failures are logged rather than
+// returned, so that the key status is still reported on machines without a TPM.
+func (t *Table) addTpmProperties(ctx context.Context, row map[string]string) {
+	rw, err := t.openTpm()
+	if err != nil {
+		level := slog.LevelInfo
+		if errors.Is(err, fs.ErrNotExist) {
+			level = slog.LevelDebug
+		}
+		t.slogger.Log(ctx, level,
+			"could not open tpm",
+			"err", err,
+		)
+		return
+	}
+	defer rw.Close()
+
+	row["tpm_present"] = "1"
+
+	props, err := readTpmProperties(rw)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelInfo,
+			"could not read tpm properties",
+			"err", err,
+		)
+		return
+	}
+
+	row["manufacturer"] = props.Manufacturer
+	row["vendor_string"] = props.VendorString
+	row["firmware_version"] = props.FirmwareVersion
+	row["spec_family"] = props.SpecFamily
+	row["spec_level"] = strconv.FormatUint(uint64(props.SpecLevel), 10)
+	row["spec_revision"] = strconv.FormatUint(uint64(props.SpecRevision), 10)
+	row["spec_year"] = strconv.FormatUint(uint64(props.SpecYear), 10)
+	row["owner_auth_set"] = boolToIntString(props.OwnerAuthSet)
+	row["in_lockout"] = boolToIntString(props.InLockout)
+	row["lockout_counter"] = strconv.FormatUint(uint64(props.LockoutCounter), 10)
+	row["max_auth_fail"] = strconv.FormatUint(uint64(props.MaxAuthFail), 10)
+	row["lockout_interval"] = strconv.FormatUint(uint64(props.LockoutInterval), 10)
+	row["lockout_recovery"] = strconv.FormatUint(uint64(props.LockoutRecovery), 10)
+	row["pcr_banks"] = strings.Join(props.PcrBanks, ",")
+}
+
+func (t *Table) addKeyStatus(ctx context.Context, row map[string]string) {
+	if t.store == nil {
+		return
+	}
+
+	status, err := tpmrunner.ReadKeyStatus(t.store)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelInfo,
+			"could not read launcher hardware key status",
+			"err", err,
+		)
+	}
+	if status == nil {
+		return
+	}
+
+	row["key_present"] = boolToIntString(status.Present)
+	row["key_type"] = status.KeyType
+	row["key_fingerprint_sha256"] = status.FingerprintSHA256
+	if !status.CreatedAt.IsZero() {
+		row["key_created_at"] = strconv.FormatInt(status.CreatedAt.Unix(), 10)
+	}
+	if !status.LastSignedAt.IsZero() {
+		row["key_last_signed_at"] = strconv.FormatInt(status.LastSignedAt.Unix(), 10)
+	}
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,171 @@
+//go:build linux
+
+package tpm_info
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/google/go-tpm-tools/simulator"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/kolide/launcher/ee/agent/storage/inmemory"
+	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// openSimulator opens the software TPM simulator. The simulator is a process-wide singleton:
+// opening it resets it to a freshly-manufactured state, and blocks until any other user has closed it.
+func openSimulator(t *testing.T) *simulator.Simulator {
+	sim, err := simulator.Get()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if !sim.IsClosed() {
+			sim.Close()
+		}
+	})
+	return sim
+}
+
+// eccSigningTemplate is a template for a password-protected ECC P-256 signing key, subject to
+// dictionary attack protection.
+var eccSigningTemplate = tpm2.Public{
+	Type:       tpm2.AlgECC,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagSign | tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin | tpm2.FlagUserWithAuth,
+	ECCParameters: &tpm2.ECCParams{
+		Sign:    &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: tpm2.AlgSHA256},
+		CurveID: tpm2.CurveNISTP256,
+	},
+}
+
+func Test_readTpmProperties(t *testing.T) {
+	t.Parallel()
+
+	sim := openSimulator(t)
+
+	props, err := readTpmProperties(sim)
+	require.NoError(t, err)
+
+	require.Equal(t, "MSFT", props.Manufacturer)
+	require.NotEmpty(t, props.VendorString)
+	require.Regexp(t, `^\d+\.\d+\.\d+\.\d+$`, props.FirmwareVersion)
+	require.Equal(t, "2.0", props.SpecFamily)
+	require.NotZero(t, props.SpecRevision)
+	require.NotZero(t, props.SpecYear)
+	require.False(t, props.OwnerAuthSet)
+	require.False(t, props.InLockout)
+	require.Equal(t, uint32(0), props.LockoutCounter)
+	require.NotZero(t, props.MaxAuthFail)
+	require.Contains(t, props.PcrBanks, "sha256")
+
+	// Set the owner password, then fail authorization to a key until the TPM locks out
+	require.NoError(t, tpm2.HierarchyChangeAuth(sim, tpm2.HandleOwner, tpm2.AuthCommand{Session: tpm2.HandlePasswordSession, Attributes: tpm2.AttrContinueSession}, "owner-password"))
+	key, _, err := tpm2.CreatePrimary(sim, tpm2.HandleOwner, tpm2.PCRSelection{}, "owner-password", "key-password", eccSigningTemplate)
+	require.NoError(t, err)
+
+	digest := make([]byte, 32)
+	for range props.MaxAuthFail {
+		_, err := tpm2.Sign(sim, key, "wrong-password", digest, nil, nil)
+		require.Error(t, err)
+	}
+
+	props, err = readTpmProperties(sim)
+	require.NoError(t, err)
+	require.True(t, props.OwnerAuthSet)
+	require.True(t, props.InLockout)
+	require.Equal(t, props.MaxAuthFail, props.LockoutCounter)
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	// Create a key in the TPM, as tpmrunner would, and keep its public area for the store
+	sim := openSimulator(t)
+	key, _, err := tpm2.CreatePrimary(sim, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", eccSigningTemplate)
+	require.NoError(t, err)
+	pub, _, _, err := tpm2.ReadPublic(sim, key)
+	require.NoError(t, err)
+	pubData, err := pub.Encode()
+	require.NoError(t, err)
+	require.NoError(t, sim.Close())
+
+	var tests = []struct {
+		name     string
+		openTpm  func() (io.ReadWriteCloser, error)
+		storeKey bool
+		expected map[string]string
+	}{
+		{
+			name:    "tpm and key",
+			openTpm: func() (io.ReadWriteCloser, error) { return simulator.Get() },
+			expected: map[string]string{
+				"tpm_present":    "1",
+				"manufacturer":   "MSFT",
+				"spec_family":    "2.0",
+				"in_lockout":     "0",
+				"pcr_banks":      "sha1,sha256,sha384,sha512",
+				"key_present":    "1",
+				"key_type":       "ecdsa-p256",
+				"key_created_at": "1700000000",
+			},
+			storeKey: true,
+		},
+		{
+			name:    "no tpm",
+			openTpm: func() (io.ReadWriteCloser, error) { return nil, fs.ErrNotExist },
+			expected: map[string]string{
+				"tpm_present": "0",
+				"key_present": "0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := inmemory.NewStore()
+			if tt.storeKey {
+				require.NoError(t, store.Set([]byte("publicEccData"), pubData))
+				require.NoError(t, store.Set([]byte("privateEccData"), []byte("opaque")))
+				require.NoError(t, store.Set([]byte("tpmKeyCreatedAt"), []byte("1700000000")))
+			}
+
+			tbl := &Table{
+				slogger: multislogger.NewNopLogger(),
+				store:   store,
+				openTpm: tt.openTpm,
+			}
+
+			rows, err := tbl.generate(t.Context(), table.QueryContext{})
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+
+			for k, v := range tt.expected {
+				require.Equal(t, v, rows[0][k], k)
+			}
+
+			if tt.storeKey {
+				require.Len(t, rows[0]["key_fingerprint_sha256"], 64)
+				require.NotContains(t, rows[0], "key_last_signed_at")
+			} else {
+				require.NotContains(t, rows[0], "manufacturer")
+			}
+		})
+	}
+}
+
+func TestTablePlugin(t *testing.T) {
+	t.Parallel()
+
+	mockFlags := typesmocks.NewFlags(t)
+	mockFlags.On("TableGenerateTimeout").Return(1 * time.Minute).Maybe()
+	mockFlags.On("RegisterChangeObserver", mock.Anything, mock.Anything).Return()
+
+	require.NotNil(t, TablePlugin(mockFlags, multislogger.NewNopLogger(), inmemory.NewStore()))
+}
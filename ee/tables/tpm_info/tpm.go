@@ -0,0 +1,167 @@
+//go:build linux
+
+package tpm_info
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+)
+
+// TPMA_PERMANENT attribute bits, see TPM 2.0 Part 2, section 8.6
+const (
+	permanentOwnerAuthSet = 1 << 0
+	permanentInLockout    = 1 << 9
+)
+
+// tpmProperties holds the subset of TPM 2.0 capabilities reported by the table.
+type tpmProperties struct {
+	Manufacturer    string
+	VendorString    string
+	FirmwareVersion string
+	SpecFamily      string
+	SpecLevel       uint32
+	SpecRevision    uint32
+	SpecYear        uint32
+	OwnerAuthSet    bool
+	InLockout       bool
+	LockoutCounter  uint32
+	MaxAuthFail     uint32
+	LockoutInterval uint32
+	LockoutRecovery uint32
+	PcrBanks        []string
+}
+
+// readTpmProperties queries the fixed and variable TPM properties and the PCR
+// allocation. It only issues TPM2_GetCapability, so it does not need any authorization.
+func readTpmProperties(rw io.ReadWriter) (*tpmProperties, error) {
+	props, err := getProperties(rw, tpm2.FamilyIndicator, tpm2.FirmwareVersion2)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixed properties: %w", err)
+	}
+
+	variable, err := getProperties(rw, tpm2.TPMAPermanent, tpm2.LockoutRecovery)
+	if err != nil {
+		return nil, fmt.Errorf("reading variable properties: %w", err)
+	}
+	for k, v := range variable {
+		props[k] = v
+	}
+
+	fw1, fw2 := props[tpm2.FirmwareVersion1], props[tpm2.FirmwareVersion2]
+
+	info := &tpmProperties{
+		Manufacturer: propertyString(props[tpm2.Manufacturer]),
+		VendorString: propertyString(
+			props[tpm2.VendorString1],
+			props[tpm2.VendorString2],
+			props[tpm2.VendorString3],
+			props[tpm2.VendorString4],
+		),
+		FirmwareVersion: fmt.Sprintf("%d.%d.%d.%d", fw1>>16, fw1&0xffff, fw2>>16, fw2&0xffff),
+		SpecFamily:      propertyString(props[tpm2.FamilyIndicator]),
+		SpecLevel:       props[tpm2.SpecLevel],
+		SpecRevision:    props[tpm2.SpecRevision],
+		SpecYear:        props[tpm2.SpecYear],
+		OwnerAuthSet:    props[tpm2.TPMAPermanent]&permanentOwnerAuthSet != 0,
+		InLockout:       props[tpm2.TPMAPermanent]&permanentInLockout != 0,
+		LockoutCounter:  props[tpm2.LockoutCounter],
+		MaxAuthFail:     props[tpm2.MaxAuthFail],
+		LockoutInterval: props[tpm2.LockoutInterval],
+		LockoutRecovery: props[tpm2.LockoutRecovery],
+	}
+
+	if info.PcrBanks, err = getPcrBanks(rw); err != nil {
+		return nil, fmt.Errorf("reading pcr banks: %w", err)
+	}
+
+	return info, nil
+}
+
+// getProperties fetches the TPM properties in the inclusive range first..last. TPMs
+// may return fewer properties than requested, so this follows moreData until the
+// range is covered.
+func getProperties(rw io.ReadWriter, first, last tpm2.TPMProp) (map[tpm2.TPMProp]uint32, error) {
+	props := make(map[tpm2.TPMProp]uint32)
+
+	next := first
+	for next <= last {
+		vals, moreData, err := tpm2.GetCapability(rw, tpm2.CapabilityTPMProperties, uint32(last-next+1), uint32(next))
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) == 0 {
+			break
+		}
+
+		for _, val := range vals {
+			prop, ok := val.(tpm2.TaggedProperty)
+			if !ok {
+				return nil, fmt.Errorf("unexpected capability value %T", val)
+			}
+			if prop.Tag > last {
+				return props, nil
+			}
+			props[prop.Tag] = prop.Value
+			next = prop.Tag + 1
+		}
+
+		if !moreData {
+			break
+		}
+	}
+
+	return props, nil
+}
+
+// getPcrBanks returns the hash algorithms that have at least one PCR allocated.
+func getPcrBanks(rw io.ReadWriter) ([]string, error) {
+	vals, _, err := tpm2.GetCapability(rw, tpm2.CapabilityPCRs, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	banks := make([]string, 0, len(vals))
+	for _, val := range vals {
+		sel, ok := val.(tpm2.PCRSelection)
+		if !ok {
+			return nil, fmt.Errorf("unexpected capability value %T", val)
+		}
+		if len(sel.PCRs) == 0 {
+			continue
+		}
+		banks = append(banks, hashAlgName(sel.Hash))
+	}
+
+	return banks, nil
+}
+
+func hashAlgName(alg tpm2.Algorithm) string {
+	switch alg {
+	case tpm2.AlgSHA1, tpm2.AlgSHA256, tpm2.AlgSHA384, tpm2.AlgSHA512, tpm2.AlgSHA3_256, tpm2.AlgSHA3_384, tpm2.AlgSHA3_512:
+		return strings.ToLower(alg.String())
+	case 0x0012:
+		return "sm3_256"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(alg))
+	}
+}
+
+// propertyString decodes properties that hold packed ASCII, such as the
+// manufacturer id and vendor strings.
+func propertyString(values ...uint32) string {
+	buf := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		buf = binary.BigEndian.AppendUint32(buf, v)
+	}
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, string(buf)))
+}
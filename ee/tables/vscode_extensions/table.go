@@ -0,0 +1,384 @@
+package vscode_extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/katc"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const tableName = "kolide_vscode_extensions"
+
+// editor describes where a VS Code derived editor keeps its extensions, and
+// where it keeps the global state database that records disabled extensions.
+// Paths are relative to the user's home directory.
+type editor struct {
+	name          string
+	extensionsDir string
+	stateDb       map[string]string // by runtime.GOOS. Unset means there is no state db
+}
+
+// userDataStateDb builds the state db locations for a desktop editor, given
+// the name of its user data directory.
+func userDataStateDb(userDataDirName string) map[string]string {
+	stateDb := filepath.Join("User", "globalStorage", "state.vscdb")
+	return map[string]string{
+		"darwin":  filepath.Join("Library", "Application Support", userDataDirName, stateDb),
+		"windows": filepath.Join("AppData", "Roaming", userDataDirName, stateDb),
+		"linux":   filepath.Join(".config", userDataDirName, stateDb),
+	}
+}
+
+// remoteStateDb builds the state db location for a remote server install,
+// which is the same on every platform.
+func remoteStateDb(serverDir string) map[string]string {
+	stateDb := filepath.Join(serverDir, "data", "User", "globalStorage", "state.vscdb")
+	return map[string]string{
+		"darwin":  stateDb,
+		"windows": stateDb,
+		"linux":   stateDb,
+	}
+}
+
+var editors = []editor{
+	{name: "vscode", extensionsDir: ".vscode/extensions", stateDb: userDataStateDb("Code")},
+	{name: "vscode_insiders", extensionsDir: ".vscode-insiders/extensions", stateDb: userDataStateDb("Code - Insiders")},
+	{name: "vscodium", extensionsDir: ".vscode-oss/extensions", stateDb: userDataStateDb("VSCodium")},
+	{name: "cursor", extensionsDir: ".cursor/extensions", stateDb: userDataStateDb("Cursor")},
+	{name: "vscode_server", extensionsDir: ".vscode-server/extensions", stateDb: remoteStateDb(".vscode-server")},
+	{name: "vscode_server_insiders", extensionsDir: ".vscode-server-insiders/extensions", stateDb: remoteStateDb(".vscode-server-insiders")},
+	{name: "cursor_server", extensionsDir: ".cursor-server/extensions", stateDb: remoteStateDb(".cursor-server")},
+}
+
+// disabledExtensionsQuery reads the list of globally disabled extensions from
+// a VS Code state database.
+const disabledExtensionsQuery = `SELECT value FROM ItemTable WHERE key = 'extensionsIdentifiers/disabled'`
+
+type Table struct {
+	slogger *slog.Logger
+}
+
+func TablePlugin(flags types.Flags, slogger *slog.Logger) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("user"),
+		table.TextColumn("editor"),
+		table.TextColumn("path"),
+		table.TextColumn("id"),
+		table.TextColumn("publisher"),
+		table.TextColumn("name"),
+		table.TextColumn("display_name"),
+		table.TextColumn("version"),
+		table.TextColumn("description"),
+		table.TextColumn("engine"),
+		table.TextColumn("main"),
+		table.TextColumn("extension_kind"),
+		table.TextColumn("activation_events"),
+		table.TextColumn("contributes"),
+		table.TextColumn("untrusted_workspaces"),
+		table.TextColumn("virtual_workspaces"),
+		table.TextColumn("source"),
+		table.BigIntColumn("installed_at"),
+		table.IntegerColumn("prerelease"),
+		table.IntegerColumn("disabled"),
+		table.IntegerColumn("obsolete"),
+	}
+
+	t := &Table{
+		slogger: slogger.With("table", tableName),
+	}
+
+	return tablewrapper.New(flags, slogger, tableName, columns, t.generate)
+}
+
+func (t *Table) generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx, "table_name", tableName)
+	defer span.End()
+
+	var results []map[string]string
+
+	editorConstraints := tablehelpers.GetConstraints(queryContext, "editor")
+
+	for _, homeDir := range tablehelpers.UserHomeDirs(tablehelpers.GetConstraints(queryContext, "user")...) {
+		for _, e := range editors {
+			if len(editorConstraints) > 0 && !slices.Contains(editorConstraints, e.name) {
+				continue
+			}
+
+			rows, err := t.generateForEditor(ctx, homeDir, e)
+			if err != nil {
+				t.slogger.Log(ctx, slog.LevelDebug,
+					"could not read editor extensions",
+					"user", homeDir.Username,
+					"editor", e.name,
+					"err", err,
+				)
+				continue
+			}
+
+			results = append(results, rows...)
+		}
+	}
+
+	return results, nil
+}
+
+// packageJson is the subset of an extension's package.json that we report on.
+type packageJson struct {
+	Publisher        string            `json:"publisher"`
+	Name             string            `json:"name"`
+	DisplayName      string            `json:"displayName"`
+	Version          string            `json:"version"`
+	Description      string            `json:"description"`
+	Main             string            `json:"main"`
+	Engines          map[string]string `json:"engines"`
+	ActivationEvents []string          `json:"activationEvents"`
+	ExtensionKind    json.RawMessage   `json:"extensionKind"`
+	Contributes      map[string]any    `json:"contributes"`
+	Capabilities     struct {
+		UntrustedWorkspaces capabilitySupport `json:"untrustedWorkspaces"`
+		VirtualWorkspaces   json.RawMessage   `json:"virtualWorkspaces"`
+	} `json:"capabilities"`
+}
+
+type capabilitySupport struct {
+	Supported json.RawMessage `json:"supported"`
+}
+
+// extensionsJsonEntry is an entry in the extensions directory's extensions.json,
+// which is the editor's record of what it has installed.
+type extensionsJsonEntry struct {
+	Identifier struct {
+		Id string `json:"id"`
+	} `json:"identifier"`
+	Version          string `json:"version"`
+	RelativeLocation string `json:"relativeLocation"`
+	Metadata         struct {
+		InstalledTimestamp  int64  `json:"installedTimestamp"`
+		Source              string `json:"source"`
+		IsPreReleaseVersion bool   `json:"isPreReleaseVersion"`
+	} `json:"metadata"`
+}
+
+func (t *Table) generateForEditor(ctx context.Context, homeDir tablehelpers.UserHomeDir, e editor) ([]map[string]string, error) {
+	extensionsDir := filepath.Join(homeDir.Path, e.extensionsDir)
+	if stat, err := os.Stat(extensionsDir); err != nil || !stat.IsDir() {
+		// Editor is not installed for this user
+		return nil, nil
+	}
+
+	installed := readExtensionsJson(extensionsDir)
+	obsolete := readObsolete(extensionsDir)
+
+	var disabled map[string]bool
+	if stateDbPath, ok := e.stateDb[runtime.GOOS]; ok {
+		disabled = t.readDisabled(ctx, filepath.Join(homeDir.Path, stateDbPath))
+	}
+
+	packageJsonPaths, err := filepath.Glob(filepath.Join(extensionsDir, "*", "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing for package.json: %w", err)
+	}
+
+	results := make([]map[string]string, 0, len(packageJsonPaths))
+	for _, packageJsonPath := range packageJsonPaths {
+		pkg, err := readPackageJson(packageJsonPath)
+		if err != nil {
+			t.slogger.Log(ctx, slog.LevelDebug,
+				"could not read extension package.json",
+				"path", packageJsonPath,
+				"err", err,
+			)
+			continue
+		}
+
+		extensionPath := filepath.Dir(packageJsonPath)
+		id := strings.ToLower(pkg.Publisher + "." + pkg.Name)
+
+		row := map[string]string{
+			"user":                 homeDir.Username,
+			"editor":               e.name,
+			"path":                 extensionPath,
+			"id":                   id,
+			"publisher":            pkg.Publisher,
+			"name":                 pkg.Name,
+			"display_name":         pkg.DisplayName,
+			"version":              pkg.Version,
+			"description":          pkg.Description,
+			"engine":               pkg.Engines["vscode"],
+			"main":                 pkg.Main,
+			"extension_kind":       rawToString(pkg.ExtensionKind),
+			"activation_events":    strings.Join(pkg.ActivationEvents, ","),
+			"contributes":          strings.Join(sortedKeys(pkg.Contributes), ","),
+			"untrusted_workspaces": rawToString(pkg.Capabilities.UntrustedWorkspaces.Supported),
+			"virtual_workspaces":   virtualWorkspaces(pkg.Capabilities.VirtualWorkspaces),
+			"disabled":             strconv.Itoa(btoi(disabled[id])),
+			"obsolete":             strconv.Itoa(btoi(obsolete[filepath.Base(extensionPath)])),
+		}
+
+		if entry, ok := installed[filepath.Base(extensionPath)]; ok {
+			row["source"] = entry.Metadata.Source
+			row["prerelease"] = strconv.Itoa(btoi(entry.Metadata.IsPreReleaseVersion))
+			if entry.Metadata.InstalledTimestamp > 0 {
+				// VS Code records this in milliseconds
+				row["installed_at"] = strconv.FormatInt(entry.Metadata.InstalledTimestamp/1000, 10)
+			}
+		}
+
+		results = append(results, row)
+	}
+
+	return results, nil
+}
+
+func readPackageJson(path string) (*packageJson, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	var pkg packageJson
+	if err := json.Unmarshal(raw, &pkg); err != nil {
+		return nil, fmt.Errorf("unmarshalling: %w", err)
+	}
+
+	if pkg.Publisher == "" || pkg.Name == "" {
+		return nil, fmt.Errorf("package.json at %s is missing publisher or name", path)
+	}
+
+	return &pkg, nil
+}
+
+// readExtensionsJson reads extensions.json, returning the entries by the
+// directory name of the extension. Older editor versions don't write this
+// file, so errors are not fatal.
+func readExtensionsJson(extensionsDir string) map[string]extensionsJsonEntry {
+	installed := make(map[string]extensionsJsonEntry)
+
+	raw, err := os.ReadFile(filepath.Join(extensionsDir, "extensions.json"))
+	if err != nil {
+		return installed
+	}
+
+	var entries []extensionsJsonEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return installed
+	}
+
+	for _, entry := range entries {
+		if entry.RelativeLocation == "" {
+			continue
+		}
+		installed[entry.RelativeLocation] = entry
+	}
+
+	return installed
+}
+
+// readObsolete reads the .obsolete file, which records extensions that have
+// been uninstalled or upgraded, but whose directories have not been cleaned
+// up yet. It is a JSON object of directory names to true.
+func readObsolete(extensionsDir string) map[string]bool {
+	obsolete := make(map[string]bool)
+
+	raw, err := os.ReadFile(filepath.Join(extensionsDir, ".obsolete"))
+	if err != nil {
+		return obsolete
+	}
+
+	_ = json.Unmarshal(raw, &obsolete)
+	return obsolete
+}
+
+// readDisabled reads the globally disabled extensions from the editor's
+// state database, using the same read-only sqlite access as KATC.
+func (t *Table) readDisabled(ctx context.Context, stateDbPath string) map[string]bool {
+	disabled := make(map[string]bool)
+
+	if _, err := os.Stat(stateDbPath); err != nil {
+		return disabled
+	}
+
+	rows, err := katc.QuerySqliteDb(ctx, t.slogger, stateDbPath, disabledExtensionsQuery)
+	if err != nil {
+		t.slogger.Log(ctx, slog.LevelDebug,
+			"could not query editor state db",
+			"path", stateDbPath,
+			"err", err,
+		)
+		return disabled
+	}
+
+	for _, row := range rows {
+		var identifiers []struct {
+			Id string `json:"id"`
+		}
+		if err := json.Unmarshal(row["value"], &identifiers); err != nil {
+			continue
+		}
+		for _, identifier := range identifiers {
+			disabled[strings.ToLower(identifier.Id)] = true
+		}
+	}
+
+	return disabled
+}
+
+// virtualWorkspaces handles the two forms of the virtualWorkspaces capability:
+// a bare boolean, or an object with a `supported` key.
+func virtualWorkspaces(raw json.RawMessage) string {
+	var support capabilitySupport
+	if err := json.Unmarshal(raw, &support); err == nil {
+		return rawToString(support.Supported)
+	}
+
+	return rawToString(raw)
+}
+
+// rawToString renders a JSON value for a text column. Strings are unquoted,
+// and arrays of strings are comma joined.
+func rawToString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var ss []string
+	if err := json.Unmarshal(raw, &ss); err == nil {
+		return strings.Join(ss, ",")
+	}
+
+	return string(raw)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func btoi(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package vscode_extensions
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func Test_generateForEditor(t *testing.T) {
+	t.Parallel()
+
+	homeDir := tablehelpers.UserHomeDir{Username: "testuser", Path: t.TempDir()}
+	vscode := editors[0]
+	extensionsDir := filepath.Join(homeDir.Path, vscode.extensionsDir)
+
+	writeFile(t, filepath.Join(extensionsDir, "ms-python.python-2024.2.1", "package.json"), `{
+		"publisher": "ms-python",
+		"name": "python",
+		"displayName": "Python",
+		"version": "2024.2.1",
+		"main": "./out/client/extension",
+		"engines": {"vscode": "^1.86.0"},
+		"activationEvents": ["onLanguage:python", "workspaceContains:*.py"],
+		"extensionKind": ["workspace"],
+		"contributes": {"commands": [], "debuggers": [], "configuration": {}},
+		"capabilities": {
+			"untrustedWorkspaces": {"supported": "limited"},
+			"virtualWorkspaces": {"supported": false}
+		}
+	}`)
+	writeFile(t, filepath.Join(extensionsDir, "evil.theme-0.0.1", "package.json"), `{
+		"publisher": "Evil",
+		"name": "theme",
+		"version": "0.0.1",
+		"capabilities": {"virtualWorkspaces": true}
+	}`)
+	writeFile(t, filepath.Join(extensionsDir, "broken-1.0.0", "package.json"), `{not json`)
+	writeFile(t, filepath.Join(extensionsDir, "extensions.json"), `[
+		{
+			"identifier": {"id": "ms-python.python"},
+			"version": "2024.2.1",
+			"relativeLocation": "ms-python.python-2024.2.1",
+			"metadata": {"installedTimestamp": 1709000000000, "source": "gallery", "isPreReleaseVersion": true}
+		}
+	]`)
+	writeFile(t, filepath.Join(extensionsDir, ".obsolete"), `{"evil.theme-0.0.1": true}`)
+
+	// Mark the theme as disabled in the state db
+	stateDbPath := filepath.Join(homeDir.Path, vscode.stateDb[runtime.GOOS])
+	require.NoError(t, os.MkdirAll(filepath.Dir(stateDbPath), 0755))
+	db, err := sql.Open("sqlite", stateDbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO ItemTable (key, value) VALUES ('extensionsIdentifiers/disabled', '[{"id":"evil.theme","uuid":"1234"}]')`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	vscodeTable := &Table{slogger: multislogger.NewNopLogger()}
+	rows, err := vscodeTable.generateForEditor(t.Context(), homeDir, vscode)
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "broken extension should be skipped")
+
+	rowsById := make(map[string]map[string]string)
+	for _, row := range rows {
+		rowsById[row["id"]] = row
+	}
+
+	python := rowsById["ms-python.python"]
+	require.Equal(t, "testuser", python["user"])
+	require.Equal(t, "vscode", python["editor"])
+	require.Equal(t, "2024.2.1", python["version"])
+	require.Equal(t, "^1.86.0", python["engine"])
+	require.Equal(t, "onLanguage:python,workspaceContains:*.py", python["activation_events"])
+	require.Equal(t, "workspace", python["extension_kind"])
+	require.Equal(t, "commands,configuration,debuggers", python["contributes"])
+	require.Equal(t, "limited", python["untrusted_workspaces"])
+	require.Equal(t, "false", python["virtual_workspaces"])
+	require.Equal(t, "gallery", python["source"])
+	require.Equal(t, "1709000000", python["installed_at"])
+	require.Equal(t, "1", python["prerelease"])
+	require.Equal(t, "0", python["disabled"])
+	require.Equal(t, "0", python["obsolete"])
+
+	theme := rowsById["evil.theme"]
+	require.Equal(t, "Evil", theme["publisher"])
+	require.Equal(t, "true", theme["virtual_workspaces"])
+	require.Equal(t, "1", theme["disabled"])
+	require.Equal(t, "1", theme["obsolete"])
+	require.NotContains(t, theme, "installed_at")
+}
+
+func Test_generateForEditor_NotInstalled(t *testing.T) {
+	t.Parallel()
+
+	homeDir := tablehelpers.UserHomeDir{Username: "testuser", Path: t.TempDir()}
+
+	vscodeTable := &Table{slogger: multislogger.NewNopLogger()}
+	for _, e := range editors {
+		rows, err := vscodeTable.generateForEditor(t.Context(), homeDir, e)
+		require.NoError(t, err)
+		require.Empty(t, rows)
+	}
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+}
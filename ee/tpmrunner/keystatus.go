@@ -0,0 +1,110 @@
+//go:build !darwin
+// +build !darwin
+
+package tpmrunner
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/kolide/launcher/ee/agent/types"
+)
+
+const (
+	keyCreatedAt    = "tpmKeyCreatedAt"
+	keyLastSignedAt = "tpmKeyLastSignedAt"
+
+	// lastSignedWriteInterval limits how often a successful sign is persisted
+	// to the store, since signing happens on every request to the control server.
+	lastSignedWriteInterval = time.Minute
+)
+
+// KeyStatus describes the launcher hardware key held in the tpmrunner's store.
+// Zero times mean the value was never recorded, which is expected for keys
+// created by older versions of launcher.
+type KeyStatus struct {
+	Present           bool
+	KeyType           string
+	FingerprintSHA256 string
+	CreatedAt         time.Time
+	LastSignedAt      time.Time
+}
+
+// ReadKeyStatus reads the status of the launcher hardware key from the store used by
+// the tpmrunner. It does not talk to the TPM. If the stored public key cannot be
+// decoded, the returned status still carries the timestamps along with the error.
+func ReadKeyStatus(store types.Getter) (*KeyStatus, error) {
+	status := &KeyStatus{}
+
+	_, pubData, err := fetchKeyData(store)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key data: %w", err)
+	}
+
+	if status.CreatedAt, err = readTimestamp(store, keyCreatedAt); err != nil {
+		return nil, err
+	}
+	if status.LastSignedAt, err = readTimestamp(store, keyLastSignedAt); err != nil {
+		return nil, err
+	}
+
+	if pubData == nil {
+		return status, nil
+	}
+	status.Present = true
+
+	pub, err := tpm2.DecodePublic(pubData)
+	if err != nil {
+		return status, fmt.Errorf("decoding tpm public area: %w", err)
+	}
+
+	key, err := pub.Key()
+	if err != nil {
+		return status, fmt.Errorf("extracting public key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		status.KeyType = "ecdsa-" + strings.ToLower(strings.ReplaceAll(k.Curve.Params().Name, "-", ""))
+	case *rsa.PublicKey:
+		status.KeyType = "rsa-" + strconv.Itoa(k.N.BitLen())
+	default:
+		status.KeyType = fmt.Sprintf("%T", key)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return status, fmt.Errorf("marshalling public key: %w", err)
+	}
+	status.FingerprintSHA256 = fmt.Sprintf("%x", sha256.Sum256(der))
+
+	return status, nil
+}
+
+func readTimestamp(store types.Getter, key string) (time.Time, error) {
+	raw, err := store.Get([]byte(key))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading %s: %w", key, err)
+	}
+	if raw == nil {
+		return time.Time{}, nil
+	}
+
+	unix, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %s: %w", key, err)
+	}
+
+	return time.Unix(unix, 0).UTC(), nil
+}
+
+func storeTimestamp(store types.Setter, key string, t time.Time) error {
+	return store.Set([]byte(key), []byte(strconv.FormatInt(t.Unix(), 10)))
+}
@@ -43,6 +43,9 @@ type (
 		interrupt     chan struct{}
 		interrupted   atomic.Bool
 		machineHasTpm atomic.Bool
+		// lastSignedAt is the unix time of the last successful sign that was
+		// written to the store
+		lastSignedAt atomic.Int64
 	}
 
 	// defaultTpmSignerCreator is the default implementation of tpmSignerCreator
@@ -175,7 +178,34 @@ func (tr *tpmRunner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 		return nil, errors.New("no signer available")
 	}
 
-	return tr.signer.Sign(rand, digest, opts)
+	sig, err := tr.signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tr.recordSign(time.Now())
+
+	return sig, nil
+}
+
+// recordSign persists the time of a successful sign, at most once per lastSignedWriteInterval.
+func (tr *tpmRunner) recordSign(now time.Time) {
+	last := tr.lastSignedAt.Load()
+	if now.Unix()-last < int64(lastSignedWriteInterval.Seconds()) {
+		return
+	}
+
+	if !tr.lastSignedAt.CompareAndSwap(last, now.Unix()) {
+		// another sign is already recording
+		return
+	}
+
+	if err := storeTimestamp(tr.store, keyLastSignedAt, now); err != nil {
+		tr.slogger.Log(context.TODO(), slog.LevelDebug,
+			"storing last signed time",
+			"err", err,
+		)
+	}
 }
 
 // This duplicates some of pkg/osquery/extension.go but that feels like the wrong place.
@@ -221,7 +251,7 @@ func clearKeyData(slogger *slog.Logger, deleter types.Deleter) {
 	slogger.Log(context.TODO(), slog.LevelInfo,
 		"clearing keys",
 	)
-	_ = deleter.Delete([]byte(privateEccData), []byte(publicEccData))
+	_ = deleter.Delete([]byte(privateEccData), []byte(publicEccData), []byte(keyCreatedAt), []byte(keyLastSignedAt))
 }
 
 func (tr *tpmRunner) loadOrCreateKeys(ctx context.Context) error {
@@ -275,6 +305,13 @@ func (tr *tpmRunner) loadOrCreateKeys(ctx context.Context) error {
 			return thisErr
 		}
 
+		if err := storeTimestamp(tr.store, keyCreatedAt, time.Now()); err != nil {
+			tr.slogger.Log(ctx, slog.LevelWarn,
+				"storing key creation time",
+				"err", err,
+			)
+		}
+
 		tr.slogger.Log(ctx, slog.LevelInfo,
 			"new tpm keys generated",
 		)
@@ -4,6 +4,8 @@
 package tpmrunner
 
 import (
+	"crypto"
+	"crypto/rand"
 	"errors"
 	"log/slog"
 	"testing"
@@ -145,3 +147,54 @@ func Test_tpmRunner(t *testing.T) {
 		require.Equal(t, expectedInterrupts, receivedInterrupts)
 	})
 }
+
+func Test_tpmRunnerKeyStatus(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := echelper.GenerateEcdsaKey()
+	require.NoError(t, err)
+
+	fakePrivData, fakePubData := []byte("fake priv data"), []byte("fake pub data")
+
+	store := inmemory.NewStore()
+	tpmSignerCreatorMock := mocks.NewTpmSignerCreator(t)
+	tpmRunner, err := New(t.Context(), multislogger.NewNopLogger(), store, withTpmSignerCreator(tpmSignerCreatorMock))
+	require.NoError(t, err)
+	tpmRunner.machineHasTpm.Store(true)
+
+	tpmSignerCreatorMock.On("CreateKey").Return(fakePrivData, fakePubData, nil).Once()
+	tpmSignerCreatorMock.On("New", fakePrivData, fakePubData).Return(privKey, nil).Once()
+	require.NotNil(t, tpmRunner.Public())
+
+	// the fake public data is not a tpm public area, so decoding fails, but
+	// the timestamps are still reported
+	status, err := ReadKeyStatus(store)
+	require.Error(t, err)
+	require.True(t, status.Present)
+	require.WithinDuration(t, time.Now(), status.CreatedAt, 5*time.Second)
+	require.True(t, status.LastSignedAt.IsZero())
+
+	digest := make([]byte, 32)
+	_, err = tpmRunner.Sign(rand.Reader, digest, crypto.SHA256)
+	require.NoError(t, err)
+
+	status, _ = ReadKeyStatus(store)
+	require.WithinDuration(t, time.Now(), status.LastSignedAt, 5*time.Second)
+
+	// subsequent signs within the write interval are not persisted
+	require.NoError(t, store.Delete([]byte(keyLastSignedAt)))
+	_, err = tpmRunner.Sign(rand.Reader, digest, crypto.SHA256)
+	require.NoError(t, err)
+
+	status, _ = ReadKeyStatus(store)
+	require.True(t, status.LastSignedAt.IsZero())
+}
+
+func TestReadKeyStatus_NoKey(t *testing.T) {
+	t.Parallel()
+
+	status, err := ReadKeyStatus(inmemory.NewStore())
+	require.NoError(t, err)
+	require.False(t, status.Present)
+	require.True(t, status.CreatedAt.IsZero())
+}
@@ -91,6 +91,7 @@ type librarian interface {
 type TufAutoupdater struct {
 	metadataClient       *client.Client
 	libraryManager       librarian
+	updateDirectory      string // base directory of the update library, also holding the bad versions files
 	osqueryTimeout       time.Duration
 	knapsack             types.Knapsack
 	updateChannel        string
@@ -164,6 +165,7 @@ func NewTufAutoupdater(ctx context.Context, k types.Knapsack, metadataHttpClient
 	if updateDirectory == "" {
 		updateDirectory = DefaultLibraryDirectory(k.RootDirectory())
 	}
+	ta.updateDirectory = updateDirectory
 	ta.libraryManager, err = newUpdateLibraryManager(k.MirrorServerURL(), mirrorHttpClient, updateDirectory, k.Slogger())
 	if err != nil {
 		return nil, fmt.Errorf("could not init update library manager: %w", err)
@@ -671,6 +673,16 @@ func (ta *TufAutoupdater) downloadUpdate(binary autoupdatableBinary, targets dat
 		return "", nil
 	}
 
+	// Don't download or switch to a version that we've already rolled back from
+	if isBadVersion(binary, ta.updateDirectory, versionFromTarget(binary, target)) {
+		ta.slogger.Log(context.TODO(), slog.LevelWarn,
+			"not selecting update that was previously marked bad",
+			"binary", binary,
+			"target", target,
+		)
+		return "", nil
+	}
+
 	// If the release is already available in our update library, there's no need to perform a download --
 	// we can immediately return to load the newly-selected version.
 	if ta.libraryManager.Available(binary, target) {
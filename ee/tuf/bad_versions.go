@@ -0,0 +1,125 @@
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BadVersion is a version of a binary that failed in the field (for example, osqueryd
+// crash-looping on startup) and was rolled back. Bad versions are never selected from
+// the update library, and never downloaded again.
+type BadVersion struct {
+	Version  string    `json:"version"`
+	MarkedAt time.Time `json:"marked_at"`
+	Reason   string    `json:"reason"`
+}
+
+// badVersionsLock serializes read-modify-write cycles on the bad versions files.
+var badVersionsLock sync.Mutex
+
+// badVersionsPath returns the location of the bad versions file for the given binary. It
+// lives alongside, rather than inside, the binary's updates directory, so that it is not
+// mistaken for a version in the library.
+func badVersionsPath(binary autoupdatableBinary, baseUpdateDirectory string) string {
+	return filepath.Join(baseUpdateDirectory, fmt.Sprintf("%s-bad-versions.json", binary))
+}
+
+// MarkBadVersion records that the given version of the binary is bad, so that it will not be
+// selected or downloaded again. The baseUpdateDirectory is the update library root, as for
+// CheckOutLatest, and may be empty to use the default location under rootDirectory.
+func MarkBadVersion(binary autoupdatableBinary, rootDirectory, baseUpdateDirectory, version, reason string) error {
+	if baseUpdateDirectory == "" {
+		baseUpdateDirectory = DefaultLibraryDirectory(rootDirectory)
+	}
+	version = trimVersionString(version)
+
+	badVersionsLock.Lock()
+	defer badVersionsLock.Unlock()
+
+	badVersions, err := readBadVersions(binary, baseUpdateDirectory)
+	if err != nil {
+		return err
+	}
+
+	badVersions = slices.DeleteFunc(badVersions, func(b BadVersion) bool { return b.Version == version })
+	badVersions = append(badVersions, BadVersion{
+		Version:  version,
+		MarkedAt: time.Now().UTC(),
+		Reason:   reason,
+	})
+
+	raw, err := json.Marshal(badVersions)
+	if err != nil {
+		return fmt.Errorf("marshalling bad versions: %w", err)
+	}
+
+	if err := os.MkdirAll(baseUpdateDirectory, 0755); err != nil {
+		return fmt.Errorf("creating update directory: %w", err)
+	}
+
+	// Write to a temporary file and rename, so that readers never see a partial file
+	path := badVersionsPath(binary, baseUpdateDirectory)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0644); err != nil {
+		return fmt.Errorf("writing bad versions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replacing bad versions file: %w", err)
+	}
+
+	return nil
+}
+
+// BadVersions returns the versions of the binary that have been marked bad.
+func BadVersions(binary autoupdatableBinary, rootDirectory, baseUpdateDirectory string) ([]BadVersion, error) {
+	if baseUpdateDirectory == "" {
+		baseUpdateDirectory = DefaultLibraryDirectory(rootDirectory)
+	}
+
+	badVersionsLock.Lock()
+	defer badVersionsLock.Unlock()
+
+	return readBadVersions(binary, baseUpdateDirectory)
+}
+
+func readBadVersions(binary autoupdatableBinary, baseUpdateDirectory string) ([]BadVersion, error) {
+	raw, err := os.ReadFile(badVersionsPath(binary, baseUpdateDirectory))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading bad versions: %w", err)
+	}
+
+	var badVersions []BadVersion
+	if err := json.Unmarshal(raw, &badVersions); err != nil {
+		return nil, fmt.Errorf("parsing bad versions: %w", err)
+	}
+
+	return badVersions, nil
+}
+
+// isBadVersion reports whether the given version of the binary has been marked bad. If the
+// bad versions can't be read, no version is considered bad.
+func isBadVersion(binary autoupdatableBinary, baseUpdateDirectory, version string) bool {
+	if baseUpdateDirectory == "" {
+		return false
+	}
+
+	badVersionsLock.Lock()
+	defer badVersionsLock.Unlock()
+
+	badVersions, err := readBadVersions(binary, baseUpdateDirectory)
+	if err != nil {
+		return false
+	}
+
+	version = trimVersionString(version)
+	return slices.ContainsFunc(badVersions, func(b BadVersion) bool { return b.Version == version })
+}
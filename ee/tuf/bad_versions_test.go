@@ -0,0 +1,89 @@
+package tuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tufci "github.com/kolide/launcher/ee/tuf/ci"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkBadVersion(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+
+	// No bad versions yet
+	badVersions, err := BadVersions(binaryOsqueryd, rootDir, "")
+	require.NoError(t, err)
+	require.Empty(t, badVersions)
+	require.False(t, isBadVersion(binaryOsqueryd, DefaultLibraryDirectory(rootDir), "5.12.1"))
+
+	require.NoError(t, MarkBadVersion(binaryOsqueryd, rootDir, "", "5.12.1", "first"))
+	require.NoError(t, MarkBadVersion(binaryOsqueryd, rootDir, "", "osqueryd version 5.12.1", "second"))
+	require.NoError(t, MarkBadVersion(binaryOsqueryd, rootDir, "", "5.13.0", "third"))
+
+	// Marking the same version twice replaces the earlier entry
+	badVersions, err = BadVersions(binaryOsqueryd, rootDir, "")
+	require.NoError(t, err)
+	require.Len(t, badVersions, 2)
+	require.Equal(t, "5.12.1", badVersions[0].Version)
+	require.Equal(t, "second", badVersions[0].Reason)
+	require.Equal(t, "5.13.0", badVersions[1].Version)
+
+	require.True(t, isBadVersion(binaryOsqueryd, DefaultLibraryDirectory(rootDir), "5.12.1"))
+	require.False(t, isBadVersion(binaryLauncher, DefaultLibraryDirectory(rootDir), "5.12.1"), "bad versions are per-binary")
+
+	// The bad versions file must not be mistaken for a version in the library
+	versions, invalid, err := sortedVersionsInLibrary(t.Context(), multislogger.NewNopLogger(), binaryOsqueryd, DefaultLibraryDirectory(rootDir))
+	require.NoError(t, err)
+	require.Empty(t, versions)
+	require.Empty(t, invalid)
+}
+
+func TestCheckOutLatest_skipsBadVersions(t *testing.T) {
+	t.Parallel()
+
+	for _, binary := range binaries {
+		t.Run(string(binary), func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			updateDir := DefaultLibraryDirectory(rootDir)
+
+			// Set up a local TUF repo
+			tufDir := LocalTufDirectory(rootDir)
+			require.NoError(t, os.MkdirAll(tufDir, 488))
+			testReleaseVersion := "1.0.30"
+			tufci.SeedLocalTufRepo(t, testReleaseVersion, rootDir)
+
+			// Download the release version, and an older version
+			releasePath, _ := pathToTargetVersionExecutable(binary, fmt.Sprintf("%s-%s.tar.gz", binary, testReleaseVersion), updateDir)
+			previousPath, previousVersion := pathToTargetVersionExecutable(binary, fmt.Sprintf("%s-1.0.20.tar.gz", binary), updateDir)
+			for _, p := range []string{releasePath, previousPath} {
+				require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+				tufci.CopyBinary(t, p)
+				require.NoError(t, os.Chmod(p, 0755))
+			}
+
+			latest, err := CheckOutLatest(t.Context(), binary, rootDir, "", "", "nightly", multislogger.NewNopLogger())
+			require.NoError(t, err)
+			require.Equal(t, releasePath, latest.Path)
+
+			// Once the release is marked bad, we should fall back to the previous version
+			require.NoError(t, MarkBadVersion(binary, rootDir, "", testReleaseVersion, "test"))
+			latest, err = CheckOutLatest(t.Context(), binary, rootDir, "", "", "nightly", multislogger.NewNopLogger())
+			require.NoError(t, err)
+			require.Equal(t, previousPath, latest.Path)
+			require.Equal(t, previousVersion, latest.Version)
+
+			// With every version marked bad, there is nothing in the library to select
+			require.NoError(t, MarkBadVersion(binary, rootDir, "", previousVersion, "test"))
+			_, err = CheckOutLatest(t.Context(), binary, rootDir, "", "", "nightly", multislogger.NewNopLogger())
+			require.Error(t, err)
+		})
+	}
+}
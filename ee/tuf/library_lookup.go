@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/kolide/launcher/ee/agent/flags/keys"
@@ -261,6 +262,9 @@ func findExecutable(ctx context.Context, binary autoupdatableBinary, tufReposito
 	}
 
 	targetPath, targetVersion := pathToTargetVersionExecutable(binary, targetName, baseUpdateDirectory)
+	if isBadVersion(binary, baseUpdateDirectory, targetVersion) {
+		return nil, fmt.Errorf("version %s from target %s has been marked bad", targetVersion, targetName)
+	}
 	if _, err := os.Stat(targetPath); err != nil && errors.Is(err, os.ErrNotExist) {
 		observability.SetError(span, err)
 		return nil, fmt.Errorf("version %s from target %s at %s is either originally installed version or not yet downloaded", targetVersion, targetName, targetPath)
@@ -293,9 +297,16 @@ func mostRecentVersion(ctx context.Context, slogger *slog.Logger, binary autoupd
 		return nil, fmt.Errorf("no versions of %s in library at %s", binary, baseUpdateDirectory)
 	}
 
+	// Versions are sorted in ascending order -- return the last one that hasn't been marked bad
+	validVersionsInLibrary = slices.DeleteFunc(validVersionsInLibrary, func(v string) bool {
+		return isBadVersion(binary, baseUpdateDirectory, v)
+	})
+	if len(validVersionsInLibrary) < 1 {
+		return nil, fmt.Errorf("all versions of %s in library at %s have been marked bad", binary, baseUpdateDirectory)
+	}
+
 	span.AddEvent("found_latest_from_library")
 
-	// Versions are sorted in ascending order -- return the last one
 	mostRecentVersionInLibraryRaw := validVersionsInLibrary[len(validVersionsInLibrary)-1]
 
 	// We rolled out TUF more broadly beginning in v1.4.1. Don't select versions earlier than that.
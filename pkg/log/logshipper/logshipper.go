@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -41,6 +42,8 @@ type LogShipper struct {
 	knapsack            types.Knapsack
 	stopFunc            context.CancelFunc
 	stopFuncMutex       sync.Mutex
+	stopped             bool // guarded by stopFuncMutex
+	restarting          atomic.Bool
 	isShippingStarted   bool
 	slogLevel           *slog.LevelVar
 	additionalSlogAttrs []slog.Attr
@@ -136,24 +139,65 @@ func (ls *LogShipper) Run() error {
 		"starting log shipping",
 	)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
 
-	ls.stopFuncMutex.Lock()
-	ls.stopFunc = cancel
-	ls.stopFuncMutex.Unlock()
+		ls.stopFuncMutex.Lock()
+		if ls.stopped {
+			ls.stopFuncMutex.Unlock()
+			cancel()
+			return nil
+		}
+		ls.stopFunc = cancel
+		ls.stopFuncMutex.Unlock()
+
+		err := ls.sendBuffer.Run(ctx)
+		cancel()
+
+		// If the send buffer was stopped for a restart, rather than by Stop, run it again
+		if !ls.restarting.Swap(false) {
+			return err
+		}
 
-	return ls.sendBuffer.Run(ctx)
+		ls.knapsack.Slogger().Log(context.Background(), slog.LevelInfo,
+			"restarting log shipping",
+		)
+	}
 }
 
 func (ls *LogShipper) Stop(_ error) {
 	ls.stopFuncMutex.Lock()
 	defer ls.stopFuncMutex.Unlock()
 
+	ls.stopped = true
 	if ls.stopFunc != nil {
 		ls.stopFunc()
 	}
 }
 
+// Restart refreshes the log shipper's auth token, ingest URL, and shipping level, then
+// flushes and restarts the send buffer.
+func (ls *LogShipper) Restart(ctx context.Context) error {
+	ls.stopFuncMutex.Lock()
+	defer ls.stopFuncMutex.Unlock()
+
+	if ls.stopped {
+		return errors.New("log shipper is stopped")
+	}
+
+	ls.Ping()
+
+	// If we haven't started shipping yet, there's nothing else to restart
+	if ls.stopFunc == nil {
+		return nil
+	}
+
+	ls.restarting.Store(true)
+	ls.stopFunc()
+
+	return nil
+}
+
 func (ls *LogShipper) Log(keyvals ...any) error {
 	filterResults(keyvals...)
 	return ls.shippingLogger.Log(keyvals...)
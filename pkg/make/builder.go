@@ -11,6 +11,8 @@ package make //nolint:predeclared
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +28,7 @@ import (
 	"github.com/Masterminds/semver"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/kolide/krypto/pkg/echelper"
 	"github.com/kolide/launcher/pkg/contexts/ctxlog"
 )
 
@@ -42,6 +45,8 @@ type Builder struct {
 	cgo                bool
 	githubActionOutput bool
 
+	controlSigningKeysPath string
+
 	cmdEnv []string
 	execCC func(context.Context, string, ...string) *exec.Cmd
 }
@@ -108,6 +113,14 @@ func WithGithubActionOutput() Option {
 	}
 }
 
+// WithControlSigningKeys embeds the control payload signing keyset at the given path as
+// launcher's pinned keys. The keyset is validated before building.
+func WithControlSigningKeys(path string) Option {
+	return func(b *Builder) {
+		b.controlSigningKeysPath = path
+	}
+}
+
 func New(opts ...Option) *Builder {
 	b := Builder{
 		os:     runtime.GOOS,
@@ -349,6 +362,14 @@ func (b *Builder) BuildCmd(src, appName string) func(context.Context) error {
 			ldFlags = append(ldFlags, fmt.Sprintf(`-X "github.com/kolide/kit/version.goVersion=%s"`, runtime.Version()))
 		}
 
+		if b.controlSigningKeysPath != "" {
+			keysFlag, err := controlSigningKeysLdFlag(b.controlSigningKeysPath)
+			if err != nil {
+				return fmt.Errorf("embedding control signing keys: %w", err)
+			}
+			ldFlags = append(ldFlags, keysFlag)
+		}
+
 		if len(ldFlags) != 0 {
 			baseArgs = append(baseArgs, fmt.Sprintf("--ldflags=%s", strings.Join(ldFlags, " ")))
 		}
@@ -489,3 +510,42 @@ func (b *Builder) execOut(ctx context.Context, argv0 string, args ...string) (st
 	}
 	return strings.TrimSpace(stdout.String()), nil
 }
+
+// controlSigningKeysLdFlag validates the control payload signing keyset at the given path, and
+// returns the ldflag that embeds it as launcher's pinned keys (see ee/control/payload_signing.go).
+// The keyset must have a positive version and at least one valid ECDSA public key, so that a
+// release build can't accidentally ship without trusted keys.
+func controlSigningKeysLdFlag(path string) (string, error) {
+	rawKeyset, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading keyset: %w", err)
+	}
+
+	var keyset struct {
+		Version int `json:"version"`
+		Keys    []struct {
+			ID        string `json:"id"`
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(rawKeyset, &keyset); err != nil {
+		return "", fmt.Errorf("unmarshalling keyset: %w", err)
+	}
+
+	if keyset.Version < 1 {
+		return "", fmt.Errorf("keyset version must be positive, got %d", keyset.Version)
+	}
+	if len(keyset.Keys) == 0 {
+		return "", errors.New("keyset has no keys")
+	}
+	for _, k := range keyset.Keys {
+		if k.ID == "" {
+			return "", errors.New("keyset contains key with no ID")
+		}
+		if _, err := echelper.PublicB64DerToEcdsaKey([]byte(k.PublicKey)); err != nil {
+			return "", fmt.Errorf("parsing key %s: %w", k.ID, err)
+		}
+	}
+
+	return fmt.Sprintf(`-X "github.com/kolide/launcher/ee/control.pinnedSigningKeysB64=%s"`, base64.StdEncoding.EncodeToString(rawKeyset)), nil
+}
@@ -2,6 +2,9 @@ package make //nolint:predeclared
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/go-kit/kit/log"
+	"github.com/kolide/krypto/pkg/echelper"
 	"github.com/stretchr/testify/require"
 )
 
@@ -109,6 +113,46 @@ func TestGoVersionCompatible(t *testing.T) {
 	}
 }
 
+func TestControlSigningKeysLdFlag(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := echelper.PublicEcdsaToB64Der(&key.PublicKey)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name   string
+		keyset string
+		passes bool
+	}{
+		{name: "valid", keyset: fmt.Sprintf(`{"version":1,"keys":[{"id":"k1","public_key":"%s"}]}`, pub), passes: true},
+		{name: "checked-in empty keyset", keyset: `{"version":0,"keys":[]}`},
+		{name: "no keys", keyset: `{"version":1,"keys":[]}`},
+		{name: "missing id", keyset: fmt.Sprintf(`{"version":1,"keys":[{"public_key":"%s"}]}`, pub)},
+		{name: "bad key", keyset: `{"version":1,"keys":[{"id":"k1","public_key":"bm90IGEga2V5"}]}`},
+		{name: "not json", keyset: `version 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "keys.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.keyset), 0644))
+
+			ldFlag, err := controlSigningKeysLdFlag(path)
+			if !tt.passes {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Contains(t, ldFlag, "github.com/kolide/launcher/ee/control.pinnedSigningKeysB64=")
+		})
+	}
+}
+
 func TestDepsGo(t *testing.T) {
 	t.Parallel()
 	ctx, cancel := context.WithCancel(t.Context())
@@ -17,9 +17,11 @@ import (
 	"github.com/kolide/launcher/ee/agent/flags/keys"
 	"github.com/kolide/launcher/ee/agent/storage"
 	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/logchunk"
 	"github.com/kolide/launcher/ee/observability"
 	"github.com/kolide/launcher/ee/uninstall"
 	"github.com/kolide/launcher/pkg/backoff"
+	"github.com/kolide/launcher/pkg/osquery/localpacks"
 	"github.com/kolide/launcher/pkg/service"
 	"github.com/osquery/osquery-go/plugin/distributed"
 	"github.com/osquery/osquery-go/plugin/logger"
@@ -55,8 +57,10 @@ type Extension struct {
 	slogger                       *slog.Logger
 	logPublicationState           *logPublicationState
 	lastRequestQueriesTimestamp   *atomic.Int64
-	distributedForwardingInterval *atomic.Int64 // how frequently to forward RequestQueries requests to the cloud, in seconds
-	forwardAllDistributedUntil    *atomic.Int64 // allows for accelerated distributed requests until given timestamp
+	distributedForwardingInterval *atomic.Int64       // how frequently to forward RequestQueries requests to the cloud, in seconds
+	forwardAllDistributedUntil    *atomic.Int64       // allows for accelerated distributed requests until given timestamp
+	sandboxInFlight               map[string]struct{} // names of queries handed to the sandbox runner whose results aren't written yet
+	sandboxInFlightLock           sync.Mutex
 }
 
 const (
@@ -70,6 +74,11 @@ const (
 	// limit specified in https://github.com/grpc/grpc-go/blob/master/server.go#L51
 	// which is 4MB. We use 3MB to be conservative.
 	defaultMaxBytesPerBatch = 3 << 20
+	// maxChunkedLogBytes is the largest log that will be split into chunks for publication.
+	// Anything larger is dropped.
+	maxChunkedLogBytes = 32 << 20
+	// minChunkedBatchBytes is the smallest batch threshold that chunks can fit into.
+	minChunkedBatchBytes = 1024
 	// Default logging interval (used if not specified in
 	// options)
 	defaultLoggingInterval = 60 * time.Second
@@ -104,7 +113,8 @@ var (
 // ExtensionOpts is options to be passed in NewExtension
 type ExtensionOpts struct {
 	// MaxBytesPerBatch is the maximum number of bytes that should be sent in
-	// one batch logging request. Any log larger than this will be dropped.
+	// one batch logging request. Any log larger than this will be split into
+	// chunks, and published over several requests.
 	MaxBytesPerBatch int
 	// LoggingInterval is the interval at which logs should be flushed to
 	// the server.
@@ -115,6 +125,9 @@ type ExtensionOpts struct {
 	// RunDifferentialQueriesImmediately allows the client to execute a new query the first time it sees it,
 	// bypassing the scheduler.
 	RunDifferentialQueriesImmediately bool
+	// SandboxRunner, if set, runs distributed queries designated as heavy in a separate
+	// osquery instance. See IsSandboxedQuery.
+	SandboxRunner SandboxQueryRunner
 }
 
 type iterationTerminatedError struct{}
@@ -487,8 +500,14 @@ func (e *Extension) GenerateConfigs(ctx context.Context) (map[string]string, err
 
 		if len(confBytes) == 0 {
 			if !e.enrolled() {
-				// Not enrolled yet -- return an empty config
-				return map[string]string{"config": "{}"}, nil
+				// Not enrolled yet -- return an empty config, plus any local packs
+				config, _ := e.mergeLocalPacks(ctx, "{}")
+				return map[string]string{"config": config}, nil
+			}
+
+			// Hosts that have never reached the server can still run local packs
+			if config, loaded := e.mergeLocalPacks(ctx, "{}"); loaded > 0 {
+				return map[string]string{"config": config}, nil
 			}
 			return nil, fmt.Errorf("loading config failed, no cached config: %w", err)
 		}
@@ -509,9 +528,55 @@ func (e *Extension) GenerateConfigs(ctx context.Context) (map[string]string, err
 		}
 	}
 
+	// Local packs are merged after caching, so that the cached config is always
+	// exactly what the server sent.
+	config, _ = e.mergeLocalPacks(ctx, config)
+
 	return map[string]string{"config": config}, nil
 }
 
+// mergeLocalPacks merges any packs in the local pack directory into config, returning
+// the merged config and the number of packs merged. Problems with the packs are logged,
+// and never prevent the rest of the config from being used.
+func (e *Extension) mergeLocalPacks(ctx context.Context, config string) (string, int) {
+	packs, invalid := localpacks.Load(localpacks.Dir(e.knapsack.RootDirectory()))
+	for _, status := range invalid {
+		e.slogger.Log(ctx, slog.LevelWarn,
+			"skipping invalid local pack",
+			"path", status.Path,
+			"err", status.Error,
+		)
+	}
+	if len(packs) == 0 {
+		return config, 0
+	}
+
+	merged, statuses, err := localpacks.Merge(config, packs)
+	if err != nil {
+		e.slogger.Log(ctx, slog.LevelWarn,
+			"could not merge local packs into config",
+			"err", err,
+		)
+		return config, 0
+	}
+
+	loaded := 0
+	for _, status := range statuses {
+		if status.State != localpacks.StateLoaded {
+			e.slogger.Log(ctx, slog.LevelInfo,
+				"local pack not loaded",
+				"path", status.Path,
+				"state", status.State,
+				"reason", status.Error,
+			)
+			continue
+		}
+		loaded++
+	}
+
+	return merged, loaded
+}
+
 // TODO: https://github.com/kolide/launcher/issues/366
 var reenrollmentInvalidErr = errors.New("enrollment invalid, reenrollment invalid")
 
@@ -720,26 +785,40 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 	// Collect up logs to be sent
 	var logs []string
 	var logIDs [][]byte
+	var oversized *bufferedLog
 	bufferFilled := false
 	totalBytes := 0
 	err = store.ForEach(func(k, v []byte) error {
+		// Note the logID for deletion. We do this by
+		// making a copy of k. It is retained in
+		// logIDs after the transaction is closed,
+		// when the goroutine ticks it zeroes out some
+		// of the IDs to delete below, causing logs to
+		// remain in the buffer and be sent again to
+		// the server.
+		logID := make([]byte, len(k))
+		copy(logID, k)
+
 		// A somewhat cumbersome if block...
 		//
-		// 1. If the log is too big, skip it and mark for deletion.
+		// 1. If the log is too big, set it aside to be sent in chunks. If it
+		//    is too big even for that, skip it and mark for deletion.
 		// 2. If the buffer would be too big with the log, break for
 		// 3. Else append it
 		//
 		// Note that (1) must come first, otherwise (2) will always trigger.
 		if e.logPublicationState.ExceedsCurrentBatchThreshold(len(v)) {
+			if len(v) <= maxChunkedLogBytes && e.logPublicationState.CurrentBatchThreshold() >= minChunkedBatchBytes {
+				// Only one oversized log is chunked per call, to bound memory use.
+				// Any others are picked up on later calls.
+				if oversized == nil {
+					oversized = &bufferedLog{id: logID, log: string(v)}
+				}
+				return nil
+			}
+
 			// Discard logs that are too big
-			logheadSize := minInt(len(v), 100)
-			e.slogger.Log(context.TODO(), slog.LevelInfo,
-				"dropped log",
-				"log_id", k,
-				"size", len(v),
-				"limit", e.Opts.MaxBytesPerBatch,
-				"loghead", string(v)[0:logheadSize],
-			)
+			e.dropLog(typ, k, v)
 		} else if e.logPublicationState.ExceedsCurrentBatchThreshold(totalBytes + len(v)) {
 			// Buffer is filled. Break the loop and come back later.
 			return iterationTerminatedError{}
@@ -748,15 +827,6 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 			totalBytes += len(v)
 		}
 
-		// Note the logID for deletion. We do this by
-		// making a copy of k. It is retained in
-		// logIDs after the transaction is closed,
-		// when the goroutine ticks it zeroes out some
-		// of the IDs to delete below, causing logs to
-		// remain in the buffer and be sent again to
-		// the server.
-		logID := make([]byte, len(k))
-		copy(logID, k)
 		logIDs = append(logIDs, logID)
 		return nil
 	})
@@ -767,11 +837,31 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 		return fmt.Errorf("reading buffered logs: %w", err)
 	}
 
-	if len(logs) == 0 {
-		// Nothing to send
-		return nil
+	if len(logs) > 0 {
+		if err := e.writeLogBatch(typ, store, logs, logIDs, bufferFilled); err != nil {
+			return err
+		}
+	}
+
+	// The oversized log goes after the regular batch, and failures are only logged, so that
+	// a log we can't publish doesn't hold up all the others. It stays in the store to be
+	// retried on a later call.
+	if oversized != nil {
+		if err := e.writeChunkedLog(typ, store, *oversized); err != nil {
+			e.slogger.Log(context.TODO(), slog.LevelWarn,
+				"could not publish oversized log in chunks, will retry",
+				"log_type", typ.String(),
+				"size", len(oversized.log),
+				"err", err,
+			)
+		}
 	}
 
+	return nil
+}
+
+// writeLogBatch publishes a batch of buffered logs, then deletes the given log IDs from the store.
+func (e *Extension) writeLogBatch(typ logger.LogType, store types.KVStore, logs []string, logIDs [][]byte, bufferFilled bool) error {
 	// inform the publication state tracking whether this batch should be used to
 	// determine the appropriate limit
 	e.logPublicationState.BeginBatch(time.Now(), bufferFilled)
@@ -779,7 +869,7 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 		service.PublicationCtxKey,
 		e.logPublicationState.CurrentValues(),
 	)
-	err = e.writeLogsWithReenroll(publicationCtx, typ, logs, true)
+	err := e.writeLogsWithReenroll(publicationCtx, typ, logs, true)
 	if err != nil {
 		return fmt.Errorf("writing logs: %w", err)
 	}
@@ -802,6 +892,67 @@ func (e *Extension) writeBufferedLogsForType(typ logger.LogType) error {
 	return nil
 }
 
+type bufferedLog struct {
+	id  []byte
+	log string
+}
+
+// dropLog records a log that is too large to be published at all.
+func (e *Extension) dropLog(typ logger.LogType, k, v []byte) {
+	logheadSize := minInt(len(v), 100)
+	e.slogger.Log(context.TODO(), slog.LevelInfo,
+		"dropped log",
+		"log_id", k,
+		"log_type", typ.String(),
+		"size", len(v),
+		"limit", e.Opts.MaxBytesPerBatch,
+		"loghead", string(v)[0:logheadSize],
+	)
+	observability.OsqueryLogDroppedCounter.Add(context.TODO(), 1)
+}
+
+// writeChunkedLog publishes a log that is larger than the current batch threshold as
+// a series of chunks, one per request, for the server to reassemble. The log is only
+// removed from the store once every chunk has been sent. If publication fails partway
+// through, the whole log is sent again later, and the server deduplicates by chunk id.
+func (e *Extension) writeChunkedLog(typ logger.LogType, store types.KVStore, bl bufferedLog) error {
+	chunks, err := logchunk.Split(bl.log, e.logPublicationState.CurrentBatchThreshold())
+	if err != nil {
+		return fmt.Errorf("splitting log: %w", err)
+	}
+
+	for _, chunk := range chunks {
+		e.logPublicationState.BeginBatch(time.Now(), false)
+		publicationCtx := context.WithValue(context.Background(),
+			service.PublicationCtxKey,
+			e.logPublicationState.CurrentValues(),
+		)
+		if err := e.writeLogsWithReenroll(publicationCtx, typ, []string{chunk}, true); err != nil {
+			return fmt.Errorf("writing chunk: %w", err)
+		}
+
+		// for now, also attempt to publish logs to agent-ingester if configured to do so.
+		if _, err := e.logPublishClient.PublishLogs(publicationCtx, typ, []string{chunk}); err != nil {
+			e.slogger.Log(publicationCtx, slog.LevelError, "encountered error publishing chunked log",
+				"err", err,
+			)
+		}
+	}
+
+	e.slogger.Log(context.TODO(), slog.LevelDebug,
+		"published oversized log in chunks",
+		"log_type", typ.String(),
+		"size", len(bl.log),
+		"chunk_count", len(chunks),
+	)
+
+	if err := store.Delete(bl.id); err != nil {
+		return fmt.Errorf("deleting sent log: %w", err)
+	}
+
+	return nil
+}
+
 // Helper to allow for a single attempt at re-enrollment
 func (e *Extension) writeLogsWithReenroll(ctx context.Context, typ logger.LogType, logs []string, reenroll bool) error {
 	// grab a reference to the existing nodekey to prevent data races with any re-enrollments
@@ -953,7 +1104,7 @@ func (e *Extension) GetQueries(ctx context.Context) (*distributed.GetQueriesResu
 		e.forwardAllDistributedUntil.Store(time.Now().Unix() + int64(queries.AccelerateSeconds))
 	}
 
-	return queries, nil
+	return e.dispatchSandboxedQueries(ctx, queries), nil
 }
 
 // Helper to allow for a single attempt at re-enrollment
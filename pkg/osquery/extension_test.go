@@ -10,8 +10,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"testing/quick"
@@ -25,8 +30,10 @@ import (
 	"github.com/kolide/launcher/ee/agent/storage/inmemory"
 	"github.com/kolide/launcher/ee/agent/types"
 	"github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/logchunk"
 	"github.com/kolide/launcher/ee/osquerypublisher"
 	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/kolide/launcher/pkg/osquery/localpacks"
 	settingsstoremock "github.com/kolide/launcher/pkg/osquery/mocks"
 	"github.com/kolide/launcher/pkg/osquery/runtime/history"
 	"github.com/kolide/launcher/pkg/service"
@@ -405,6 +412,81 @@ func TestExtensionGenerateConfigsTransportError(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestExtensionGenerateConfigsLocalPacks(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(localpacks.Dir(rootDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(localpacks.Dir(rootDir), "compliance.conf"),
+		[]byte(`{"queries":{"os_version":{"query":"select * from os_version;","interval":3600}}}`), 0644))
+
+	k := mocks.NewKnapsack(t)
+	k.On("OsquerydPath").Maybe().Return("")
+	k.On("LatestOsquerydPath", testifymock.Anything).Maybe().Return("")
+	configStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String())
+	require.NoError(t, err)
+	k.On("ConfigStore").Return(configStore)
+	k.On("Slogger").Return(multislogger.NewNopLogger())
+	k.On("RootDirectory").Return(rootDir)
+	k.On("DistributedForwardingInterval").Maybe().Return(60 * time.Second)
+	k.On("RegisterChangeObserver", testifymock.Anything, testifymock.Anything).Maybe().Return()
+	k.On("DeregisterChangeObserver", testifymock.Anything).Maybe().Return()
+	k.On("NodeKey", testifymock.Anything).Return(ulid.New(), nil).Maybe()
+	k.On("OsqueryPublisherPercentEnabled").Return(0).Maybe()
+	k.On("OsqueryPublisherURL").Return("").Maybe()
+	tokenStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.TokenStore.String())
+	require.NoError(t, err)
+	k.On("TokenStore").Return(tokenStore).Maybe()
+	osqHistory, err := history.InitHistory(inmemory.NewStore())
+	require.NoError(t, err)
+	k.On("OsqueryHistory").Return(osqHistory).Maybe()
+	k.On("UseCachedDataForScheduledQueries").Return(true).Maybe()
+
+	serverConfig := `{"options":{"verbose":true},"packs":{"server":{"queries":{"q":{"query":"select 1;","interval":60}}}}}`
+	reachable := true
+	m := &mock.KolideService{
+		RequestConfigFunc: func(ctx context.Context, nodeKey string) (string, bool, error) {
+			if !reachable {
+				return "", false, errors.New("transport")
+			}
+			return serverConfig, false, nil
+		},
+	}
+	s := settingsstoremock.NewSettingsStoreWriter(t)
+	s.On("WriteSettings").Return(nil).Maybe()
+	e, err := NewExtension(t.Context(), m, makeTestOsqLogPublisher(k), s, k, types.DefaultEnrollmentID, ExtensionOpts{})
+	require.Nil(t, err)
+
+	requirePacks := func(configs map[string]string, expected ...string) {
+		var parsed struct {
+			Packs map[string]json.RawMessage `json:"packs"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(configs["config"]), &parsed))
+		require.ElementsMatch(t, expected, slices.Collect(maps.Keys(parsed.Packs)))
+	}
+
+	// With the server reachable, local packs are merged into its config, but only
+	// the server's config is cached
+	configs, err := e.GenerateConfigs(t.Context())
+	require.NoError(t, err)
+	requirePacks(configs, "server", "compliance")
+	cached, err := Config(configStore, types.DefaultEnrollmentID)
+	require.NoError(t, err)
+	require.NotContains(t, cached, "compliance")
+
+	// With the server unreachable, local packs are merged into the cached config
+	reachable = false
+	configs, err = e.GenerateConfigs(t.Context())
+	require.NoError(t, err)
+	requirePacks(configs, "server", "compliance")
+
+	// With no cached config at all, local packs run alone
+	require.NoError(t, configStore.Delete(storage.KeyByIdentifier([]byte(configKey), storage.IdentifierTypeEnrollment, []byte(types.DefaultEnrollmentID))))
+	configs, err = e.GenerateConfigs(t.Context())
+	require.NoError(t, err)
+	requirePacks(configs, "compliance")
+}
+
 func TestExtensionGenerateConfigsCaching(t *testing.T) {
 	configVal := `{"foo":"bar","options":{"distributed_interval":5,"verbose":true}}`
 	m := &mock.KolideService{
@@ -480,6 +562,7 @@ func TestGenerateConfigs_CannotEnrollYet(t *testing.T) {
 	}
 
 	k := mocks.NewKnapsack(t)
+	k.On("RootDirectory").Maybe().Return(t.TempDir())
 	k.On("OsquerydPath").Maybe().Return("")
 	k.On("LatestOsquerydPath", testifymock.Anything).Maybe().Return("")
 	k.On("ConfigStore").Return(storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String()))
@@ -555,6 +638,7 @@ func TestGenerateConfigs_WorksAfterSecretlessEnrollment(t *testing.T) {
 	}
 
 	k := mocks.NewKnapsack(t)
+	k.On("RootDirectory").Maybe().Return(t.TempDir())
 	k.On("OsquerydPath").Maybe().Return("")
 	k.On("LatestOsquerydPath", testifymock.Anything).Maybe().Return("")
 	configStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String())
@@ -1173,6 +1257,141 @@ func TestExtensionWriteBufferedLogsDropsBigLog(t *testing.T) {
 	require.Equal(t, 0, finalLogCount, "no more queued logs")
 }
 
+func TestExtensionWriteBufferedLogsChunksBigLog(t *testing.T) {
+	expectedNodeKey := ulid.New()
+
+	var publishedLogs []string
+	m := &mock.KolideService{
+		PublishLogsFunc: func(ctx context.Context, nodeKey string, logType logger.LogType, logs []string) (string, string, bool, error) {
+			require.Equal(t, expectedNodeKey, nodeKey)
+			require.Equal(t, logger.LogTypeString, logType)
+			publishedLogs = append(publishedLogs, logs...)
+			return "", "", false, nil
+		},
+	}
+
+	k := mocks.NewKnapsack(t)
+	k.On("OsquerydPath").Maybe().Return("")
+	k.On("LatestOsquerydPath", testifymock.Anything).Maybe().Return("")
+	k.On("ConfigStore").Return(storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String()))
+	k.On("Slogger").Return(multislogger.NewNopLogger())
+	k.On("ReadEnrollSecret").Maybe().Return("enroll_secret", nil)
+	k.On("RootDirectory").Maybe().Return("whatever")
+	k.On("DistributedForwardingInterval").Maybe().Return(60 * time.Second)
+	k.On("RegisterChangeObserver", testifymock.Anything, testifymock.Anything).Maybe().Return()
+	k.On("DeregisterChangeObserver", testifymock.Anything).Maybe().Return()
+	k.On("OsqueryPublisherPercentEnabled").Return(0).Maybe()
+	k.On("OsqueryPublisherURL").Return("").Maybe()
+	tokenStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.TokenStore.String())
+	require.NoError(t, err)
+	k.On("TokenStore").Return(tokenStore).Maybe()
+	lpc := makeTestOsqLogPublisher(k)
+	store := inmemory.NewStore()
+	osqHistory, err := history.InitHistory(store)
+	require.NoError(t, err)
+	k.On("OsqueryHistory").Return(osqHistory).Maybe()
+	k.On("UseCachedDataForScheduledQueries").Return(true).Maybe()
+	k.On("GetEnrollmentDetails").Return(types.EnrollmentDetails{OSVersion: "1", Hostname: "test"}, nil).Maybe()
+	k.On("NodeKey", testifymock.Anything).Return(expectedNodeKey, nil)
+
+	resultLogsStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.ResultLogsStore.String())
+	require.NoError(t, err)
+	k.On("ResultLogsStore").Return(resultLogsStore)
+
+	e, err := NewExtension(t.Context(), m, lpc, settingsstoremock.NewSettingsStoreWriter(t), k, ulid.New(), ExtensionOpts{
+		MaxBytesPerBatch: 1024,
+	})
+	require.Nil(t, err)
+
+	bigLog := `{"name":"big","columns":{"data":"` + strings.Repeat("0123456789", 500) + `"}}`
+	e.LogString(t.Context(), logger.LogTypeString, "res1")
+	e.LogString(t.Context(), logger.LogTypeString, bigLog)
+	e.LogString(t.Context(), logger.LogTypeString, "res2")
+
+	// The regular batch is sent, followed by the big log in chunks
+	require.NoError(t, e.writeBufferedLogsForType(logger.LogTypeString))
+
+	reassembler := logchunk.NewReassembler()
+	var received []string
+	for _, published := range publishedLogs {
+		require.LessOrEqual(t, len(published), 1024)
+		log, ok, err := reassembler.Add(published)
+		require.NoError(t, err)
+		if ok {
+			received = append(received, log)
+		}
+	}
+	require.Equal(t, []string{"res1", "res2", bigLog}, received)
+
+	finalLogCount, err := e.knapsack.ResultLogsStore().Count()
+	require.NoError(t, err)
+	require.Equal(t, 0, finalLogCount, "no more queued logs")
+}
+
+func TestExtensionWriteBufferedLogsChunkFailureDoesNotBlockBatch(t *testing.T) {
+	expectedNodeKey := ulid.New()
+
+	var publishedLogs []string
+	m := &mock.KolideService{
+		PublishLogsFunc: func(ctx context.Context, nodeKey string, logType logger.LogType, logs []string) (string, string, bool, error) {
+			require.Equal(t, expectedNodeKey, nodeKey)
+			require.Equal(t, logger.LogTypeString, logType)
+			if _, isChunk := logchunk.Parse(logs[0]); isChunk {
+				return "", "", false, errors.New("test error publishing chunk")
+			}
+			publishedLogs = append(publishedLogs, logs...)
+			return "", "", false, nil
+		},
+	}
+
+	k := mocks.NewKnapsack(t)
+	k.On("OsquerydPath").Maybe().Return("")
+	k.On("LatestOsquerydPath", testifymock.Anything).Maybe().Return("")
+	k.On("ConfigStore").Return(storageci.NewStore(t, multislogger.NewNopLogger(), storage.ConfigStore.String()))
+	k.On("Slogger").Return(multislogger.NewNopLogger())
+	k.On("ReadEnrollSecret").Maybe().Return("enroll_secret", nil)
+	k.On("RootDirectory").Maybe().Return("whatever")
+	k.On("DistributedForwardingInterval").Maybe().Return(60 * time.Second)
+	k.On("RegisterChangeObserver", testifymock.Anything, testifymock.Anything).Maybe().Return()
+	k.On("DeregisterChangeObserver", testifymock.Anything).Maybe().Return()
+	k.On("OsqueryPublisherPercentEnabled").Return(0).Maybe()
+	k.On("OsqueryPublisherURL").Return("").Maybe()
+	tokenStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.TokenStore.String())
+	require.NoError(t, err)
+	k.On("TokenStore").Return(tokenStore).Maybe()
+	lpc := makeTestOsqLogPublisher(k)
+	store := inmemory.NewStore()
+	osqHistory, err := history.InitHistory(store)
+	require.NoError(t, err)
+	k.On("OsqueryHistory").Return(osqHistory).Maybe()
+	k.On("UseCachedDataForScheduledQueries").Return(true).Maybe()
+	k.On("GetEnrollmentDetails").Return(types.EnrollmentDetails{OSVersion: "1", Hostname: "test"}, nil).Maybe()
+	k.On("NodeKey", testifymock.Anything).Return(expectedNodeKey, nil)
+
+	resultLogsStore, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.ResultLogsStore.String())
+	require.NoError(t, err)
+	k.On("ResultLogsStore").Return(resultLogsStore)
+
+	e, err := NewExtension(t.Context(), m, lpc, settingsstoremock.NewSettingsStoreWriter(t), k, ulid.New(), ExtensionOpts{
+		MaxBytesPerBatch: 1024,
+	})
+	require.Nil(t, err)
+
+	bigLog := `{"name":"big","columns":{"data":"` + strings.Repeat("0123456789", 500) + `"}}`
+	e.LogString(t.Context(), logger.LogTypeString, "res1")
+	e.LogString(t.Context(), logger.LogTypeString, bigLog)
+	e.LogString(t.Context(), logger.LogTypeString, "res2")
+
+	// The regular batch is still sent when the big log can't be
+	require.NoError(t, e.writeBufferedLogsForType(logger.LogTypeString))
+	require.Equal(t, []string{"res1", "res2"}, publishedLogs)
+
+	// The big log remains queued, to be retried
+	finalLogCount, err := e.knapsack.ResultLogsStore().Count()
+	require.NoError(t, err)
+	require.Equal(t, 1, finalLogCount, "big log still queued")
+}
+
 func TestExtensionWriteLogsLoop(t *testing.T) {
 	expectedNodeKey := ulid.New()
 	var gotStatusLogs, gotResultLogs []string
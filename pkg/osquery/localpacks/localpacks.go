@@ -0,0 +1,270 @@
+// Package localpacks loads osquery packs from a directory on local disk, so that
+// hosts that cannot reach the server can still run scheduled queries.
+//
+// Each file in the directory named <name>.conf holds a single osquery pack, in
+// the same format as a pack in the osquery config. The pack is named for the file.
+// Packs are merged into the config from the server (or the cached config, when the
+// server is unreachable) under these rules:
+//
+//   - The server's config always takes precedence. A local pack whose name matches
+//     a pack in the server's config is skipped, and reported as shadowed.
+//   - Local packs only add to the config's packs; they never change any other part
+//     of the config, such as the schedule or options.
+//   - A file that cannot be parsed or fails validation is skipped, and reported as
+//     invalid. Other files are unaffected.
+package localpacks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// DirName is the name of the local pack directory inside the launcher root directory.
+	DirName = "packs.d"
+
+	fileExtension = ".conf"
+
+	// maxFileBytes bounds the size of a single pack file.
+	maxFileBytes = 1 << 20
+)
+
+// States a pack file can be in.
+const (
+	StateLoaded   = "loaded"
+	StateInvalid  = "invalid"
+	StateShadowed = "shadowed"
+)
+
+var validPackName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Pack is a validated pack loaded from disk.
+type Pack struct {
+	Name       string
+	Path       string
+	QueryCount int
+	raw        json.RawMessage
+}
+
+// Status describes the outcome of loading and merging a single pack file.
+type Status struct {
+	Path       string
+	Name       string
+	State      string
+	QueryCount int
+	Error      string
+}
+
+// Dir returns the local pack directory for the given launcher root directory.
+func Dir(rootDirectory string) string {
+	return filepath.Join(rootDirectory, DirName)
+}
+
+// Load reads and validates every pack file in dir, in lexical order. It returns the
+// valid packs, and a status for every invalid file. A missing directory holds no packs.
+func Load(dir string) ([]Pack, []Status) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+fileExtension))
+	if err != nil {
+		// Only possible with a malformed pattern
+		return nil, nil
+	}
+	sort.Strings(matches)
+
+	var packs []Pack
+	var invalid []Status
+	for _, path := range matches {
+		pack, err := loadPack(path)
+		if err != nil {
+			invalid = append(invalid, Status{
+				Path:  path,
+				Name:  pack.Name,
+				State: StateInvalid,
+				Error: err.Error(),
+			})
+			continue
+		}
+		packs = append(packs, pack)
+	}
+
+	return packs, invalid
+}
+
+// loadPack reads and validates the pack at path. The returned pack always has
+// its Name and Path set, even on error.
+func loadPack(path string) (Pack, error) {
+	pack := Pack{
+		Name: strings.TrimSuffix(filepath.Base(path), fileExtension),
+		Path: path,
+	}
+
+	if !validPackName.MatchString(pack.Name) {
+		return pack, fmt.Errorf("invalid pack name %q", pack.Name)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return pack, fmt.Errorf("stat: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return pack, errors.New("not a regular file")
+	}
+	if info.Size() > maxFileBytes {
+		return pack, fmt.Errorf("file size %d exceeds maximum %d", info.Size(), maxFileBytes)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return pack, fmt.Errorf("reading: %w", err)
+	}
+
+	count, err := validate(raw)
+	if err != nil {
+		return pack, err
+	}
+
+	pack.QueryCount = count
+	pack.raw = raw
+	return pack, nil
+}
+
+type packFile struct {
+	Queries map[string]packQuery `json:"queries"`
+}
+
+type packQuery struct {
+	Query    string           `json:"query"`
+	Interval *json.RawMessage `json:"interval"`
+}
+
+// validate checks that raw is a pack osquery will accept, and returns its number of queries.
+func validate(raw []byte) (int, error) {
+	var pf packFile
+	if err := json.Unmarshal(raw, &pf); err != nil {
+		return 0, fmt.Errorf("parsing pack: %w", err)
+	}
+
+	if len(pf.Queries) == 0 {
+		return 0, errors.New("pack has no queries")
+	}
+
+	for name, q := range pf.Queries {
+		if strings.TrimSpace(q.Query) == "" {
+			return 0, fmt.Errorf("query %q: missing query", name)
+		}
+		if q.Interval == nil {
+			return 0, fmt.Errorf("query %q: missing interval", name)
+		}
+
+		// osquery accepts the interval as a number or a numeric string
+		var interval json.Number
+		if err := json.Unmarshal(*q.Interval, &interval); err != nil {
+			return 0, fmt.Errorf("query %q: invalid interval: %w", name, err)
+		}
+		if n, err := interval.Int64(); err != nil || n <= 0 {
+			return 0, fmt.Errorf("query %q: interval must be a positive integer", name)
+		}
+	}
+
+	return len(pf.Queries), nil
+}
+
+// Merge adds packs to config, following the precedence rules described in the package
+// documentation. It returns the merged config and a status for every pack. If config
+// is not a JSON object, it returns an error, and the caller should use config unchanged.
+func Merge(config string, packs []Pack) (string, []Status, error) {
+	if len(packs) == 0 {
+		return config, nil, nil
+	}
+
+	// A host that has never received a config has nothing to merge into
+	base := config
+	if strings.TrimSpace(base) == "" {
+		base = "{}"
+	}
+
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(base), &parsed); err != nil {
+		return config, nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if parsed == nil {
+		parsed = make(map[string]json.RawMessage)
+	}
+
+	// Packs in the config may be inline objects or paths to pack files; either way
+	// only the names matter here.
+	configPacks := make(map[string]json.RawMessage)
+	if raw, ok := parsed["packs"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &configPacks); err != nil {
+			return config, nil, fmt.Errorf("parsing config packs: %w", err)
+		}
+	}
+
+	statuses := make([]Status, 0, len(packs))
+	loaded := 0
+	for _, pack := range packs {
+		status := Status{
+			Path:       pack.Path,
+			Name:       pack.Name,
+			State:      StateLoaded,
+			QueryCount: pack.QueryCount,
+		}
+
+		if _, ok := configPacks[pack.Name]; ok {
+			status.State = StateShadowed
+			status.Error = "a pack with this name is in the server config"
+		} else {
+			configPacks[pack.Name] = pack.raw
+			loaded++
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	if loaded == 0 {
+		return config, statuses, nil
+	}
+
+	rawPacks, err := json.Marshal(configPacks)
+	if err != nil {
+		return config, nil, fmt.Errorf("marshalling packs: %w", err)
+	}
+	parsed["packs"] = rawPacks
+
+	merged, err := json.Marshal(parsed)
+	if err != nil {
+		return config, nil, fmt.Errorf("marshalling config: %w", err)
+	}
+
+	return string(merged), statuses, nil
+}
+
+// Statuses loads the packs in dir and merges them into config, returning a status for
+// every pack file, in lexical order by path.
+func Statuses(dir string, config string) []Status {
+	packs, statuses := Load(dir)
+
+	_, merged, err := Merge(config, packs)
+	if err != nil {
+		// The packs can't be merged at all, so none of them are in effect
+		for _, pack := range packs {
+			statuses = append(statuses, Status{
+				Path:       pack.Path,
+				Name:       pack.Name,
+				State:      StateInvalid,
+				QueryCount: pack.QueryCount,
+				Error:      err.Error(),
+			})
+		}
+	} else {
+		statuses = append(statuses, merged...)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses
+}
@@ -0,0 +1,124 @@
+package localpacks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const validPack = `{
+  "platform": "linux",
+  "queries": {
+    "os_version": {"query": "select * from os_version;", "interval": 3600},
+    "users": {"query": "select * from users;", "interval": "600"}
+  }
+}`
+
+func writePack(t *testing.T, dir, name, contents string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePack(t, dir, "compliance.conf", validPack)
+	writePack(t, dir, "broken.conf", `{"queries":`)
+	writePack(t, dir, "empty.conf", `{"queries": {}}`)
+	writePack(t, dir, "no_interval.conf", `{"queries": {"q": {"query": "select 1;"}}}`)
+	writePack(t, dir, "zero_interval.conf", `{"queries": {"q": {"query": "select 1;", "interval": 0}}}`)
+	writePack(t, dir, "bad name.conf", validPack)
+	writePack(t, dir, "ignored.json", validPack)
+
+	packs, invalid := Load(dir)
+	require.Len(t, packs, 1)
+	require.Equal(t, "compliance", packs[0].Name)
+	require.Equal(t, 2, packs[0].QueryCount)
+
+	invalidNames := make([]string, 0, len(invalid))
+	for _, status := range invalid {
+		require.Equal(t, StateInvalid, status.State)
+		require.NotEmpty(t, status.Error)
+		invalidNames = append(invalidNames, status.Name)
+	}
+	require.Equal(t, []string{"bad name", "broken", "empty", "no_interval", "zero_interval"}, invalidNames)
+}
+
+func TestLoad_MissingDir(t *testing.T) {
+	t.Parallel()
+
+	packs, invalid := Load(filepath.Join(t.TempDir(), "does-not-exist"))
+	require.Empty(t, packs)
+	require.Empty(t, invalid)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePack(t, dir, "compliance.conf", validPack)
+	writePack(t, dir, "server_pack.conf", validPack)
+	packs, _ := Load(dir)
+
+	serverConfig := `{"options":{"host_identifier":"uuid"},"packs":{"server_pack":{"queries":{"q":{"query":"select 2;","interval":60}}}}}`
+
+	merged, statuses, err := Merge(serverConfig, packs)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, StateLoaded, statuses[0].State)
+	require.Equal(t, StateShadowed, statuses[1].State)
+
+	var parsed struct {
+		Options map[string]string                     `json:"options"`
+		Packs   map[string]map[string]json.RawMessage `json:"packs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(merged), &parsed))
+	require.Equal(t, "uuid", parsed.Options["host_identifier"], "other config is untouched")
+	require.Contains(t, parsed.Packs, "compliance")
+	require.JSONEq(t, `{"q":{"query":"select 2;","interval":60}}`, string(parsed.Packs["server_pack"]["queries"]), "server pack wins")
+}
+
+func TestMerge_EmptyConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePack(t, dir, "compliance.conf", validPack)
+	packs, _ := Load(dir)
+
+	for _, config := range []string{"", "{}"} {
+		merged, statuses, err := Merge(config, packs)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		require.Contains(t, merged, `"compliance"`)
+	}
+}
+
+func TestMerge_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePack(t, dir, "compliance.conf", validPack)
+	packs, _ := Load(dir)
+
+	merged, _, err := Merge("not json", packs)
+	require.Error(t, err)
+	require.Equal(t, "not json", merged)
+}
+
+func TestStatuses(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writePack(t, dir, "a.conf", validPack)
+	writePack(t, dir, "b.conf", `nope`)
+	writePack(t, dir, "c.conf", validPack)
+
+	statuses := Statuses(dir, `{"packs":{"c":"/etc/osquery/packs/c.conf"}}`)
+	require.Len(t, statuses, 3)
+	require.Equal(t, StateLoaded, statuses[0].State)
+	require.Equal(t, StateInvalid, statuses[1].State)
+	require.Equal(t, StateShadowed, statuses[2].State)
+}
@@ -103,3 +103,8 @@ func (lps *logPublicationState) increaseBatchThreshold() {
 	newTargetThreshold := lps.currentMaxBytesPerBatch + batchIncrementAmount
 	lps.currentMaxBytesPerBatch = minInt(newTargetThreshold, lps.maxBytesPerBatch)
 }
+
+// CurrentBatchThreshold returns the batch size limit currently being enforced.
+func (lps *logPublicationState) CurrentBatchThreshold() int {
+	return lps.currentMaxBytesPerBatch
+}
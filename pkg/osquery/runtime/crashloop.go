@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tuf"
+)
+
+const (
+	// If osquery fails on startup crashLoopThreshold times within crashLoopWindow, we consider
+	// it to be in a crash loop.
+	crashLoopThreshold = 5
+	crashLoopWindow    = 10 * time.Minute
+)
+
+// checkForCrashLoop looks at osquery instance history to determine whether osqueryd is failing
+// on startup repeatedly. If it is, and the osqueryd binary in use came from the update library,
+// it marks that version bad so that the next launch falls back to the previous version in the
+// library (or the installed version). It returns true if osqueryd is in a crash loop that could
+// not be resolved by rolling back, in which case the caller should back off before relaunching.
+func (r *Runner) checkForCrashLoop(ctx context.Context, enrollmentId string) bool {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	osqHistory := r.knapsack.OsqueryHistory()
+	if osqHistory == nil {
+		return false
+	}
+
+	r.crashLoopLock.Lock()
+	defer r.crashLoopLock.Unlock()
+
+	// Only count failures of the osqueryd binary we'd launch next, so that the version we rolled
+	// back to gets a fresh window -- even across launcher restarts.
+	current, libraryErr := tuf.CheckOutLatest(ctx, "osqueryd", r.knapsack.RootDirectory(), r.knapsack.UpdateDirectory(),
+		r.knapsack.PinnedOsquerydVersion(), r.knapsack.UpdateChannel(), r.slogger)
+	var binaryPath string
+	if libraryErr == nil {
+		binaryPath = current.Path
+	} else {
+		binaryPath = r.knapsack.OsquerydPath()
+	}
+
+	failures, err := osqHistory.StartupFailuresSince(enrollmentId, binaryPath, time.Now().Add(-crashLoopWindow))
+	if err != nil {
+		r.slogger.Log(ctx, slog.LevelWarn,
+			"could not count osquery startup failures in history",
+			"enrollment_id", enrollmentId,
+			"err", err,
+		)
+		return false
+	}
+	if failures < crashLoopThreshold {
+		return false
+	}
+
+	if libraryErr != nil {
+		r.slogger.Log(ctx, slog.LevelError,
+			"osqueryd is in a crash loop and cannot be rolled back: no osqueryd version in update library to roll back from",
+			"enrollment_id", enrollmentId,
+			"startup_failures", failures,
+			"osqueryd_path", binaryPath,
+			"err", libraryErr,
+		)
+		return true
+	}
+
+	if err := r.rollBackOsqueryd(ctx, current, failures); err != nil {
+		r.slogger.Log(ctx, slog.LevelError,
+			"osqueryd is in a crash loop and cannot be rolled back",
+			"enrollment_id", enrollmentId,
+			"startup_failures", failures,
+			"err", err,
+		)
+		return true
+	}
+
+	return false
+}
+
+// rollBackOsqueryd marks the given osqueryd version, currently selected from the update library, as bad.
+func (r *Runner) rollBackOsqueryd(ctx context.Context, current *tuf.BinaryUpdateInfo, failures int) error {
+	reason := fmt.Sprintf("osqueryd failed on startup %d times within %s", failures, crashLoopWindow)
+	if err := tuf.MarkBadVersion("osqueryd", r.knapsack.RootDirectory(), r.knapsack.UpdateDirectory(), current.Version, reason); err != nil {
+		return fmt.Errorf("marking osqueryd version %s bad: %w", current.Version, err)
+	}
+
+	observability.OsqueryRollbackCounter.Add(ctx, 1)
+	r.slogger.Log(ctx, slog.LevelWarn,
+		"osqueryd is in a crash loop, marked current version bad to roll back to previous version",
+		"bad_version", current.Version,
+		"bad_version_path", current.Path,
+		"reason", reason,
+	)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package runtime
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/agent/types"
+	typesMocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/tuf"
+	tufci "github.com/kolide/launcher/ee/tuf/ci"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckForCrashLoop(t *testing.T) {
+	t.Parallel()
+
+	rootDirectory := t.TempDir()
+
+	// Set up an update library with two versions of osqueryd
+	for _, version := range []string{"5.10.0", "5.11.0"} {
+		executableName := "osqueryd"
+		if runtime.GOOS == "windows" {
+			executableName += ".exe"
+		}
+		tufci.CopyBinary(t, filepath.Join(tuf.DefaultLibraryDirectory(rootDirectory), "osqueryd", version, executableName))
+	}
+
+	k := typesMocks.NewKnapsack(t)
+	k.On("RootDirectory").Return(rootDirectory)
+	k.On("UpdateDirectory").Return("")
+	k.On("PinnedOsquerydVersion").Return("")
+	k.On("UpdateChannel").Return("stable")
+	k.On("OsquerydPath").Return("/installed/osqueryd").Maybe()
+	osqHistory := setupHistory(t, k)
+
+	newRunner := func() *Runner {
+		return &Runner{
+			knapsack: k,
+			slogger:  multislogger.NewNopLogger(),
+		}
+	}
+	runner := newRunner()
+
+	exitDuringStartup := func(times int, binaryPath string, reason string) {
+		for range times {
+			runId := ulid.New()
+			require.NoError(t, osqHistory.NewInstance(types.DefaultEnrollmentID, runId))
+			require.NoError(t, osqHistory.SetBinaryPath(runId, binaryPath))
+			require.NoError(t, osqHistory.SetExitDetails(runId, types.OsqueryInstanceExit{Reason: reason, ExitCode: -1}))
+			require.NoError(t, osqHistory.SetExited(runId, errors.New("osqueryd exited")))
+		}
+	}
+	failStartup := func(times int, binaryPath string) {
+		exitDuringStartup(times, binaryPath, types.OsqueryExitReasonCrash)
+	}
+	libraryPath := func(version string) string {
+		current, err := tuf.CheckOutLatest(t.Context(), "osqueryd", rootDirectory, "", "", "stable", multislogger.NewNopLogger())
+		require.NoError(t, err)
+		require.Equal(t, version, current.Version)
+		return current.Path
+	}
+
+	// Launcher stopping osqueryd during startup, e.g. for a flag change or shutdown, is not a failure
+	newestPath := libraryPath("5.11.0")
+	exitDuringStartup(crashLoopThreshold, newestPath, types.OsqueryExitReasonLauncherRestart)
+	exitDuringStartup(crashLoopThreshold, newestPath, types.OsqueryExitReasonLauncherShutdown)
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+	badVersions, err := tuf.BadVersions("osqueryd", rootDirectory, "")
+	require.NoError(t, err)
+	require.Empty(t, badVersions)
+
+	// Below the threshold, nothing happens
+	failStartup(crashLoopThreshold-1, newestPath)
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+	badVersions, err = tuf.BadVersions("osqueryd", rootDirectory, "")
+	require.NoError(t, err)
+	require.Empty(t, badVersions)
+
+	// At the threshold, the newest version is marked bad
+	failStartup(1, newestPath)
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID), "rollback should resolve the crash loop")
+	badVersions, err = tuf.BadVersions("osqueryd", rootDirectory, "")
+	require.NoError(t, err)
+	require.Len(t, badVersions, 1)
+	require.Equal(t, "5.11.0", badVersions[0].Version)
+
+	// Failures of the bad version don't count against the version we rolled back to,
+	// even after a launcher restart
+	previousPath := libraryPath("5.10.0")
+	require.False(t, newRunner().checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+	badVersions, err = tuf.BadVersions("osqueryd", rootDirectory, "")
+	require.NoError(t, err)
+	require.Len(t, badVersions, 1)
+
+	// The previous version crash loops too, and is also rolled back
+	failStartup(crashLoopThreshold, previousPath)
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+	badVersions, err = tuf.BadVersions("osqueryd", rootDirectory, "")
+	require.NoError(t, err)
+	require.Len(t, badVersions, 2)
+	require.Equal(t, "5.10.0", badVersions[1].Version)
+
+	// With nothing left to roll back to, the caller should back off once the installed
+	// version crash loops
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+	failStartup(crashLoopThreshold, "/installed/osqueryd")
+	require.True(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+}
+
+func TestCheckForCrashLoop_NoHistory(t *testing.T) {
+	t.Parallel()
+
+	k := typesMocks.NewKnapsack(t)
+	k.On("OsqueryHistory").Return(nil)
+
+	runner := &Runner{
+		knapsack: k,
+		slogger:  multislogger.NewNopLogger(),
+	}
+
+	require.False(t, runner.checkForCrashLoop(t.Context(), types.DefaultEnrollmentID))
+}
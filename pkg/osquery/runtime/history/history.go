@@ -124,6 +124,50 @@ func (h *History) LatestInstanceUptimeMinutes(enrollmentId string) (int64, error
 	return uptimeSeconds / 60, nil
 }
 
+// startupFailureReasons are the exit reasons that point to a problem with osqueryd itself. Exits
+// that launcher initiated for its own reasons, e.g. a flag change or shutdown, are not failures.
+var startupFailureReasons = map[string]struct{}{
+	types.OsqueryExitReasonCrash:         {},
+	types.OsqueryExitReasonWatchdog:      {},
+	types.OsqueryExitReasonExited:        {},
+	types.OsqueryExitReasonComponentExit: {},
+	types.OsqueryExitReasonLaunchFailed:  {},
+}
+
+// StartupFailuresSince counts the instances for the given enrollment id that exited at or
+// after the given time without ever connecting, for a reason that points to osqueryd failing
+// on startup. If binaryPath is set, only instances that ran that osqueryd binary are counted.
+// Because history only holds the last few instances, the count is capped at maxInstances.
+func (h *History) StartupFailuresSince(enrollmentId string, binaryPath string, since time.Time) (int, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	failures := 0
+	for _, instance := range h.instances {
+		if instance.EnrollmentId != enrollmentId || instance.ExitTime == "" || instance.ConnectTime != "" {
+			continue
+		}
+		if _, ok := startupFailureReasons[instance.ExitReason]; !ok {
+			continue
+		}
+		if binaryPath != "" && instance.BinaryPath != binaryPath {
+			continue
+		}
+
+		exitTime, err := time.Parse(time.RFC3339, instance.ExitTime)
+		if err != nil {
+			return 0, fmt.Errorf("parsing exit time %s: %w", instance.ExitTime, err)
+		}
+
+		// Times in history only have second precision
+		if !exitTime.Before(since.Truncate(time.Second)) {
+			failures += 1
+		}
+	}
+
+	return failures, nil
+}
+
 // NewInstance adds a new instance to the osquery instance history after setting
 // all available metadata and saves this new instance internally
 func (h *History) NewInstance(enrollmentId string, runId string) error {
@@ -210,6 +254,71 @@ func (h *History) SetExited(runID string, exitError error) error {
 	return nil
 }
 
+// SetBinaryPath finds the target instance by the provided run id, and records the path
+// to the osqueryd binary it is running
+func (h *History) SetBinaryPath(runID string, binaryPath string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	instance, err := h.instanceByRunId(runID)
+	if err != nil {
+		return err
+	}
+	instance.BinaryPath = binaryPath
+
+	if err := h.save(); err != nil {
+		return fmt.Errorf("error saving osquery_instance_history: %w", err)
+	}
+
+	return nil
+}
+
+// RecordResourceUsage finds the target instance by the provided run id, and updates its
+// peak resource usage. Since this is called frequently, the update is not saved until
+// the next change to the instance's history (typically, its exit).
+func (h *History) RecordResourceUsage(runID string, rssBytes uint64, cpuPercent float64) error {
+	h.Lock()
+	defer h.Unlock()
+
+	instance, err := h.instanceByRunId(runID)
+	if err != nil {
+		return err
+	}
+	instance.RecordResourceUsage(rssBytes, cpuPercent)
+
+	return nil
+}
+
+// SetExitDetails finds the target instance by the provided run id, and records how and
+// why it exited
+func (h *History) SetExitDetails(runID string, exit types.OsqueryInstanceExit) error {
+	h.Lock()
+	defer h.Unlock()
+
+	instance, err := h.instanceByRunId(runID)
+	if err != nil {
+		return err
+	}
+	instance.SetExitDetails(exit)
+
+	if err := h.save(); err != nil {
+		return fmt.Errorf("error saving osquery_instance_history: %w", err)
+	}
+
+	return nil
+}
+
+// instanceByRunId returns the instance with the given run id; the caller must hold the lock.
+func (h *History) instanceByRunId(runID string) (*instance, error) {
+	for i := len(h.instances) - 1; i > -1; i -= 1 {
+		if h.instances[i].RunId == runID {
+			return h.instances[i], nil
+		}
+	}
+
+	return nil, NoInstancesError{}
+}
+
 func (h *History) addInstanceToHistory(newInstance *instance) {
 	if h.instances == nil {
 		h.instances = []*instance{newInstance}
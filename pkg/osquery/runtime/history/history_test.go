@@ -118,8 +118,8 @@ func TestGetHistory(t *testing.T) {
 				},
 			},
 			want: []map[string]string{
-				{"connect_time": "", "errors": "", "exit_time": "", "hostname": "", "instance_id": "", "instance_run_id": "", "enrollment_id": "", "start_time": "first_expected_start_time", "version": ""},
-				{"connect_time": "", "errors": "", "exit_time": "", "hostname": "", "instance_id": "", "instance_run_id": "", "enrollment_id": "", "start_time": "second_expected_start_time", "version": ""},
+				{"connect_time": "", "errors": "", "exit_time": "", "hostname": "", "instance_id": "", "instance_run_id": "", "enrollment_id": "", "start_time": "first_expected_start_time", "version": "", "binary_path": "", "exit_reason": "", "exit_detail": "", "exit_code": "", "exit_signal": "", "peak_rss_bytes": "0", "peak_cpu_percent": "0.00"},
+				{"connect_time": "", "errors": "", "exit_time": "", "hostname": "", "instance_id": "", "instance_run_id": "", "enrollment_id": "", "start_time": "second_expected_start_time", "version": "", "binary_path": "", "exit_reason": "", "exit_detail": "", "exit_code": "", "exit_signal": "", "peak_rss_bytes": "0", "peak_cpu_percent": "0.00"},
 			},
 		},
 		{
@@ -222,15 +222,22 @@ func TestLatestInstanceStats(t *testing.T) {
 				},
 			},
 			want: map[string]string{
-				"connect_time":    "",
-				"errors":          "",
-				"exit_time":       "",
-				"hostname":        "",
-				"instance_id":     "",
-				"instance_run_id": "",
-				"enrollment_id":   "test",
-				"start_time":      "third_expected_start_time",
-				"version":         "",
+				"connect_time":     "",
+				"errors":           "",
+				"exit_time":        "",
+				"hostname":         "",
+				"instance_id":      "",
+				"instance_run_id":  "",
+				"enrollment_id":    "test",
+				"start_time":       "third_expected_start_time",
+				"version":          "",
+				"binary_path":      "",
+				"exit_reason":      "",
+				"exit_detail":      "",
+				"exit_code":        "",
+				"exit_signal":      "",
+				"peak_rss_bytes":   "0",
+				"peak_cpu_percent": "0.00",
 			},
 		},
 		{
@@ -352,6 +359,53 @@ func TestLatestInstanceUptimeMinutes(t *testing.T) {
 	}
 }
 
+func TestStartupFailuresSince(t *testing.T) {
+	t.Parallel()
+
+	minutesAgo := func(m int) string {
+		return time.Now().UTC().Add(time.Duration(-m) * time.Minute).Format(time.RFC3339)
+	}
+
+	currentHistory, err := InitHistory(setupStorage(t,
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(60), ExitTime: minutesAgo(30), ExitReason: types.OsqueryExitReasonCrash},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(30), ExitTime: minutesAgo(9), ExitReason: types.OsqueryExitReasonLaunchFailed},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(9), ConnectTime: minutesAgo(9), ExitTime: minutesAgo(8), ExitReason: types.OsqueryExitReasonCrash},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(9), ExitTime: minutesAgo(8), ExitReason: types.OsqueryExitReasonLauncherRestart},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(8), ExitTime: minutesAgo(7), ExitReason: types.OsqueryExitReasonLauncherShutdown},
+		&instance{EnrollmentId: "notTheDefault", StartTime: minutesAgo(8), ExitTime: minutesAgo(6), ExitReason: types.OsqueryExitReasonComponentExit},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(8), ExitTime: minutesAgo(4), BinaryPath: "/osqueryd/5.11.0/osqueryd", ExitReason: types.OsqueryExitReasonWatchdog},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, StartTime: minutesAgo(4)},
+	))
+	require.NoError(t, err, "expected to be able to initialize history without error")
+
+	// The connected instance, the instances launcher stopped during startup, and the running
+	// instance are not startup failures
+	failures, err := currentHistory.StartupFailuresSince(types.DefaultEnrollmentID, "", time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 2, failures)
+
+	failures, err = currentHistory.StartupFailuresSince(types.DefaultEnrollmentID, "", time.Now().Add(-5*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, failures)
+
+	// Only failures of the given binary are counted, when one is given
+	failures, err = currentHistory.StartupFailuresSince(types.DefaultEnrollmentID, "/osqueryd/5.11.0/osqueryd", time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, failures)
+
+	failures, err = currentHistory.StartupFailuresSince(types.DefaultEnrollmentID, "/osqueryd/5.10.0/osqueryd", time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 0, failures)
+
+	failures, err = currentHistory.StartupFailuresSince("notTheDefault", "", time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, failures)
+
+	failures, err = currentHistory.StartupFailuresSince("unknown", "", time.Now().Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 0, failures)
+}
+
 func TestLatestInstanceId(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -682,6 +736,55 @@ func TestSetExited(t *testing.T) {
 	}
 }
 
+func TestSetExitDetails(t *testing.T) {
+	t.Parallel()
+
+	runId := ulid.New()
+	otherRunId := ulid.New()
+	osqHistory, err := InitHistory(setupStorage(t,
+		&instance{EnrollmentId: types.DefaultEnrollmentID, RunId: runId, StartTime: timeNow()},
+		&instance{EnrollmentId: types.DefaultEnrollmentID, RunId: otherRunId, StartTime: timeNow()},
+	))
+	require.NoError(t, err, "expected to be able to initialize history without error")
+
+	require.NoError(t, osqHistory.SetBinaryPath(runId, "/usr/local/kolide-k2/bin/osqueryd"))
+	require.NoError(t, osqHistory.RecordResourceUsage(runId, 200, 12.5))
+	require.NoError(t, osqHistory.RecordResourceUsage(runId, 300, 3))
+	require.NoError(t, osqHistory.SetExitDetails(runId, types.OsqueryInstanceExit{
+		Reason:   types.OsqueryExitReasonCrash,
+		Detail:   "signal: segmentation fault",
+		ExitCode: -1,
+		Signal:   "segmentation fault",
+	}))
+	require.NoError(t, osqHistory.SetExitDetails(otherRunId, types.OsqueryInstanceExit{
+		Reason:   types.OsqueryExitReasonLauncherRestart,
+		Detail:   "flag change",
+		ExitCode: 0,
+	}))
+
+	require.ErrorIs(t, osqHistory.SetExitDetails("unknown", types.OsqueryInstanceExit{}), NoInstancesError{})
+	require.ErrorIs(t, osqHistory.RecordResourceUsage("unknown", 1, 1), NoInstancesError{})
+
+	// Reload from storage, to confirm that everything was saved
+	reloadedHistory, err := InitHistory(osqHistory.store)
+	require.NoError(t, err)
+	historyStats, err := reloadedHistory.GetHistory()
+	require.NoError(t, err)
+	require.Len(t, historyStats, 2)
+
+	require.Equal(t, "/usr/local/kolide-k2/bin/osqueryd", historyStats[0]["binary_path"])
+	require.Equal(t, types.OsqueryExitReasonCrash, historyStats[0]["exit_reason"])
+	require.Equal(t, "signal: segmentation fault", historyStats[0]["exit_detail"])
+	require.Equal(t, "", historyStats[0]["exit_code"])
+	require.Equal(t, "segmentation fault", historyStats[0]["exit_signal"])
+	require.Equal(t, "300", historyStats[0]["peak_rss_bytes"])
+	require.Equal(t, "12.50", historyStats[0]["peak_cpu_percent"])
+
+	require.Equal(t, types.OsqueryExitReasonLauncherRestart, historyStats[1]["exit_reason"])
+	require.Equal(t, "0", historyStats[1]["exit_code"])
+	require.Equal(t, "", historyStats[1]["exit_signal"])
+}
+
 // setupStorage creates storage and seeds it with the given instances.
 func setupStorage(t *testing.T, seedInstances ...*instance) types.KVStore {
 	s, err := storageci.NewStore(t, multislogger.NewNopLogger(), storage.OsqueryHistoryInstanceStore.String())
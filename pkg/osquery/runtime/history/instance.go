@@ -2,6 +2,7 @@ package history
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/kolide/launcher/ee/agent/types"
 )
@@ -16,6 +17,15 @@ type instance struct {
 	InstanceId   string // ID from osquery
 	Version      string
 	Error        string
+	BinaryPath   string // path to the osqueryd binary this instance ran
+	// Exit details -- see types.OsqueryInstanceExit
+	ExitReason string
+	ExitDetail string
+	ExitCode   string // empty if unknown
+	ExitSignal string
+	// Peak resource usage of osqueryd and its worker, as sampled while the instance ran
+	PeakRSSBytes   uint64
+	PeakCPUPercent float64
 }
 
 type ExpectedAtLeastOneRowError struct{}
@@ -63,20 +73,44 @@ func (i *instance) Exited(exitError error) error {
 	return nil
 }
 
+// RecordResourceUsage updates the instance's peak RSS and CPU usage.
+func (i *instance) RecordResourceUsage(rssBytes uint64, cpuPercent float64) {
+	i.PeakRSSBytes = max(i.PeakRSSBytes, rssBytes)
+	i.PeakCPUPercent = max(i.PeakCPUPercent, cpuPercent)
+}
+
+// SetExitDetails records how and why the instance exited.
+func (i *instance) SetExitDetails(exit types.OsqueryInstanceExit) {
+	i.ExitReason = exit.Reason
+	i.ExitDetail = exit.Detail
+	i.ExitSignal = exit.Signal
+	i.ExitCode = ""
+	if exit.ExitCode >= 0 {
+		i.ExitCode = strconv.Itoa(exit.ExitCode)
+	}
+}
+
 func (i *instance) toMap() map[string]string {
 	if i == nil {
 		return nil
 	}
 
 	return map[string]string{
-		"enrollment_id":   i.EnrollmentId,
-		"instance_run_id": i.RunId,
-		"start_time":      i.StartTime,
-		"connect_time":    i.ConnectTime,
-		"exit_time":       i.ExitTime,
-		"hostname":        i.Hostname,
-		"instance_id":     i.InstanceId,
-		"version":         i.Version,
-		"errors":          i.Error,
+		"enrollment_id":    i.EnrollmentId,
+		"instance_run_id":  i.RunId,
+		"start_time":       i.StartTime,
+		"connect_time":     i.ConnectTime,
+		"exit_time":        i.ExitTime,
+		"hostname":         i.Hostname,
+		"instance_id":      i.InstanceId,
+		"version":          i.Version,
+		"errors":           i.Error,
+		"binary_path":      i.BinaryPath,
+		"exit_reason":      i.ExitReason,
+		"exit_detail":      i.ExitDetail,
+		"exit_code":        i.ExitCode,
+		"exit_signal":      i.ExitSignal,
+		"peak_rss_bytes":   strconv.FormatUint(i.PeakRSSBytes, 10),
+		"peak_cpu_percent": strconv.FormatFloat(i.PeakCPUPercent, 'f', 2, 64),
 	}
 }
@@ -13,15 +13,18 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/kolide/kit/ulid"
 	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/cgroups"
 	"github.com/kolide/launcher/ee/errgroup"
 	"github.com/kolide/launcher/ee/gowrapper"
 	kolidelog "github.com/kolide/launcher/ee/log/osquerylogs"
 	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/performance"
 	"github.com/kolide/launcher/pkg/backoff"
 	launcherosq "github.com/kolide/launcher/pkg/osquery"
 	"github.com/kolide/launcher/pkg/osquery/table"
@@ -70,6 +73,14 @@ const (
 	// How frequently we should healthcheck the client/server
 	healthCheckInterval = 60 * time.Second
 
+	// How frequently we sample osqueryd's resource usage for instance history
+	resourceUsageSampleInterval = 1 * time.Minute
+
+	// How recently the osquery watchdog must have stopped a worker for us to attribute
+	// the instance's exit to the watchdog -- long enough to cover the health check that
+	// typically notices the stopped worker.
+	watchdogExitWindow = 3 * time.Minute
+
 	// The maximum amount of time to wait for the osquery socket to be available -- overrides context deadline
 	maxSocketWaitTime = 30 * time.Second
 
@@ -100,6 +111,14 @@ func WithAugeasLensFunction(f func(dir string) error) OsqueryInstanceOption {
 	}
 }
 
+// withSandboxRunner sets the runner for distributed queries designated as heavy. Without one,
+// the instance runs heavy queries itself.
+func withSandboxRunner(sandboxRunner launcherosq.SandboxQueryRunner) OsqueryInstanceOption {
+	return func(i *OsqueryInstance) {
+		i.sandboxRunner = sandboxRunner
+	}
+}
+
 // OsqueryInstance is the type which represents a currently running instance
 // of osqueryd.
 type OsqueryInstance struct {
@@ -122,6 +141,14 @@ type OsqueryInstance struct {
 	extensionManagerClient  *osquery.ExtensionManagerClient
 	history                 types.OsqueryHistorian
 	startFunc               func(cmd *exec.Cmd) error
+	sandboxRunner           launcherosq.SandboxQueryRunner // runs heavy distributed queries; may be nil
+	// the following track why the instance exited, for instance history
+	exitLock         sync.Mutex
+	exitReason       string // one of the types.OsqueryExitReason constants; the first cause recorded wins
+	exitDetail       string
+	exitRequested    bool // whether launcher explicitly asked the instance to exit, e.g. via Restart
+	lastWatchdogKill time.Time
+	watchdogKillMsg  string
 }
 
 // Healthy will check to determine whether or not the osquery process that is
@@ -254,6 +281,78 @@ func (i *OsqueryInstance) BeginShutdown() {
 	i.errgroup.Shutdown()
 }
 
+// RequestExit records why launcher is asking the instance to exit, for instance history.
+// It should be called before `BeginShutdown`.
+func (i *OsqueryInstance) RequestExit(reason string, detail string) {
+	if i.setExitReason(reason, detail) {
+		i.exitLock.Lock()
+		i.exitRequested = true
+		i.exitLock.Unlock()
+	}
+}
+
+// setExitReason records why the instance is exiting, unless a cause has already been
+// recorded. It returns true if this cause was recorded.
+func (i *OsqueryInstance) setExitReason(reason string, detail string) bool {
+	i.exitLock.Lock()
+	defer i.exitLock.Unlock()
+
+	if i.exitReason != "" {
+		return false
+	}
+	i.exitReason = reason
+	i.exitDetail = detail
+	return true
+}
+
+// recordWatchdogKill notes that the osquery watchdog stopped a worker or extension.
+func (i *OsqueryInstance) recordWatchdogKill(msg string) {
+	i.exitLock.Lock()
+	defer i.exitLock.Unlock()
+
+	i.lastWatchdogKill = time.Now()
+	i.watchdogKillMsg = msg
+}
+
+// exitDetails classifies the instance's exit. It should be called after the instance's
+// errgroup has exited.
+func (i *OsqueryInstance) exitDetails(exitErr error) types.OsqueryInstanceExit {
+	i.exitLock.Lock()
+	defer i.exitLock.Unlock()
+
+	exit := types.OsqueryInstanceExit{
+		Reason:   i.exitReason,
+		Detail:   i.exitDetail,
+		ExitCode: -1,
+	}
+
+	if i.cmd != nil && i.cmd.ProcessState != nil {
+		exit.ExitCode = i.cmd.ProcessState.ExitCode()
+		if status, ok := i.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			exit.Signal = status.Signal().String()
+		}
+	}
+
+	// Unless launcher asked the instance to exit, a recent watchdog kill is the likeliest root
+	// cause -- even if what we noticed was a failed health check or an osqueryd crash.
+	if !i.exitRequested && !i.lastWatchdogKill.IsZero() && time.Since(i.lastWatchdogKill) < watchdogExitWindow {
+		exit.Reason = types.OsqueryExitReasonWatchdog
+		exit.Detail = i.watchdogKillMsg
+		return exit
+	}
+
+	// Otherwise, if we don't have a cause, one of the instance's other components (e.g. an
+	// extension server) exited, and the errgroup shut down the rest of the instance.
+	if exit.Reason == "" {
+		exit.Reason = types.OsqueryExitReasonComponentExit
+		if exitErr != nil {
+			exit.Detail = exitErr.Error()
+		}
+	}
+
+	return exit
+}
+
 // WaitShutdown waits for the instance's errgroup routines to exit, then returns the
 // initial error. It should be called after either `Exited` has returned, or after
 // the instance has been asked to shut down via call to `BeginShutdown`.
@@ -263,6 +362,12 @@ func (i *OsqueryInstance) WaitShutdown(ctx context.Context) error {
 
 	// Record shutdown in stats, if initialized
 	if i.history != nil {
+		if err := i.history.SetExitDetails(i.runId, i.exitDetails(exitErr)); err != nil {
+			i.slogger.Log(ctx, slog.LevelWarn,
+				"error recording osquery instance exit details to history",
+				"err", err,
+			)
+		}
 		if err := i.history.SetExited(i.runId, exitErr); err != nil {
 			i.slogger.Log(ctx, slog.LevelWarn,
 				"error recording osquery instance exit to history",
@@ -447,6 +552,9 @@ func (i *OsqueryInstance) Launch() error {
 	// Assign a PGID that matches the PID. This lets us kill the entire process group later.
 	i.cmd.SysProcAttr = setpgid()
 
+	// Start osqueryd in its cgroup, if cgroup limits are enabled
+	cgroups.Get(cgroups.Osqueryd).Apply(i.cmd)
+
 	// remove any socket already at the extension socket path to ensure
 	// that it's not left over from a previous instance
 	if err := os.RemoveAll(i.paths.extensionSocketPath); err != nil {
@@ -474,6 +582,11 @@ func (i *OsqueryInstance) Launch() error {
 				"could not create new osquery instance history",
 				"err", err,
 			)
+		} else if err := i.history.SetBinaryPath(i.runId, currentOsquerydBinaryPath); err != nil {
+			i.slogger.Log(ctx, slog.LevelWarn,
+				"could not record osqueryd binary path in osquery instance history",
+				"err", err,
+			)
 		}
 	}
 
@@ -482,11 +595,24 @@ func (i *OsqueryInstance) Launch() error {
 	// code. eg: this runs if we could exec. Failure to exec is above.)
 	i.errgroup.StartGoroutine(ctx, "monitor_osquery_process", func() error {
 		err := i.cmd.Wait()
+
+		// If the errgroup was already shutting down, we killed osqueryd -- the cause has been recorded
+		// elsewhere. Otherwise, osqueryd exited on its own.
+		osquerydExitedOnItsOwn := false
+		select {
+		case <-i.errgroup.Exited():
+		default:
+			osquerydExitedOnItsOwn = true
+		}
+
 		switch {
 		case err == nil, isExitOk(err):
 			i.slogger.Log(ctx, slog.LevelInfo,
 				"osquery exited successfully",
 			)
+			if osquerydExitedOnItsOwn {
+				i.setExitReason(types.OsqueryExitReasonExited, "")
+			}
 			return errors.New("osquery process exited successfully")
 		default:
 			msgPairs := append(
@@ -498,10 +624,21 @@ func (i *OsqueryInstance) Launch() error {
 				"error running osquery command",
 				msgPairs...,
 			)
+			if osquerydExitedOnItsOwn {
+				i.setExitReason(types.OsqueryExitReasonCrash, err.Error())
+			}
 			return fmt.Errorf("running osqueryd command: %w", err)
 		}
 	})
 
+	// Sample osqueryd's resource usage on interval, to record its peak usage in history
+	if i.history != nil {
+		i.errgroup.StartRepeatedGoroutine(ctx, "sample_resource_usage", resourceUsageSampleInterval, resourceUsageSampleInterval, func() error {
+			i.sampleResourceUsage(ctx)
+			return nil
+		})
+	}
+
 	// Start an extension manager for the extensions that osquery
 	// needs for config/log/etc.
 	i.extensionManagerClient, err = i.StartOsqueryClient()
@@ -558,6 +695,7 @@ func (i *OsqueryInstance) Launch() error {
 		}
 
 		if err := i.healthcheckWithRetries(ctx, 5, 1*time.Second); err != nil {
+			i.setExitReason(types.OsqueryExitReasonHealthcheckFailed, err.Error())
 			return fmt.Errorf("health check failed: %w", err)
 		}
 
@@ -567,6 +705,40 @@ func (i *OsqueryInstance) Launch() error {
 	return nil
 }
 
+// sampleResourceUsage records the current memory and CPU usage of osqueryd and its worker
+// in instance history, so that history reflects the instance's peak usage.
+func (i *OsqueryInstance) sampleResourceUsage(ctx context.Context) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	if i.cmd == nil || i.cmd.Process == nil {
+		return
+	}
+
+	stats, err := performance.ProcessTreeStats(ctx, int32(i.cmd.Process.Pid))
+	if err != nil {
+		i.slogger.Log(ctx, slog.LevelDebug,
+			"could not sample osqueryd resource usage",
+			"err", err,
+		)
+		return
+	}
+
+	var rssBytes uint64
+	var cpuPercent float64
+	for _, ps := range stats {
+		rssBytes += ps.MemInfo.RSS
+		cpuPercent += ps.CPUPercent
+	}
+
+	if err := i.history.RecordResourceUsage(i.runId, rssBytes, cpuPercent); err != nil {
+		i.slogger.Log(ctx, slog.LevelDebug,
+			"could not record osqueryd resource usage in history",
+			"err", err,
+		)
+	}
+}
+
 // startOsquerydProcess starts the osquery instance's `cmd` and waits for the osqueryd process
 // to create a socket file, indicating it's started up successfully.
 func (i *OsqueryInstance) startOsquerydProcess(ctx context.Context) error {
@@ -670,6 +842,7 @@ func (i *OsqueryInstance) startKolideSaasExtension(ctx context.Context) error {
 	// create the osquery extension
 	extOpts := launcherosq.ExtensionOpts{
 		LoggingInterval: i.knapsack.LoggingInterval(),
+		SandboxRunner:   i.sandboxRunner,
 	}
 
 	// Setting MaxBytesPerBatch is a tradeoff. If it's too low, we
@@ -859,6 +1032,7 @@ func (i *OsqueryInstance) createOsquerydCommand(osquerydBinary string) (*exec.Cm
 		),
 		i.knapsack.RootDirectory(),
 		kolidelog.WithLevel(slog.LevelInfo),
+		kolidelog.WithWatchdogKillHandler(i.recordWatchdogKill),
 	)
 
 	// Apply user-provided flags last so that they can override other flags set
@@ -881,8 +1055,15 @@ func (i *OsqueryInstance) createOsquerydCommand(osquerydBinary string) (*exec.Cm
 		fmt.Sprintf("--extensions_require=%s", KolideSaasExtensionName),
 	)
 
+	cmd.Env = osquerydEnvironment(cmd)
+
+	return cmd, nil
+}
+
+// osquerydEnvironment returns the environment that osqueryd should run with.
+func osquerydEnvironment(cmd *exec.Cmd) []string {
 	// We need environment variables to be set to ensure paths can be resolved appropriately.
-	cmd.Env = cmd.Environ()
+	env := cmd.Environ()
 
 	// On darwin, run osquery using a magic macOS variable to ensure we
 	// get proper versions strings back. I'm not totally sure why apple
@@ -890,22 +1071,22 @@ func (i *OsqueryInstance) createOsquerydCommand(osquerydBinary string) (*exec.Cm
 	// See:
 	// https://eclecticlight.co/2020/08/13/macos-version-numbering-isnt-so-simple/
 	// https://github.com/osquery/osquery/pull/6824
-	cmd.Env = append(cmd.Env, "SYSTEM_VERSION_COMPAT=0")
+	env = append(env, "SYSTEM_VERSION_COMPAT=0")
 
 	// On Windows, we need to ensure the `SystemDrive` environment variable is set to _something_,
 	// so if it isn't already set, we set it to an empty string.
 	systemDriveEnvVarFound := false
-	for _, e := range cmd.Env {
+	for _, e := range env {
 		if strings.Contains(strings.ToLower(e), "systemdrive") {
 			systemDriveEnvVarFound = true
 			break
 		}
 	}
 	if !systemDriveEnvVarFound {
-		cmd.Env = append(cmd.Env, "SystemDrive=")
+		env = append(env, "SystemDrive=")
 	}
 
-	return cmd, nil
+	return env
 }
 
 // StartOsqueryClient will create and return a new osquery client with a connection
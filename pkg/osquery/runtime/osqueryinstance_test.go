@@ -258,6 +258,48 @@ func Test_healthcheckWithRetries(t *testing.T) {
 	require.Error(t, i.healthcheckWithRetries(t.Context(), 5, 100*time.Millisecond))
 }
 
+func Test_exitDetails(t *testing.T) {
+	t.Parallel()
+
+	newTestInstance := func(t *testing.T) *OsqueryInstance {
+		k := typesMocks.NewKnapsack(t)
+		k.On("Slogger").Return(multislogger.NewNopLogger())
+		setupHistory(t, k)
+		lpc := makeTestOsqLogPublisher(t, k)
+		return newInstance(types.DefaultEnrollmentID, k, mockServiceClient(t), lpc, settingsstoremock.NewSettingsStoreWriter(t))
+	}
+
+	// Launcher-requested exits are recorded as such, even after a watchdog kill
+	i := newTestInstance(t)
+	i.recordWatchdogKill("osqueryd worker (123) stopping: Memory limits exceeded: 300MB")
+	i.RequestExit(types.OsqueryExitReasonLauncherRestart, "flag change")
+	i.setExitReason(types.OsqueryExitReasonCrash, "signal: killed")
+	exit := i.exitDetails(context.Canceled)
+	require.Equal(t, types.OsqueryExitReasonLauncherRestart, exit.Reason)
+	require.Equal(t, "flag change", exit.Detail)
+	require.Equal(t, -1, exit.ExitCode, "osqueryd never ran, so exit code is unknown")
+
+	// A recent watchdog kill is the likely cause of a failed health check
+	i = newTestInstance(t)
+	i.recordWatchdogKill("osqueryd worker (123) stopping: Memory limits exceeded: 300MB")
+	i.setExitReason(types.OsqueryExitReasonHealthcheckFailed, "osquery did not respond")
+	exit = i.exitDetails(errors.New("health check failed"))
+	require.Equal(t, types.OsqueryExitReasonWatchdog, exit.Reason)
+	require.Contains(t, exit.Detail, "Memory limits exceeded")
+
+	// ...but not if the watchdog kill was long ago
+	i.lastWatchdogKill = time.Now().Add(-2 * watchdogExitWindow)
+	exit = i.exitDetails(errors.New("health check failed"))
+	require.Equal(t, types.OsqueryExitReasonHealthcheckFailed, exit.Reason)
+	require.Equal(t, "osquery did not respond", exit.Detail)
+
+	// Without a recorded cause, another component of the instance exited
+	i = newTestInstance(t)
+	exit = i.exitDetails(errors.New("extension server exited"))
+	require.Equal(t, types.OsqueryExitReasonComponentExit, exit.Reason)
+	require.Equal(t, "extension server exited", exit.Detail)
+}
+
 func TestHealthy(t *testing.T) {
 	t.Parallel()
 	downloadOnceFunc()
@@ -504,6 +546,7 @@ func TestReloadKatcExtension(t *testing.T) {
 	s.On("WriteSettings").Return(nil)
 	osqHistory := setupHistory(t, k)
 	k.On("ServerReleaseTrackerDataStore").Return(inmemory.NewStore()).Maybe()
+	k.On("ControlSubsystemCacheStore").Return(inmemory.NewStore()).Maybe()
 	lpc := makeTestOsqLogPublisher(t, k)
 
 	// Create an instance and launch it
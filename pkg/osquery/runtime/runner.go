@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/kolide/launcher/ee/agent/flags/keys"
 	"github.com/kolide/launcher/ee/agent/types"
 	"github.com/kolide/launcher/ee/observability"
+	launcherosq "github.com/kolide/launcher/pkg/osquery"
 	"github.com/kolide/launcher/pkg/service"
 	"golang.org/x/sync/errgroup"
 )
@@ -39,6 +41,8 @@ type Runner struct {
 	interrupted      *atomic.Bool
 	needsRestart     *atomic.Bool
 	restartLock      sync.Mutex // use a restart lock to ensure we don't get multiple quick succession restarts due to in modern standy flapping
+	crashLoopLock    sync.Mutex // serializes crash loop checks across instances
+	sandboxLock      sync.Mutex // ensures we only run one sandboxed osquery instance at a time
 }
 
 func New(k types.Knapsack, serviceClient service.KolideService, logPublishClient types.OsqueryPublisher, settingsWriter settingsStoreWriter, opts ...OsqueryInstanceOption) *Runner {
@@ -146,6 +150,16 @@ func (r *Runner) runInstance(enrollmentId string) error {
 			)
 		}
 
+		// If osqueryd keeps failing on startup and we couldn't roll it back, slow down
+		// before trying again.
+		if r.checkForCrashLoop(ctx, enrollmentId) {
+			select {
+			case <-r.shutdown:
+				return nil
+			case <-time.After(launchRetryDelay):
+			}
+		}
+
 		var launchErr error
 		instance, launchErr = r.launchInstanceWithRetries(ctx, enrollmentId)
 		if launchErr != nil {
@@ -171,7 +185,7 @@ func (r *Runner) launchInstanceWithRetries(ctx context.Context, enrollmentId str
 		// Add the instance to our instances map right away, so that if we receive a shutdown
 		// request during launch, we can shut down the instance.
 		r.instanceLock.Lock()
-		instance := newInstance(enrollmentId, r.knapsack, r.serviceClient, r.logPublishClient, r.settingsWriter, r.opts...)
+		instance := newInstance(enrollmentId, r.knapsack, r.serviceClient, r.logPublishClient, r.settingsWriter, slices.Concat(r.opts, []OsqueryInstanceOption{withSandboxRunner(r)})...)
 		r.instances[enrollmentId] = instance
 		r.instanceLock.Unlock()
 		err := instance.Launch()
@@ -192,6 +206,7 @@ func (r *Runner) launchInstanceWithRetries(ctx context.Context, enrollmentId str
 			"err", err,
 			"enrollment_id", enrollmentId,
 		)
+		instance.RequestExit(types.OsqueryExitReasonLaunchFailed, err.Error())
 		instance.BeginShutdown()
 		if err := instance.WaitShutdown(ctx); err != context.Canceled && err != nil {
 			r.slogger.Log(ctx, slog.LevelWarn,
@@ -201,6 +216,10 @@ func (r *Runner) launchInstanceWithRetries(ctx context.Context, enrollmentId str
 			)
 		}
 
+		// A failed launch may mean the osqueryd version is bad -- roll back if so. We're
+		// already delaying before the next attempt, so there's no need for further backoff.
+		r.checkForCrashLoop(ctx, enrollmentId)
+
 		select {
 		case <-r.shutdown:
 			return nil, fmt.Errorf("runner received shutdown, halting before successfully launching instance for %s", enrollmentId)
@@ -212,6 +231,11 @@ func (r *Runner) launchInstanceWithRetries(ctx context.Context, enrollmentId str
 }
 
 func (r *Runner) Query(query string) ([]map[string]string, error) {
+	// Heavy queries run in a sandboxed instance, to avoid tripping the main instance's watchdog
+	if launcherosq.IsSandboxedQuery("", query) {
+		return r.querySandboxed(query)
+	}
+
 	r.instanceLock.Lock()
 	defer r.instanceLock.Unlock()
 
@@ -246,15 +270,16 @@ func (r *Runner) Shutdown() error {
 
 	close(r.shutdown)
 
-	if err := r.triggerShutdownForInstances(ctx); err != nil {
+	if err := r.triggerShutdownForInstances(ctx, types.OsqueryExitReasonLauncherShutdown, "runner shutdown"); err != nil {
 		return fmt.Errorf("triggering shutdown for instances during runner shutdown: %w", err)
 	}
 
 	return nil
 }
 
-// triggerShutdownForInstances asks all instances in `r.instances` to shut down.
-func (r *Runner) triggerShutdownForInstances(ctx context.Context) error {
+// triggerShutdownForInstances asks all instances in `r.instances` to shut down, recording
+// the given reason in instance history.
+func (r *Runner) triggerShutdownForInstances(ctx context.Context, reason string, detail string) error {
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
 
@@ -267,6 +292,7 @@ func (r *Runner) triggerShutdownForInstances(ctx context.Context) error {
 		id := enrollmentId
 		i := instance
 		shutdownWg.Go(func() error {
+			i.RequestExit(reason, detail)
 			i.BeginShutdown()
 			if err := i.WaitShutdown(ctx); err != context.Canceled && err != nil {
 				return fmt.Errorf("shutting down instance %s: %w", id, err)
@@ -312,9 +338,15 @@ func (r *Runner) FlagsChanged(ctx context.Context, flagKeys ...keys.FlagKey) {
 		"needs_restart", r.needsRestart.Load(),
 	)
 
-	// r.Restart will check if we are in modern standby and set the needsRestart flag if so and we will restart
+	// Note why we're restarting, for osquery instance history
+	restartReason := fmt.Sprintf("flag change: %v", flagKeys)
+	if len(flagKeys) == 1 && flagKeys[0] == keys.InModernStandby {
+		restartReason = "modern standby wake"
+	}
+
+	// r.RestartWithReason will check if we are in modern standby and set the needsRestart flag if so and we will restart
 	// when we exit modern standby
-	if err := r.Restart(ctx); err != nil {
+	if err := r.RestartWithReason(ctx, restartReason); err != nil {
 		r.slogger.Log(ctx, slog.LevelError,
 			"could not restart osquery instance after flag change or needed restart",
 			"err", err,
@@ -353,7 +385,7 @@ func (r *Runner) Ping() {
 		"KATC configuration changed, restarting instance to apply",
 	)
 
-	if err := r.Restart(ctx); err != nil {
+	if err := r.RestartWithReason(ctx, "KATC configuration change"); err != nil {
 		r.slogger.Log(ctx, slog.LevelError,
 			"could not restart osquery instance after KATC configuration changed",
 			"err", err,
@@ -366,6 +398,12 @@ func (r *Runner) Ping() {
 // If we are in modern standby, we will not restart now, but will instead
 // set a flag to indicate that we should restart when we exit modern standby.
 func (r *Runner) Restart(ctx context.Context) error {
+	return r.RestartWithReason(ctx, "restart requested")
+}
+
+// RestartWithReason behaves like Restart, recording the given reason for the restart
+// in osquery instance history.
+func (r *Runner) RestartWithReason(ctx context.Context, reason string) error {
 	ctx, span := observability.StartSpan(ctx)
 	defer span.End()
 
@@ -374,6 +412,7 @@ func (r *Runner) Restart(ctx context.Context) error {
 
 	r.slogger.Log(ctx, slog.LevelDebug,
 		"runner.Restart called",
+		"reason", reason,
 	)
 
 	// check to see if we are in modern standby; if so, we cannot restart now
@@ -391,7 +430,7 @@ func (r *Runner) Restart(ctx context.Context) error {
 	r.needsRestart.Store(false)
 
 	// Shut down the instances -- this will trigger a restart in each `runInstance`.
-	if err := r.triggerShutdownForInstances(ctx); err != nil {
+	if err := r.triggerShutdownForInstances(ctx, types.OsqueryExitReasonLauncherRestart, reason); err != nil {
 		return fmt.Errorf("triggering shutdown for instances during runner restart: %w", err)
 	}
 
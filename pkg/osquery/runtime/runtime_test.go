@@ -382,6 +382,7 @@ func TestPing(t *testing.T) {
 	k.On("DeregisterChangeObserver", mock.Anything).Maybe().Return()
 	k.On("UseCachedDataForScheduledQueries").Return(true).Maybe()
 	k.On("ServerReleaseTrackerDataStore").Return(inmemory.NewStore()).Maybe()
+	k.On("ControlSubsystemCacheStore").Return(inmemory.NewStore()).Maybe()
 	lpc := makeTestOsqLogPublisher(t, k)
 
 	// Start the runner
@@ -741,6 +742,7 @@ func TestMultipleInstances(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, defaultInstanceStats, "exit_time")
 	require.NotEmpty(t, defaultInstanceStats["exit_time"], "exit time should be added to default instance stats on shutdown")
+	require.Equal(t, types.OsqueryExitReasonLauncherShutdown, defaultInstanceStats["exit_reason"], "exit should be attributed to launcher shutdown")
 
 	require.Contains(t, runner.instances, extraEnrollmentId)
 	extraInstanceStats, err = osqHistory.LatestInstanceStats(extraEnrollmentId)
@@ -824,6 +826,7 @@ func TestRunnerHandlesImmediateShutdownWithMultipleInstances(t *testing.T) {
 	require.NotEmpty(t, defaultInstanceStats["connect_time"], "connect time should be added to default instance stats on start up")
 	require.Contains(t, defaultInstanceStats, "exit_time")
 	require.NotEmpty(t, defaultInstanceStats["exit_time"], "exit time should be added to default instance stats on shutdown")
+	require.Equal(t, types.OsqueryExitReasonLauncherShutdown, defaultInstanceStats["exit_reason"], "exit should be attributed to launcher shutdown")
 
 	// Confirm the additional instance was started, and then exited
 	require.Contains(t, runner.instances, extraEnrollmentId)
@@ -968,6 +971,8 @@ func TestOsqueryDies(t *testing.T) {
 	require.Contains(t, firstInstance, "errors")
 	require.NotEmpty(t, firstInstance["errors"], "error should be added to stats when unexpected shutdown occurs")
 	require.NotEmpty(t, firstInstance["exit_time"], "exit time should be added to instance when unexpected shutdown occurs")
+	require.Equal(t, types.OsqueryExitReasonCrash, firstInstance["exit_reason"], "unexpected shutdown should be recorded as a crash")
+	require.NotEmpty(t, firstInstance["binary_path"])
 	// the second instance will have already had it's start and connect time checked by wait healthy
 	// check that there is no exit time or error set
 	require.Contains(t, lastInstance, "exit_time")
@@ -1155,6 +1160,7 @@ func setUpMockStores(t *testing.T, k *typesMocks.Knapsack) {
 	k.On("BboltDB").Return(storageci.SetupDB(t)).Maybe()
 	k.On("WindowsUpdatesCacheStore").Return(inmemory.NewStore()).Maybe()
 	k.On("ServerReleaseTrackerDataStore").Return(inmemory.NewStore()).Maybe()
+	k.On("ControlSubsystemCacheStore").Return(inmemory.NewStore()).Maybe()
 }
 
 func setupHistory(t *testing.T, k *typesMocks.Knapsack) *history.History {
@@ -1244,3 +1250,69 @@ func testRootDirectory(t *testing.T) string {
 
 	return rootDir
 }
+
+func TestRunSandboxedQueries(t *testing.T) {
+	t.Parallel()
+	requirePermissions(t)
+	downloadOnceFunc()
+	setupOnceFunc()
+
+	rootDirectory := testRootDirectory(t)
+
+	logBytes, slogger := setUpTestSlogger()
+
+	k := typesMocks.NewKnapsack(t)
+	k.On("EnrollmentIDs").Return([]string{types.DefaultEnrollmentID})
+	k.On("RegisterChangeObserver", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	k.On("Slogger").Return(slogger)
+	k.On("LatestOsquerydPath", mock.Anything).Return(testOsqueryBinary)
+	k.On("RootDirectory").Return(rootDirectory).Maybe()
+	k.On("WatchdogMemoryLimitMB").Return(600)
+	k.On("WatchdogUtilizationLimitPercent").Return(50)
+	k.On("TableGenerateTimeout").Return(4 * time.Minute).Maybe()
+	k.On("GetEnrollmentDetails").Return(types.EnrollmentDetails{OSVersion: "1", Hostname: "test"}, nil).Maybe()
+	k.On("RegisterChangeObserver", mock.Anything, mock.Anything).Maybe().Return()
+	k.On("DeregisterChangeObserver", mock.Anything).Maybe().Return()
+	k.On("UseCachedDataForScheduledQueries").Return(true).Maybe()
+	setUpMockStores(t, k)
+	setupHistory(t, k)
+
+	runner := New(k, mockServiceClient(t), makeTestOsqLogPublisher(t, k), settingsstoremock.NewSettingsStoreWriter(t))
+
+	results := runner.RunSandboxedQueries(t.Context(), types.DefaultEnrollmentID,
+		map[string]string{
+			"one":             "select 1 as one;",
+			"not_discovered":  "select 2 as two;",
+			"invalid":         "select * from not_a_real_table;",
+			"launcher_tables": "select count(*) as count from osquery_registry where name = 'kolide_launcher_info';",
+			"bad_discovery":   "select 3 as three;",
+		},
+		map[string]string{
+			"not_discovered": "select 1 where 1 = 0;",
+			"bad_discovery":  "select * from not_a_real_table;",
+		},
+	)
+
+	resultsByName := make(map[string]distributed.Result)
+	for _, result := range results {
+		resultsByName[result.QueryName] = result
+	}
+	require.Len(t, resultsByName, 4, "query whose discovery query returned no rows should not run: %s", logBytes.String())
+
+	require.Equal(t, 0, resultsByName["one"].Status)
+	require.Equal(t, []map[string]string{{"one": "1"}}, resultsByName["one"].Rows)
+
+	require.Equal(t, 1, resultsByName["invalid"].Status)
+	require.NotEmpty(t, resultsByName["invalid"].Message)
+
+	require.Equal(t, 1, resultsByName["bad_discovery"].Status, "query whose discovery query failed should be reported as failed")
+	require.Contains(t, resultsByName["bad_discovery"].Message, "running discovery query")
+
+	require.Equal(t, 0, resultsByName["launcher_tables"].Status, "launcher tables should be available after relaunching the sandbox")
+	require.Equal(t, []map[string]string{{"count": "1"}}, resultsByName["launcher_tables"].Rows)
+
+	// The sandbox should leave nothing behind
+	leftovers, err := filepath.Glob(filepath.Join(rootDirectory, "osquery-sandbox-*"))
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+}
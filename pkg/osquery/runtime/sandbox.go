@@ -0,0 +1,406 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kolide/kit/ulid"
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/cgroups"
+	"github.com/kolide/launcher/ee/gowrapper"
+	kolidelog "github.com/kolide/launcher/ee/log/osquerylogs"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/pkg/backoff"
+	launcherosq "github.com/kolide/launcher/pkg/osquery"
+	"github.com/kolide/launcher/pkg/osquery/table"
+	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/plugin/distributed"
+)
+
+const (
+	// sandboxExtensionName is the name of the extension providing Kolide tables to the sandboxed
+	// osquery instance. It provides no config, distributed, or logger plugins.
+	sandboxExtensionName = "kolide_sandbox"
+
+	// The sandboxed instance's watchdog limits are stricter than the main instance's, so that a
+	// runaway query is killed before it affects the rest of the device.
+	sandboxWatchdogMemoryLimitMB           = 300
+	sandboxWatchdogUtilizationLimitPercent = 25
+
+	// How long a single query may run in the sandboxed instance.
+	sandboxQueryTimeout = 5 * time.Minute
+)
+
+// sandboxInstance is a short-lived osqueryd process used to run heavy queries away from the
+// main osquery instance. It runs no schedule, has no persistent database, and does not log
+// or request distributed queries; it only answers queries over its extension socket.
+type sandboxInstance struct {
+	knapsack     types.Knapsack
+	slogger      *slog.Logger
+	enrollmentId string
+	socketPath   string
+	pidfilePath  string
+	configPath   string
+	cmd          *exec.Cmd
+	client       *osquery.ExtensionManagerClient
+	server       *osquery.ExtensionManagerServer
+}
+
+// RunSandboxedQueries implements osquery.SandboxQueryRunner. It launches a sandboxed osquery
+// instance, runs the queries in it, and shuts it down again. If a query fails -- for example,
+// because the watchdog killed it -- the instance is relaunched for the remaining queries.
+func (r *Runner) RunSandboxedQueries(ctx context.Context, enrollmentId string, queries map[string]string, discovery map[string]string) []distributed.Result {
+	ctx, span := observability.StartSpan(ctx, "query_count", len(queries))
+	defer span.End()
+
+	// Only run one sandboxed instance at a time
+	r.sandboxLock.Lock()
+	defer r.sandboxLock.Unlock()
+
+	ctx, cancel := r.contextUntilShutdown(ctx)
+	defer cancel()
+
+	var sandbox *sandboxInstance
+	defer func() {
+		if sandbox != nil {
+			sandbox.shutdown(ctx)
+		}
+	}()
+
+	queryNames := make([]string, 0, len(queries))
+	for name := range queries {
+		queryNames = append(queryNames, name)
+	}
+	slices.Sort(queryNames)
+
+	results := make([]distributed.Result, 0, len(queries))
+	for _, name := range queryNames {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if sandbox == nil {
+			var err error
+			sandbox, err = launchSandbox(ctx, r.knapsack, enrollmentId)
+			if err != nil {
+				r.slogger.Log(ctx, slog.LevelError,
+					"could not launch sandboxed osquery instance",
+					"err", err,
+				)
+				results = append(results, failedResult(name, fmt.Errorf("launching sandboxed osquery instance: %w", err)))
+				continue
+			}
+		}
+
+		// As in osquery, a query only runs if its discovery query returns rows
+		if discoveryQuery, ok := discovery[name]; ok {
+			rows, err := sandbox.query(ctx, discoveryQuery)
+			if err != nil {
+				r.slogger.Log(ctx, slog.LevelWarn,
+					"discovery query failed in sandboxed osquery instance",
+					"query_name", name,
+					"err", err,
+				)
+				results = append(results, failedResult(name, fmt.Errorf("running discovery query: %w", err)))
+				sandbox.shutdown(ctx)
+				sandbox = nil
+				continue
+			}
+			if len(rows) == 0 {
+				continue
+			}
+		}
+
+		rows, err := sandbox.query(ctx, queries[name])
+		if err != nil {
+			r.slogger.Log(ctx, slog.LevelWarn,
+				"query failed in sandboxed osquery instance",
+				"query_name", name,
+				"err", err,
+			)
+			results = append(results, failedResult(name, err))
+			sandbox.shutdown(ctx)
+			sandbox = nil
+			continue
+		}
+
+		results = append(results, distributed.Result{
+			QueryName: name,
+			Status:    0,
+			Rows:      rows,
+		})
+	}
+
+	return results
+}
+
+// querySandboxed runs a single ad hoc query in a sandboxed osquery instance.
+func (r *Runner) querySandboxed(query string) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(context.Background())
+	defer span.End()
+
+	r.sandboxLock.Lock()
+	defer r.sandboxLock.Unlock()
+
+	ctx, cancel := r.contextUntilShutdown(ctx)
+	defer cancel()
+
+	sandbox, err := launchSandbox(ctx, r.knapsack, types.DefaultEnrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("launching sandboxed osquery instance: %w", err)
+	}
+	defer sandbox.shutdown(ctx)
+
+	return sandbox.query(ctx, query)
+}
+
+// contextUntilShutdown returns a context that is cancelled when the runner shuts down.
+func (r *Runner) contextUntilShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	gowrapper.Go(ctx, r.slogger, func() {
+		select {
+		case <-r.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+	return ctx, cancel
+}
+
+func failedResult(name string, err error) distributed.Result {
+	return distributed.Result{
+		QueryName: name,
+		Status:    1,
+		Rows:      []map[string]string{},
+		Message:   err.Error(),
+	}
+}
+
+// launchSandbox starts a sandboxed osqueryd process, and registers Kolide's tables with it.
+func launchSandbox(ctx context.Context, k types.Knapsack, enrollmentId string) (*sandboxInstance, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	runId := ulid.New()
+	rootDirectory := k.RootDirectory()
+	s := &sandboxInstance{
+		knapsack:     k,
+		slogger:      k.Slogger().With("component", "osquery_sandbox", "enrollment_id", enrollmentId, "instance_run_id", runId),
+		enrollmentId: enrollmentId,
+		socketPath:   SocketPath(rootDirectory, "sandbox-"+runId),
+		pidfilePath:  filepath.Join(rootDirectory, fmt.Sprintf("osquery-sandbox-%s.pid", runId)),
+		configPath:   filepath.Join(rootDirectory, fmt.Sprintf("osquery-sandbox-%s.conf", runId)),
+	}
+
+	// Clean up after a partial launch
+	launched := false
+	defer func() {
+		if !launched {
+			s.shutdown(ctx)
+		}
+	}()
+
+	// Without a config of its own, osquery would fall back to the system config, which may
+	// contain a schedule.
+	if err := os.WriteFile(s.configPath, []byte("{}"), 0600); err != nil {
+		return nil, fmt.Errorf("writing sandbox config: %w", err)
+	}
+
+	osquerydPath := k.LatestOsquerydPath(ctx)
+	cmd, err := s.createOsquerydCommand(osquerydPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating osqueryd command: %w", err)
+	}
+	cmd.SysProcAttr = setpgid()
+	cgroups.Get(cgroups.Osqueryd).Apply(cmd)
+
+	s.slogger.Log(ctx, slog.LevelInfo,
+		"launching sandboxed osqueryd",
+		"path", cmd.Path,
+		"args", strings.Join(cmd.Args, " "),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting osqueryd: %w", err)
+	}
+	s.cmd = cmd
+
+	if err := backoff.WaitFor(func() error {
+		_, err := os.Stat(s.socketPath)
+		return err
+	}, osqueryStartupTimeout, osqueryStartupRecheckInterval); err != nil {
+		return nil, fmt.Errorf("timeout waiting for osqueryd to create socket at %s: %w", s.socketPath, err)
+	}
+
+	if err := backoff.WaitFor(func() error {
+		var newErr error
+		s.client, newErr = osquery.NewClient(s.socketPath, socketOpenTimeout/2, osquery.DefaultWaitTime(1*time.Second), osquery.MaxWaitTime(maxSocketWaitTime))
+		return newErr
+	}, socketOpenTimeout, socketOpenInterval); err != nil {
+		return nil, fmt.Errorf("could not create an extension client: %w", err)
+	}
+
+	if err := backoff.WaitFor(func() error {
+		var newErr error
+		s.server, newErr = osquery.NewExtensionManagerServer(
+			sandboxExtensionName,
+			s.socketPath,
+			osquery.ServerTimeout(1*time.Minute),
+			osquery.WithClient(s.client),
+		)
+		return newErr
+	}, socketOpenTimeout, socketOpenInterval); err != nil {
+		return nil, fmt.Errorf("could not create an extension server: %w", err)
+	}
+	s.server.RegisterPlugin(table.PlatformTables(k, enrollmentId, s.slogger.With("component", "platform_tables"), osquerydPath)...)
+	s.server.RegisterPlugin(table.LauncherTables(k, s.slogger.With("component", "launcher_tables"))...)
+
+	server := s.server
+	gowrapper.Go(ctx, s.slogger, func() {
+		if err := server.Start(); err != nil {
+			s.slogger.Log(context.TODO(), slog.LevelDebug,
+				"sandbox extension manager server exited",
+				"err", err,
+			)
+		}
+	})
+
+	// Wait for our tables to be registered before handing out the instance
+	if err := backoff.WaitFor(func() error {
+		extensions, err := s.client.ExtensionsContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, extension := range extensions {
+			if extension.Name == sandboxExtensionName {
+				return nil
+			}
+		}
+		return errors.New("sandbox extension not registered yet")
+	}, socketOpenTimeout, socketOpenInterval); err != nil {
+		return nil, fmt.Errorf("waiting for sandbox extension registration: %w", err)
+	}
+
+	launched = true
+	return s, nil
+}
+
+// createOsquerydCommand returns the command to run the sandboxed osqueryd.
+func (s *sandboxInstance) createOsquerydCommand(osquerydBinary string) (*exec.Cmd, error) {
+	certs, err := launcherosq.InstallCaCerts(s.knapsack.RootDirectory(), s.slogger)
+	if err != nil {
+		return nil, fmt.Errorf("installing CA certs: %w", err)
+	}
+
+	args := []string{
+		"--config_plugin=filesystem",
+		fmt.Sprintf("--config_path=%s", s.configPath),
+		"--disable_distributed=true",
+		"--disable_logging=true",
+		"--disable_events=true",
+		"--disable_database=true",
+		"--host_identifier=uuid",
+		"--force=true",
+		"--utc",
+		fmt.Sprintf("--tls_server_certs=%s", certs),
+		fmt.Sprintf("--watchdog_memory_limit=%d", min(s.knapsack.WatchdogMemoryLimitMB(), sandboxWatchdogMemoryLimitMB)),
+		fmt.Sprintf("--watchdog_utilization_limit=%d", min(s.knapsack.WatchdogUtilizationLimitPercent(), sandboxWatchdogUtilizationLimitPercent)),
+		"--watchdog_delay=0",
+		fmt.Sprintf("--pidfile=%s", s.pidfilePath),
+		fmt.Sprintf("--extensions_socket=%s", s.socketPath),
+		fmt.Sprintf("--extensions_autoload=%s", filepath.Join(s.knapsack.RootDirectory(), "osquery.autoload")),
+		"--disable_extensions=false",
+		"--extensions_timeout=20",
+		fmt.Sprintf("--extensions_require=%s", sandboxExtensionName),
+	}
+
+	augeasPath := filepath.Join(s.knapsack.RootDirectory(), "augeas-lenses")
+	if _, err := os.Stat(augeasPath); err == nil && runtime.GOOS != "windows" {
+		args = append(args, fmt.Sprintf("--augeas_lenses=%s", augeasPath))
+	}
+
+	args = append(args, platformArgs()...)
+
+	// We trust the autoupdate library to find the correct path, so this is an allowable use of exec.Command
+	cmd := exec.Command( //nolint:forbidigo,noctx
+		osquerydBinary,
+		args...,
+	)
+	cmd.Stdout = kolidelog.NewOsqueryLogAdapter(
+		s.slogger.With("component", "osquery", "osqlevel", "stdout"),
+		s.knapsack.RootDirectory(),
+		kolidelog.WithLevel(slog.LevelDebug),
+	)
+	cmd.Stderr = kolidelog.NewOsqueryLogAdapter(
+		s.slogger.With("component", "osquery", "osqlevel", "stderr"),
+		s.knapsack.RootDirectory(),
+		kolidelog.WithLevel(slog.LevelInfo),
+	)
+	cmd.Env = osquerydEnvironment(cmd)
+
+	return cmd, nil
+}
+
+// query runs the given query, giving up after sandboxQueryTimeout.
+func (s *sandboxInstance) query(ctx context.Context, query string) ([]map[string]string, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, sandboxQueryTimeout)
+	defer cancel()
+
+	resp, err := s.client.QueryContext(ctx, query)
+	if err != nil {
+		observability.SetError(span, err)
+		return nil, fmt.Errorf("querying sandboxed osquery instance: %w", err)
+	}
+	if resp.Status.Code != int32(0) {
+		observability.SetError(span, errors.New(resp.Status.Message))
+		return nil, errors.New(resp.Status.Message)
+	}
+
+	return resp.Response, nil
+}
+
+// shutdown stops the sandboxed osqueryd process and cleans up after it.
+func (s *sandboxInstance) shutdown(ctx context.Context) {
+	if s.server != nil {
+		if err := s.server.Shutdown(ctx); err != nil {
+			s.slogger.Log(ctx, slog.LevelDebug,
+				"error shutting down sandbox extension server",
+				"err", err,
+			)
+		}
+	}
+	if s.client != nil {
+		s.client.Close()
+	}
+
+	if s.cmd != nil && s.cmd.Process != nil {
+		if err := killProcessGroup(s.cmd); err != nil {
+			s.slogger.Log(ctx, slog.LevelDebug,
+				"error killing sandboxed osqueryd",
+				"err", err,
+			)
+		}
+		_ = s.cmd.Wait()
+	}
+
+	for _, path := range []string{s.pidfilePath, s.configPath, s.socketPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.slogger.Log(ctx, slog.LevelDebug,
+				"could not remove sandbox file",
+				"path", path,
+				"err", err,
+			)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package osquery
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/kolide/launcher/ee/gowrapper"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/osquery/osquery-go/plugin/distributed"
+)
+
+const (
+	// SandboxQueryNamePrefix designates a distributed query as heavy: queries whose names
+	// start with this prefix run in a separate, sandboxed osquery instance, so that they
+	// cannot trip the watchdog of the instance running scheduled queries.
+	SandboxQueryNamePrefix = "kolide_sandbox:"
+
+	// SandboxQueryHint lets the server designate any query as heavy, regardless of its name,
+	// by starting the query with this comment. osquery ignores it.
+	SandboxQueryHint = "/* kolide:sandbox */"
+)
+
+// SandboxQueryRunner runs queries in a sandboxed osquery instance, separate from the
+// instance that would otherwise run them.
+type SandboxQueryRunner interface {
+	// RunSandboxedQueries runs the given distributed queries, skipping any whose discovery
+	// query returns no rows, and returns a result for every query that ran or failed.
+	RunSandboxedQueries(ctx context.Context, enrollmentId string, queries map[string]string, discovery map[string]string) []distributed.Result
+}
+
+// IsSandboxedQuery reports whether the query with the given name and SQL has been designated
+// heavy, by name prefix or by server hint. The name may be empty for ad hoc queries.
+func IsSandboxedQuery(name string, query string) bool {
+	return strings.HasPrefix(name, SandboxQueryNamePrefix) ||
+		strings.HasPrefix(strings.TrimSpace(query), SandboxQueryHint)
+}
+
+// dispatchSandboxedQueries removes the heavy queries from the given queries, and hands them
+// off to the sandbox runner in the background. Their results are published through this
+// extension, the same as results from osquery. Queries that are still queued or running in
+// the sandbox from an earlier call are not dispatched again. It returns the queries osquery
+// should run.
+func (e *Extension) dispatchSandboxedQueries(ctx context.Context, queries *distributed.GetQueriesResult) *distributed.GetQueriesResult {
+	if e.Opts.SandboxRunner == nil || queries == nil || len(queries.Queries) == 0 {
+		return queries
+	}
+
+	remaining := &distributed.GetQueriesResult{
+		Queries:           make(map[string]string),
+		AccelerateSeconds: queries.AccelerateSeconds,
+	}
+	sandboxed := make(map[string]string)
+	sandboxedDiscovery := make(map[string]string)
+	for name, query := range queries.Queries {
+		if !IsSandboxedQuery(name, query) {
+			remaining.Queries[name] = query
+			if discovery, ok := queries.Discovery[name]; ok {
+				if remaining.Discovery == nil {
+					remaining.Discovery = make(map[string]string)
+				}
+				remaining.Discovery[name] = discovery
+			}
+			continue
+		}
+
+		sandboxed[name] = query
+		if discovery, ok := queries.Discovery[name]; ok {
+			sandboxedDiscovery[name] = discovery
+		}
+	}
+
+	if len(sandboxed) == 0 {
+		return queries
+	}
+
+	// The server re-sends queries until it receives their results; skip those we're already working on
+	for name := range e.claimSandboxQueries(sandboxed) {
+		delete(sandboxed, name)
+		delete(sandboxedDiscovery, name)
+	}
+	if len(sandboxed) == 0 {
+		e.slogger.Log(ctx, slog.LevelDebug,
+			"heavy distributed queries already in flight in sandboxed osquery instance",
+		)
+		return remaining
+	}
+
+	e.slogger.Log(ctx, slog.LevelInfo,
+		"running heavy distributed queries in sandboxed osquery instance",
+		"query_count", len(sandboxed),
+	)
+
+	// The request context ends when osquery's GetQueries call returns, so run detached from it
+	gowrapper.Go(context.Background(), e.slogger, func() {
+		ctx, span := observability.StartSpan(context.Background(), "query_count", len(sandboxed))
+		defer span.End()
+		defer e.releaseSandboxQueries(sandboxed)
+
+		results := e.Opts.SandboxRunner.RunSandboxedQueries(ctx, e.enrollmentId, sandboxed, sandboxedDiscovery)
+		if len(results) == 0 {
+			return
+		}
+
+		if err := e.WriteResults(ctx, results); err != nil {
+			e.slogger.Log(ctx, slog.LevelError,
+				"could not write results of sandboxed queries",
+				"query_count", len(results),
+				"err", err,
+			)
+		}
+	})
+
+	return remaining
+}
+
+// claimSandboxQueries marks the given queries as in flight in the sandbox, returning the names of
+// any that already were.
+func (e *Extension) claimSandboxQueries(queries map[string]string) map[string]struct{} {
+	e.sandboxInFlightLock.Lock()
+	defer e.sandboxInFlightLock.Unlock()
+
+	if e.sandboxInFlight == nil {
+		e.sandboxInFlight = make(map[string]struct{})
+	}
+
+	alreadyInFlight := make(map[string]struct{})
+	for name := range queries {
+		if _, ok := e.sandboxInFlight[name]; ok {
+			alreadyInFlight[name] = struct{}{}
+			continue
+		}
+		e.sandboxInFlight[name] = struct{}{}
+	}
+
+	return alreadyInFlight
+}
+
+// releaseSandboxQueries marks the given queries as no longer in flight in the sandbox.
+func (e *Extension) releaseSandboxQueries(queries map[string]string) {
+	e.sandboxInFlightLock.Lock()
+	defer e.sandboxInFlightLock.Unlock()
+
+	for name := range queries {
+		delete(e.sandboxInFlight, name)
+	}
+}
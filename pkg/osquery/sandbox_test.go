@@ -0,0 +1,208 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kolide/kit/ulid"
+	settingsstoremock "github.com/kolide/launcher/pkg/osquery/mocks"
+	"github.com/kolide/launcher/pkg/service/mock"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSandboxedQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name      string
+		queryName string
+		query     string
+		expected  bool
+	}{
+		{name: "ordinary query", queryName: "kolide_distributed_1", query: "select * from time;", expected: false},
+		{name: "name prefix", queryName: SandboxQueryNamePrefix + "hashes", query: "select * from hash;", expected: true},
+		{name: "server hint", queryName: "kolide_distributed_2", query: "  " + SandboxQueryHint + " select * from hash;", expected: true},
+		{name: "server hint without name", query: SandboxQueryHint + "\nselect * from hash;", expected: true},
+		{name: "hint not at start", queryName: "kolide_distributed_3", query: "select * from hash; " + SandboxQueryHint, expected: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, IsSandboxedQuery(tt.queryName, tt.query))
+		})
+	}
+}
+
+type fakeSandboxRunner struct {
+	queries   chan map[string]string
+	discovery chan map[string]string
+}
+
+func (f *fakeSandboxRunner) RunSandboxedQueries(_ context.Context, _ string, queries map[string]string, discovery map[string]string) []distributed.Result {
+	f.queries <- queries
+	f.discovery <- discovery
+
+	results := make([]distributed.Result, 0, len(queries))
+	for name := range queries {
+		results = append(results, distributed.Result{QueryName: name, Rows: []map[string]string{{"sandboxed": "1"}}})
+	}
+	return results
+}
+
+func TestExtensionGetQueries_Sandboxed(t *testing.T) {
+	t.Parallel()
+
+	heavyName := SandboxQueryNamePrefix + "hashes"
+	hintedQuery := SandboxQueryHint + " select * from hash where path = '/etc/hosts';"
+	published := make(chan []distributed.Result, 1)
+	m := &mock.KolideService{
+		RequestQueriesFunc: func(ctx context.Context, nodeKey string) (*distributed.GetQueriesResult, bool, error) {
+			return &distributed.GetQueriesResult{
+				Queries: map[string]string{
+					"time":    "select * from time",
+					heavyName: "select * from hash where directory = '/usr/bin';",
+					"hinted":  hintedQuery,
+				},
+				Discovery: map[string]string{
+					"time":    "select 1",
+					heavyName: "select 1 from os_version where platform = 'darwin'",
+				},
+			}, false, nil
+		},
+		PublishResultsFunc: func(ctx context.Context, nodeKey string, results []distributed.Result) (string, string, bool, error) {
+			published <- results
+			return "", "", false, nil
+		},
+	}
+	k := makeKnapsack(t)
+	lpc := makeTestOsqLogPublisher(k)
+	sandboxRunner := &fakeSandboxRunner{
+		queries:   make(chan map[string]string, 1),
+		discovery: make(chan map[string]string, 1),
+	}
+	e, err := NewExtension(t.Context(), m, lpc, settingsstoremock.NewSettingsStoreWriter(t), k, ulid.New(), ExtensionOpts{SandboxRunner: sandboxRunner})
+	require.NoError(t, err)
+
+	queries, err := e.GetQueries(t.Context())
+	require.NoError(t, err)
+
+	// Only the ordinary query goes to osquery
+	require.Equal(t, map[string]string{"time": "select * from time"}, queries.Queries)
+	require.Equal(t, map[string]string{"time": "select 1"}, queries.Discovery)
+
+	// The heavy queries go to the sandbox, along with their discovery queries
+	select {
+	case sandboxed := <-sandboxRunner.queries:
+		require.Len(t, sandboxed, 2)
+		require.Equal(t, hintedQuery, sandboxed["hinted"])
+		require.Contains(t, sandboxed, heavyName)
+		require.Equal(t, map[string]string{heavyName: "select 1 from os_version where platform = 'darwin'"}, <-sandboxRunner.discovery)
+	case <-time.After(5 * time.Second):
+		t.Fatal("heavy queries not sent to sandbox")
+	}
+
+	// And their results are published through the extension
+	select {
+	case results := <-published:
+		require.Len(t, results, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sandboxed results not published")
+	}
+}
+
+func TestExtensionGetQueries_NoSandboxRunner(t *testing.T) {
+	t.Parallel()
+
+	expectedQueries := map[string]string{
+		"time":                           "select * from time",
+		SandboxQueryNamePrefix + "heavy": "select * from hash where directory = '/usr/bin';",
+	}
+	m := &mock.KolideService{
+		RequestQueriesFunc: func(ctx context.Context, nodeKey string) (*distributed.GetQueriesResult, bool, error) {
+			return &distributed.GetQueriesResult{Queries: expectedQueries}, false, nil
+		},
+	}
+	k := makeKnapsack(t)
+	lpc := makeTestOsqLogPublisher(k)
+	e, err := NewExtension(t.Context(), m, lpc, settingsstoremock.NewSettingsStoreWriter(t), k, ulid.New(), ExtensionOpts{})
+	require.NoError(t, err)
+
+	// Without a sandbox, osquery runs everything
+	queries, err := e.GetQueries(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, expectedQueries, queries.Queries)
+}
+
+func TestExtensionGetQueries_SandboxedAlreadyInFlight(t *testing.T) {
+	t.Parallel()
+
+	heavyName := SandboxQueryNamePrefix + "hashes"
+	published := make(chan []distributed.Result, 1)
+	m := &mock.KolideService{
+		RequestQueriesFunc: func(ctx context.Context, nodeKey string) (*distributed.GetQueriesResult, bool, error) {
+			return &distributed.GetQueriesResult{
+				Queries: map[string]string{
+					"time":    "select * from time",
+					heavyName: "select * from hash where directory = '/usr/bin';",
+				},
+			}, false, nil
+		},
+		PublishResultsFunc: func(ctx context.Context, nodeKey string, results []distributed.Result) (string, string, bool, error) {
+			published <- results
+			return "", "", false, nil
+		},
+	}
+	k := makeKnapsack(t)
+	lpc := makeTestOsqLogPublisher(k)
+	// Unbuffered, so that the sandbox run blocks until we read its queries
+	sandboxRunner := &fakeSandboxRunner{
+		queries:   make(chan map[string]string),
+		discovery: make(chan map[string]string, 1),
+	}
+	e, err := NewExtension(t.Context(), m, lpc, settingsstoremock.NewSettingsStoreWriter(t), k, ulid.New(), ExtensionOpts{SandboxRunner: sandboxRunner})
+	require.NoError(t, err)
+
+	// The server re-sends the heavy query while the sandbox is still working on it
+	for range 2 {
+		queries, err := e.GetQueries(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"time": "select * from time"}, queries.Queries)
+	}
+
+	// It is only dispatched to the sandbox once
+	select {
+	case sandboxed := <-sandboxRunner.queries:
+		require.Contains(t, sandboxed, heavyName)
+		<-sandboxRunner.discovery
+	case <-time.After(5 * time.Second):
+		t.Fatal("heavy query not sent to sandbox")
+	}
+	select {
+	case results := <-published:
+		require.Len(t, results, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sandboxed results not published")
+	}
+	select {
+	case <-sandboxRunner.queries:
+		t.Fatal("heavy query sent to sandbox again while in flight")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// Once its results are written, it can be dispatched again
+	require.Eventually(t, func() bool {
+		e.sandboxInFlightLock.Lock()
+		defer e.sandboxInFlightLock.Unlock()
+		return len(e.sandboxInFlight) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	_, err = e.GetQueries(t.Context())
+	require.NoError(t, err)
+	select {
+	case sandboxed := <-sandboxRunner.queries:
+		require.Contains(t, sandboxed, heavyName)
+		<-sandboxRunner.discovery
+	case <-time.After(5 * time.Second):
+		t.Fatal("heavy query not sent to sandbox after previous run completed")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	typesmocks "github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
 	"github.com/kolide/launcher/pkg/log/multislogger"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -45,7 +46,7 @@ func TestGDriveSyncHistoryInfo(t *testing.T) { //nolint:paralleltest // We need
 	require.NoError(t, db.Close())
 
 	// Point the table to this new db by modifying package vars
-	homeDirLocations[runtime.GOOS] = append(homeDirLocations[runtime.GOOS], tempHomeDir)
+	tablehelpers.HomeDirLocations[runtime.GOOS] = append(tablehelpers.HomeDirLocations[runtime.GOOS], tempHomeDir)
 
 	// Create table and verify the name is what we expect
 	gdriveHistoryTable := GDriveSyncHistoryInfo(mockFlags, slogger)
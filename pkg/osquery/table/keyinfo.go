@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log/slog"
 	"strconv"
+	"strings"
 
 	"github.com/kolide/launcher/ee/agent/types"
 	"github.com/kolide/launcher/ee/keyidentifier"
@@ -20,14 +21,9 @@ type KeyInfoTable struct {
 
 func KeyInfo(flags types.Flags, slogger *slog.Logger) *table.Plugin {
 
-	columns := []table.ColumnDefinition{
+	columns := append([]table.ColumnDefinition{
 		table.TextColumn("path"),
-		table.TextColumn("type"),
-		table.IntegerColumn("encrypted"),
-		table.IntegerColumn("bits"),
-		table.TextColumn("fingerprint_sha256"),
-		table.TextColumn("fingerprint_md5"),
-	}
+	}, keyInfoColumns()...)
 
 	// we don't want the logging in osquery, so don't instantiate WithSlogger()
 	kIdentifer, err := keyidentifier.New()
@@ -69,29 +65,85 @@ func (t *KeyInfoTable) generate(ctx context.Context, queryContext table.QueryCon
 			continue
 		}
 
-		res := map[string]string{
-			"path": constraint.Expression,
-			"type": ki.Type,
-		}
+		res := keyInfoRow(ki)
+		res["path"] = constraint.Expression
 
-		if ki.Encrypted != nil {
-			res["encrypted"] = strconv.Itoa(btoi(*ki.Encrypted))
-		}
+		results = append(results, res)
+	}
 
-		if ki.Bits != 0 {
-			res["bits"] = strconv.FormatInt(int64(ki.Bits), 10)
-		}
+	return results, nil
 
-		if ki.FingerprintSHA256 != "" {
-			res["fingerprint_sha256"] = ki.FingerprintSHA256
-		}
-		if ki.FingerprintMD5 != "" {
-			res["fingerprint_md5"] = ki.FingerprintMD5
-		}
+}
 
-		results = append(results, res)
+// keyInfoColumns are the columns shared by the tables that report on
+// keyidentifier results.
+func keyInfoColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("type"),
+		table.TextColumn("format"),
+		table.IntegerColumn("encrypted"),
+		table.IntegerColumn("bits"),
+		table.TextColumn("fingerprint_sha256"),
+		table.TextColumn("fingerprint_md5"),
+		table.TextColumn("cipher"),
+		table.TextColumn("kdf"),
+		table.IntegerColumn("kdf_rounds"),
+		table.IntegerColumn("hardware_backed"),
+		table.TextColumn("cert_type"),
+		table.TextColumn("cert_key_id"),
+		table.TextColumn("cert_principals"),
+		table.BigIntColumn("cert_valid_after"),
+		table.BigIntColumn("cert_valid_before"),
+		table.TextColumn("cert_signing_ca_fingerprint_sha256"),
 	}
+}
 
-	return results, nil
+// keyInfoRow converts a KeyInfo into a row for keyInfoColumns. Unknown
+// values are left unset, so they come through as null.
+func keyInfoRow(ki *keyidentifier.KeyInfo) map[string]string {
+	res := map[string]string{
+		"type":            ki.Type,
+		"format":          ki.Format,
+		"hardware_backed": strconv.Itoa(btoi(ki.HardwareBacked)),
+	}
+
+	if ki.Encrypted != nil {
+		res["encrypted"] = strconv.Itoa(btoi(*ki.Encrypted))
+	}
+
+	if ki.Bits != 0 {
+		res["bits"] = strconv.FormatInt(int64(ki.Bits), 10)
+	}
+
+	if ki.FingerprintSHA256 != "" {
+		res["fingerprint_sha256"] = ki.FingerprintSHA256
+	}
+	if ki.FingerprintMD5 != "" {
+		res["fingerprint_md5"] = ki.FingerprintMD5
+	}
+
+	if ki.Cipher != "" {
+		res["cipher"] = ki.Cipher
+	}
+	if ki.Kdf != "" {
+		res["kdf"] = ki.Kdf
+	}
+	if ki.KdfRounds != 0 {
+		res["kdf_rounds"] = strconv.Itoa(ki.KdfRounds)
+	}
+
+	if cert := ki.Certificate; cert != nil {
+		res["cert_type"] = cert.CertType
+		res["cert_key_id"] = cert.KeyId
+		res["cert_principals"] = strings.Join(cert.Principals, ",")
+		res["cert_signing_ca_fingerprint_sha256"] = cert.SigningCAFingerprintSHA256
+		if !cert.ValidAfter.IsZero() {
+			res["cert_valid_after"] = strconv.FormatInt(cert.ValidAfter.Unix(), 10)
+		}
+		if !cert.ValidBefore.IsZero() {
+			res["cert_valid_before"] = strconv.FormatInt(cert.ValidBefore.Unix(), 10)
+		}
+	}
 
+	return res
 }
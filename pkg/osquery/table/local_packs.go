@@ -0,0 +1,54 @@
+package table
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/kolide/launcher/ee/tables/tablewrapper"
+	"github.com/kolide/launcher/pkg/osquery"
+	"github.com/kolide/launcher/pkg/osquery/localpacks"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// LocalPacksTable reports on the packs in the local pack directory, and whether each
+// was merged into the osquery config.
+func LocalPacksTable(flags types.Flags, slogger *slog.Logger, rootDirectory string, store types.Getter) *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("path"),
+		table.TextColumn("name"),
+		table.TextColumn("state"),
+		table.IntegerColumn("query_count"),
+		table.TextColumn("error"),
+	}
+	return tablewrapper.New(flags, slogger, "kolide_local_packs", columns, generateLocalPacks(rootDirectory, store))
+}
+
+func generateLocalPacks(rootDirectory string, store types.Getter) table.GenerateFunc {
+	return func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		_, span := observability.StartSpan(ctx, "table_name", "kolide_local_packs")
+		defer span.End()
+
+		// Local packs are merged into the cached config the same way they are
+		// merged into the config osquery received
+		config, err := osquery.Config(store, types.DefaultEnrollmentID)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := localpacks.Statuses(localpacks.Dir(rootDirectory), config)
+		results := make([]map[string]string, 0, len(statuses))
+		for _, status := range statuses {
+			results = append(results, map[string]string{
+				"path":        status.Path,
+				"name":        status.Name,
+				"state":       status.State,
+				"query_count": strconv.Itoa(status.QueryCount),
+				"error":       status.Error,
+			})
+		}
+		return results, nil
+	}
+}
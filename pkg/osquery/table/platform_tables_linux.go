@@ -30,6 +30,7 @@ import (
 	brew_upgradeable "github.com/kolide/launcher/ee/tables/homebrew"
 	nix_env_upgradeable "github.com/kolide/launcher/ee/tables/nix_env/upgradeable"
 	"github.com/kolide/launcher/ee/tables/secureboot"
+	"github.com/kolide/launcher/ee/tables/tpm_info"
 	"github.com/kolide/launcher/ee/tables/xfconf"
 	"github.com/kolide/launcher/ee/tables/xrdb"
 	"github.com/kolide/launcher/ee/tables/zfs"
@@ -44,6 +45,7 @@ func platformSpecificTables(k types.Knapsack, slogger *slog.Logger, currentOsque
 		gsettings.Metadata(k, slogger),
 		nix_env_upgradeable.TablePlugin(k, slogger),
 		secureboot.TablePlugin(k, slogger),
+		tpm_info.TablePlugin(k, slogger, k.ConfigStore()),
 		xrdb.TablePlugin(k, slogger),
 		fscrypt_info.TablePlugin(k, slogger),
 		falcon_kernel_check.TablePlugin(k, slogger),
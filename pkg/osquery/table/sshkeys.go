@@ -8,7 +8,6 @@ import (
 	"context"
 	"log/slog"
 	"runtime"
-	"strconv"
 
 	"github.com/kolide/launcher/ee/agent/types"
 	"github.com/kolide/launcher/ee/keyidentifier"
@@ -30,15 +29,10 @@ type SshKeysTable struct {
 
 // New returns a new table extension
 func SshKeys(flags types.Flags, slogger *slog.Logger) *table.Plugin {
-	columns := []table.ColumnDefinition{
+	columns := append([]table.ColumnDefinition{
 		table.TextColumn("user"),
 		table.TextColumn("path"),
-		table.TextColumn("type"),
-		table.IntegerColumn("encrypted"),
-		table.IntegerColumn("bits"),
-		table.TextColumn("fingerprint_sha256"),
-		table.TextColumn("fingerprint_md5"),
-	}
+	}, keyInfoColumns()...)
 
 	// we don't want the logging in osquery, so don't instantiate WithSlogger()
 	kIdentifer, err := keyidentifier.New()
@@ -92,26 +86,9 @@ func (t *SshKeysTable) generate(ctx context.Context, queryContext table.QueryCon
 				continue
 			}
 
-			res := map[string]string{
-				"path": file.path,
-				"user": file.user,
-				"type": ki.Type,
-			}
-
-			if ki.Encrypted != nil {
-				res["encrypted"] = strconv.Itoa(btoi(*ki.Encrypted))
-			}
-
-			if ki.Bits != 0 {
-				res["bits"] = strconv.FormatInt(int64(ki.Bits), 10)
-			}
-
-			if ki.FingerprintSHA256 != "" {
-				res["fingerprint_sha256"] = ki.FingerprintSHA256
-			}
-			if ki.FingerprintMD5 != "" {
-				res["fingerprint_md5"] = ki.FingerprintMD5
-			}
+			res := keyInfoRow(ki)
+			res["path"] = file.path
+			res["user"] = file.user
 
 			results = append(results, res)
 		}
@@ -12,6 +12,7 @@ import (
 	"github.com/kolide/launcher/ee/agent/types"
 	"github.com/kolide/launcher/ee/allowedcmd"
 	"github.com/kolide/launcher/ee/katc"
+	"github.com/kolide/launcher/ee/tables/control_subsystems"
 	"github.com/kolide/launcher/ee/tables/cryptoinfotable"
 	"github.com/kolide/launcher/ee/tables/dataflattentable"
 	"github.com/kolide/launcher/ee/tables/desktopprocs"
@@ -19,12 +20,15 @@ import (
 	json_parser "github.com/kolide/launcher/ee/tables/execparsers/json"
 	"github.com/kolide/launcher/ee/tables/firefox_preferences"
 	"github.com/kolide/launcher/ee/tables/jwt"
+	"github.com/kolide/launcher/ee/tables/language_packages"
 	"github.com/kolide/launcher/ee/tables/launcher_db"
+	"github.com/kolide/launcher/ee/tables/launcher_table_stats"
 	"github.com/kolide/launcher/ee/tables/osquery_instance_history"
 	"github.com/kolide/launcher/ee/tables/release_tracker_data"
 	"github.com/kolide/launcher/ee/tables/sleeper"
 	"github.com/kolide/launcher/ee/tables/tdebug"
 	"github.com/kolide/launcher/ee/tables/tufinfo"
+	"github.com/kolide/launcher/ee/tables/vscode_extensions"
 
 	osquery "github.com/osquery/osquery-go"
 )
@@ -36,6 +40,7 @@ func LauncherTables(k types.Knapsack, slogger *slog.Logger) []osquery.OsqueryPlu
 		LauncherConfigTable(k, slogger, k.ConfigStore(), k),
 		LauncherDbInfo(k, slogger, k.BboltDB()),
 		LauncherInfoTable(k, slogger, k.ConfigStore(), k.LauncherHistoryStore()),
+		LocalPacksTable(k, slogger, k.RootDirectory(), k.ConfigStore()),
 		launcher_db.TablePlugin(k, slogger, "kolide_server_data", k.ServerProvidedDataStore()),
 		launcher_db.TablePlugin(k, slogger, "kolide_control_flags", k.AgentFlagsStore()),
 		LauncherAutoupdateConfigTable(slogger, k),
@@ -43,6 +48,8 @@ func LauncherTables(k types.Knapsack, slogger *slog.Logger) []osquery.OsqueryPlu
 		release_tracker_data.TablePlugin(k, slogger, k.ServerReleaseTrackerDataStore()),
 		tufinfo.TufReleaseVersionTable(slogger, k),
 		desktopprocs.TablePlugin(k, slogger),
+		launcher_table_stats.TablePlugin(k, slogger),
+		control_subsystems.TablePlugin(k, slogger, k.ControlSubsystemCacheStore()),
 	}
 }
 
@@ -61,6 +68,8 @@ func PlatformTables(k types.Knapsack, enrollmentId string, slogger *slog.Logger,
 		firefox_preferences.TablePlugin(k, slogger),
 		sleeper.TablePlugin(k, slogger),
 		jwt.TablePlugin(k, slogger),
+		vscode_extensions.TablePlugin(k, slogger),
+		language_packages.TablePlugin(k, slogger),
 		dataflattentable.NewExecAndParseTable(k, slogger, "kolide_zerotier_info", json_parser.Parser, allowedcmd.ZerotierCli, []string{"info", "-j"}),
 		dataflattentable.NewExecAndParseTable(k, slogger, "kolide_zerotier_networks", json_parser.Parser, allowedcmd.ZerotierCli, []string{"listnetworks", "-j"}),
 		dataflattentable.NewExecAndParseTable(k, slogger, "kolide_zerotier_peers", json_parser.Parser, allowedcmd.ZerotierCli, []string{"listpeers", "-j"}),
@@ -5,7 +5,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
+
+	"github.com/kolide/launcher/ee/tables/tablehelpers"
 )
 
 type findFile struct {
@@ -20,12 +21,6 @@ func WithUsername(username string) FindFileOpt {
 	}
 }
 
-var homeDirLocations = map[string][]string{
-	"windows": {"/Users"}, // windows10 uses /Users
-	"darwin":  {"/Users"},
-}
-var homeDirDefaultLocation = []string{"/home"}
-
 type userFileInfo struct {
 	user string
 	path string
@@ -41,56 +36,15 @@ func findFileInUserDirs(pattern string, slogger *slog.Logger, opts ...FindFileOp
 		opt(ff)
 	}
 
-	homedirRoots, ok := homeDirLocations[runtime.GOOS]
-	if !ok {
-		homedirRoots = homeDirDefaultLocation
-		slogger.Log(context.TODO(), slog.LevelDebug,
-			"platform not found using default",
-			"home_dir_root", homedirRoots,
-		)
-	}
-
-	foundPaths := []userFileInfo{}
-
 	// Redo/remove when we make username a required parameter
-	if ff.username == "" {
-		for _, possibleHome := range homedirRoots {
-
-			userDirs, err := os.ReadDir(possibleHome)
-			if err != nil {
-				// This possibleHome doesn't exist. Move on
-				continue
-			}
-
-			// For each user's dir, in this possibleHome, check!
-			for _, ud := range userDirs {
-				userPathPattern := filepath.Join(possibleHome, ud.Name(), pattern)
-				fullPaths, err := filepath.Glob(userPathPattern)
-				if err != nil {
-					// skipping ErrBadPattern
-					slogger.Log(context.TODO(), slog.LevelDebug,
-						"bad file pattern",
-						"pattern", userPathPattern,
-					)
-					continue
-				}
-				for _, fullPath := range fullPaths {
-					if stat, err := os.Stat(fullPath); err == nil && stat.Mode().IsRegular() {
-						foundPaths = append(foundPaths, userFileInfo{
-							user: ud.Name(),
-							path: fullPath,
-						})
-					}
-				}
-			}
-		}
-
-		return foundPaths, nil
+	var usernames []string
+	if ff.username != "" {
+		usernames = append(usernames, ff.username)
 	}
 
-	// We have a username. Future normal path here
-	for _, possibleHome := range homedirRoots {
-		userPathPattern := filepath.Join(possibleHome, ff.username, pattern)
+	foundPaths := []userFileInfo{}
+	for _, homeDir := range tablehelpers.UserHomeDirs(usernames...) {
+		userPathPattern := filepath.Join(homeDir.Path, pattern)
 		fullPaths, err := filepath.Glob(userPathPattern)
 		if err != nil {
 			// skipping ErrBadPattern
@@ -103,12 +57,13 @@ func findFileInUserDirs(pattern string, slogger *slog.Logger, opts ...FindFileOp
 		for _, fullPath := range fullPaths {
 			if stat, err := os.Stat(fullPath); err == nil && stat.Mode().IsRegular() {
 				foundPaths = append(foundPaths, userFileInfo{
-					user: ff.username,
+					user: homeDir.Username,
 					path: fullPath,
 				})
 			}
 		}
 	}
+
 	return foundPaths, nil
 }
 
@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-kit/kit/transport/http/jsonrpc"
 	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/compression"
 )
 
 // forceNoChunkedEncoding forces the connection not to use chunked
@@ -85,6 +86,9 @@ func NewJSONRPCClient(
 		}
 	}
 
+	// Compress request bodies once the server advertises that it accepts compressed requests
+	httpClient.Transport = compression.NewTransport(httpClient.Transport, compression.NewNegotiator())
+
 	commonOpts := []jsonrpc.ClientOption{
 		jsonrpc.SetClient(httpClient),
 		jsonrpc.ClientBefore(
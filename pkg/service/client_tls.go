@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kolide/launcher/ee/agent/types"
+	"github.com/kolide/launcher/ee/observability"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+)
+
+// Paths of osquery's TLS remote API, see https://osquery.readthedocs.io/en/stable/deployment/remote/
+const (
+	tlsEnrollPath           = "/api/v1/osquery/enroll"
+	tlsConfigPath           = "/api/v1/osquery/config"
+	tlsLogPath              = "/api/v1/osquery/log"
+	tlsDistributedReadPath  = "/api/v1/osquery/distributed/read"
+	tlsDistributedWritePath = "/api/v1/osquery/distributed/write"
+
+	// tlsHealthPath is not part of osquery's remote API, but is served by common
+	// implementations of it (for example, Fleet).
+	tlsHealthPath = "/healthz"
+
+	// maxTLSResponseSize bounds how much of a response body we will read.
+	maxTLSResponseSize = 64 << 20
+)
+
+// tlsClient implements KolideService against a server speaking osquery's
+// standard TLS remote API.
+type tlsClient struct {
+	serverURL  *url.URL
+	httpClient *http.Client
+}
+
+// NewTLSClient creates a new client (implementation of the KolideService
+// interface) that speaks the osquery TLS remote API. An invalid node key
+// reported by the server is surfaced as reauth, the same as the JSONRPC client.
+func NewTLSClient(k types.Knapsack, rootPool *x509.CertPool) KolideService {
+	serverURL := &url.URL{
+		Scheme: "https",
+		Host:   k.KolideServerURL(),
+	}
+
+	httpClient := &http.Client{
+		Timeout: time.Second * 30,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+	}
+
+	if k.InsecureTransportTLS() {
+		serverURL.Scheme = "http"
+	} else {
+		tlsConfig := makeTLSConfig(k, rootPool)
+		// Self-hosted servers frequently listen on a non-default port, which
+		// must not be part of the name the certificate is verified against.
+		tlsConfig.ServerName = serverURL.Hostname()
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		}
+	}
+
+	var client KolideService = &tlsClient{
+		serverURL:  serverURL,
+		httpClient: httpClient,
+	}
+
+	client = LoggingMiddleware(k)(client)
+	// Wrap with UUID middleware after logger so that UUID is available in
+	// the logger context.
+	client = uuidMiddleware(client)
+
+	return client
+}
+
+type tlsNodeInvalidResponse struct {
+	NodeInvalid bool   `json:"node_invalid"`
+	Error       string `json:"error,omitempty"`
+}
+
+type tlsEnrollRequest struct {
+	EnrollSecret   string         `json:"enroll_secret"`
+	HostIdentifier string         `json:"host_identifier"`
+	HostDetails    tlsHostDetails `json:"host_details"`
+}
+
+// tlsHostDetails mirrors the tables that osquery itself sends on enrollment.
+type tlsHostDetails struct {
+	OSVersion    map[string]string `json:"os_version"`
+	OsqueryInfo  map[string]string `json:"osquery_info"`
+	SystemInfo   map[string]string `json:"system_info"`
+	PlatformInfo map[string]string `json:"platform_info"`
+}
+
+type tlsEnrollResponse struct {
+	tlsNodeInvalidResponse
+	NodeKey string `json:"node_key"`
+}
+
+type tlsNodeKeyRequest struct {
+	NodeKey string `json:"node_key"`
+}
+
+type tlsLogRequest struct {
+	NodeKey string            `json:"node_key"`
+	LogType string            `json:"log_type"`
+	Data    []json.RawMessage `json:"data"`
+}
+
+type tlsDistributedReadResponse struct {
+	tlsNodeInvalidResponse
+	distributed.GetQueriesResult
+}
+
+type tlsDistributedWriteRequest struct {
+	NodeKey  string                         `json:"node_key"`
+	Queries  map[string][]map[string]string `json:"queries"`
+	Statuses map[string]int                 `json:"statuses"`
+	Messages map[string]string              `json:"messages"`
+	Stats    map[string]*distributed.Stats  `json:"stats,omitempty"`
+}
+
+// RequestEnrollment implements KolideService.RequestEnrollment. The TLS API has no
+// agent ingester, so the returned token is always empty.
+func (c *tlsClient) RequestEnrollment(ctx context.Context, enrollSecret, hostIdentifier string, details EnrollmentDetails) (string, bool, string, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	req := tlsEnrollRequest{
+		EnrollSecret:   enrollSecret,
+		HostIdentifier: hostIdentifier,
+		HostDetails: tlsHostDetails{
+			OSVersion: map[string]string{
+				"name":          details.OSName,
+				"version":       details.OSVersion,
+				"build":         details.OSBuildID,
+				"platform":      details.OSPlatform,
+				"platform_like": details.OSPlatformLike,
+				"arch":          details.GOARCH,
+			},
+			OsqueryInfo: map[string]string{
+				"version": details.OsqueryVersion,
+			},
+			SystemInfo: map[string]string{
+				"hostname":        details.Hostname,
+				"uuid":            details.HardwareUUID,
+				"hardware_vendor": details.HardwareVendor,
+				"hardware_model":  details.HardwareModel,
+				"hardware_serial": details.HardwareSerial,
+				"cpu_type":        details.GOARCH,
+				"computer_name":   details.Hostname,
+				"local_hostname":  details.Hostname,
+			},
+			PlatformInfo: map[string]string{},
+		},
+	}
+
+	var resp tlsEnrollResponse
+	if err := c.post(ctx, tlsEnrollPath, req, &resp); err != nil {
+		if errors.Is(err, errTLSNodeInvalid) {
+			return "", true, "", nil
+		}
+		observability.SetError(span, err)
+		return "", false, "", err
+	}
+
+	if resp.NodeInvalid {
+		return "", true, "", nil
+	}
+
+	if resp.NodeKey == "" {
+		return "", false, "", errors.New("enrollment response did not include a node key")
+	}
+
+	return resp.NodeKey, false, "", nil
+}
+
+// RequestConfig implements KolideService.RequestConfig. The whole response body is the config.
+func (c *tlsClient) RequestConfig(ctx context.Context, nodeKey string) (string, bool, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	var raw json.RawMessage
+	if err := c.post(ctx, tlsConfigPath, tlsNodeKeyRequest{NodeKey: nodeKey}, &raw); err != nil {
+		if errors.Is(err, errTLSNodeInvalid) {
+			return "", true, nil
+		}
+		observability.SetError(span, err)
+		return "", false, err
+	}
+
+	var invalid tlsNodeInvalidResponse
+	if err := json.Unmarshal(raw, &invalid); err != nil {
+		return "", false, fmt.Errorf("unmarshalling config: %w", err)
+	}
+	if invalid.NodeInvalid {
+		return "", true, nil
+	}
+
+	return string(raw), false, nil
+}
+
+// PublishLogs implements KolideService.PublishLogs.
+func (c *tlsClient) PublishLogs(ctx context.Context, nodeKey string, logType logger.LogType, logs []string) (string, string, bool, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	req := tlsLogRequest{
+		NodeKey: nodeKey,
+		LogType: tlsLogType(logType),
+		Data:    make([]json.RawMessage, 0, len(logs)),
+	}
+
+	// osquery sends each log line as a JSON object. Anything that isn't valid
+	// JSON is sent as a string, rather than failing the whole batch.
+	for _, log := range logs {
+		if json.Valid([]byte(log)) {
+			req.Data = append(req.Data, json.RawMessage(log))
+			continue
+		}
+
+		encoded, err := json.Marshal(log)
+		if err != nil {
+			return "", "", false, fmt.Errorf("encoding log: %w", err)
+		}
+		req.Data = append(req.Data, encoded)
+	}
+
+	return c.postWithNodeKey(ctx, tlsLogPath, req)
+}
+
+// RequestQueries implements KolideService.RequestQueries.
+func (c *tlsClient) RequestQueries(ctx context.Context, nodeKey string) (*distributed.GetQueriesResult, bool, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	var resp tlsDistributedReadResponse
+	if err := c.post(ctx, tlsDistributedReadPath, tlsNodeKeyRequest{NodeKey: nodeKey}, &resp); err != nil {
+		if errors.Is(err, errTLSNodeInvalid) {
+			return nil, true, nil
+		}
+		observability.SetError(span, err)
+		return nil, false, err
+	}
+
+	if resp.NodeInvalid {
+		return nil, true, nil
+	}
+
+	return &resp.GetQueriesResult, false, nil
+}
+
+// PublishResults implements KolideService.PublishResults.
+func (c *tlsClient) PublishResults(ctx context.Context, nodeKey string, results []distributed.Result) (string, string, bool, error) {
+	ctx, span := observability.StartSpan(ctx)
+	defer span.End()
+
+	req := tlsDistributedWriteRequest{
+		NodeKey:  nodeKey,
+		Queries:  make(map[string][]map[string]string, len(results)),
+		Statuses: make(map[string]int, len(results)),
+		Messages: make(map[string]string, len(results)),
+		Stats:    make(map[string]*distributed.Stats),
+	}
+
+	for _, result := range results {
+		rows := result.Rows
+		if rows == nil {
+			rows = []map[string]string{}
+		}
+		req.Queries[result.QueryName] = rows
+		req.Statuses[result.QueryName] = result.Status
+		if result.Message != "" {
+			req.Messages[result.QueryName] = result.Message
+		}
+		if result.QueryStats != nil {
+			req.Stats[result.QueryName] = result.QueryStats
+		}
+	}
+
+	return c.postWithNodeKey(ctx, tlsDistributedWritePath, req)
+}
+
+// CheckHealth implements KolideService.CheckHealth, returning 1 when the server's health endpoint reports OK.
+func (c *tlsClient) CheckHealth(ctx context.Context) (int32, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.serverURL.JoinPath(tlsHealthPath).String(), http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("checking health: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxTLSResponseSize))
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, nil
+	}
+
+	return 1, nil
+}
+
+var errTLSNodeInvalid = errors.New("node key invalid")
+
+// postWithNodeKey posts a request whose response only carries node validity, and
+// translates the result into the return values shared by PublishLogs and PublishResults.
+func (c *tlsClient) postWithNodeKey(ctx context.Context, path string, req any) (string, string, bool, error) {
+	var resp tlsNodeInvalidResponse
+	if err := c.post(ctx, path, req, &resp); err != nil {
+		if errors.Is(err, errTLSNodeInvalid) {
+			return "", "", true, nil
+		}
+		return "", "", false, err
+	}
+
+	if resp.NodeInvalid {
+		return "", "", true, nil
+	}
+
+	return "", "", false, nil
+}
+
+// post sends req as JSON to the given path, and decodes the response into resp.
+// Servers commonly reject an invalid node key with a 401, which is returned as
+// errTLSNodeInvalid.
+func (c *tlsClient) post(ctx context.Context, path string, req any, resp any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshalling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serverURL.JoinPath(path).String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("making request to %s: %w", path, err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxTLSResponseSize))
+	if err != nil {
+		return fmt.Errorf("reading response from %s: %w", path, err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		var invalid tlsNodeInvalidResponse
+		_ = json.Unmarshal(respBody, &invalid)
+
+		if httpResp.StatusCode == http.StatusUnauthorized || invalid.NodeInvalid {
+			return errTLSNodeInvalid
+		}
+
+		if invalid.Error != "" {
+			return fmt.Errorf("unexpected status %d from %s: %s", httpResp.StatusCode, path, invalid.Error)
+		}
+		return fmt.Errorf("unexpected status %d from %s", httpResp.StatusCode, path)
+	}
+
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return fmt.Errorf("unmarshalling response from %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// tlsLogType maps launcher's log types onto the two that osquery's TLS logger uses.
+func tlsLogType(logType logger.LogType) string {
+	switch logType {
+	case logger.LogTypeString, logger.LogTypeSnapshot:
+		return "result"
+	default:
+		return "status"
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kolide/launcher/ee/agent/types/mocks"
+	"github.com/kolide/launcher/pkg/log/multislogger"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOsqueryTLSServer is a minimal in-process implementation of osquery's TLS remote API.
+type fakeOsqueryTLSServer struct {
+	sync.Mutex
+	enrollSecret string
+	nodeKey      string
+	config       string
+	queries      map[string]string
+	logs         map[string][]json.RawMessage
+	written      map[string][]map[string]string
+	statuses     map[string]int
+	hostDetails  tlsHostDetails
+}
+
+func newFakeOsqueryTLSServer() *fakeOsqueryTLSServer {
+	return &fakeOsqueryTLSServer{
+		enrollSecret: "secret",
+		config:       `{"options":{"distributed_interval":60},"schedule":{}}`,
+		queries:      map[string]string{"q1": "select 1"},
+		logs:         make(map[string][]json.RawMessage),
+		written:      make(map[string][]map[string]string),
+		statuses:     make(map[string]int),
+	}
+}
+
+func (s *fakeOsqueryTLSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.URL.Path == tlsHealthPath {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		tlsEnrollRequest
+		tlsDistributedWriteRequest
+		LogType string            `json:"log_type"`
+		Data    []json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Path == tlsEnrollPath {
+		if req.EnrollSecret != s.enrollSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid enroll secret","node_invalid":true}`))
+			return
+		}
+		s.nodeKey = "node-" + req.HostIdentifier
+		s.hostDetails = req.HostDetails
+		json.NewEncoder(w).Encode(map[string]string{"node_key": s.nodeKey})
+		return
+	}
+
+	if s.nodeKey == "" || req.tlsDistributedWriteRequest.NodeKey != s.nodeKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid node key","node_invalid":true}`))
+		return
+	}
+
+	switch r.URL.Path {
+	case tlsConfigPath:
+		w.Write([]byte(s.config))
+	case tlsLogPath:
+		s.logs[req.LogType] = append(s.logs[req.LogType], req.Data...)
+		w.Write([]byte(`{}`))
+	case tlsDistributedReadPath:
+		json.NewEncoder(w).Encode(map[string]any{"queries": s.queries})
+	case tlsDistributedWritePath:
+		for name, rows := range req.Queries {
+			s.written[name] = rows
+			s.statuses[name] = req.Statuses[name]
+		}
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestTLSClient(t *testing.T) {
+	t.Parallel()
+
+	fakeServer := newFakeOsqueryTLSServer()
+	testServer := httptest.NewTLSServer(fakeServer)
+	t.Cleanup(testServer.Close)
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(testServer.Certificate())
+
+	mockKnapsack := mocks.NewKnapsack(t)
+	mockKnapsack.On("KolideServerURL").Return(u.Host)
+	mockKnapsack.On("InsecureTransportTLS").Return(false)
+	mockKnapsack.On("Transport").Return("tls")
+	mockKnapsack.On("InsecureTLS").Return(false)
+	mockKnapsack.On("CertPins").Return(nil)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	client := NewTLSClient(mockKnapsack, rootPool)
+
+	// Before enrollment, everything reports an invalid node key
+	_, invalid, err := client.RequestConfig(t.Context(), "unknown")
+	require.NoError(t, err)
+	require.True(t, invalid)
+
+	// Enrollment with the wrong secret is invalid
+	nodeKey, invalid, _, err := client.RequestEnrollment(t.Context(), "wrong", "host", EnrollmentDetails{})
+	require.NoError(t, err)
+	require.True(t, invalid)
+	require.Empty(t, nodeKey)
+
+	nodeKey, invalid, token, err := client.RequestEnrollment(t.Context(), "secret", "host", EnrollmentDetails{Hostname: "myhost", OSVersion: "14.1"})
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.Empty(t, token)
+	require.Equal(t, "node-host", nodeKey)
+	require.Equal(t, "myhost", fakeServer.hostDetails.SystemInfo["hostname"])
+	require.Equal(t, "14.1", fakeServer.hostDetails.OSVersion["version"])
+
+	config, invalid, err := client.RequestConfig(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.JSONEq(t, fakeServer.config, config)
+
+	_, _, invalid, err = client.PublishLogs(t.Context(), nodeKey, logger.LogTypeSnapshot, []string{`{"name":"pack_q","action":"snapshot"}`, "not json"})
+	require.NoError(t, err)
+	require.False(t, invalid)
+	_, _, invalid, err = client.PublishLogs(t.Context(), nodeKey, logger.LogTypeStatus, []string{`{"severity":"0"}`})
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.Len(t, fakeServer.logs["result"], 2)
+	require.JSONEq(t, `"not json"`, string(fakeServer.logs["result"][1]))
+	require.Len(t, fakeServer.logs["status"], 1)
+
+	queries, invalid, err := client.RequestQueries(t.Context(), nodeKey)
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.Equal(t, fakeServer.queries, queries.Queries)
+
+	_, _, invalid, err = client.PublishResults(t.Context(), nodeKey, []distributed.Result{
+		{QueryName: "q1", Status: 0, Rows: []map[string]string{{"1": "1"}}},
+		{QueryName: "q2", Status: 1, Message: "no such table"},
+	})
+	require.NoError(t, err)
+	require.False(t, invalid)
+	require.Equal(t, []map[string]string{{"1": "1"}}, fakeServer.written["q1"])
+	require.Equal(t, []map[string]string{}, fakeServer.written["q2"])
+	require.Equal(t, 1, fakeServer.statuses["q2"])
+
+	status, err := client.CheckHealth(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), status)
+
+	// A node key that the server no longer recognizes is surfaced as reauth, for every method
+	_, invalid, err = client.RequestQueries(t.Context(), "stale")
+	require.NoError(t, err)
+	require.True(t, invalid)
+	_, _, invalid, err = client.PublishLogs(t.Context(), "stale", logger.LogTypeString, nil)
+	require.NoError(t, err)
+	require.True(t, invalid)
+	_, _, invalid, err = client.PublishResults(t.Context(), "stale", nil)
+	require.NoError(t, err)
+	require.True(t, invalid)
+}
+
+func TestTLSClient_NodeInvalidInBody(t *testing.T) {
+	t.Parallel()
+
+	// Some servers report node_invalid with a 200 rather than a 401
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"node_invalid": true}`))
+	}))
+	t.Cleanup(testServer.Close)
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	mockKnapsack := mocks.NewKnapsack(t)
+	mockKnapsack.On("KolideServerURL").Return(u.Host)
+	mockKnapsack.On("InsecureTransportTLS").Return(true)
+	mockKnapsack.On("Slogger").Return(multislogger.NewNopLogger())
+
+	client := NewTLSClient(mockKnapsack, nil)
+
+	_, invalid, err := client.RequestConfig(t.Context(), "node_key")
+	require.NoError(t, err)
+	require.True(t, invalid)
+
+	_, invalid, err = client.RequestQueries(t.Context(), "node_key")
+	require.NoError(t, err)
+	require.True(t, invalid)
+
+	_, _, invalid, err = client.PublishLogs(t.Context(), "node_key", logger.LogTypeStatus, []string{"{}"})
+	require.NoError(t, err)
+	require.True(t, invalid)
+}